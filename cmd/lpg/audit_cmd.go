@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/soloengine/lpg/internal/audit"
+)
+
+const auditUsage = `usage: lpg audit <command> [flags]
+
+commands:
+  verify   check the hash chain, and checkpoint signatures with --public-key;
+           exits 1 at the first bad record
+  tail     print the last records; -f follows new ones
+  query    print records matching --request-id, --route, --category,
+           --outcome, --provider, --since
+  export   write verified records as --format jsonl or csv`
+
+var csvHeader = []string{"timestamp", "request_id", "policy_version", "risk_category", "route", "action_summary", "outcome", "http_status", "error_code", "provider", "retries", "latency_ms", "abstracted", "kind", "prev_hash", "entry_hash"}
+
+// runAudit implements the audit subcommands and returns the process exit
+// code: 0 on success, 1 when the chain fails verification or a command
+// fails, 2 on usage errors.
+func runAudit(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, auditUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("lpg audit "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configPath := fs.String("config", "", "path to a YAML configuration file, for audit.path")
+	path := fs.String("path", "", "audit log path; overrides --config and LPG_AUDIT_PATH")
+	var filter recordFilter
+	var follow bool
+	var lines int
+	var format, out, publicKey string
+	switch args[0] {
+	case "verify":
+		fs.StringVar(&publicKey, "public-key", "", "PEM Ed25519 public key to check checkpoint signatures against")
+	case "tail":
+		fs.BoolVar(&follow, "f", false, "follow records as they are appended")
+		fs.IntVar(&lines, "n", 10, "number of records to print first")
+	case "query":
+		filter.register(fs)
+	case "export":
+		filter.register(fs)
+		fs.StringVar(&format, "format", "jsonl", "jsonl or csv")
+		fs.StringVar(&out, "out", "", "output file (default stdout)")
+		fs.StringVar(&publicKey, "public-key", "", "PEM Ed25519 public key to check checkpoint signatures against")
+	default:
+		fmt.Fprintf(stderr, "unknown audit command %q\n%s\n", args[0], auditUsage)
+		return 2
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if err := filter.parseSince(time.Now()); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if format != "" && format != "jsonl" && format != "csv" {
+		fmt.Fprintf(stderr, "unsupported --format %q: must be jsonl or csv\n", format)
+		return 2
+	}
+
+	if *path == "" {
+		cfg, err := loadStartupConfig(*configPath)
+		if err != nil {
+			fmt.Fprintf(stderr, "invalid startup configuration: %v\n", err)
+			return 2
+		}
+		*path = cfg.AuditPath
+	}
+
+	var err error
+	switch args[0] {
+	case "verify":
+		var unsigned int
+		if unsigned, err = verifyAudit(*path, publicKey); err == nil {
+			fmt.Fprintf(stdout, "audit chain verified: %s\n", *path)
+			if publicKey != "" {
+				fmt.Fprintf(stdout, "checkpoint signatures verified; %d records after the last signed checkpoint\n", unsigned)
+			}
+		}
+	case "tail":
+		err = tailAudit(ctx, *path, lines, follow, stdout)
+	case "query":
+		err = writeRecords(*path, filter, "jsonl", stdout)
+	case "export":
+		err = exportAudit(*path, publicKey, filter, format, out, stdout)
+	}
+	if err != nil {
+		var verifyErr *audit.VerifyError
+		if errors.As(err, &verifyErr) {
+			fmt.Fprintf(stderr, "audit chain verification failed: %v\n", err)
+		} else {
+			fmt.Fprintln(stderr, err)
+		}
+		return 1
+	}
+	return 0
+}
+
+type recordFilter struct {
+	requestID string
+	route     string
+	category  string
+	outcome   string
+	provider  string
+	sinceFlag string
+	since     time.Time
+}
+
+func (f *recordFilter) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.requestID, "request-id", "", "only records of this request")
+	fs.StringVar(&f.route, "route", "", "only records with this route")
+	fs.StringVar(&f.category, "category", "", "only records with this risk category")
+	fs.StringVar(&f.outcome, "outcome", "", "only records with this outcome: success, blocked or error")
+	fs.StringVar(&f.provider, "provider", "", "only records whose last provider was this one")
+	fs.StringVar(&f.sinceFlag, "since", "", "only records from this RFC 3339 time, or this long ago (for example 24h)")
+}
+
+func (f *recordFilter) parseSince(now time.Time) error {
+	value := strings.TrimSpace(f.sinceFlag)
+	if value == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		f.since = now.Add(-d)
+		return nil
+	}
+	since, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return fmt.Errorf("invalid --since %q: want a duration or RFC 3339 time", value)
+	}
+	f.since = since
+	return nil
+}
+
+func (f recordFilter) match(record audit.Record) bool {
+	if f.requestID != "" && record.RequestID != f.requestID {
+		return false
+	}
+	if f.route != "" && record.Route != f.route {
+		return false
+	}
+	if f.category != "" && !strings.EqualFold(record.RiskCategory, f.category) {
+		return false
+	}
+	if f.outcome != "" && !strings.EqualFold(string(record.Outcome), f.outcome) {
+		return false
+	}
+	if f.provider != "" && record.Provider != f.provider {
+		return false
+	}
+	return f.since.IsZero() || !record.Timestamp.Before(f.since)
+}
+
+// verifyAudit checks the hash chain and, when publicKey names a key file,
+// the checkpoint signatures. It returns the number of records after the
+// last signed checkpoint, which only the hash chain vouches for.
+func verifyAudit(path, publicKey string) (int, error) {
+	if publicKey == "" {
+		return 0, audit.VerifyChain(path)
+	}
+	pub, err := audit.LoadPublicKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+	return audit.VerifyChainWithKey(path, pub)
+}
+
+// exportAudit refuses to export a chain that fails verification, per PRD
+// 8.5.
+func exportAudit(path, publicKey string, filter recordFilter, format, out string, stdout io.Writer) error {
+	if _, err := verifyAudit(path, publicKey); err != nil {
+		return fmt.Errorf("refusing to export: %w", err)
+	}
+	if out == "" {
+		return writeRecords(path, filter, format, stdout)
+	}
+
+	f, err := os.OpenFile(out, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := writeRecords(path, filter, format, f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeRecords(path string, filter recordFilter, format string, w io.Writer) error {
+	if format == "csv" {
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return err
+		}
+		if err := audit.ReadRecords(path, func(record audit.Record) error {
+			if !filter.match(record) {
+				return nil
+			}
+			return cw.Write([]string{
+				record.Timestamp.Format(time.RFC3339Nano),
+				record.RequestID,
+				record.PolicyVersion,
+				record.RiskCategory,
+				record.Route,
+				record.ActionSummary,
+				string(record.Outcome),
+				optionalInt(int64(record.HTTPStatus)),
+				record.ErrorCode,
+				record.Provider,
+				optionalInt(int64(record.Retries)),
+				optionalInt(record.LatencyMS),
+				strconv.FormatBool(record.Abstracted),
+				record.Kind,
+				record.PrevHash,
+				record.EntryHash,
+			})
+		}); err != nil {
+			return err
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	enc := json.NewEncoder(w)
+	return audit.ReadRecords(path, func(record audit.Record) error {
+		if !filter.match(record) {
+			return nil
+		}
+		return enc.Encode(record)
+	})
+}
+
+// optionalInt writes zero as an empty cell, matching the record, which
+// omits zero counts and statuses.
+func optionalInt(n int64) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func tailAudit(ctx context.Context, path string, lines int, follow bool, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	if lines > 0 {
+		last := make([]audit.Record, 0, lines)
+		if err := audit.ReadRecords(path, func(record audit.Record) error {
+			if len(last) == lines {
+				last = append(last[:0], last[1:]...)
+			}
+			last = append(last, record)
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, record := range last {
+			if err := enc.Encode(record); err != nil {
+				return err
+			}
+		}
+	}
+	if !follow {
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	return audit.Follow(ctx, path, 500*time.Millisecond, func(record audit.Record) error {
+		return enc.Encode(record)
+	})
+}
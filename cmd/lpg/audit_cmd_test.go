@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/csv"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/soloengine/lpg/internal/audit"
+)
+
+func writeAuditLog(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	cw, err := audit.NewChainWriter(path)
+	if err != nil {
+		t.Fatalf("NewChainWriter failed: %v", err)
+	}
+	for _, event := range []audit.Event{
+		{RequestID: "req-1", PolicyVersion: "v2.1", ActionSummary: "route=sanitized_forward", RiskCategory: "Medium", Route: "sanitized_forward"},
+		{RequestID: "req-2", PolicyVersion: "v2.1", ActionSummary: "route=high_abstraction", RiskCategory: "High", Route: "high_abstraction", Outcome: audit.OutcomeError, HTTPStatus: 502, ErrorCode: "ERR_PROVIDER_FAILURE", Provider: "remote"},
+		{RequestID: "req-3", PolicyVersion: "v2.1", ActionSummary: "route=sanitized_forward", RiskCategory: "Medium", Route: "sanitized_forward"},
+	} {
+		if _, err := cw.Append(event); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	return path
+}
+
+func tamperAuditLog(t *testing.T, path string) {
+	t.Helper()
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read audit log failed: %v", err)
+	}
+	modified := strings.Replace(string(contents), `"request_id":"req-2"`, `"request_id":"req-x"`, 1)
+	if err := os.WriteFile(path, []byte(modified), 0o600); err != nil {
+		t.Fatalf("write audit log failed: %v", err)
+	}
+}
+
+func runAuditForTest(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := runAudit(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestAuditVerifyReportsFirstBadLine(t *testing.T) {
+	path := writeAuditLog(t)
+	if code, stdout, stderr := runAuditForTest("verify", "--path", path); code != 0 || !strings.Contains(stdout, "audit chain verified") {
+		t.Fatalf("expected an untampered log to verify, got %d %q %q", code, stdout, stderr)
+	}
+
+	tamperAuditLog(t, path)
+	code, _, stderr := runAuditForTest("verify", "--path", path)
+	if code != 1 || !strings.Contains(stderr, "invalid entry_hash at audit.log line 2") {
+		t.Fatalf("expected exit 1 naming line 2, got %d %q", code, stderr)
+	}
+}
+
+func TestAuditQueryFilters(t *testing.T) {
+	path := writeAuditLog(t)
+
+	_, stdout, _ := runAuditForTest("query", "--path", path, "--route", "sanitized_forward", "--category", "medium")
+	if got := strings.Count(stdout, "\n"); got != 2 || strings.Contains(stdout, "req-2") {
+		t.Fatalf("expected the two sanitized_forward records, got %q", stdout)
+	}
+	_, stdout, _ = runAuditForTest("query", "--path", path, "--request-id", "req-2", "--since", "1h")
+	if got := strings.Count(stdout, "\n"); got != 1 || !strings.Contains(stdout, `"request_id":"req-2"`) {
+		t.Fatalf("expected only req-2, got %q", stdout)
+	}
+	_, stdout, _ = runAuditForTest("query", "--path", path, "--outcome", "error", "--provider", "remote")
+	if got := strings.Count(stdout, "\n"); got != 1 || !strings.Contains(stdout, `"error_code":"ERR_PROVIDER_FAILURE"`) {
+		t.Fatalf("expected only the failed req-2, got %q", stdout)
+	}
+	if code, _, _ := runAuditForTest("query", "--path", path, "--since", "yesterday"); code != 2 {
+		t.Fatalf("expected a usage error for an invalid --since, got %d", code)
+	}
+}
+
+func TestAuditExportWritesCSV(t *testing.T) {
+	path := writeAuditLog(t)
+	out := filepath.Join(t.TempDir(), "export.csv")
+
+	if code, _, stderr := runAuditForTest("export", "--path", path, "--format", "csv", "--out", out); code != 0 {
+		t.Fatalf("export failed: %d %q", code, stderr)
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatalf("open export failed: %v", err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("parse export failed: %v", err)
+	}
+	if len(rows) != 4 || rows[0][0] != "timestamp" || rows[2][1] != "req-2" {
+		t.Fatalf("unexpected export rows %v", rows)
+	}
+	if rows[2][6] != "error" || rows[2][7] != "502" || rows[1][7] != "" {
+		t.Fatalf("unexpected structured columns %v", rows[1:3])
+	}
+}
+
+func TestAuditExportRefusesTamperedChain(t *testing.T) {
+	path := writeAuditLog(t)
+	tamperAuditLog(t, path)
+
+	code, stdout, stderr := runAuditForTest("export", "--path", path, "--format", "jsonl")
+	if code != 1 || stdout != "" || !strings.Contains(stderr, "refusing to export") {
+		t.Fatalf("expected export to refuse a tampered chain, got %d %q %q", code, stdout, stderr)
+	}
+}
+
+func TestAuditTailPrintsLastRecords(t *testing.T) {
+	path := writeAuditLog(t)
+
+	_, stdout, _ := runAuditForTest("tail", "--path", path, "-n", "2")
+	if got := strings.Count(stdout, "\n"); got != 2 || strings.Contains(stdout, "req-1") {
+		t.Fatalf("expected the last two records, got %q", stdout)
+	}
+	if code, _, _ := runAuditForTest("rewrite"); code != 2 {
+		t.Fatalf("expected a usage error for an unknown command, got %d", code)
+	}
+}
+
+func TestAuditVerifyChecksCheckpointSignatures(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey failed: %v", err)
+	}
+	dir := t.TempDir()
+	pubPath := filepath.Join(dir, "audit.pub")
+	if err := os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write public key failed: %v", err)
+	}
+
+	path := filepath.Join(dir, "audit.log")
+	cw, err := audit.NewChainWriterWithOptions(path, audit.Options{SigningKey: priv, CheckpointEvery: 2})
+	if err != nil {
+		t.Fatalf("NewChainWriterWithOptions failed: %v", err)
+	}
+	for _, id := range []string{"req-1", "req-2", "req-3"} {
+		if _, err := cw.Append(audit.Event{RequestID: id, PolicyVersion: "v2.1", ActionSummary: "route=sanitized_forward"}); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	code, stdout, stderr := runAuditForTest("verify", "--path", path, "--public-key", pubPath)
+	if code != 0 || !strings.Contains(stdout, "1 records after the last signed checkpoint") {
+		t.Fatalf("expected signed checkpoints to verify, got %d %q %q", code, stdout, stderr)
+	}
+
+	unsigned := writeAuditLog(t)
+	code, _, stderr = runAuditForTest("export", "--path", unsigned, "--public-key", pubPath)
+	if code != 1 || !strings.Contains(stderr, "no signed checkpoint") {
+		t.Fatalf("expected export to refuse an unsigned chain, got %d %q", code, stderr)
+	}
+}
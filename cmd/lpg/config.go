@@ -3,22 +3,58 @@ package main
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/soloengine/lpg/internal/audit"
+	"github.com/soloengine/lpg/internal/proxy"
+	"github.com/soloengine/lpg/internal/risk"
+	"github.com/soloengine/lpg/internal/router"
+	"github.com/soloengine/lpg/internal/sanitizer"
 )
 
 const (
 	defaultAuditPath            = "./audit.log"
+	defaultAuditSegmentBytes    = 64 << 20
+	defaultAuditSegmentAge      = 24 * time.Hour
+	defaultAuditRetention       = 30 * 24 * time.Hour
+	defaultAuditCheckpointEvery = 1000
+	defaultAuditCheckpointAfter = time.Hour
+	defaultAuditSyncInterval    = time.Second
 	defaultProviderTimeout      = 2 * time.Second
+	defaultStreamIdleTimeout    = 30 * time.Second
+	defaultRetryMaxAttempts     = 2
+	defaultRetryBaseDelay       = 200 * time.Millisecond
+	defaultRetryMaxDelay        = 2 * time.Second
+	defaultRetryBudget          = 10 * time.Second
+	defaultBreakerFailureRate   = 0.5
+	defaultBreakerMinRequests   = 5
+	defaultBreakerWindow        = 20
+	defaultBreakerOpenDuration  = 30 * time.Second
+	defaultBreakerProbeInterval = 5 * time.Second
+	defaultIdempotencyTTL       = 24 * time.Hour
+	defaultIdempotencyEntries   = 1024
 	defaultMimoModel            = "mimo-v2-flash"
 	defaultUpstreamAPIKeyHeader = "Authorization"
 	defaultUpstreamAPIKeyPrefix = "Bearer"
 	defaultUpstreamChatPath     = "/v1/chat/completions"
+	defaultEmbeddingsPath       = "/v1/embeddings"
+	defaultUpstreamModelsPath   = "/v1/models"
 
 	defaultLocalAbstractionAPIKeyHeader = "Authorization"
 	defaultLocalAbstractionAPIKeyPrefix = "Bearer"
 	defaultLocalAbstractionChatPath     = "/v1/chat/completions"
+
+	defaultLocalEmbeddingsAPIKeyHeader = "Authorization"
+	defaultLocalEmbeddingsAPIKeyPrefix = "Bearer"
+
+	defaultConfidenceThreshold = 0.70
+	defaultPolicyVersion       = "v2.1-phase1"
+
+	failureModeFailClosed = "fail_closed"
+	auditModeRedacted     = "audit_redacted"
 )
 
 type providerMode string
@@ -30,13 +66,86 @@ const (
 	providerOpenAICompatible providerMode = "openai_compatible"
 )
 
+// providerChainEntry constrains one provider of the fallback chain. Empty
+// Routes or Categories mean "any"; endpoint settings come from the per-mode
+// provider sections, so each mode appears at most once.
+type providerChainEntry struct {
+	Mode       providerMode
+	Local      bool
+	Routes     []router.Route
+	Categories []risk.Category
+}
+
 type startupConfig struct {
 	AuditPath       string
+	AuditMode       string
+	StrictAudit     bool
 	Provider        providerMode
 	ProviderTimeout time.Duration
+	// AuditMaxSegmentBytes and AuditMaxSegmentAge seal the active audit
+	// segment (0 disables each); AuditRetention deletes sealed segments
+	// older than it (0 keeps them forever).
+	AuditMaxSegmentBytes int64
+	AuditMaxSegmentAge   time.Duration
+	AuditRetention       time.Duration
+	// AuditSigningKeyPath names a PEM Ed25519 private key. When set, a
+	// signed checkpoint is written every AuditCheckpointEvery records or
+	// AuditCheckpointInterval, and before each segment is sealed.
+	AuditSigningKeyPath     string
+	AuditCheckpointEvery    int
+	AuditCheckpointInterval time.Duration
+	// AuditSync is record, group or interval; see audit.SyncMode.
+	// AuditSyncInterval only applies to interval.
+	AuditSync         string
+	AuditSyncInterval time.Duration
+	// AuditEntityHashKeyPath names a file holding the HMAC key for mapped
+	// entity values in audit records. Unset, records only count them.
+	AuditEntityHashKeyPath string
+	// Retry* shape retries of Low/Medium requests that carry an
+	// Idempotency-Key. ProviderTimeout bounds each attempt; RetryBudget
+	// bounds the whole request, failover included (0 disables the cap).
+	RetryMaxAttempts int
+	RetryBaseDelay   time.Duration
+	RetryMaxDelay    time.Duration
+	RetryBudget      time.Duration
+	// StreamIdleTimeout bounds the gap between chunks once a stream has
+	// started; until then ProviderTimeout and RetryBudget apply.
+	StreamIdleTimeout time.Duration
+	// Breaker* configure the per-provider circuit breaker. It opens once
+	// BreakerFailureRate of the last BreakerWindow requests failed, counting
+	// from BreakerMinRequests; BreakerProbeInterval 0 disables health probes.
+	BreakerEnabled       bool
+	BreakerFailureRate   float64
+	BreakerMinRequests   int
+	BreakerWindow        int
+	BreakerOpenDuration  time.Duration
+	BreakerProbeInterval time.Duration
+	// Idempotency* bound the Idempotency-Key response cache;
+	// IdempotencyMaxEntries 0 disables it.
+	IdempotencyTTL        time.Duration
+	IdempotencyMaxEntries int
+	// ProviderChain is the ordered fallback chain. When empty, Provider is
+	// the only provider and serves every forwarding route.
+	ProviderChain []providerChainEntry
 
 	AllowRawForwarding bool
 	CriticalLocalOnly  bool
+	FailureMode        string
+	RehydrateRoutes    []router.Route
+	RehydrateTools     []string
+	ModelAllowlist     []string
+	ImagePolicies      proxy.ImagePolicies
+	EntityActions      router.EntityPolicies
+	// UserPseudonymKeyPath names a file holding the HMAC key for the
+	// pseudonym that replaces the request's user field. Unset, a random key
+	// is generated at startup.
+	UserPseudonymKeyPath string
+
+	ConfidenceThreshold float64
+	PolicyVersion       string
+	RiskModel           risk.Model
+	SanitizerEntities   []string
+	CustomRules         []sanitizer.Rule
 
 	VLLMBaseURL string
 	VLLMModel   string
@@ -51,6 +160,11 @@ type startupConfig struct {
 	UpstreamAPIKeyHeader string
 	UpstreamAPIKeyPrefix string
 	UpstreamChatPath     string
+	// UpstreamResponsesPath enables native /v1/responses forwarding for
+	// openai_compatible; empty translates Responses to chat completions.
+	UpstreamResponsesPath  string
+	UpstreamEmbeddingsPath string
+	UpstreamModelsPath     string
 
 	LocalAbstractionBaseURL      string
 	LocalAbstractionAPIKey       string
@@ -58,49 +172,219 @@ type startupConfig struct {
 	LocalAbstractionAPIKeyHeader string
 	LocalAbstractionAPIKeyPrefix string
 	LocalAbstractionChatPath     string
+
+	// LocalEmbeddings* configure the local embedding model that serves
+	// /v1/embeddings inputs scored high or critical.
+	LocalEmbeddingsBaseURL      string
+	LocalEmbeddingsAPIKey       string
+	LocalEmbeddingsModel        string
+	LocalEmbeddingsAPIKeyHeader string
+	LocalEmbeddingsAPIKeyPrefix string
+	LocalEmbeddingsPath         string
 }
 
-func loadStartupConfigFromEnv() (startupConfig, error) {
-	cfg := startupConfig{
+func defaultStartupConfig() startupConfig {
+	return startupConfig{
 		AuditPath:                    defaultAuditPath,
+		AuditMode:                    auditModeRedacted,
+		AuditMaxSegmentBytes:         defaultAuditSegmentBytes,
+		AuditMaxSegmentAge:           defaultAuditSegmentAge,
+		AuditRetention:               defaultAuditRetention,
+		AuditCheckpointEvery:         defaultAuditCheckpointEvery,
+		AuditCheckpointInterval:      defaultAuditCheckpointAfter,
+		AuditSync:                    string(audit.SyncGroupCommit),
+		AuditSyncInterval:            defaultAuditSyncInterval,
 		Provider:                     providerStub,
 		ProviderTimeout:              defaultProviderTimeout,
+		RetryMaxAttempts:             defaultRetryMaxAttempts,
+		RetryBaseDelay:               defaultRetryBaseDelay,
+		RetryMaxDelay:                defaultRetryMaxDelay,
+		RetryBudget:                  defaultRetryBudget,
+		StreamIdleTimeout:            defaultStreamIdleTimeout,
+		BreakerEnabled:               true,
+		BreakerFailureRate:           defaultBreakerFailureRate,
+		BreakerMinRequests:           defaultBreakerMinRequests,
+		BreakerWindow:                defaultBreakerWindow,
+		BreakerOpenDuration:          defaultBreakerOpenDuration,
+		BreakerProbeInterval:         defaultBreakerProbeInterval,
+		IdempotencyTTL:               defaultIdempotencyTTL,
+		IdempotencyMaxEntries:        defaultIdempotencyEntries,
+		FailureMode:                  failureModeFailClosed,
+		RehydrateRoutes:              []router.Route{router.RouteSanitizedForward},
+		ConfidenceThreshold:          defaultConfidenceThreshold,
+		PolicyVersion:                defaultPolicyVersion,
+		RiskModel:                    risk.DefaultModel(),
+		SanitizerEntities:            sanitizer.BuiltinEntityTypes(),
 		MimoModel:                    defaultMimoModel,
 		UpstreamAPIKeyHeader:         defaultUpstreamAPIKeyHeader,
 		UpstreamAPIKeyPrefix:         defaultUpstreamAPIKeyPrefix,
 		UpstreamChatPath:             defaultUpstreamChatPath,
+		UpstreamEmbeddingsPath:       defaultEmbeddingsPath,
+		UpstreamModelsPath:           defaultUpstreamModelsPath,
 		LocalAbstractionAPIKeyHeader: defaultLocalAbstractionAPIKeyHeader,
 		LocalAbstractionAPIKeyPrefix: defaultLocalAbstractionAPIKeyPrefix,
 		LocalAbstractionChatPath:     defaultLocalAbstractionChatPath,
+		LocalEmbeddingsAPIKeyHeader:  defaultLocalEmbeddingsAPIKeyHeader,
+		LocalEmbeddingsAPIKeyPrefix:  defaultLocalEmbeddingsAPIKeyPrefix,
+		LocalEmbeddingsPath:          defaultEmbeddingsPath,
 	}
+}
+
+func loadStartupConfigFromEnv() (startupConfig, error) {
+	return loadStartupConfig("")
+}
+
+func loadStartupConfig(path string) (startupConfig, error) {
+	cfg := defaultStartupConfig()
 
+	if path != "" {
+		if err := applyConfigFile(&cfg, path); err != nil {
+			return startupConfig{}, err
+		}
+	}
+	if err := applyEnvOverrides(&cfg); err != nil {
+		return startupConfig{}, err
+	}
+	if err := validateStartupConfig(cfg); err != nil {
+		return startupConfig{}, err
+	}
+	return cfg, nil
+}
+
+func applyEnvOverrides(cfg *startupConfig) error {
 	if value := strings.TrimSpace(os.Getenv("LPG_AUDIT_PATH")); value != "" {
 		cfg.AuditPath = value
 	}
 
+	if value := strings.TrimSpace(os.Getenv("LPG_AUDIT_MAX_SEGMENT_BYTES")); value != "" {
+		maxBytes, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return configErrorf("LPG_AUDIT_MAX_SEGMENT_BYTES", "%v", err)
+		}
+		cfg.AuditMaxSegmentBytes = maxBytes
+	}
+
+	if value := strings.TrimSpace(os.Getenv("LPG_AUDIT_SIGNING_KEY")); value != "" {
+		cfg.AuditSigningKeyPath = value
+	}
+	if value := strings.TrimSpace(os.Getenv("LPG_AUDIT_CHECKPOINT_EVERY")); value != "" {
+		every, err := strconv.Atoi(value)
+		if err != nil {
+			return configErrorf("LPG_AUDIT_CHECKPOINT_EVERY", "%v", err)
+		}
+		cfg.AuditCheckpointEvery = every
+	}
+	if value := strings.TrimSpace(os.Getenv("LPG_AUDIT_ENTITY_HASH_KEY")); value != "" {
+		cfg.AuditEntityHashKeyPath = value
+	}
+	if value := strings.TrimSpace(os.Getenv("LPG_AUDIT_SYNC")); value != "" {
+		cfg.AuditSync = value
+	}
+
+	if value, ok := envValue("LPG_STRICT_AUDIT"); ok && value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return configErrorf("LPG_STRICT_AUDIT", "%v", err)
+		}
+		cfg.StrictAudit = parsed
+	}
+
 	if value := strings.TrimSpace(os.Getenv("LPG_PROVIDER")); value != "" {
 		provider, err := parseProviderMode(value)
 		if err != nil {
-			return startupConfig{}, err
+			return configErrorf("LPG_PROVIDER", "%v", err)
 		}
 		cfg.Provider = provider
 	}
 
+	if value := strings.TrimSpace(os.Getenv("LPG_PROVIDER_CHAIN")); value != "" {
+		chain, err := parseProviderChain(value)
+		if err != nil {
+			return configErrorf("LPG_PROVIDER_CHAIN", "%v", err)
+		}
+		cfg.ProviderChain = chain
+	}
+
 	if value := strings.TrimSpace(os.Getenv("LPG_PROVIDER_TIMEOUT")); value != "" {
 		timeout, err := time.ParseDuration(value)
 		if err != nil {
-			return startupConfig{}, fmt.Errorf("invalid LPG_PROVIDER_TIMEOUT: %w", err)
-		}
-		if timeout <= 0 {
-			return startupConfig{}, fmt.Errorf("invalid LPG_PROVIDER_TIMEOUT: must be > 0")
+			return configErrorf("LPG_PROVIDER_TIMEOUT", "%v", err)
 		}
 		cfg.ProviderTimeout = timeout
 	}
 
+	if value := strings.TrimSpace(os.Getenv("LPG_RETRY_MAX_ATTEMPTS")); value != "" {
+		attempts, err := strconv.Atoi(value)
+		if err != nil {
+			return configErrorf("LPG_RETRY_MAX_ATTEMPTS", "%v", err)
+		}
+		cfg.RetryMaxAttempts = attempts
+	}
+	if value, ok := envValue("LPG_CIRCUIT_BREAKER_ENABLED"); ok && value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return configErrorf("LPG_CIRCUIT_BREAKER_ENABLED", "%v", err)
+		}
+		cfg.BreakerEnabled = parsed
+	}
+	if value := strings.TrimSpace(os.Getenv("LPG_CIRCUIT_BREAKER_FAILURE_RATE")); value != "" {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return configErrorf("LPG_CIRCUIT_BREAKER_FAILURE_RATE", "%v", err)
+		}
+		cfg.BreakerFailureRate = rate
+	}
+	for _, env := range []struct {
+		key    string
+		target *int
+	}{
+		{"LPG_CIRCUIT_BREAKER_MIN_REQUESTS", &cfg.BreakerMinRequests},
+		{"LPG_CIRCUIT_BREAKER_WINDOW", &cfg.BreakerWindow},
+	} {
+		if value := strings.TrimSpace(os.Getenv(env.key)); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return configErrorf(env.key, "%v", err)
+			}
+			*env.target = parsed
+		}
+	}
+	if value := strings.TrimSpace(os.Getenv("LPG_IDEMPOTENCY_MAX_ENTRIES")); value != "" {
+		entries, err := strconv.Atoi(value)
+		if err != nil {
+			return configErrorf("LPG_IDEMPOTENCY_MAX_ENTRIES", "%v", err)
+		}
+		cfg.IdempotencyMaxEntries = entries
+	}
+	for _, env := range []struct {
+		key    string
+		target *time.Duration
+	}{
+		{"LPG_RETRY_BASE_DELAY", &cfg.RetryBaseDelay},
+		{"LPG_RETRY_MAX_DELAY", &cfg.RetryMaxDelay},
+		{"LPG_RETRY_BUDGET", &cfg.RetryBudget},
+		{"LPG_STREAM_IDLE_TIMEOUT", &cfg.StreamIdleTimeout},
+		{"LPG_CIRCUIT_BREAKER_OPEN_DURATION", &cfg.BreakerOpenDuration},
+		{"LPG_CIRCUIT_BREAKER_PROBE_INTERVAL", &cfg.BreakerProbeInterval},
+		{"LPG_IDEMPOTENCY_TTL", &cfg.IdempotencyTTL},
+		{"LPG_AUDIT_MAX_SEGMENT_AGE", &cfg.AuditMaxSegmentAge},
+		{"LPG_AUDIT_RETENTION", &cfg.AuditRetention},
+		{"LPG_AUDIT_CHECKPOINT_INTERVAL", &cfg.AuditCheckpointInterval},
+		{"LPG_AUDIT_SYNC_INTERVAL", &cfg.AuditSyncInterval},
+	} {
+		if value := strings.TrimSpace(os.Getenv(env.key)); value != "" {
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				return configErrorf(env.key, "%v", err)
+			}
+			*env.target = duration
+		}
+	}
+
 	if value, ok := envValue("LPG_ALLOW_RAW_FORWARDING"); ok {
 		parsed, err := strconv.ParseBool(value)
 		if err != nil {
-			return startupConfig{}, fmt.Errorf("invalid LPG_ALLOW_RAW_FORWARDING: %w", err)
+			return configErrorf("LPG_ALLOW_RAW_FORWARDING", "%v", err)
 		}
 		cfg.AllowRawForwarding = parsed
 	}
@@ -108,23 +392,69 @@ func loadStartupConfigFromEnv() (startupConfig, error) {
 	if value, ok := envValue("LPG_CRITICAL_LOCAL_ONLY"); ok {
 		parsed, err := strconv.ParseBool(value)
 		if err != nil {
-			return startupConfig{}, fmt.Errorf("invalid LPG_CRITICAL_LOCAL_ONLY: %w", err)
+			return configErrorf("LPG_CRITICAL_LOCAL_ONLY", "%v", err)
 		}
 		cfg.CriticalLocalOnly = parsed
 	}
 
-	cfg.VLLMBaseURL = strings.TrimSpace(os.Getenv("LPG_VLLM_BASE_URL"))
-	cfg.VLLMModel = strings.TrimSpace(os.Getenv("LPG_VLLM_MODEL"))
+	if value := strings.TrimSpace(os.Getenv("LPG_REHYDRATE_ROUTES")); value != "" {
+		routes, err := parseRehydrateRoutes(strings.Split(value, ","))
+		if err != nil {
+			return configErrorf("LPG_REHYDRATE_ROUTES", "%v", err)
+		}
+		cfg.RehydrateRoutes = routes
+	}
 
-	cfg.MimoBaseURL = strings.TrimSpace(os.Getenv("LPG_MIMO_BASE_URL"))
-	cfg.MimoAPIKey = strings.TrimSpace(os.Getenv("LPG_MIMO_API_KEY"))
-	if value := strings.TrimSpace(os.Getenv("LPG_MIMO_MODEL")); value != "" {
-		cfg.MimoModel = value
+	if value := strings.TrimSpace(os.Getenv("LPG_REHYDRATE_TOOLS")); value != "" {
+		cfg.RehydrateTools = parseNames(strings.Split(value, ","))
+	}
+
+	if value := strings.TrimSpace(os.Getenv("LPG_MODEL_ALLOWLIST")); value != "" {
+		cfg.ModelAllowlist = parseNames(strings.Split(value, ","))
+	}
+
+	if value := strings.TrimSpace(os.Getenv("LPG_USER_PSEUDONYM_KEY")); value != "" {
+		cfg.UserPseudonymKeyPath = value
+	}
+
+	if value := strings.TrimSpace(os.Getenv("LPG_IMAGE_POLICY")); value != "" {
+		policies, err := parseImagePolicies(value)
+		if err != nil {
+			return configErrorf("LPG_IMAGE_POLICY", "%v", err)
+		}
+		cfg.ImagePolicies = policies
+	}
+
+	if value := strings.TrimSpace(os.Getenv("LPG_ENTITY_ACTIONS")); value != "" {
+		actions, err := parseEntityActions(value)
+		if err != nil {
+			return configErrorf("LPG_ENTITY_ACTIONS", "%v", err)
+		}
+		cfg.EntityActions = actions
+	}
+
+	if value := strings.TrimSpace(os.Getenv("LPG_CONFIDENCE_THRESHOLD")); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return configErrorf("LPG_CONFIDENCE_THRESHOLD", "%v", err)
+		}
+		cfg.ConfidenceThreshold = parsed
 	}
 
-	cfg.UpstreamBaseURL = strings.TrimSpace(os.Getenv("LPG_UPSTREAM_BASE_URL"))
-	cfg.UpstreamAPIKey = strings.TrimSpace(os.Getenv("LPG_UPSTREAM_API_KEY"))
-	cfg.UpstreamModel = strings.TrimSpace(os.Getenv("LPG_UPSTREAM_MODEL"))
+	if value := strings.TrimSpace(os.Getenv("LPG_POLICY_VERSION")); value != "" {
+		cfg.PolicyVersion = value
+	}
+
+	overrideString(&cfg.VLLMBaseURL, "LPG_VLLM_BASE_URL")
+	overrideString(&cfg.VLLMModel, "LPG_VLLM_MODEL")
+
+	overrideString(&cfg.MimoBaseURL, "LPG_MIMO_BASE_URL")
+	overrideString(&cfg.MimoAPIKey, "LPG_MIMO_API_KEY")
+	overrideString(&cfg.MimoModel, "LPG_MIMO_MODEL")
+
+	overrideString(&cfg.UpstreamBaseURL, "LPG_UPSTREAM_BASE_URL")
+	overrideString(&cfg.UpstreamAPIKey, "LPG_UPSTREAM_API_KEY")
+	overrideString(&cfg.UpstreamModel, "LPG_UPSTREAM_MODEL")
 	if value, ok := envValue("LPG_UPSTREAM_API_KEY_HEADER"); ok {
 		cfg.UpstreamAPIKeyHeader = value
 	}
@@ -134,10 +464,17 @@ func loadStartupConfigFromEnv() (startupConfig, error) {
 	if value, ok := envValue("LPG_UPSTREAM_CHAT_PATH"); ok {
 		cfg.UpstreamChatPath = value
 	}
+	overrideString(&cfg.UpstreamResponsesPath, "LPG_UPSTREAM_RESPONSES_PATH")
+	if value, ok := envValue("LPG_UPSTREAM_EMBEDDINGS_PATH"); ok {
+		cfg.UpstreamEmbeddingsPath = value
+	}
+	if value, ok := envValue("LPG_UPSTREAM_MODELS_PATH"); ok {
+		cfg.UpstreamModelsPath = value
+	}
 
-	cfg.LocalAbstractionBaseURL = strings.TrimSpace(os.Getenv("LPG_LOCAL_ABSTRACTION_BASE_URL"))
-	cfg.LocalAbstractionAPIKey = strings.TrimSpace(os.Getenv("LPG_LOCAL_ABSTRACTION_API_KEY"))
-	cfg.LocalAbstractionModel = strings.TrimSpace(os.Getenv("LPG_LOCAL_ABSTRACTION_MODEL"))
+	overrideString(&cfg.LocalAbstractionBaseURL, "LPG_LOCAL_ABSTRACTION_BASE_URL")
+	overrideString(&cfg.LocalAbstractionAPIKey, "LPG_LOCAL_ABSTRACTION_API_KEY")
+	overrideString(&cfg.LocalAbstractionModel, "LPG_LOCAL_ABSTRACTION_MODEL")
 	if value, ok := envValue("LPG_LOCAL_ABSTRACTION_API_KEY_HEADER"); ok {
 		cfg.LocalAbstractionAPIKeyHeader = value
 	}
@@ -148,34 +485,248 @@ func loadStartupConfigFromEnv() (startupConfig, error) {
 		cfg.LocalAbstractionChatPath = value
 	}
 
-	switch cfg.Provider {
+	overrideString(&cfg.LocalEmbeddingsBaseURL, "LPG_LOCAL_EMBEDDINGS_BASE_URL")
+	overrideString(&cfg.LocalEmbeddingsAPIKey, "LPG_LOCAL_EMBEDDINGS_API_KEY")
+	overrideString(&cfg.LocalEmbeddingsModel, "LPG_LOCAL_EMBEDDINGS_MODEL")
+	if value, ok := envValue("LPG_LOCAL_EMBEDDINGS_API_KEY_HEADER"); ok {
+		cfg.LocalEmbeddingsAPIKeyHeader = value
+	}
+	if value, ok := envValue("LPG_LOCAL_EMBEDDINGS_API_KEY_PREFIX"); ok {
+		cfg.LocalEmbeddingsAPIKeyPrefix = value
+	}
+	if value, ok := envValue("LPG_LOCAL_EMBEDDINGS_PATH"); ok {
+		cfg.LocalEmbeddingsPath = value
+	}
+
+	return nil
+}
+
+func validateStartupConfig(cfg startupConfig) error {
+	if strings.TrimSpace(cfg.AuditPath) == "" {
+		return configErrorf("audit.path", "must not be empty")
+	}
+	if cfg.AuditMode != auditModeRedacted {
+		return configErrorf("audit.mode", "unsupported value %q: must be %q", cfg.AuditMode, auditModeRedacted)
+	}
+	if cfg.AuditMaxSegmentBytes < 0 {
+		return configErrorf("audit.max_segment_bytes", "must be >= 0")
+	}
+	if cfg.AuditMaxSegmentAge < 0 {
+		return configErrorf("audit.max_segment_age", "must be >= 0")
+	}
+	if cfg.AuditRetention < 0 {
+		return configErrorf("audit.retention", "must be >= 0")
+	}
+	if cfg.AuditRetention > 0 && cfg.AuditMaxSegmentBytes == 0 && cfg.AuditMaxSegmentAge == 0 {
+		return configErrorf("audit.retention", "requires audit.max_segment_bytes or audit.max_segment_age")
+	}
+	if cfg.AuditCheckpointEvery < 0 {
+		return configErrorf("audit.checkpoint_every", "must be >= 0")
+	}
+	if cfg.AuditCheckpointInterval < 0 {
+		return configErrorf("audit.checkpoint_interval", "must be >= 0")
+	}
+	switch audit.SyncMode(cfg.AuditSync) {
+	case audit.SyncPerRecord, audit.SyncGroupCommit:
+	case audit.SyncPeriodic:
+		if cfg.AuditSyncInterval <= 0 {
+			return configErrorf("audit.sync_interval", "must be > 0 when audit.sync is interval")
+		}
+	default:
+		return configErrorf("audit.sync", "must be one of record, group, interval")
+	}
+	if cfg.ProviderTimeout <= 0 {
+		return configErrorf("provider.timeout", "must be > 0")
+	}
+	if cfg.RetryMaxAttempts < 1 {
+		return configErrorf("provider.retry.max_attempts", "must be >= 1")
+	}
+	if cfg.RetryBaseDelay <= 0 {
+		return configErrorf("provider.retry.base_delay", "must be > 0")
+	}
+	if cfg.RetryMaxDelay < cfg.RetryBaseDelay {
+		return configErrorf("provider.retry.max_delay", "must be >= provider.retry.base_delay")
+	}
+	if cfg.RetryBudget < 0 {
+		return configErrorf("provider.retry.budget", "must be >= 0")
+	}
+	if cfg.StreamIdleTimeout <= 0 {
+		return configErrorf("provider.stream_idle_timeout", "must be > 0")
+	}
+	if cfg.BreakerEnabled {
+		if cfg.BreakerFailureRate <= 0 || cfg.BreakerFailureRate > 1 {
+			return configErrorf("provider.circuit_breaker.failure_rate", "must be > 0 and <= 1, got %v", cfg.BreakerFailureRate)
+		}
+		if cfg.BreakerWindow < 1 {
+			return configErrorf("provider.circuit_breaker.window", "must be >= 1")
+		}
+		if cfg.BreakerMinRequests < 1 || cfg.BreakerMinRequests > cfg.BreakerWindow {
+			return configErrorf("provider.circuit_breaker.min_requests", "must be >= 1 and <= provider.circuit_breaker.window")
+		}
+		if cfg.BreakerOpenDuration <= 0 {
+			return configErrorf("provider.circuit_breaker.open_duration", "must be > 0")
+		}
+		if cfg.BreakerProbeInterval < 0 {
+			return configErrorf("provider.circuit_breaker.probe_interval", "must be >= 0")
+		}
+	}
+	if cfg.IdempotencyMaxEntries < 0 {
+		return configErrorf("idempotency.max_entries", "must be >= 0")
+	}
+	if cfg.IdempotencyMaxEntries > 0 && cfg.IdempotencyTTL <= 0 {
+		return configErrorf("idempotency.ttl", "must be > 0")
+	}
+	if cfg.FailureMode != failureModeFailClosed {
+		return configErrorf("routing.failure_mode", "unsupported value %q: must be %q", cfg.FailureMode, failureModeFailClosed)
+	}
+	if cfg.ConfidenceThreshold <= 0 || cfg.ConfidenceThreshold > 1 {
+		return configErrorf("scorer.confidence_threshold", "must be > 0 and <= 1, got %v", cfg.ConfidenceThreshold)
+	}
+	if strings.TrimSpace(cfg.PolicyVersion) == "" {
+		return configErrorf("scorer.policy_version", "must not be empty")
+	}
+	s, err := sanitizerFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+	known := map[string]bool{}
+	for _, entityType := range s.EntityTypes() {
+		known[entityType] = true
+	}
+	for _, entityType := range sortedKeys(cfg.EntityActions) {
+		if !known[entityType] {
+			return configErrorf("routing.entity_actions."+entityType, "no sanitizer rule detects entity type %q", entityType)
+		}
+	}
+	if err := validateRiskModel(cfg.RiskModel, known); err != nil {
+		return err
+	}
+
+	seen := map[providerMode]bool{}
+	for i, entry := range cfg.ProviderChain {
+		field := fmt.Sprintf("provider.chain[%d]", i)
+		if seen[entry.Mode] {
+			return configErrorf(field+".mode", "duplicate provider %q", entry.Mode)
+		}
+		seen[entry.Mode] = true
+	}
+	for _, entry := range cfg.providerChain() {
+		if err := validateProviderSettings(cfg, entry.Mode); err != nil {
+			return err
+		}
+	}
+
+	if cfg.LocalAbstractionBaseURL != "" && cfg.LocalAbstractionModel == "" {
+		return configErrorf("provider.local_abstraction.model", "LPG_LOCAL_ABSTRACTION_MODEL is required when LPG_LOCAL_ABSTRACTION_BASE_URL is set")
+	}
+	if cfg.LocalAbstractionBaseURL == "" && cfg.LocalAbstractionModel != "" {
+		return configErrorf("provider.local_abstraction.base_url", "LPG_LOCAL_ABSTRACTION_BASE_URL is required when LPG_LOCAL_ABSTRACTION_MODEL is set")
+	}
+	if cfg.LocalEmbeddingsBaseURL != "" && cfg.LocalEmbeddingsModel == "" {
+		return configErrorf("provider.local_embeddings.model", "LPG_LOCAL_EMBEDDINGS_MODEL is required when LPG_LOCAL_EMBEDDINGS_BASE_URL is set")
+	}
+	if cfg.LocalEmbeddingsBaseURL == "" && cfg.LocalEmbeddingsModel != "" {
+		return configErrorf("provider.local_embeddings.base_url", "LPG_LOCAL_EMBEDDINGS_BASE_URL is required when LPG_LOCAL_EMBEDDINGS_MODEL is set")
+	}
+
+	return nil
+}
+
+func validateRiskModel(model risk.Model, knownEntities map[string]bool) error {
+	if model.DefaultWeight < 0 {
+		return configErrorf("scorer.default_weight", "must be >= 0")
+	}
+	for _, entityType := range sortedKeys(model.Weights) {
+		if !knownEntities[entityType] {
+			return configErrorf("scorer.weights."+entityType, "no sanitizer rule detects entity type %q", entityType)
+		}
+		if model.Weights[entityType] < 0 {
+			return configErrorf("scorer.weights."+entityType, "must be >= 0")
+		}
+	}
+	if model.RepeatDecay < 0 || model.RepeatDecay > 1 {
+		return configErrorf("scorer.repeat_decay", "must be >= 0 and <= 1, got %v", model.RepeatDecay)
+	}
+	for i, bonus := range model.CoOccurrences {
+		field := fmt.Sprintf("scorer.co_occurrence[%d]", i)
+		if strings.TrimSpace(bonus.Name) == "" {
+			return configErrorf(field+".name", "is required")
+		}
+		if len(bonus.EntityTypes) < 2 {
+			return configErrorf(field+".entities", "must list at least two entity types")
+		}
+		for _, entityType := range bonus.EntityTypes {
+			if !knownEntities[entityType] {
+				return configErrorf(field+".entities", "no sanitizer rule detects entity type %q", entityType)
+			}
+		}
+		if bonus.Bonus < 0 {
+			return configErrorf(field+".bonus", "must be >= 0")
+		}
+	}
+	for _, keyword := range sortedKeys(model.ContextKeywords) {
+		if strings.TrimSpace(keyword) == "" {
+			return configErrorf("scorer.context_keywords", "keywords must not be empty")
+		}
+		if model.ContextKeywords[keyword] < 0 {
+			return configErrorf("scorer.context_keywords."+keyword, "must be >= 0")
+		}
+	}
+	return nil
+}
+
+func validateProviderSettings(cfg startupConfig, mode providerMode) error {
+	switch mode {
 	case providerStub:
 		// no additional required variables
 	case providerVLLMLocal:
 		if cfg.VLLMBaseURL == "" {
-			return startupConfig{}, fmt.Errorf("LPG_VLLM_BASE_URL is required when LPG_PROVIDER=%q", providerVLLMLocal)
+			return configErrorf("provider.vllm.base_url", "LPG_VLLM_BASE_URL is required when LPG_PROVIDER=%q", providerVLLMLocal)
 		}
 	case providerMimoOnline:
 		if cfg.MimoBaseURL == "" {
-			return startupConfig{}, fmt.Errorf("LPG_MIMO_BASE_URL is required when LPG_PROVIDER=%q", providerMimoOnline)
+			return configErrorf("provider.mimo.base_url", "LPG_MIMO_BASE_URL is required when LPG_PROVIDER=%q", providerMimoOnline)
 		}
 		if cfg.MimoAPIKey == "" {
-			return startupConfig{}, fmt.Errorf("LPG_MIMO_API_KEY is required when LPG_PROVIDER=%q", providerMimoOnline)
+			return configErrorf("provider.mimo.api_key", "LPG_MIMO_API_KEY is required when LPG_PROVIDER=%q", providerMimoOnline)
 		}
 	case providerOpenAICompatible:
 		if cfg.UpstreamBaseURL == "" {
-			return startupConfig{}, fmt.Errorf("LPG_UPSTREAM_BASE_URL is required when LPG_PROVIDER=%q", providerOpenAICompatible)
+			return configErrorf("provider.upstream.base_url", "LPG_UPSTREAM_BASE_URL is required when LPG_PROVIDER=%q", providerOpenAICompatible)
 		}
 	}
 
-	if cfg.LocalAbstractionBaseURL != "" && cfg.LocalAbstractionModel == "" {
-		return startupConfig{}, fmt.Errorf("LPG_LOCAL_ABSTRACTION_MODEL is required when LPG_LOCAL_ABSTRACTION_BASE_URL is set")
-	}
-	if cfg.LocalAbstractionBaseURL == "" && cfg.LocalAbstractionModel != "" {
-		return startupConfig{}, fmt.Errorf("LPG_LOCAL_ABSTRACTION_BASE_URL is required when LPG_LOCAL_ABSTRACTION_MODEL is set")
+	return nil
+}
+
+// providerChain returns the configured chain, or the single provider mode
+// when no chain is set.
+func (cfg startupConfig) providerChain() []providerChainEntry {
+	if len(cfg.ProviderChain) > 0 {
+		return cfg.ProviderChain
 	}
+	return []providerChainEntry{{Mode: cfg.Provider, Local: defaultProviderLocality(cfg.Provider)}}
+}
 
-	return cfg, nil
+// defaultProviderLocality reports whether a mode runs on operator
+// infrastructure. openai_compatible may point anywhere, so it is remote
+// unless the chain entry says otherwise.
+func defaultProviderLocality(mode providerMode) bool {
+	return mode == providerStub || mode == providerVLLMLocal
+}
+
+// sanitizerFromConfig combines the selected built-in rules with any custom
+// rules; both take part in the same longest-span-first overlap resolution.
+func sanitizerFromConfig(cfg startupConfig) (*sanitizer.Sanitizer, error) {
+	rules, err := sanitizer.BuiltinRules(cfg.SanitizerEntities...)
+	if err != nil {
+		return nil, configErrorf("sanitizer.entities", "%v", err)
+	}
+	s, err := sanitizer.New(append(rules, cfg.CustomRules...)...)
+	if err != nil {
+		return nil, configErrorf("sanitizer.custom_rules", "%v", err)
+	}
+	return s, nil
 }
 
 func parseProviderMode(raw string) (providerMode, error) {
@@ -186,7 +737,153 @@ func parseProviderMode(raw string) (providerMode, error) {
 	case "generic", "openai", "custom", "llamacpp_local":
 		return providerOpenAICompatible, nil
 	default:
-		return "", fmt.Errorf("invalid LPG_PROVIDER %q: must be one of %q, %q, %q, %q", raw, providerStub, providerVLLMLocal, providerMimoOnline, providerOpenAICompatible)
+		return "", fmt.Errorf("invalid provider %q: must be one of %q, %q, %q, %q", raw, providerStub, providerVLLMLocal, providerMimoOnline, providerOpenAICompatible)
+	}
+}
+
+// parseProviderChain parses the LPG_PROVIDER_CHAIN mode list. Entries from
+// the environment carry no route or category constraints.
+func parseProviderChain(raw string) ([]providerChainEntry, error) {
+	chain := make([]providerChainEntry, 0)
+	for _, value := range strings.Split(raw, ",") {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		mode, err := parseProviderMode(value)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, providerChainEntry{Mode: mode, Local: defaultProviderLocality(mode)})
+	}
+	return chain, nil
+}
+
+// parseForwardingRoutes accepts only the routes that reach a provider;
+// critical_local_only is served by the local abstractor, never the chain.
+func parseForwardingRoutes(values []string) ([]router.Route, error) {
+	routes := make([]router.Route, 0, len(values))
+	for _, value := range values {
+		route := router.Route(strings.ToLower(strings.TrimSpace(value)))
+		switch route {
+		case router.RouteRawForward, router.RouteSanitizedForward, router.RouteHighAbstraction:
+			routes = append(routes, route)
+		default:
+			return nil, fmt.Errorf("invalid route %q: must be one of %q, %q, %q", value, router.RouteRawForward, router.RouteSanitizedForward, router.RouteHighAbstraction)
+		}
+	}
+	return routes, nil
+}
+
+func parseRiskCategories(values []string) ([]risk.Category, error) {
+	categories := make([]risk.Category, 0, len(values))
+	for _, value := range values {
+		category := risk.Category(strings.TrimSpace(value))
+		switch category {
+		case risk.CategoryLow, risk.CategoryMedium, risk.CategoryHigh, risk.CategoryCritical:
+			categories = append(categories, category)
+		default:
+			return nil, fmt.Errorf("invalid category %q: must be one of %q, %q, %q, %q", value, risk.CategoryLow, risk.CategoryMedium, risk.CategoryHigh, risk.CategoryCritical)
+		}
+	}
+	return categories, nil
+}
+
+func parseRehydrateRoutes(values []string) ([]router.Route, error) {
+	if len(values) == 1 && strings.EqualFold(strings.TrimSpace(values[0]), "none") {
+		return []router.Route{}, nil
+	}
+
+	routes := make([]router.Route, 0)
+	for _, value := range values {
+		route := router.Route(strings.ToLower(strings.TrimSpace(value)))
+		switch route {
+		case "":
+			continue
+		case router.RouteRawForward, router.RouteSanitizedForward, router.RouteHighAbstraction, router.RouteCriticalLocalOnly:
+			routes = append(routes, route)
+		default:
+			return nil, fmt.Errorf("invalid route %q: must be one of %q, %q, %q, %q or \"none\"", value, router.RouteRawForward, router.RouteSanitizedForward, router.RouteHighAbstraction, router.RouteCriticalLocalOnly)
+		}
+	}
+	return routes, nil
+}
+
+// parseNames trims tool or model names and drops empty entries. Both are
+// case-sensitive, so they are kept as written.
+func parseNames(values []string) []string {
+	names := make([]string, 0, len(values))
+	for _, value := range values {
+		if name := strings.TrimSpace(value); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// parseEntityActions parses "ENTITY:action" pairs, for example
+// "SSN:block,EMAIL:escalate_one_band".
+func parseEntityActions(raw string) (router.EntityPolicies, error) {
+	actions := router.EntityPolicies{}
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		entityType, rawAction, ok := strings.Cut(pair, ":")
+		entityType = strings.TrimSpace(entityType)
+		if !ok || entityType == "" {
+			return nil, fmt.Errorf("invalid entry %q: must be ENTITY:action", pair)
+		}
+		if _, dup := actions[entityType]; dup {
+			return nil, fmt.Errorf("duplicate entity type %q", entityType)
+		}
+		action, err := router.ParseAction(strings.TrimSpace(rawAction))
+		if err != nil {
+			return nil, err
+		}
+		actions[entityType] = action
+	}
+	return actions, nil
+}
+
+// parseImagePolicies parses "route:policy" pairs, for example
+// "sanitized_forward:allow,high_abstraction:strip".
+func parseImagePolicies(raw string) (proxy.ImagePolicies, error) {
+	policies := proxy.ImagePolicies{}
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		rawRoute, rawPolicy, ok := strings.Cut(pair, ":")
+		route := router.Route(strings.ToLower(strings.TrimSpace(rawRoute)))
+		if !ok || route == "" {
+			return nil, fmt.Errorf("invalid entry %q: must be route:policy", pair)
+		}
+		if _, dup := policies[route]; dup {
+			return nil, fmt.Errorf("duplicate route %q", route)
+		}
+		policy, err := proxy.ParseImagePolicy(route, rawPolicy)
+		if err != nil {
+			return nil, err
+		}
+		policies[route] = policy
+	}
+	return policies, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func overrideString(target *string, key string) {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		*target = value
 	}
 }
 
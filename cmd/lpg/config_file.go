@@ -0,0 +1,567 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/soloengine/lpg/internal/proxy"
+	"github.com/soloengine/lpg/internal/risk"
+	"github.com/soloengine/lpg/internal/router"
+	"github.com/soloengine/lpg/internal/sanitizer"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	configFileVersion = 1
+
+	configValidationErrorCode = "ERR_CONFIG_VALIDATION"
+)
+
+// configValidationError reports a configuration problem against the dotted
+// field path (or environment variable) that caused it, so startup failures
+// are stable and easy to grep for.
+type configValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *configValidationError) Error() string {
+	return configValidationErrorCode + ": " + e.Field + ": " + e.Message
+}
+
+func configErrorf(field, format string, args ...any) error {
+	return &configValidationError{Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// fileConfig is the versioned YAML schema accepted by --config. Pointer
+// fields distinguish "not set" from zero values so that defaults survive
+// partial files.
+type fileConfig struct {
+	Version     int                   `yaml:"version"`
+	Provider    fileProviderConfig    `yaml:"provider"`
+	Routing     fileRoutingConfig     `yaml:"routing"`
+	Scorer      fileScorerConfig      `yaml:"scorer"`
+	Sanitizer   fileSanitizerConfig   `yaml:"sanitizer"`
+	Audit       fileAuditConfig       `yaml:"audit"`
+	Idempotency fileIdempotencyConfig `yaml:"idempotency"`
+}
+
+type fileProviderConfig struct {
+	Mode             *string              `yaml:"mode"`
+	Timeout          *string              `yaml:"timeout"`
+	StreamIdle       *string              `yaml:"stream_idle_timeout"`
+	Retry            fileRetryConfig      `yaml:"retry"`
+	CircuitBreaker   fileBreakerConfig    `yaml:"circuit_breaker"`
+	VLLM             fileVLLMConfig       `yaml:"vllm"`
+	Mimo             fileMimoConfig       `yaml:"mimo"`
+	Upstream         fileEndpointConfig   `yaml:"upstream"`
+	LocalAbstraction fileEndpointConfig   `yaml:"local_abstraction"`
+	LocalEmbeddings  fileEmbeddingsConfig `yaml:"local_embeddings"`
+	Chain            []fileChainEntry     `yaml:"chain"`
+}
+
+type fileRetryConfig struct {
+	MaxAttempts *int    `yaml:"max_attempts"`
+	BaseDelay   *string `yaml:"base_delay"`
+	MaxDelay    *string `yaml:"max_delay"`
+	Budget      *string `yaml:"budget"`
+}
+
+type fileBreakerConfig struct {
+	Enabled       *bool    `yaml:"enabled"`
+	FailureRate   *float64 `yaml:"failure_rate"`
+	MinRequests   *int     `yaml:"min_requests"`
+	Window        *int     `yaml:"window"`
+	OpenDuration  *string  `yaml:"open_duration"`
+	ProbeInterval *string  `yaml:"probe_interval"`
+}
+
+type fileChainEntry struct {
+	Mode       string   `yaml:"mode"`
+	Local      *bool    `yaml:"local"`
+	Routes     []string `yaml:"routes"`
+	Categories []string `yaml:"categories"`
+}
+
+type fileVLLMConfig struct {
+	BaseURL *string `yaml:"base_url"`
+	Model   *string `yaml:"model"`
+}
+
+type fileMimoConfig struct {
+	BaseURL *string `yaml:"base_url"`
+	APIKey  *string `yaml:"api_key"`
+	Model   *string `yaml:"model"`
+}
+
+type fileEndpointConfig struct {
+	BaseURL      *string `yaml:"base_url"`
+	APIKey       *string `yaml:"api_key"`
+	Model        *string `yaml:"model"`
+	APIKeyHeader *string `yaml:"api_key_header"`
+	APIKeyPrefix *string `yaml:"api_key_prefix"`
+	ChatPath     *string `yaml:"chat_path"`
+	// ResponsesPath, EmbeddingsPath and ModelsPath only apply to
+	// provider.upstream.
+	ResponsesPath  *string `yaml:"responses_path"`
+	EmbeddingsPath *string `yaml:"embeddings_path"`
+	ModelsPath     *string `yaml:"models_path"`
+}
+
+type fileEmbeddingsConfig struct {
+	BaseURL        *string `yaml:"base_url"`
+	APIKey         *string `yaml:"api_key"`
+	Model          *string `yaml:"model"`
+	APIKeyHeader   *string `yaml:"api_key_header"`
+	APIKeyPrefix   *string `yaml:"api_key_prefix"`
+	EmbeddingsPath *string `yaml:"embeddings_path"`
+}
+
+type fileRoutingConfig struct {
+	AllowRawForwarding *bool             `yaml:"allow_raw_forwarding"`
+	CriticalLocalOnly  *bool             `yaml:"critical_local_only"`
+	FailureMode        *string           `yaml:"failure_mode"`
+	RehydrateRoutes    *[]string         `yaml:"rehydrate_routes"`
+	RehydrateTools     *[]string         `yaml:"rehydrate_tools"`
+	ModelAllowlist     *[]string         `yaml:"model_allowlist"`
+	ImagePolicy        map[string]string `yaml:"image_policy"`
+	EntityActions      map[string]string `yaml:"entity_actions"`
+	// UserPseudonymKey is a path, never the key itself.
+	UserPseudonymKey *string `yaml:"user_pseudonym_key"`
+}
+
+type fileScorerConfig struct {
+	ConfidenceThreshold *float64           `yaml:"confidence_threshold"`
+	PolicyVersion       *string            `yaml:"policy_version"`
+	DefaultWeight       *float64           `yaml:"default_weight"`
+	Weights             map[string]float64 `yaml:"weights"`
+	RepeatDecay         *float64           `yaml:"repeat_decay"`
+	CoOccurrence        []fileCoOccurrence `yaml:"co_occurrence"`
+	ContextKeywords     map[string]float64 `yaml:"context_keywords"`
+}
+
+type fileCoOccurrence struct {
+	Name     string   `yaml:"name"`
+	Entities []string `yaml:"entities"`
+	Bonus    float64  `yaml:"bonus"`
+}
+
+type fileSanitizerConfig struct {
+	Entities    *[]string        `yaml:"entities"`
+	CustomRules []fileCustomRule `yaml:"custom_rules"`
+}
+
+type fileCustomRule struct {
+	EntityType            string   `yaml:"entity_type"`
+	Pattern               string   `yaml:"pattern"`
+	Confidence            *float64 `yaml:"confidence"`
+	SurrogateTemplate     string   `yaml:"surrogate_template"`
+	HardBlock             bool     `yaml:"hard_block"`
+	Validator             string   `yaml:"validator"`
+	UnvalidatedConfidence float64  `yaml:"unvalidated_confidence"`
+}
+
+type fileAuditConfig struct {
+	Path            *string `yaml:"path"`
+	Mode            *string `yaml:"mode"`
+	Strict          *bool   `yaml:"strict"`
+	MaxSegmentBytes *int64  `yaml:"max_segment_bytes"`
+	MaxSegmentAge   *string `yaml:"max_segment_age"`
+	Retention       *string `yaml:"retention"`
+	// SigningKey is a path, never the key itself.
+	SigningKey         *string `yaml:"signing_key"`
+	CheckpointEvery    *int    `yaml:"checkpoint_every"`
+	CheckpointInterval *string `yaml:"checkpoint_interval"`
+	Sync               *string `yaml:"sync"`
+	SyncInterval       *string `yaml:"sync_interval"`
+	EntityHashKey      *string `yaml:"entity_hash_key"`
+}
+
+type fileIdempotencyConfig struct {
+	TTL        *string `yaml:"ttl"`
+	MaxEntries *int    `yaml:"max_entries"`
+}
+
+func applyConfigFile(cfg *startupConfig, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return configErrorf("config", "read %s: %v", path, err)
+	}
+
+	file, err := parseConfigFile(data)
+	if err != nil {
+		return err
+	}
+	return file.apply(cfg)
+}
+
+func parseConfigFile(data []byte) (fileConfig, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fileConfig{}, configErrorf("config", "%v", err)
+	}
+	if len(root.Content) == 0 {
+		return fileConfig{}, configErrorf("version", "is required")
+	}
+	if err := checkKnownKeys(root.Content[0], reflect.TypeOf(fileConfig{}), ""); err != nil {
+		return fileConfig{}, err
+	}
+
+	var file fileConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return fileConfig{}, configErrorf("config", "%v", err)
+	}
+
+	switch file.Version {
+	case configFileVersion:
+	case 0:
+		return fileConfig{}, configErrorf("version", "is required")
+	default:
+		return fileConfig{}, configErrorf("version", "unsupported version %d: must be %d", file.Version, configFileVersion)
+	}
+	return file, nil
+}
+
+// checkKnownKeys walks the YAML tree against the schema and rejects the
+// first unknown key in document order, reporting its dotted path.
+func checkKnownKeys(node *yaml.Node, schema reflect.Type, path string) error {
+	for schema.Kind() == reflect.Pointer {
+		schema = schema.Elem()
+	}
+	if schema.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode {
+		for i, item := range node.Content {
+			if err := checkKnownKeys(item, schema.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if schema.Kind() != reflect.Struct || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	fields := make(map[string]reflect.Type, schema.NumField())
+	for i := 0; i < schema.NumField(); i++ {
+		field := schema.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		fields[name] = field.Type
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		fieldPath := key.Value
+		if path != "" {
+			fieldPath = path + "." + key.Value
+		}
+		fieldType, ok := fields[key.Value]
+		if !ok {
+			return configErrorf(fieldPath, "unknown key (line %d)", key.Line)
+		}
+		if err := checkKnownKeys(node.Content[i+1], fieldType, fieldPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f fileConfig) apply(cfg *startupConfig) error {
+	if f.Provider.Mode != nil {
+		provider, err := parseProviderMode(*f.Provider.Mode)
+		if err != nil {
+			return configErrorf("provider.mode", "%v", err)
+		}
+		cfg.Provider = provider
+	}
+	for i, raw := range f.Provider.Chain {
+		entry, err := raw.parse()
+		if err != nil {
+			return configErrorf(fmt.Sprintf("provider.chain[%d].%s", i, err.Field), "%s", err.Message)
+		}
+		cfg.ProviderChain = append(cfg.ProviderChain, entry)
+	}
+	if f.Provider.Timeout != nil {
+		timeout, err := time.ParseDuration(strings.TrimSpace(*f.Provider.Timeout))
+		if err != nil {
+			return configErrorf("provider.timeout", "%v", err)
+		}
+		cfg.ProviderTimeout = timeout
+	}
+	if f.Provider.Retry.MaxAttempts != nil {
+		cfg.RetryMaxAttempts = *f.Provider.Retry.MaxAttempts
+	}
+	if err := setDuration(&cfg.RetryBaseDelay, "provider.retry.base_delay", f.Provider.Retry.BaseDelay); err != nil {
+		return err
+	}
+	if err := setDuration(&cfg.RetryMaxDelay, "provider.retry.max_delay", f.Provider.Retry.MaxDelay); err != nil {
+		return err
+	}
+	if err := setDuration(&cfg.RetryBudget, "provider.retry.budget", f.Provider.Retry.Budget); err != nil {
+		return err
+	}
+	if err := setDuration(&cfg.StreamIdleTimeout, "provider.stream_idle_timeout", f.Provider.StreamIdle); err != nil {
+		return err
+	}
+	breaker := f.Provider.CircuitBreaker
+	if breaker.Enabled != nil {
+		cfg.BreakerEnabled = *breaker.Enabled
+	}
+	if breaker.FailureRate != nil {
+		cfg.BreakerFailureRate = *breaker.FailureRate
+	}
+	if breaker.MinRequests != nil {
+		cfg.BreakerMinRequests = *breaker.MinRequests
+	}
+	if breaker.Window != nil {
+		cfg.BreakerWindow = *breaker.Window
+	}
+	if err := setDuration(&cfg.BreakerOpenDuration, "provider.circuit_breaker.open_duration", breaker.OpenDuration); err != nil {
+		return err
+	}
+	if err := setDuration(&cfg.BreakerProbeInterval, "provider.circuit_breaker.probe_interval", breaker.ProbeInterval); err != nil {
+		return err
+	}
+
+	setString(&cfg.VLLMBaseURL, f.Provider.VLLM.BaseURL)
+	setString(&cfg.VLLMModel, f.Provider.VLLM.Model)
+
+	setString(&cfg.MimoBaseURL, f.Provider.Mimo.BaseURL)
+	setString(&cfg.MimoAPIKey, f.Provider.Mimo.APIKey)
+	setString(&cfg.MimoModel, f.Provider.Mimo.Model)
+
+	setString(&cfg.UpstreamBaseURL, f.Provider.Upstream.BaseURL)
+	setString(&cfg.UpstreamAPIKey, f.Provider.Upstream.APIKey)
+	setString(&cfg.UpstreamModel, f.Provider.Upstream.Model)
+	setString(&cfg.UpstreamAPIKeyHeader, f.Provider.Upstream.APIKeyHeader)
+	setString(&cfg.UpstreamAPIKeyPrefix, f.Provider.Upstream.APIKeyPrefix)
+	setString(&cfg.UpstreamChatPath, f.Provider.Upstream.ChatPath)
+	setString(&cfg.UpstreamResponsesPath, f.Provider.Upstream.ResponsesPath)
+	setString(&cfg.UpstreamEmbeddingsPath, f.Provider.Upstream.EmbeddingsPath)
+	setString(&cfg.UpstreamModelsPath, f.Provider.Upstream.ModelsPath)
+
+	setString(&cfg.LocalAbstractionBaseURL, f.Provider.LocalAbstraction.BaseURL)
+	setString(&cfg.LocalAbstractionAPIKey, f.Provider.LocalAbstraction.APIKey)
+	setString(&cfg.LocalAbstractionModel, f.Provider.LocalAbstraction.Model)
+	setString(&cfg.LocalAbstractionAPIKeyHeader, f.Provider.LocalAbstraction.APIKeyHeader)
+	setString(&cfg.LocalAbstractionAPIKeyPrefix, f.Provider.LocalAbstraction.APIKeyPrefix)
+	setString(&cfg.LocalAbstractionChatPath, f.Provider.LocalAbstraction.ChatPath)
+	if f.Provider.LocalAbstraction.ResponsesPath != nil {
+		return configErrorf("provider.local_abstraction.responses_path", "not supported: the local abstractor always uses chat completions")
+	}
+	if f.Provider.LocalAbstraction.EmbeddingsPath != nil {
+		return configErrorf("provider.local_abstraction.embeddings_path", "not supported: configure provider.local_embeddings instead")
+	}
+	if f.Provider.LocalAbstraction.ModelsPath != nil {
+		return configErrorf("provider.local_abstraction.models_path", "not supported: the local abstractor reports its configured model")
+	}
+
+	setString(&cfg.LocalEmbeddingsBaseURL, f.Provider.LocalEmbeddings.BaseURL)
+	setString(&cfg.LocalEmbeddingsAPIKey, f.Provider.LocalEmbeddings.APIKey)
+	setString(&cfg.LocalEmbeddingsModel, f.Provider.LocalEmbeddings.Model)
+	setString(&cfg.LocalEmbeddingsAPIKeyHeader, f.Provider.LocalEmbeddings.APIKeyHeader)
+	setString(&cfg.LocalEmbeddingsAPIKeyPrefix, f.Provider.LocalEmbeddings.APIKeyPrefix)
+	setString(&cfg.LocalEmbeddingsPath, f.Provider.LocalEmbeddings.EmbeddingsPath)
+
+	if f.Routing.AllowRawForwarding != nil {
+		cfg.AllowRawForwarding = *f.Routing.AllowRawForwarding
+	}
+	if f.Routing.CriticalLocalOnly != nil {
+		cfg.CriticalLocalOnly = *f.Routing.CriticalLocalOnly
+	}
+	setString(&cfg.FailureMode, f.Routing.FailureMode)
+	if f.Routing.RehydrateRoutes != nil {
+		routes, err := parseRehydrateRoutes(*f.Routing.RehydrateRoutes)
+		if err != nil {
+			return configErrorf("routing.rehydrate_routes", "%v", err)
+		}
+		cfg.RehydrateRoutes = routes
+	}
+	if f.Routing.RehydrateTools != nil {
+		cfg.RehydrateTools = parseNames(*f.Routing.RehydrateTools)
+	}
+	if f.Routing.ModelAllowlist != nil {
+		cfg.ModelAllowlist = parseNames(*f.Routing.ModelAllowlist)
+	}
+	setString(&cfg.UserPseudonymKeyPath, f.Routing.UserPseudonymKey)
+	if f.Routing.ImagePolicy != nil {
+		cfg.ImagePolicies = proxy.ImagePolicies{}
+		for _, rawRoute := range sortedKeys(f.Routing.ImagePolicy) {
+			route := router.Route(rawRoute)
+			policy, err := proxy.ParseImagePolicy(route, f.Routing.ImagePolicy[rawRoute])
+			if err != nil {
+				return configErrorf("routing.image_policy."+rawRoute, "%v", err)
+			}
+			cfg.ImagePolicies[route] = policy
+		}
+	}
+	if f.Routing.EntityActions != nil {
+		entityTypes := make([]string, 0, len(f.Routing.EntityActions))
+		for entityType := range f.Routing.EntityActions {
+			entityTypes = append(entityTypes, entityType)
+		}
+		sort.Strings(entityTypes)
+
+		cfg.EntityActions = router.EntityPolicies{}
+		for _, entityType := range entityTypes {
+			action, err := router.ParseAction(strings.TrimSpace(f.Routing.EntityActions[entityType]))
+			if err != nil {
+				return configErrorf("routing.entity_actions."+entityType, "%v", err)
+			}
+			cfg.EntityActions[entityType] = action
+		}
+	}
+
+	if f.Scorer.ConfidenceThreshold != nil {
+		cfg.ConfidenceThreshold = *f.Scorer.ConfidenceThreshold
+	}
+	setString(&cfg.PolicyVersion, f.Scorer.PolicyVersion)
+	if f.Scorer.DefaultWeight != nil {
+		cfg.RiskModel.DefaultWeight = *f.Scorer.DefaultWeight
+	}
+	if f.Scorer.Weights != nil {
+		cfg.RiskModel.Weights = f.Scorer.Weights
+	}
+	if f.Scorer.RepeatDecay != nil {
+		cfg.RiskModel.RepeatDecay = *f.Scorer.RepeatDecay
+	}
+	for _, bonus := range f.Scorer.CoOccurrence {
+		entityTypes := make([]string, 0, len(bonus.Entities))
+		for _, entityType := range bonus.Entities {
+			entityTypes = append(entityTypes, strings.TrimSpace(entityType))
+		}
+		cfg.RiskModel.CoOccurrences = append(cfg.RiskModel.CoOccurrences, risk.CoOccurrence{
+			Name:        strings.TrimSpace(bonus.Name),
+			EntityTypes: entityTypes,
+			Bonus:       bonus.Bonus,
+		})
+	}
+	if f.Scorer.ContextKeywords != nil {
+		cfg.RiskModel.ContextKeywords = f.Scorer.ContextKeywords
+	}
+
+	if f.Sanitizer.Entities != nil {
+		cfg.SanitizerEntities = append([]string(nil), (*f.Sanitizer.Entities)...)
+	}
+	for i, custom := range f.Sanitizer.CustomRules {
+		rule, err := custom.compile()
+		if err != nil {
+			return configErrorf(fmt.Sprintf("sanitizer.custom_rules[%d].%s", i, err.Field), "%s", err.Message)
+		}
+		cfg.CustomRules = append(cfg.CustomRules, rule)
+	}
+
+	setString(&cfg.AuditPath, f.Audit.Path)
+	setString(&cfg.AuditMode, f.Audit.Mode)
+	if f.Audit.MaxSegmentBytes != nil {
+		cfg.AuditMaxSegmentBytes = *f.Audit.MaxSegmentBytes
+	}
+	if err := setDuration(&cfg.AuditMaxSegmentAge, "audit.max_segment_age", f.Audit.MaxSegmentAge); err != nil {
+		return err
+	}
+	if err := setDuration(&cfg.AuditRetention, "audit.retention", f.Audit.Retention); err != nil {
+		return err
+	}
+	setString(&cfg.AuditSigningKeyPath, f.Audit.SigningKey)
+	if f.Audit.CheckpointEvery != nil {
+		cfg.AuditCheckpointEvery = *f.Audit.CheckpointEvery
+	}
+	if err := setDuration(&cfg.AuditCheckpointInterval, "audit.checkpoint_interval", f.Audit.CheckpointInterval); err != nil {
+		return err
+	}
+	setString(&cfg.AuditSync, f.Audit.Sync)
+	setString(&cfg.AuditEntityHashKeyPath, f.Audit.EntityHashKey)
+	if err := setDuration(&cfg.AuditSyncInterval, "audit.sync_interval", f.Audit.SyncInterval); err != nil {
+		return err
+	}
+	if err := setDuration(&cfg.IdempotencyTTL, "idempotency.ttl", f.Idempotency.TTL); err != nil {
+		return err
+	}
+	if f.Idempotency.MaxEntries != nil {
+		cfg.IdempotencyMaxEntries = *f.Idempotency.MaxEntries
+	}
+	if f.Audit.Strict != nil {
+		cfg.StrictAudit = *f.Audit.Strict
+	}
+
+	return nil
+}
+
+func (e fileChainEntry) parse() (providerChainEntry, *configValidationError) {
+	mode, err := parseProviderMode(e.Mode)
+	if err != nil {
+		return providerChainEntry{}, &configValidationError{Field: "mode", Message: err.Error()}
+	}
+	routes, err := parseForwardingRoutes(e.Routes)
+	if err != nil {
+		return providerChainEntry{}, &configValidationError{Field: "routes", Message: err.Error()}
+	}
+	categories, err := parseRiskCategories(e.Categories)
+	if err != nil {
+		return providerChainEntry{}, &configValidationError{Field: "categories", Message: err.Error()}
+	}
+	local := defaultProviderLocality(mode)
+	if e.Local != nil {
+		local = *e.Local
+	}
+	return providerChainEntry{Mode: mode, Local: local, Routes: routes, Categories: categories}, nil
+}
+
+func (r fileCustomRule) compile() (sanitizer.Rule, *configValidationError) {
+	if strings.TrimSpace(r.Pattern) == "" {
+		return sanitizer.Rule{}, &configValidationError{Field: "pattern", Message: "is required"}
+	}
+	regex, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return sanitizer.Rule{}, &configValidationError{Field: "pattern", Message: err.Error()}
+	}
+	if r.Confidence == nil {
+		return sanitizer.Rule{}, &configValidationError{Field: "confidence", Message: "is required"}
+	}
+	var validator sanitizer.Validator
+	if name := strings.TrimSpace(r.Validator); name != "" {
+		v, ok := sanitizer.LookupValidator(name)
+		if !ok {
+			return sanitizer.Rule{}, &configValidationError{
+				Field:   "validator",
+				Message: fmt.Sprintf("unknown validator %q: must be one of %s", name, strings.Join(sanitizer.ValidatorNames(), ", ")),
+			}
+		}
+		validator = v
+	}
+	return sanitizer.Rule{
+		EntityType:            strings.TrimSpace(r.EntityType),
+		Regex:                 regex,
+		Confidence:            *r.Confidence,
+		SurrogateTemplate:     strings.TrimSpace(r.SurrogateTemplate),
+		HardBlock:             r.HardBlock,
+		Validator:             validator,
+		UnvalidatedConfidence: r.UnvalidatedConfidence,
+	}, nil
+}
+
+func setString(target *string, value *string) {
+	if value != nil {
+		*target = strings.TrimSpace(*value)
+	}
+}
+
+func setDuration(target *time.Duration, field string, value *string) error {
+	if value == nil {
+		return nil
+	}
+	duration, err := time.ParseDuration(strings.TrimSpace(*value))
+	if err != nil {
+		return configErrorf(field, "%v", err)
+	}
+	*target = duration
+	return nil
+}
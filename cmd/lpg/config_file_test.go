@@ -0,0 +1,831 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/soloengine/lpg/internal/router"
+	"github.com/soloengine/lpg/internal/sanitizer"
+)
+
+func clearStartupEnv(t *testing.T) {
+	t.Helper()
+	for _, entry := range os.Environ() {
+		key, _, _ := strings.Cut(entry, "=")
+		if strings.HasPrefix(key, "LPG_") {
+			t.Setenv(key, "")
+			_ = os.Unsetenv(key)
+		}
+	}
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "lpg.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadStartupConfigReadsYAMLFile(t *testing.T) {
+	clearStartupEnv(t)
+	path := writeConfigFile(t, `
+version: 1
+provider:
+  mode: openai_compatible
+  timeout: 5s
+  stream_idle_timeout: 1m
+  upstream:
+    base_url: https://api.example.test
+    model: gpt-test
+    api_key_header: X-API-Key
+    api_key_prefix: ""
+routing:
+  allow_raw_forwarding: true
+  critical_local_only: true
+  failure_mode: fail_closed
+  rehydrate_routes: [sanitized_forward, high_abstraction]
+  rehydrate_tools: [send_email, " lookup_customer "]
+  model_allowlist: [gpt-test]
+scorer:
+  confidence_threshold: 0.85
+  policy_version: v3-test
+sanitizer:
+  entities: [EMAIL, SSN]
+audit:
+  path: /tmp/lpg-audit.log
+  mode: audit_redacted
+  strict: true
+`)
+
+	cfg, err := loadStartupConfig(path)
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error: %v", err)
+	}
+
+	if cfg.Provider != providerOpenAICompatible || cfg.ProviderTimeout != 5*time.Second || cfg.StreamIdleTimeout != time.Minute {
+		t.Fatalf("unexpected provider settings: %q %s %s", cfg.Provider, cfg.ProviderTimeout, cfg.StreamIdleTimeout)
+	}
+	if cfg.UpstreamBaseURL != "https://api.example.test" || cfg.UpstreamModel != "gpt-test" {
+		t.Fatalf("unexpected upstream settings: %q %q", cfg.UpstreamBaseURL, cfg.UpstreamModel)
+	}
+	if cfg.UpstreamAPIKeyHeader != "X-API-Key" || cfg.UpstreamAPIKeyPrefix != "" {
+		t.Fatalf("unexpected upstream auth settings: %q %q", cfg.UpstreamAPIKeyHeader, cfg.UpstreamAPIKeyPrefix)
+	}
+	if cfg.UpstreamChatPath != defaultUpstreamChatPath {
+		t.Fatalf("expected unset chat path to keep default, got %q", cfg.UpstreamChatPath)
+	}
+	if !cfg.AllowRawForwarding || !cfg.CriticalLocalOnly {
+		t.Fatalf("unexpected routing flags: raw=%t critical_local_only=%t", cfg.AllowRawForwarding, cfg.CriticalLocalOnly)
+	}
+	if len(cfg.RehydrateRoutes) != 2 || cfg.RehydrateRoutes[1] != router.RouteHighAbstraction {
+		t.Fatalf("unexpected rehydrate routes: %v", cfg.RehydrateRoutes)
+	}
+	if strings.Join(cfg.RehydrateTools, ",") != "send_email,lookup_customer" {
+		t.Fatalf("unexpected rehydrate tools: %v", cfg.RehydrateTools)
+	}
+	if strings.Join(cfg.ModelAllowlist, ",") != "gpt-test" {
+		t.Fatalf("unexpected model allowlist: %v", cfg.ModelAllowlist)
+	}
+	if cfg.ConfidenceThreshold != 0.85 || cfg.PolicyVersion != "v3-test" {
+		t.Fatalf("unexpected scorer settings: %v %q", cfg.ConfidenceThreshold, cfg.PolicyVersion)
+	}
+	if strings.Join(cfg.SanitizerEntities, ",") != "EMAIL,SSN" {
+		t.Fatalf("unexpected sanitizer entities: %v", cfg.SanitizerEntities)
+	}
+	if cfg.AuditPath != "/tmp/lpg-audit.log" || !cfg.StrictAudit {
+		t.Fatalf("unexpected audit settings: %q strict=%t", cfg.AuditPath, cfg.StrictAudit)
+	}
+}
+
+func TestLoadStartupConfigDefaultsAreSecure(t *testing.T) {
+	clearStartupEnv(t)
+
+	cfg, err := loadStartupConfig(writeConfigFile(t, "version: 1\n"))
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error: %v", err)
+	}
+
+	if cfg.FailureMode != failureModeFailClosed {
+		t.Fatalf("expected failure mode %q, got %q", failureModeFailClosed, cfg.FailureMode)
+	}
+	if cfg.AuditMode != auditModeRedacted {
+		t.Fatalf("expected audit mode %q, got %q", auditModeRedacted, cfg.AuditMode)
+	}
+	if cfg.AllowRawForwarding {
+		t.Fatal("expected raw forwarding to be disabled by default")
+	}
+	if cfg.ConfidenceThreshold != defaultConfidenceThreshold || cfg.PolicyVersion != defaultPolicyVersion {
+		t.Fatalf("unexpected scorer defaults: %v %q", cfg.ConfidenceThreshold, cfg.PolicyVersion)
+	}
+	if strings.Join(cfg.SanitizerEntities, ",") != strings.Join(sanitizer.BuiltinEntityTypes(), ",") {
+		t.Fatalf("unexpected default sanitizer entities: %v", cfg.SanitizerEntities)
+	}
+}
+
+func TestLoadStartupConfigEnvOverridesFile(t *testing.T) {
+	clearStartupEnv(t)
+	path := writeConfigFile(t, `
+version: 1
+provider:
+  mode: vllm_local
+  vllm:
+    base_url: http://file-vllm:8000
+    model: file-model
+scorer:
+  confidence_threshold: 0.9
+audit:
+  path: /tmp/file-audit.log
+`)
+	t.Setenv("LPG_VLLM_MODEL", "env-model")
+	t.Setenv("LPG_CONFIDENCE_THRESHOLD", "0.5")
+	t.Setenv("LPG_AUDIT_PATH", "/tmp/env-audit.log")
+
+	cfg, err := loadStartupConfig(path)
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error: %v", err)
+	}
+
+	if cfg.VLLMBaseURL != "http://file-vllm:8000" {
+		t.Fatalf("expected file base URL to survive empty env, got %q", cfg.VLLMBaseURL)
+	}
+	if cfg.VLLMModel != "env-model" {
+		t.Fatalf("expected env model override, got %q", cfg.VLLMModel)
+	}
+	if cfg.ConfidenceThreshold != 0.5 {
+		t.Fatalf("expected env threshold override, got %v", cfg.ConfidenceThreshold)
+	}
+	if cfg.AuditPath != "/tmp/env-audit.log" {
+		t.Fatalf("expected env audit path override, got %q", cfg.AuditPath)
+	}
+}
+
+func TestLoadStartupConfigRejectsInvalidFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "missing version",
+			content: "audit:\n  strict: true\n",
+			want:    "ERR_CONFIG_VALIDATION: version: is required",
+		},
+		{
+			name:    "unsupported version",
+			content: "version: 2\n",
+			want:    "ERR_CONFIG_VALIDATION: version: unsupported version 2: must be 1",
+		},
+		{
+			name:    "unknown top-level key",
+			content: "version: 1\nproviders: {}\n",
+			want:    "ERR_CONFIG_VALIDATION: providers: unknown key (line 2)",
+		},
+		{
+			name:    "unknown nested key",
+			content: "version: 1\nprovider:\n  vllm:\n    api_key: nope\n",
+			want:    "ERR_CONFIG_VALIDATION: provider.vllm.api_key: unknown key (line 4)",
+		},
+		{
+			name:    "responses path on local abstraction",
+			content: "version: 1\nprovider:\n  local_abstraction:\n    responses_path: /v1/responses\n",
+			want:    "ERR_CONFIG_VALIDATION: provider.local_abstraction.responses_path: not supported: the local abstractor always uses chat completions",
+		},
+		{
+			name:    "embeddings path on local abstraction",
+			content: "version: 1\nprovider:\n  local_abstraction:\n    embeddings_path: /v1/embeddings\n",
+			want:    "ERR_CONFIG_VALIDATION: provider.local_abstraction.embeddings_path: not supported: configure provider.local_embeddings instead",
+		},
+		{
+			name:    "image allow on high route",
+			content: "version: 1\nrouting:\n  image_policy:\n    high_abstraction: allow\n",
+			want:    `ERR_CONFIG_VALIDATION: routing.image_policy.high_abstraction: image policy "allow" is not allowed on high_abstraction: images never leave the host on high or critical routes`,
+		},
+		{
+			name:    "models path on local abstraction",
+			content: "version: 1\nprovider:\n  local_abstraction:\n    models_path: /v1/models\n",
+			want:    "ERR_CONFIG_VALIDATION: provider.local_abstraction.models_path: not supported: the local abstractor reports its configured model",
+		},
+		{
+			name:    "local embeddings without model",
+			content: "version: 1\nprovider:\n  local_embeddings:\n    base_url: http://127.0.0.1:8082\n",
+			want:    "ERR_CONFIG_VALIDATION: provider.local_embeddings.model: LPG_LOCAL_EMBEDDINGS_MODEL is required when LPG_LOCAL_EMBEDDINGS_BASE_URL is set",
+		},
+		{
+			name:    "insecure failure mode",
+			content: "version: 1\nrouting:\n  failure_mode: fail_open\n",
+			want:    `ERR_CONFIG_VALIDATION: routing.failure_mode: unsupported value "fail_open": must be "fail_closed"`,
+		},
+		{
+			name:    "raw audit mode",
+			content: "version: 1\naudit:\n  mode: audit_raw\n",
+			want:    `ERR_CONFIG_VALIDATION: audit.mode: unsupported value "audit_raw": must be "audit_redacted"`,
+		},
+		{
+			name:    "threshold out of range",
+			content: "version: 1\nscorer:\n  confidence_threshold: 1.5\n",
+			want:    "ERR_CONFIG_VALIDATION: scorer.confidence_threshold: must be > 0 and <= 1, got 1.5",
+		},
+		{
+			name:    "invalid timeout",
+			content: "version: 1\nprovider:\n  timeout: soon\n",
+			want:    "ERR_CONFIG_VALIDATION: provider.timeout:",
+		},
+		{
+			name:    "zero stream idle timeout",
+			content: "version: 1\nprovider:\n  stream_idle_timeout: 0s\n",
+			want:    "ERR_CONFIG_VALIDATION: provider.stream_idle_timeout: must be > 0",
+		},
+		{
+			name:    "retry without attempts",
+			content: "version: 1\nprovider:\n  retry:\n    max_attempts: 0\n",
+			want:    "ERR_CONFIG_VALIDATION: provider.retry.max_attempts: must be >= 1",
+		},
+		{
+			name:    "retry max delay below base delay",
+			content: "version: 1\nprovider:\n  retry:\n    base_delay: 2s\n    max_delay: 1s\n",
+			want:    "ERR_CONFIG_VALIDATION: provider.retry.max_delay: must be >= provider.retry.base_delay",
+		},
+		{
+			name:    "invalid retry budget",
+			content: "version: 1\nprovider:\n  retry:\n    budget: later\n",
+			want:    "ERR_CONFIG_VALIDATION: provider.retry.budget:",
+		},
+		{
+			name:    "audit retention without rotation",
+			content: "version: 1\naudit:\n  max_segment_bytes: 0\n  max_segment_age: 0s\n  retention: 720h\n",
+			want:    "ERR_CONFIG_VALIDATION: audit.retention: requires audit.max_segment_bytes or audit.max_segment_age",
+		},
+		{
+			name:    "invalid audit segment age",
+			content: "version: 1\naudit:\n  max_segment_age: daily\n",
+			want:    "ERR_CONFIG_VALIDATION: audit.max_segment_age:",
+		},
+		{
+			name:    "negative audit checkpoint count",
+			content: "version: 1\naudit:\n  checkpoint_every: -1\n",
+			want:    "ERR_CONFIG_VALIDATION: audit.checkpoint_every: must be >= 0",
+		},
+		{
+			name:    "unknown audit sync mode",
+			content: "version: 1\naudit:\n  sync: never\n",
+			want:    "ERR_CONFIG_VALIDATION: audit.sync: must be one of record, group, interval",
+		},
+		{
+			name:    "audit sync interval without a period",
+			content: "version: 1\naudit:\n  sync: interval\n  sync_interval: 0s\n",
+			want:    "ERR_CONFIG_VALIDATION: audit.sync_interval: must be > 0 when audit.sync is interval",
+		},
+		{
+			name:    "circuit breaker failure rate out of range",
+			content: "version: 1\nprovider:\n  circuit_breaker:\n    failure_rate: 1.5\n",
+			want:    "ERR_CONFIG_VALIDATION: provider.circuit_breaker.failure_rate: must be > 0 and <= 1",
+		},
+		{
+			name:    "circuit breaker min requests above window",
+			content: "version: 1\nprovider:\n  circuit_breaker:\n    window: 4\n    min_requests: 5\n",
+			want:    "ERR_CONFIG_VALIDATION: provider.circuit_breaker.min_requests:",
+		},
+		{
+			name:    "negative idempotency entries",
+			content: "version: 1\nidempotency:\n  max_entries: -1\n",
+			want:    "ERR_CONFIG_VALIDATION: idempotency.max_entries: must be >= 0",
+		},
+		{
+			name:    "zero idempotency ttl",
+			content: "version: 1\nidempotency:\n  ttl: 0s\n",
+			want:    "ERR_CONFIG_VALIDATION: idempotency.ttl: must be > 0",
+		},
+		{
+			name:    "unknown sanitizer entity",
+			content: "version: 1\nsanitizer:\n  entities: [EMAIL, PASSPORT]\n",
+			want:    "ERR_CONFIG_VALIDATION: sanitizer.entities:",
+		},
+		{
+			name:    "invalid custom rule regex",
+			content: "version: 1\nsanitizer:\n  custom_rules:\n    - entity_type: EMPLOYEE_ID\n      pattern: 'E(\\d{6}'\n      confidence: 0.9\n      surrogate_template: EMP-{n}\n",
+			want:    "ERR_CONFIG_VALIDATION: sanitizer.custom_rules[0].pattern: error parsing regexp",
+		},
+		{
+			name:    "custom rule missing confidence",
+			content: "version: 1\nsanitizer:\n  custom_rules:\n    - entity_type: EMPLOYEE_ID\n      pattern: 'E\\d{6}'\n      surrogate_template: EMP-{n}\n",
+			want:    "ERR_CONFIG_VALIDATION: sanitizer.custom_rules[0].confidence: is required",
+		},
+		{
+			name:    "custom rule unknown key",
+			content: "version: 1\nsanitizer:\n  custom_rules:\n    - entity_type: EMPLOYEE_ID\n      regex: 'E\\d{6}'\n",
+			want:    "ERR_CONFIG_VALIDATION: sanitizer.custom_rules[0].regex: unknown key (line 5)",
+		},
+		{
+			name:    "custom rule unknown validator",
+			content: "version: 1\nsanitizer:\n  custom_rules:\n    - entity_type: EMPLOYEE_ID\n      pattern: 'E\\d{6}'\n      confidence: 0.9\n      surrogate_template: EMP-{n}\n      validator: crc32\n",
+			want:    `ERR_CONFIG_VALIDATION: sanitizer.custom_rules[0].validator: unknown validator "crc32": must be one of aba, luhn, mod97, nino, verhoeff`,
+		},
+		{
+			name:    "custom rule duplicates built-in",
+			content: "version: 1\nsanitizer:\n  custom_rules:\n    - entity_type: EMAIL\n      pattern: 'x@y'\n      confidence: 0.9\n      surrogate_template: mail-{n}\n",
+			want:    `ERR_CONFIG_VALIDATION: sanitizer.custom_rules: duplicate entity type "EMAIL"`,
+		},
+		{
+			name:    "invalid entity action",
+			content: "version: 1\nrouting:\n  entity_actions:\n    SSN: allow\n",
+			want:    `ERR_CONFIG_VALIDATION: routing.entity_actions.SSN: invalid action "allow"`,
+		},
+		{
+			name:    "entity action for undetected entity",
+			content: "version: 1\nrouting:\n  entity_actions:\n    PASSPORT: block\n",
+			want:    `ERR_CONFIG_VALIDATION: routing.entity_actions.PASSPORT: no sanitizer rule detects entity type "PASSPORT"`,
+		},
+		{
+			name:    "provider chain with unknown category",
+			content: "version: 1\nprovider:\n  chain:\n    - mode: stub\n      categories: [Severe]\n",
+			want:    `ERR_CONFIG_VALIDATION: provider.chain[0].categories: invalid category "Severe"`,
+		},
+		{
+			name:    "provider chain with non-forwarding route",
+			content: "version: 1\nprovider:\n  chain:\n    - mode: stub\n      routes: [critical_local_only]\n",
+			want:    `ERR_CONFIG_VALIDATION: provider.chain[0].routes: invalid route "critical_local_only"`,
+		},
+		{
+			name:    "provider chain with duplicate mode",
+			content: "version: 1\nprovider:\n  chain:\n    - mode: stub\n    - mode: stub\n",
+			want:    `ERR_CONFIG_VALIDATION: provider.chain[1].mode: duplicate provider "stub"`,
+		},
+		{
+			name:    "provider chain missing endpoint settings",
+			content: "version: 1\nprovider:\n  chain:\n    - mode: stub\n    - mode: vllm_local\n",
+			want:    "ERR_CONFIG_VALIDATION: provider.vllm.base_url:",
+		},
+		{
+			name:    "risk weight for unknown entity",
+			content: "version: 1\nscorer:\n  weights:\n    PASSPORT: 40\n",
+			want:    `ERR_CONFIG_VALIDATION: scorer.weights.PASSPORT: no sanitizer rule detects entity type "PASSPORT"`,
+		},
+		{
+			name:    "repeat decay out of range",
+			content: "version: 1\nscorer:\n  repeat_decay: 1.5\n",
+			want:    "ERR_CONFIG_VALIDATION: scorer.repeat_decay: must be >= 0 and <= 1, got 1.5",
+		},
+		{
+			name:    "co-occurrence with a single entity",
+			content: "version: 1\nscorer:\n  co_occurrence:\n    - name: solo\n      entities: [SSN]\n      bonus: 10\n",
+			want:    "ERR_CONFIG_VALIDATION: scorer.co_occurrence[0].entities: must list at least two entity types",
+		},
+		{
+			name:    "negative context keyword bonus",
+			content: "version: 1\nscorer:\n  context_keywords:\n    salary: -5\n",
+			want:    "ERR_CONFIG_VALIDATION: scorer.context_keywords.salary: must be >= 0",
+		},
+		{
+			name:    "missing provider requirement",
+			content: "version: 1\nprovider:\n  mode: mimo_online\n",
+			want:    "ERR_CONFIG_VALIDATION: provider.mimo.base_url:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearStartupEnv(t)
+			_, err := loadStartupConfig(writeConfigFile(t, tt.content))
+			if err == nil {
+				t.Fatal("expected validation error")
+			}
+			if !strings.HasPrefix(err.Error(), tt.want) {
+				t.Fatalf("expected error starting with %q, got %q", tt.want, err.Error())
+			}
+		})
+	}
+}
+
+func TestLoadStartupConfigLoadsCustomSanitizerRules(t *testing.T) {
+	clearStartupEnv(t)
+	path := writeConfigFile(t, `
+version: 1
+sanitizer:
+  custom_rules:
+    - entity_type: EMPLOYEE_ID
+      pattern: '\bE\d{6}\b'
+      confidence: 0.95
+      surrogate_template: EMP-{n}
+    - entity_type: CUSTOMER_ACCOUNT
+      pattern: '\bACCT-\d{8}\b'
+      confidence: 0.9
+      surrogate_template: account-{n}
+      hard_block: true
+    - entity_type: LOYALTY_CARD
+      pattern: '\bL\d{16}\b'
+      confidence: 0.9
+      surrogate_template: loyalty-{n}
+      validator: luhn
+`)
+
+	cfg, err := loadStartupConfig(path)
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error: %v", err)
+	}
+	if len(cfg.CustomRules) != 3 || !cfg.CustomRules[1].HardBlock || cfg.CustomRules[2].Validator == nil {
+		t.Fatalf("unexpected custom rules: %+v", cfg.CustomRules)
+	}
+
+	s, err := sanitizerFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("sanitizerFromConfig returned error: %v", err)
+	}
+	result, err := s.Sanitize("E123456 opened ACCT-12345678 for alice@example.com with L4111111111111111, not L4111111111111112")
+	if err != nil {
+		t.Fatalf("sanitize failed: %v", err)
+	}
+	if result.Sanitized != "EMP-1 opened account-1 for person1@example.net with loyalty-1, not L4111111111111112" {
+		t.Fatalf("unexpected sanitized output %q", result.Sanitized)
+	}
+}
+
+func TestLoadStartupConfigReportsMissingFile(t *testing.T) {
+	clearStartupEnv(t)
+
+	_, err := loadStartupConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err == nil || !strings.HasPrefix(err.Error(), "ERR_CONFIG_VALIDATION: config:") {
+		t.Fatalf("expected config read error, got %v", err)
+	}
+}
+
+func TestExampleConfigFileLoads(t *testing.T) {
+	clearStartupEnv(t)
+
+	cfg, err := loadStartupConfig(filepath.Join("..", "..", "lpg.example.yaml"))
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error for example file: %v", err)
+	}
+	if _, err := sanitizerFromConfig(cfg); err != nil {
+		t.Fatalf("sanitizerFromConfig returned error for example file: %v", err)
+	}
+}
+
+func TestLoadStartupConfigParsesEntityActions(t *testing.T) {
+	clearStartupEnv(t)
+	path := writeConfigFile(t, `
+version: 1
+routing:
+  entity_actions:
+    SSN: block
+    EMAIL: escalate_one_band
+sanitizer:
+  custom_rules:
+    - entity_type: EMPLOYEE_ID
+      pattern: '\bE\d{6}\b'
+      confidence: 0.95
+      surrogate_template: EMP-{n}
+`)
+
+	cfg, err := loadStartupConfig(path)
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error: %v", err)
+	}
+	if cfg.EntityActions["SSN"] != router.ActionBlock || cfg.EntityActions["EMAIL"] != router.ActionEscalateOneBand {
+		t.Fatalf("unexpected entity actions from file: %v", cfg.EntityActions)
+	}
+
+	t.Setenv("LPG_ENTITY_ACTIONS", "EMPLOYEE_ID:force_local_only, PHONE:mask_only")
+	cfg, err = loadStartupConfig(path)
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error: %v", err)
+	}
+	if len(cfg.EntityActions) != 2 || cfg.EntityActions["EMPLOYEE_ID"] != router.ActionForceLocalOnly || cfg.EntityActions["PHONE"] != router.ActionMaskOnly {
+		t.Fatalf("expected env to replace entity actions, got %v", cfg.EntityActions)
+	}
+
+	t.Setenv("LPG_ENTITY_ACTIONS", "SSN")
+	if _, err := loadStartupConfig(path); err == nil || !strings.HasPrefix(err.Error(), "ERR_CONFIG_VALIDATION: LPG_ENTITY_ACTIONS:") {
+		t.Fatalf("expected LPG_ENTITY_ACTIONS validation error, got %v", err)
+	}
+}
+
+func TestLoadStartupConfigParsesRiskModel(t *testing.T) {
+	clearStartupEnv(t)
+	path := writeConfigFile(t, `
+version: 1
+scorer:
+  default_weight: 20
+  weights:
+    SSN: 45
+    EMAIL: 10
+  repeat_decay: 0.5
+  co_occurrence:
+    - name: identity_bundle
+      entities: [SSN, PHONE]
+      bonus: 15
+  context_keywords:
+    diagnosis: 20
+`)
+
+	cfg, err := loadStartupConfig(path)
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error: %v", err)
+	}
+	model := cfg.RiskModel
+	if model.DefaultWeight != 20 || model.Weights["SSN"] != 45 || model.Weights["EMAIL"] != 10 || model.RepeatDecay != 0.5 {
+		t.Fatalf("unexpected risk model weights: %+v", model)
+	}
+	if len(model.CoOccurrences) != 1 || model.CoOccurrences[0].Name != "identity_bundle" || model.CoOccurrences[0].Bonus != 15 {
+		t.Fatalf("unexpected co-occurrence bonuses: %+v", model.CoOccurrences)
+	}
+	if model.ContextKeywords["diagnosis"] != 20 {
+		t.Fatalf("unexpected context keywords: %+v", model.ContextKeywords)
+	}
+}
+
+func TestLoadStartupConfigParsesProviderChain(t *testing.T) {
+	clearStartupEnv(t)
+	path := writeConfigFile(t, `
+version: 1
+provider:
+  mode: stub
+  vllm:
+    base_url: http://127.0.0.1:8000
+  mimo:
+    base_url: https://mimo.example.invalid
+    api_key: test-key
+  chain:
+    - mode: mimo_online
+      routes: [sanitized_forward, high_abstraction]
+      categories: [Low, Medium]
+    - mode: vllm_local
+`)
+
+	cfg, err := loadStartupConfig(path)
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error: %v", err)
+	}
+	chain := cfg.providerChain()
+	if len(chain) != 2 {
+		t.Fatalf("expected two chain entries, got %+v", chain)
+	}
+	if chain[0].Mode != providerMimoOnline || chain[0].Local || len(chain[0].Routes) != 2 || len(chain[0].Categories) != 2 {
+		t.Fatalf("unexpected first chain entry: %+v", chain[0])
+	}
+	if chain[1].Mode != providerVLLMLocal || !chain[1].Local || len(chain[1].Routes) != 0 {
+		t.Fatalf("unexpected second chain entry: %+v", chain[1])
+	}
+
+	providers, err := providersFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("providersFromConfig returned error: %v", err)
+	}
+	if providerChainNames(providers) != "mimo_online>vllm_local" {
+		t.Fatalf("unexpected provider order %q", providerChainNames(providers))
+	}
+
+	t.Setenv("LPG_PROVIDER_CHAIN", "vllm_local")
+	cfg, err = loadStartupConfig(path)
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error: %v", err)
+	}
+	if len(cfg.ProviderChain) != 1 || cfg.ProviderChain[0].Mode != providerVLLMLocal {
+		t.Fatalf("expected env to replace provider chain, got %+v", cfg.ProviderChain)
+	}
+}
+
+func TestUserPseudonymKeyFromConfig(t *testing.T) {
+	clearStartupEnv(t)
+	dir := t.TempDir()
+	short := filepath.Join(dir, "short.key")
+	long := filepath.Join(dir, "user.key")
+	if err := os.WriteFile(short, []byte("too short\n"), 0o600); err != nil {
+		t.Fatalf("write key failed: %v", err)
+	}
+	if err := os.WriteFile(long, []byte(strings.Repeat("u", 48)+"\n"), 0o600); err != nil {
+		t.Fatalf("write key failed: %v", err)
+	}
+
+	cfg, err := loadStartupConfig(writeConfigFile(t, "version: 1\nrouting:\n  user_pseudonym_key: "+short+"\n"))
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error: %v", err)
+	}
+	if _, err := userPseudonymKeyFromConfig(cfg); err == nil {
+		t.Fatal("expected a short user pseudonym key to be rejected")
+	}
+
+	t.Setenv("LPG_USER_PSEUDONYM_KEY", long)
+	cfg, err = loadStartupConfig(writeConfigFile(t, "version: 1\nrouting:\n  user_pseudonym_key: "+short+"\n"))
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error: %v", err)
+	}
+	key, err := userPseudonymKeyFromConfig(cfg)
+	if err != nil || len(key) != 48 {
+		t.Fatalf("expected the env key file to win and load trimmed, got %d bytes, %v", len(key), err)
+	}
+}
+
+func TestLoadStartupConfigParsesRetryPolicy(t *testing.T) {
+	clearStartupEnv(t)
+	path := writeConfigFile(t, `
+version: 1
+provider:
+  retry:
+    max_attempts: 4
+    base_delay: 50ms
+    max_delay: 1s
+    budget: 0s
+`)
+	t.Setenv("LPG_RETRY_MAX_DELAY", "3s")
+
+	cfg, err := loadStartupConfig(path)
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error: %v", err)
+	}
+	if cfg.RetryMaxAttempts != 4 || cfg.RetryBaseDelay != 50*time.Millisecond || cfg.RetryMaxDelay != 3*time.Second || cfg.RetryBudget != 0 {
+		t.Fatalf("unexpected retry settings: %d %s %s %s", cfg.RetryMaxAttempts, cfg.RetryBaseDelay, cfg.RetryMaxDelay, cfg.RetryBudget)
+	}
+}
+
+func TestLoadStartupConfigParsesIdempotencyCache(t *testing.T) {
+	clearStartupEnv(t)
+	path := writeConfigFile(t, `
+version: 1
+idempotency:
+  ttl: 30m
+  max_entries: 64
+`)
+
+	cfg, err := loadStartupConfig(path)
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error: %v", err)
+	}
+	if cfg.IdempotencyTTL != 30*time.Minute || cfg.IdempotencyMaxEntries != 64 {
+		t.Fatalf("unexpected idempotency settings: %s %d", cfg.IdempotencyTTL, cfg.IdempotencyMaxEntries)
+	}
+	cache, err := idempotencyFromConfig(cfg)
+	if err != nil || cache == nil {
+		t.Fatalf("expected an idempotency cache, got %v %v", cache, err)
+	}
+
+	t.Setenv("LPG_IDEMPOTENCY_MAX_ENTRIES", "0")
+	cfg, err = loadStartupConfig(path)
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error: %v", err)
+	}
+	if cache, err := idempotencyFromConfig(cfg); err != nil || cache != nil {
+		t.Fatalf("expected max_entries 0 to disable the cache, got %v %v", cache, err)
+	}
+}
+
+func TestLoadStartupConfigParsesAuditRotation(t *testing.T) {
+	clearStartupEnv(t)
+	cfg, err := loadStartupConfig(writeConfigFile(t, "version: 1\n"))
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error: %v", err)
+	}
+	if cfg.AuditRetention != 30*24*time.Hour {
+		t.Fatalf("expected 30-day audit retention by default, got %s", cfg.AuditRetention)
+	}
+
+	path := writeConfigFile(t, `
+version: 1
+audit:
+  max_segment_bytes: 1048576
+  max_segment_age: 1h
+  retention: 0s
+`)
+	t.Setenv("LPG_AUDIT_MAX_SEGMENT_AGE", "6h")
+
+	cfg, err = loadStartupConfig(path)
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error: %v", err)
+	}
+	if cfg.AuditMaxSegmentBytes != 1<<20 || cfg.AuditMaxSegmentAge != 6*time.Hour || cfg.AuditRetention != 0 {
+		t.Fatalf("unexpected audit rotation settings: %d %s %s", cfg.AuditMaxSegmentBytes, cfg.AuditMaxSegmentAge, cfg.AuditRetention)
+	}
+}
+
+func TestLoadStartupConfigParsesAuditSigning(t *testing.T) {
+	clearStartupEnv(t)
+	path := writeConfigFile(t, `
+version: 1
+audit:
+  signing_key: /etc/lpg/audit.key
+  checkpoint_every: 50
+  checkpoint_interval: 10m
+`)
+	t.Setenv("LPG_AUDIT_CHECKPOINT_EVERY", "0")
+
+	cfg, err := loadStartupConfig(path)
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error: %v", err)
+	}
+	if cfg.AuditSigningKeyPath != "/etc/lpg/audit.key" || cfg.AuditCheckpointEvery != 0 || cfg.AuditCheckpointInterval != 10*time.Minute {
+		t.Fatalf("unexpected audit signing settings: %q %d %s", cfg.AuditSigningKeyPath, cfg.AuditCheckpointEvery, cfg.AuditCheckpointInterval)
+	}
+	if _, err := auditWriterFromConfig(cfg); err == nil || !strings.Contains(err.Error(), "audit signing key") {
+		t.Fatalf("expected a missing signing key to fail startup, got %v", err)
+	}
+}
+
+func TestLoadStartupConfigParsesAuditSync(t *testing.T) {
+	clearStartupEnv(t)
+	cfg, err := loadStartupConfig(writeConfigFile(t, "version: 1\n"))
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error: %v", err)
+	}
+	if cfg.AuditSync != "group" {
+		t.Fatalf("expected group commit by default, got %q", cfg.AuditSync)
+	}
+
+	path := writeConfigFile(t, `
+version: 1
+audit:
+  sync: record
+  sync_interval: 5s
+`)
+	t.Setenv("LPG_AUDIT_SYNC", "interval")
+
+	cfg, err = loadStartupConfig(path)
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error: %v", err)
+	}
+	if cfg.AuditSync != "interval" || cfg.AuditSyncInterval != 5*time.Second {
+		t.Fatalf("unexpected audit sync settings: %q %s", cfg.AuditSync, cfg.AuditSyncInterval)
+	}
+}
+
+func TestEntityHashKeyFromConfig(t *testing.T) {
+	clearStartupEnv(t)
+	dir := t.TempDir()
+	short := filepath.Join(dir, "short.key")
+	long := filepath.Join(dir, "entity.key")
+	if err := os.WriteFile(short, []byte("too short\n"), 0o600); err != nil {
+		t.Fatalf("write key failed: %v", err)
+	}
+	if err := os.WriteFile(long, []byte(strings.Repeat("k", 64)+"\n"), 0o600); err != nil {
+		t.Fatalf("write key failed: %v", err)
+	}
+
+	cfg, err := loadStartupConfig(writeConfigFile(t, "version: 1\naudit:\n  entity_hash_key: "+short+"\n"))
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error: %v", err)
+	}
+	if _, err := entityHashKeyFromConfig(cfg); err == nil {
+		t.Fatal("expected a short entity hash key to be rejected")
+	}
+
+	t.Setenv("LPG_AUDIT_ENTITY_HASH_KEY", long)
+	cfg, err = loadStartupConfig(writeConfigFile(t, "version: 1\naudit:\n  entity_hash_key: "+short+"\n"))
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error: %v", err)
+	}
+	key, err := entityHashKeyFromConfig(cfg)
+	if err != nil || len(key) != 64 {
+		t.Fatalf("expected the env key file to win and load trimmed, got %d bytes, %v", len(key), err)
+	}
+}
+
+func TestLoadStartupConfigParsesCircuitBreaker(t *testing.T) {
+	clearStartupEnv(t)
+	path := writeConfigFile(t, `
+version: 1
+provider:
+  circuit_breaker:
+    failure_rate: 0.25
+    min_requests: 2
+    window: 8
+    open_duration: 10s
+    probe_interval: 0s
+`)
+	t.Setenv("LPG_CIRCUIT_BREAKER_OPEN_DURATION", "1m")
+
+	cfg, err := loadStartupConfig(path)
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error: %v", err)
+	}
+	if !cfg.BreakerEnabled || cfg.BreakerFailureRate != 0.25 || cfg.BreakerMinRequests != 2 || cfg.BreakerWindow != 8 || cfg.BreakerOpenDuration != time.Minute || cfg.BreakerProbeInterval != 0 {
+		t.Fatalf("unexpected circuit breaker settings: %+v", cfg)
+	}
+	providers, err := providersFromConfig(cfg)
+	if err != nil || providers[0].Breaker == nil {
+		t.Fatalf("expected the provider to get a circuit breaker, got %+v %v", providers, err)
+	}
+
+	t.Setenv("LPG_CIRCUIT_BREAKER_ENABLED", "false")
+	cfg, err = loadStartupConfig(path)
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error: %v", err)
+	}
+	if providers, err := providersFromConfig(cfg); err != nil || providers[0].Breaker != nil {
+		t.Fatalf("expected no circuit breaker when disabled, got %+v %v", providers, err)
+	}
+}
+
+func TestProviderChainDefaultsToSingleProvider(t *testing.T) {
+	clearStartupEnv(t)
+	cfg, err := loadStartupConfig(writeConfigFile(t, "version: 1\n"))
+	if err != nil {
+		t.Fatalf("loadStartupConfig returned error: %v", err)
+	}
+	chain := cfg.providerChain()
+	if len(chain) != 1 || chain[0].Mode != providerStub || !chain[0].Local {
+		t.Fatalf("expected single local stub provider, got %+v", chain)
+	}
+}
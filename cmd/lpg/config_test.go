@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/soloengine/lpg/internal/proxy"
+	"github.com/soloengine/lpg/internal/router"
 )
 
 func TestLoadStartupConfigFromEnvDefaults(t *testing.T) {
@@ -64,11 +65,15 @@ func TestLoadStartupConfigFromEnvDefaults(t *testing.T) {
 	if cfg.CriticalLocalOnly {
 		t.Fatal("expected critical local-only to default false")
 	}
+	if len(cfg.RehydrateRoutes) != 1 || cfg.RehydrateRoutes[0] != router.RouteSanitizedForward {
+		t.Fatalf("expected default rehydrate routes [%s], got %v", router.RouteSanitizedForward, cfg.RehydrateRoutes)
+	}
 }
 
 func TestLoadStartupConfigFromEnvParsesTimeoutAndProvider(t *testing.T) {
 	t.Setenv("LPG_PROVIDER", "vLLM_local")
 	t.Setenv("LPG_PROVIDER_TIMEOUT", "1500ms")
+	t.Setenv("LPG_STREAM_IDLE_TIMEOUT", "45s")
 	t.Setenv("LPG_VLLM_BASE_URL", "http://127.0.0.1:8000")
 	t.Setenv("LPG_VLLM_MODEL", "local-model")
 
@@ -83,6 +88,9 @@ func TestLoadStartupConfigFromEnvParsesTimeoutAndProvider(t *testing.T) {
 	if cfg.ProviderTimeout != 1500*time.Millisecond {
 		t.Fatalf("expected timeout %s, got %s", 1500*time.Millisecond, cfg.ProviderTimeout)
 	}
+	if cfg.StreamIdleTimeout != 45*time.Second {
+		t.Fatalf("expected stream idle timeout 45s, got %s", cfg.StreamIdleTimeout)
+	}
 	if cfg.VLLMBaseURL != "http://127.0.0.1:8000" {
 		t.Fatalf("unexpected vLLM base URL %q", cfg.VLLMBaseURL)
 	}
@@ -120,6 +128,77 @@ func TestLoadStartupConfigFromEnvRejectsInvalidRoutingFlags(t *testing.T) {
 	}
 }
 
+func TestLoadStartupConfigFromEnvParsesRehydrateRoutes(t *testing.T) {
+	t.Setenv("LPG_REHYDRATE_ROUTES", "sanitized_forward, critical_local_only")
+
+	cfg, err := loadStartupConfigFromEnv()
+	if err != nil {
+		t.Fatalf("loadStartupConfigFromEnv returned error: %v", err)
+	}
+	if len(cfg.RehydrateRoutes) != 2 || cfg.RehydrateRoutes[0] != router.RouteSanitizedForward || cfg.RehydrateRoutes[1] != router.RouteCriticalLocalOnly {
+		t.Fatalf("unexpected rehydrate routes %v", cfg.RehydrateRoutes)
+	}
+
+	t.Setenv("LPG_REHYDRATE_ROUTES", "none")
+	cfg, err = loadStartupConfigFromEnv()
+	if err != nil {
+		t.Fatalf("loadStartupConfigFromEnv returned error: %v", err)
+	}
+	if len(cfg.RehydrateRoutes) != 0 {
+		t.Fatalf("expected rehydration disabled, got %v", cfg.RehydrateRoutes)
+	}
+
+	t.Setenv("LPG_REHYDRATE_ROUTES", "critical_blocked")
+	if _, err := loadStartupConfigFromEnv(); err == nil {
+		t.Fatal("expected error for invalid LPG_REHYDRATE_ROUTES")
+	}
+}
+
+func TestLoadStartupConfigFromEnvParsesRehydrateTools(t *testing.T) {
+	t.Setenv("LPG_REHYDRATE_TOOLS", "send_email, ,lookup_customer")
+
+	cfg, err := loadStartupConfigFromEnv()
+	if err != nil {
+		t.Fatalf("loadStartupConfigFromEnv returned error: %v", err)
+	}
+	if len(cfg.RehydrateTools) != 2 || cfg.RehydrateTools[0] != "send_email" || cfg.RehydrateTools[1] != "lookup_customer" {
+		t.Fatalf("unexpected rehydrate tools %v", cfg.RehydrateTools)
+	}
+}
+
+func TestLoadStartupConfigFromEnvParsesModelAllowlist(t *testing.T) {
+	t.Setenv("LPG_MODEL_ALLOWLIST", "gpt-4o-mini, qwen2.5:3b,")
+	t.Setenv("LPG_UPSTREAM_MODELS_PATH", "/custom/models")
+
+	cfg, err := loadStartupConfigFromEnv()
+	if err != nil {
+		t.Fatalf("loadStartupConfigFromEnv returned error: %v", err)
+	}
+	if len(cfg.ModelAllowlist) != 2 || cfg.ModelAllowlist[0] != "gpt-4o-mini" || cfg.ModelAllowlist[1] != "qwen2.5:3b" {
+		t.Fatalf("unexpected model allowlist %v", cfg.ModelAllowlist)
+	}
+	if cfg.UpstreamModelsPath != "/custom/models" {
+		t.Fatalf("unexpected upstream models path %q", cfg.UpstreamModelsPath)
+	}
+}
+
+func TestLoadStartupConfigFromEnvParsesImagePolicy(t *testing.T) {
+	t.Setenv("LPG_IMAGE_POLICY", "sanitized_forward:allow, high_abstraction:strip")
+
+	cfg, err := loadStartupConfigFromEnv()
+	if err != nil {
+		t.Fatalf("loadStartupConfigFromEnv returned error: %v", err)
+	}
+	if len(cfg.ImagePolicies) != 2 || cfg.ImagePolicies[router.RouteSanitizedForward] != proxy.ImagePolicyAllow || cfg.ImagePolicies[router.RouteHighAbstraction] != proxy.ImagePolicyStrip {
+		t.Fatalf("unexpected image policies %v", cfg.ImagePolicies)
+	}
+
+	t.Setenv("LPG_IMAGE_POLICY", "critical_local_only:allow")
+	if _, err := loadStartupConfigFromEnv(); err == nil {
+		t.Fatal("expected allow on critical_local_only to be rejected")
+	}
+}
+
 func TestLoadStartupConfigFromEnvRejectsInvalidProvider(t *testing.T) {
 	t.Setenv("LPG_PROVIDER", "not-a-provider")
 
@@ -144,6 +223,25 @@ func TestLoadStartupConfigFromEnvRejectsNonPositiveTimeout(t *testing.T) {
 	}
 }
 
+func TestLoadStartupConfigFromEnvParsesRetryPolicy(t *testing.T) {
+	t.Setenv("LPG_RETRY_MAX_ATTEMPTS", "3")
+	t.Setenv("LPG_RETRY_BASE_DELAY", "100ms")
+	t.Setenv("LPG_RETRY_BUDGET", "5s")
+
+	cfg, err := loadStartupConfigFromEnv()
+	if err != nil {
+		t.Fatalf("loadStartupConfigFromEnv returned error: %v", err)
+	}
+	if cfg.RetryMaxAttempts != 3 || cfg.RetryBaseDelay != 100*time.Millisecond || cfg.RetryMaxDelay != defaultRetryMaxDelay || cfg.RetryBudget != 5*time.Second {
+		t.Fatalf("unexpected retry settings: %d %s %s %s", cfg.RetryMaxAttempts, cfg.RetryBaseDelay, cfg.RetryMaxDelay, cfg.RetryBudget)
+	}
+
+	t.Setenv("LPG_RETRY_MAX_ATTEMPTS", "twice")
+	if _, err := loadStartupConfigFromEnv(); err == nil {
+		t.Fatal("expected error for invalid LPG_RETRY_MAX_ATTEMPTS")
+	}
+}
+
 func TestLoadStartupConfigFromEnvRequiresVLLMBaseURL(t *testing.T) {
 	t.Setenv("LPG_PROVIDER", string(providerVLLMLocal))
 	t.Setenv("LPG_VLLM_BASE_URL", "")
@@ -195,6 +293,7 @@ func TestLoadStartupConfigFromEnvSupportsOpenAICompatibleProvider(t *testing.T)
 	t.Setenv("LPG_UPSTREAM_API_KEY_HEADER", "X-API-Key")
 	t.Setenv("LPG_UPSTREAM_API_KEY_PREFIX", "Token")
 	t.Setenv("LPG_UPSTREAM_CHAT_PATH", "/custom/chat")
+	t.Setenv("LPG_UPSTREAM_RESPONSES_PATH", "/custom/responses")
 
 	cfg, err := loadStartupConfigFromEnv()
 	if err != nil {
@@ -222,6 +321,9 @@ func TestLoadStartupConfigFromEnvSupportsOpenAICompatibleProvider(t *testing.T)
 	if cfg.UpstreamChatPath != "/custom/chat" {
 		t.Fatalf("unexpected upstream chat path %q", cfg.UpstreamChatPath)
 	}
+	if cfg.UpstreamResponsesPath != "/custom/responses" {
+		t.Fatalf("unexpected upstream responses path %q", cfg.UpstreamResponsesPath)
+	}
 }
 
 func TestLoadStartupConfigFromEnvRequiresOpenAICompatibleBaseURL(t *testing.T) {
@@ -301,6 +403,36 @@ func TestLoadStartupConfigFromEnvRequiresLocalAbstractionBaseURLWhenModelSet(t *
 	}
 }
 
+func TestLoadStartupConfigFromEnvSupportsLocalEmbeddingsSettings(t *testing.T) {
+	t.Setenv("LPG_UPSTREAM_EMBEDDINGS_PATH", "/custom/embeddings")
+	t.Setenv("LPG_LOCAL_EMBEDDINGS_BASE_URL", "http://127.0.0.1:8082")
+	t.Setenv("LPG_LOCAL_EMBEDDINGS_MODEL", "nomic-embed-text")
+	t.Setenv("LPG_LOCAL_EMBEDDINGS_PATH", "/local/embeddings")
+
+	cfg, err := loadStartupConfigFromEnv()
+	if err != nil {
+		t.Fatalf("loadStartupConfigFromEnv returned error: %v", err)
+	}
+	if cfg.UpstreamEmbeddingsPath != "/custom/embeddings" {
+		t.Fatalf("unexpected upstream embeddings path %q", cfg.UpstreamEmbeddingsPath)
+	}
+	if cfg.LocalEmbeddingsBaseURL != "http://127.0.0.1:8082" || cfg.LocalEmbeddingsModel != "nomic-embed-text" || cfg.LocalEmbeddingsPath != "/local/embeddings" {
+		t.Fatalf("unexpected local embeddings settings %+v", cfg)
+	}
+	if cfg.LocalEmbeddingsAPIKeyHeader != "Authorization" || cfg.LocalEmbeddingsAPIKeyPrefix != "Bearer" {
+		t.Fatalf("expected default local embeddings auth header, got %q %q", cfg.LocalEmbeddingsAPIKeyHeader, cfg.LocalEmbeddingsAPIKeyPrefix)
+	}
+}
+
+func TestLoadStartupConfigFromEnvRequiresLocalEmbeddingsBaseURLWhenModelSet(t *testing.T) {
+	t.Setenv("LPG_LOCAL_EMBEDDINGS_BASE_URL", "")
+	t.Setenv("LPG_LOCAL_EMBEDDINGS_MODEL", "nomic-embed-text")
+
+	if _, err := loadStartupConfigFromEnv(); err == nil {
+		t.Fatal("expected error when local embeddings model is set without base URL")
+	}
+}
+
 func TestUpstreamFromConfigReturnsExpectedAdapter(t *testing.T) {
 	vllmCfg := startupConfig{
 		Provider:             providerVLLMLocal,
@@ -311,7 +443,7 @@ func TestUpstreamFromConfigReturnsExpectedAdapter(t *testing.T) {
 		UpstreamAPIKeyPrefix: defaultUpstreamAPIKeyPrefix,
 		UpstreamChatPath:     defaultUpstreamChatPath,
 	}
-	upstream, err := upstreamFromConfig(vllmCfg)
+	upstream, err := upstreamFromConfig(vllmCfg, vllmCfg.Provider)
 	if err != nil {
 		t.Fatalf("upstreamFromConfig returned error for vLLM: %v", err)
 	}
@@ -329,7 +461,7 @@ func TestUpstreamFromConfigReturnsExpectedAdapter(t *testing.T) {
 		UpstreamAPIKeyPrefix: defaultUpstreamAPIKeyPrefix,
 		UpstreamChatPath:     defaultUpstreamChatPath,
 	}
-	upstream, err = upstreamFromConfig(mimoCfg)
+	upstream, err = upstreamFromConfig(mimoCfg, mimoCfg.Provider)
 	if err != nil {
 		t.Fatalf("upstreamFromConfig returned error for mimo: %v", err)
 	}
@@ -347,7 +479,7 @@ func TestUpstreamFromConfigReturnsExpectedAdapter(t *testing.T) {
 		UpstreamChatPath:     "/custom/chat",
 		ProviderTimeout:      defaultProviderTimeout,
 	}
-	upstream, err = upstreamFromConfig(openAICfg)
+	upstream, err = upstreamFromConfig(openAICfg, openAICfg.Provider)
 	if err != nil {
 		t.Fatalf("upstreamFromConfig returned error for openai compatible: %v", err)
 	}
@@ -363,7 +495,7 @@ func TestUpstreamFromConfigReturnsExpectedAdapter(t *testing.T) {
 		UpstreamAPIKeyPrefix: defaultUpstreamAPIKeyPrefix,
 		UpstreamChatPath:     defaultUpstreamChatPath,
 	}
-	upstream, err = upstreamFromConfig(stubCfg)
+	upstream, err = upstreamFromConfig(stubCfg, stubCfg.Provider)
 	if err != nil {
 		t.Fatalf("upstreamFromConfig returned error for stub: %v", err)
 	}
@@ -373,7 +505,7 @@ func TestUpstreamFromConfigReturnsExpectedAdapter(t *testing.T) {
 }
 
 func TestUpstreamFromConfigRejectsUnsupportedProvider(t *testing.T) {
-	_, err := upstreamFromConfig(startupConfig{Provider: providerMode("bad")})
+	_, err := upstreamFromConfig(startupConfig{}, providerMode("bad"))
 	if err == nil {
 		t.Fatal("expected error for unsupported provider")
 	}
@@ -414,3 +546,26 @@ func TestAbstractorFromConfigReturnsErrorForInvalidLocalAbstractionConfig(t *tes
 		t.Fatal("expected error when local abstraction model is missing")
 	}
 }
+
+func TestEmbeddingsFromConfigPrefersConfiguredLocalModel(t *testing.T) {
+	providers := []proxy.Provider{{Name: "stub", Adapter: proxy.StubUpstream{}, Local: true}}
+
+	local, err := localEmbeddingsFromConfig(startupConfig{}, providers)
+	if err != nil {
+		t.Fatalf("localEmbeddingsFromConfig returned error: %v", err)
+	}
+	if _, ok := local.(proxy.StubUpstream); !ok {
+		t.Fatalf("expected local stub to serve local embeddings, got %T", local)
+	}
+
+	local, err = localEmbeddingsFromConfig(startupConfig{
+		LocalEmbeddingsBaseURL: "http://127.0.0.1:8082",
+		LocalEmbeddingsModel:   "nomic-embed-text",
+	}, providers)
+	if err != nil {
+		t.Fatalf("localEmbeddingsFromConfig returned error: %v", err)
+	}
+	if _, ok := local.(*proxy.OpenAICompatibleEmbeddings); !ok {
+		t.Fatalf("expected *proxy.OpenAICompatibleEmbeddings, got %T", local)
+	}
+}
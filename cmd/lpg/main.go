@@ -1,29 +1,45 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/soloengine/lpg/internal/audit"
 	"github.com/soloengine/lpg/internal/proxy"
+	"github.com/soloengine/lpg/internal/rehydrate"
 	"github.com/soloengine/lpg/internal/risk"
 	"github.com/soloengine/lpg/internal/router"
-	"github.com/soloengine/lpg/internal/sanitizer"
 )
 
 func main() {
-	cfg, err := loadStartupConfigFromEnv()
+	if len(os.Args) > 1 && os.Args[1] == "audit" {
+		os.Exit(runAudit(context.Background(), os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	configPath := flag.String("config", "", "path to a YAML configuration file; environment variables override its values")
+	flag.Parse()
+
+	cfg, err := loadStartupConfig(*configPath)
 	if err != nil {
 		log.Fatalf("invalid startup configuration: %v", err)
 	}
 
-	chainWriter, err := audit.NewChainWriter(cfg.AuditPath)
+	chainWriter, err := auditWriterFromConfig(cfg)
 	if err != nil {
 		log.Fatalf("failed to initialize audit writer: %v", err)
 	}
 
-	upstream, err := upstreamFromConfig(cfg)
+	providers, err := providersFromConfig(cfg)
 	if err != nil {
 		log.Fatalf("failed to initialize upstream provider: %v", err)
 	}
@@ -33,30 +49,205 @@ func main() {
 		log.Fatalf("failed to initialize local abstraction provider: %v", err)
 	}
 
+	localEmbeddings, err := localEmbeddingsFromConfig(cfg, providers)
+	if err != nil {
+		log.Fatalf("failed to initialize local embeddings provider: %v", err)
+	}
+
+	sanitizerEngine, err := sanitizerFromConfig(cfg)
+	if err != nil {
+		log.Fatalf("failed to initialize sanitizer: %v", err)
+	}
+
+	idempotency, err := idempotencyFromConfig(cfg)
+	if err != nil {
+		log.Fatalf("failed to initialize idempotency cache: %v", err)
+	}
+
+	entityKey, err := entityHashKeyFromConfig(cfg)
+	if err != nil {
+		log.Fatalf("failed to load audit entity hash key: %v", err)
+	}
+
+	userKey, err := userPseudonymKeyFromConfig(cfg)
+	if err != nil {
+		log.Fatalf("failed to load user pseudonym key: %v", err)
+	}
+
 	handler := proxy.NewHandler(proxy.HandlerConfig{
-		Sanitizer:       sanitizer.NewDefault(),
-		Scorer:          risk.NewScorer(0.70),
+		Sanitizer:       sanitizerEngine,
+		Scorer:          risk.NewScorerWithModel(cfg.ConfidenceThreshold, cfg.RiskModel),
 		Router:          router.NewEngineWithCriticalLocalOnly(cfg.AllowRawForwarding, cfg.CriticalLocalOnly),
-		Upstream:        upstream,
+		Providers:       providers,
+		LocalEmbeddings: localEmbeddings,
 		Abstractor:      abstractor,
 		Audit:           chainWriter,
-		PolicyVersion:   "v2.1-phase1",
+		Rehydrator:      rehydrate.NewGuardWithPatterns(sanitizerEngine, cfg.RehydrateRoutes...),
+		EntityPolicies:  cfg.EntityActions,
+		RehydrateTools:  cfg.RehydrateTools,
+		ModelAllowlist:  cfg.ModelAllowlist,
+		ImagePolicies:   cfg.ImagePolicies,
+		PolicyVersion:   cfg.PolicyVersion,
 		ProviderTimeout: cfg.ProviderTimeout,
+		Retry: proxy.RetryPolicy{
+			MaxAttempts: cfg.RetryMaxAttempts,
+			BaseDelay:   cfg.RetryBaseDelay,
+			MaxDelay:    cfg.RetryMaxDelay,
+			Budget:      cfg.RetryBudget,
+		},
+		StreamIdleTimeout: cfg.StreamIdleTimeout,
+		Idempotency:       idempotency,
+		UserPseudonymKey:  userKey,
+		AuditEntityKey:    entityKey,
+		StrictAudit:       cfg.StrictAudit,
 	})
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/v1/chat/completions", handler.HandleChatCompletions)
+	mux.HandleFunc("/v1/responses", handler.HandleResponses)
+	mux.HandleFunc("/v1/embeddings", handler.HandleEmbeddings)
+	mux.HandleFunc("/v1/models", handler.HandleModels)
 	mux.HandleFunc("/v1/debug/explain", handler.HandleDebugExplain)
 
 	addr := "127.0.0.1:8080"
-	log.Printf("lpg proxy listening on %s (provider=%s raw_forward=%t critical_local_only=%t local_abstractor=%t)", addr, cfg.Provider, cfg.AllowRawForwarding, cfg.CriticalLocalOnly, cfg.LocalAbstractionBaseURL != "")
-	if err := http.ListenAndServe(addr, mux); err != nil {
+	log.Printf("lpg proxy listening on %s (providers=%s raw_forward=%t critical_local_only=%t local_abstractor=%t)", addr, providerChainNames(providers), cfg.AllowRawForwarding, cfg.CriticalLocalOnly, cfg.LocalAbstractionBaseURL != "")
+	server := &http.Server{Addr: addr, Handler: mux}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("server shutdown: %v", err)
+		}
+	}()
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("server failed: %v", err)
 	}
+	<-drained
+
+	// Closing the audit writer flushes records not yet fsynced under
+	// audit.sync interval.
+	if err := chainWriter.Close(); err != nil {
+		log.Fatalf("failed to close audit writer: %v", err)
+	}
 }
 
-func upstreamFromConfig(cfg startupConfig) (proxy.UpstreamAdapter, error) {
-	switch cfg.Provider {
+// shutdownTimeout bounds how long in-flight requests may finish, and so
+// write their audit records, after SIGINT or SIGTERM.
+const shutdownTimeout = 10 * time.Second
+
+func auditWriterFromConfig(cfg startupConfig) (*audit.ChainWriter, error) {
+	opts := audit.Options{
+		MaxBytes:           cfg.AuditMaxSegmentBytes,
+		MaxAge:             cfg.AuditMaxSegmentAge,
+		Retention:          cfg.AuditRetention,
+		CheckpointEvery:    cfg.AuditCheckpointEvery,
+		CheckpointInterval: cfg.AuditCheckpointInterval,
+		Sync:               audit.SyncMode(cfg.AuditSync),
+		SyncInterval:       cfg.AuditSyncInterval,
+	}
+	if cfg.AuditSigningKeyPath != "" {
+		key, err := audit.LoadSigningKey(cfg.AuditSigningKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("audit signing key: %w", err)
+		}
+		opts.SigningKey = key
+	}
+	return audit.NewChainWriterWithOptions(cfg.AuditPath, opts)
+}
+
+// minEntityHashKeyBytes keeps the HMAC key from being guessable, since the
+// hashed values often are.
+const minEntityHashKeyBytes = 32
+
+func entityHashKeyFromConfig(cfg startupConfig) ([]byte, error) {
+	if cfg.AuditEntityHashKeyPath == "" {
+		return nil, nil
+	}
+	contents, err := os.ReadFile(cfg.AuditEntityHashKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	key := bytes.TrimSpace(contents)
+	if len(key) < minEntityHashKeyBytes {
+		return nil, fmt.Errorf("%s: key must be at least %d bytes", cfg.AuditEntityHashKeyPath, minEntityHashKeyBytes)
+	}
+	return key, nil
+}
+
+// minUserPseudonymKeyBytes keeps the pseudonym key from being guessable,
+// since the identifiers it hides often are.
+const minUserPseudonymKeyBytes = 32
+
+func userPseudonymKeyFromConfig(cfg startupConfig) ([]byte, error) {
+	if cfg.UserPseudonymKeyPath == "" {
+		return nil, nil
+	}
+	contents, err := os.ReadFile(cfg.UserPseudonymKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	key := bytes.TrimSpace(contents)
+	if len(key) < minUserPseudonymKeyBytes {
+		return nil, fmt.Errorf("%s: key must be at least %d bytes", cfg.UserPseudonymKeyPath, minUserPseudonymKeyBytes)
+	}
+	return key, nil
+}
+
+func providersFromConfig(cfg startupConfig) ([]proxy.Provider, error) {
+	chain := cfg.providerChain()
+	providers := make([]proxy.Provider, 0, len(chain))
+	for _, entry := range chain {
+		adapter, err := upstreamFromConfig(cfg, entry.Mode)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Mode, err)
+		}
+		providers = append(providers, proxy.Provider{
+			Name:       string(entry.Mode),
+			Adapter:    adapter,
+			Local:      entry.Local,
+			Routes:     entry.Routes,
+			Categories: entry.Categories,
+			Breaker:    breakerFromConfig(cfg, adapter),
+		})
+	}
+	return providers, nil
+}
+
+// breakerFromConfig gives each provider its own circuit breaker. Probes
+// share the provider timeout.
+func breakerFromConfig(cfg startupConfig, adapter proxy.UpstreamAdapter) *proxy.CircuitBreaker {
+	if !cfg.BreakerEnabled {
+		return nil
+	}
+	probeInterval := cfg.BreakerProbeInterval
+	if probeInterval == 0 {
+		probeInterval = -1
+	}
+	return proxy.NewCircuitBreaker(proxy.BreakerConfig{
+		Window:        cfg.BreakerWindow,
+		MinRequests:   cfg.BreakerMinRequests,
+		FailureRate:   cfg.BreakerFailureRate,
+		OpenDuration:  cfg.BreakerOpenDuration,
+		ProbeInterval: probeInterval,
+		ProbeTimeout:  cfg.ProviderTimeout,
+	}, adapter)
+}
+
+func providerChainNames(providers []proxy.Provider) string {
+	names := make([]string, 0, len(providers))
+	for _, p := range providers {
+		names = append(names, p.Name)
+	}
+	return strings.Join(names, ">")
+}
+
+func upstreamFromConfig(cfg startupConfig, mode providerMode) (proxy.UpstreamAdapter, error) {
+	switch mode {
 	case providerStub:
 		return proxy.StubUpstream{}, nil
 	case providerVLLMLocal:
@@ -65,15 +256,18 @@ func upstreamFromConfig(cfg startupConfig) (proxy.UpstreamAdapter, error) {
 		return proxy.NewMimoUpstream(cfg.MimoBaseURL, cfg.MimoAPIKey, cfg.MimoModel)
 	case providerOpenAICompatible:
 		return proxy.NewOpenAICompatibleUpstream(proxy.OpenAICompatibleConfig{
-			BaseURL:      cfg.UpstreamBaseURL,
-			APIKey:       cfg.UpstreamAPIKey,
-			Model:        cfg.UpstreamModel,
-			APIKeyHeader: cfg.UpstreamAPIKeyHeader,
-			APIKeyPrefix: cfg.UpstreamAPIKeyPrefix,
-			ChatPath:     cfg.UpstreamChatPath,
+			BaseURL:        cfg.UpstreamBaseURL,
+			APIKey:         cfg.UpstreamAPIKey,
+			Model:          cfg.UpstreamModel,
+			APIKeyHeader:   cfg.UpstreamAPIKeyHeader,
+			APIKeyPrefix:   cfg.UpstreamAPIKeyPrefix,
+			ChatPath:       cfg.UpstreamChatPath,
+			ResponsesPath:  cfg.UpstreamResponsesPath,
+			EmbeddingsPath: cfg.UpstreamEmbeddingsPath,
+			ModelsPath:     cfg.UpstreamModelsPath,
 		})
 	default:
-		return nil, fmt.Errorf("unsupported provider mode %q", cfg.Provider)
+		return nil, fmt.Errorf("unsupported provider mode %q", mode)
 	}
 }
 
@@ -91,3 +285,41 @@ func abstractorFromConfig(cfg startupConfig) (proxy.Abstractor, error) {
 		ChatPath:     cfg.LocalAbstractionChatPath,
 	})
 }
+
+// idempotencyFromConfig returns nil when the cache is disabled.
+func idempotencyFromConfig(cfg startupConfig) (*proxy.IdempotencyCache, error) {
+	if cfg.IdempotencyMaxEntries == 0 {
+		return nil, nil
+	}
+	return proxy.NewIdempotencyCache(cfg.IdempotencyTTL, cfg.IdempotencyMaxEntries)
+}
+
+// localEmbeddingsFromConfig picks the adapter for high and critical
+// /v1/embeddings inputs: the configured local embedding model, falling back
+// to a local provider that can embed. Egress batches go through the
+// provider chain instead.
+func localEmbeddingsFromConfig(cfg startupConfig, providers []proxy.Provider) (proxy.EmbeddingsAdapter, error) {
+	var local proxy.EmbeddingsAdapter
+	for _, p := range providers {
+		if adapter, ok := p.Adapter.(proxy.EmbeddingsAdapter); ok && p.Local {
+			local = adapter
+			break
+		}
+	}
+
+	if cfg.LocalEmbeddingsBaseURL != "" {
+		adapter, err := proxy.NewOpenAICompatibleEmbeddings(proxy.OpenAICompatibleConfig{
+			BaseURL:        cfg.LocalEmbeddingsBaseURL,
+			APIKey:         cfg.LocalEmbeddingsAPIKey,
+			Model:          cfg.LocalEmbeddingsModel,
+			APIKeyHeader:   cfg.LocalEmbeddingsAPIKeyHeader,
+			APIKeyPrefix:   cfg.LocalEmbeddingsAPIKeyPrefix,
+			EmbeddingsPath: cfg.LocalEmbeddingsPath,
+		})
+		if err != nil {
+			return nil, err
+		}
+		local = adapter
+	}
+	return local, nil
+}
@@ -1,3 +1,5 @@
 module github.com/soloengine/lpg
 
 go 1.24.13
+
+require gopkg.in/yaml.v3 v3.0.1
@@ -1,123 +1,308 @@
 package audit
 
 import (
-	"bufio"
+	"crypto/ed25519"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
-	"strings"
+	"path/filepath"
 	"sync"
 	"time"
 )
 
+// KindCheckpoint marks records written by the writer itself rather than for
+// a request: periodic signed checkpoints and retention checkpoints. Event
+// records leave Kind empty.
+const KindCheckpoint = "checkpoint"
+
+// SyncMode chooses when appended records are fsynced.
+type SyncMode string
+
+const (
+	// SyncGroupCommit fsyncs before Append returns, and appends that arrive
+	// while an fsync is in flight share the next one. It is the default.
+	SyncGroupCommit SyncMode = "group"
+	// SyncPerRecord fsyncs each record before the next one is written.
+	SyncPerRecord SyncMode = "record"
+	// SyncPeriodic returns once the record is written and fsyncs every
+	// Options.SyncInterval, so a crash can lose up to one interval.
+	SyncPeriodic SyncMode = "interval"
+)
+
+var errWriterClosed = errors.New("audit writer is closed")
+
+// Event is what a request contributes to the log. ActionSummary stays a
+// readable digest; the fields after it are for querying and are omitted
+// from the record when zero.
 type Event struct {
 	RequestID     string
 	PolicyVersion string
 	ActionSummary string
 	RiskCategory  string
 	Route         string
+
+	Outcome    Outcome
+	HTTPStatus int
+	ErrorCode  string
+	// Provider is the last provider tried, and Retries counts attempts
+	// beyond the first across all of them.
+	Provider string
+	Retries  int
+	Latency  time.Duration
+	// Detections counts mapped entities by entity type.
+	Detections   map[string]int
+	Abstracted   bool
+	EntityHashes []EntityHash
 }
 
 type Record struct {
-	Timestamp     time.Time `json:"timestamp"`
-	RequestID     string    `json:"request_id"`
-	PolicyVersion string    `json:"policy_version"`
-	ActionSummary string    `json:"action_summary"`
-	RiskCategory  string    `json:"risk_category"`
-	Route         string    `json:"route"`
-	PrevHash      string    `json:"prev_hash"`
-	EntryHash     string    `json:"entry_hash"`
+	Timestamp     time.Time      `json:"timestamp"`
+	RequestID     string         `json:"request_id"`
+	PolicyVersion string         `json:"policy_version"`
+	ActionSummary string         `json:"action_summary"`
+	RiskCategory  string         `json:"risk_category"`
+	Route         string         `json:"route"`
+	Outcome       Outcome        `json:"outcome,omitempty"`
+	HTTPStatus    int            `json:"http_status,omitempty"`
+	ErrorCode     string         `json:"error_code,omitempty"`
+	Provider      string         `json:"provider,omitempty"`
+	Retries       int            `json:"retries,omitempty"`
+	LatencyMS     int64          `json:"latency_ms,omitempty"`
+	Detections    map[string]int `json:"detections,omitempty"`
+	Abstracted    bool           `json:"abstracted,omitempty"`
+	EntityHashes  []EntityHash   `json:"entity_hashes,omitempty"`
+	Kind          string         `json:"kind,omitempty"`
+	// Checkpoint is set on retention checkpoints only; periodic checkpoints
+	// carry just a Signature.
+	Checkpoint *Checkpoint `json:"checkpoint,omitempty"`
+	PrevHash   string      `json:"prev_hash"`
+	EntryHash  string      `json:"entry_hash"`
+	// Signature is a base64 Ed25519 signature over EntryHash. It is not
+	// covered by the hash.
+	Signature string `json:"signature,omitempty"`
 }
 
+// Checkpoint records a retention prune. PrunedThrough is the entry_hash of
+// the last deleted record, which the first remaining record's prev_hash
+// still links to.
+type Checkpoint struct {
+	PrunedThrough  string `json:"pruned_through"`
+	PrunedSegments int    `json:"pruned_segments"`
+}
+
+// Options control segment rotation and retention. Zero values disable each.
+type Options struct {
+	// MaxBytes and MaxAge seal the active segment once it has reached
+	// MaxBytes or its first record is MaxAge old.
+	MaxBytes int64
+	MaxAge   time.Duration
+	// Retention deletes sealed segments once they were sealed longer ago
+	// than Retention, leaving a checkpoint in the chain.
+	Retention time.Duration
+	// SigningKey, when set, signs checkpoint records. A signed checkpoint
+	// is then also written every CheckpointEvery records or after
+	// CheckpointInterval, whichever comes first, and before a segment is
+	// sealed.
+	SigningKey         ed25519.PrivateKey
+	CheckpointEvery    int
+	CheckpointInterval time.Duration
+	// Sync chooses when records reach the disk; the zero value is
+	// SyncGroupCommit. SyncInterval is the fsync period of SyncPeriodic.
+	Sync         SyncMode
+	SyncInterval time.Duration
+}
+
+// ChainWriter appends hash-chained records to the active segment at path.
+// Sealed segments sit next to it as path.<seal time>, and the chain runs
+// across them in order.
 type ChainWriter struct {
 	mu       sync.Mutex
 	path     string
+	opts     Options
 	prevHash string
 	now      func() time.Time
+
+	// size and segmentStart describe the active segment.
+	size         int64
+	segmentStart time.Time
+	// sinceCheckpoint counts event records after the last checkpoint.
+	sinceCheckpoint int
+	lastCheckpoint  time.Time
+
+	// file is the open active segment, or nil until the next write.
+	// written numbers the records written through it and the segments
+	// before it.
+	file    *os.File
+	written uint64
+	// deferredErr holds a failed periodic fsync for the next Append.
+	deferredErr error
+	closed      bool
+	stop        chan struct{}
+	done        chan struct{}
+
+	// syncMu serializes fsyncs and guards the fields below. It is taken
+	// after mu, never before.
+	syncMu sync.Mutex
+	synced uint64
+	// failedFrom and failedThrough bound the records whose fsync failed
+	// with syncErr.
+	failedFrom    uint64
+	failedThrough uint64
+	syncErr       error
 }
 
 func NewChainWriter(path string) (*ChainWriter, error) {
+	return NewChainWriterWithOptions(path, Options{})
+}
+
+func NewChainWriterWithOptions(path string, opts Options) (*ChainWriter, error) {
+	switch opts.Sync {
+	case "":
+		opts.Sync = SyncGroupCommit
+	case SyncGroupCommit, SyncPerRecord:
+	case SyncPeriodic:
+		if opts.SyncInterval <= 0 {
+			return nil, errors.New("audit sync interval must be positive")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported audit sync mode %q", opts.Sync)
+	}
+
 	cw := &ChainWriter{
 		path: path,
+		opts: opts,
 		now:  time.Now,
 	}
 
-	if err := cw.loadPrevHash(); err != nil {
+	if err := cw.loadState(); err != nil {
+		return nil, err
+	}
+	cw.lastCheckpoint = cw.now()
+	if err := cw.prune(); err != nil {
+		return nil, err
+	}
+	if err := cw.commit(cw.written); err != nil {
 		return nil, err
 	}
 
+	if opts.Sync == SyncPeriodic {
+		cw.stop = make(chan struct{})
+		cw.done = make(chan struct{})
+		go cw.syncPeriodically(opts.SyncInterval)
+	}
 	return cw, nil
 }
 
+// Append chains event onto the log. Only the write itself holds the chain
+// lock; under SyncGroupCommit the fsync is waited for after releasing it.
+// Write and fsync failures are returned so that StrictAudit can fail the
+// request.
 func (cw *ChainWriter) Append(event Event) (Record, error) {
+	record, seq, err := cw.append(event)
+	if err != nil {
+		return Record{}, err
+	}
+	if cw.opts.Sync == SyncGroupCommit {
+		if err := cw.commit(seq); err != nil {
+			return Record{}, err
+		}
+	}
+	return record, nil
+}
+
+func (cw *ChainWriter) append(event Event) (Record, uint64, error) {
 	cw.mu.Lock()
 	defer cw.mu.Unlock()
 
-	record := Record{
+	if cw.closed {
+		return Record{}, 0, errWriterClosed
+	}
+	if err := cw.deferredErr; err != nil {
+		cw.deferredErr = nil
+		return Record{}, 0, fmt.Errorf("audit fsync failed, recent records may be lost: %w", err)
+	}
+	if err := cw.checkpointIfDue(); err != nil {
+		return Record{}, 0, err
+	}
+	if err := cw.rotateIfDue(); err != nil {
+		return Record{}, 0, err
+	}
+
+	record, err := cw.write(Record{
 		Timestamp:     cw.now().UTC(),
 		RequestID:     event.RequestID,
 		PolicyVersion: event.PolicyVersion,
 		ActionSummary: event.ActionSummary,
 		RiskCategory:  event.RiskCategory,
 		Route:         event.Route,
-		PrevHash:      cw.prevHash,
-	}
+		Outcome:       event.Outcome,
+		HTTPStatus:    event.HTTPStatus,
+		ErrorCode:     event.ErrorCode,
+		Provider:      event.Provider,
+		Retries:       event.Retries,
+		LatencyMS:     event.Latency.Milliseconds(),
+		Detections:    event.Detections,
+		Abstracted:    event.Abstracted,
+		EntityHashes:  event.EntityHashes,
+	})
+	return record, cw.written, err
+}
 
+// write chains record onto the active segment. Checkpoints are signed when
+// a key is configured.
+func (cw *ChainWriter) write(record Record) (Record, error) {
+	record.PrevHash = cw.prevHash
 	hash, err := entryHash(record)
 	if err != nil {
 		return Record{}, err
 	}
 	record.EntryHash = hash
-
-	line, err := json.Marshal(record)
-	if err != nil {
-		return Record{}, err
+	if record.Kind == KindCheckpoint && cw.opts.SigningKey != nil {
+		record.Signature = signEntryHash(cw.opts.SigningKey, hash)
 	}
 
-	f, err := os.OpenFile(cw.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	line, err := json.Marshal(record)
 	if err != nil {
 		return Record{}, err
 	}
 
-	if _, err := f.Write(append(line, '\n')); err != nil {
-		if closeErr := f.Close(); closeErr != nil {
-			return Record{}, closeErr
+	if cw.file == nil {
+		f, err := os.OpenFile(cw.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return Record{}, err
 		}
-		return Record{}, err
+		cw.file = f
 	}
-	if err := f.Close(); err != nil {
+	if _, err := cw.file.Write(append(line, '\n')); err != nil {
+		// Drop any partial line and reopen on the next write, so the
+		// chain resumes cleanly once the fault clears.
+		_ = cw.file.Truncate(cw.size)
+		_ = cw.closeFile()
 		return Record{}, err
 	}
 
+	cw.written++
 	cw.prevHash = record.EntryHash
-	return record, nil
-}
-
-func (cw *ChainWriter) loadPrevHash() error {
-	contents, err := os.ReadFile(cw.path)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return nil
-		}
-		return err
+	cw.size += int64(len(line) + 1)
+	if cw.segmentStart.IsZero() {
+		cw.segmentStart = record.Timestamp
 	}
-
-	trimmed := strings.TrimSpace(string(contents))
-	if trimmed == "" {
-		return nil
+	if record.Kind == KindCheckpoint {
+		cw.sinceCheckpoint = 0
+		cw.lastCheckpoint = record.Timestamp
+	} else {
+		cw.sinceCheckpoint++
 	}
 
-	lines := strings.Split(trimmed, "\n")
-	lastLine := lines[len(lines)-1]
-	var record Record
-	if err := json.Unmarshal([]byte(lastLine), &record); err != nil {
-		return err
+	if cw.opts.Sync == SyncPerRecord {
+		if err := cw.file.Sync(); err != nil {
+			return Record{}, err
+		}
 	}
-	cw.prevHash = record.EntryHash
-	return nil
+	return record, nil
 }
 
 func entryHash(record Record) (string, error) {
@@ -128,6 +313,19 @@ func entryHash(record Record) (string, error) {
 		ActionSummary string    `json:"action_summary"`
 		RiskCategory  string    `json:"risk_category"`
 		Route         string    `json:"route"`
+		// Omitted when empty so records written before these fields
+		// existed hash as they always have.
+		Outcome      Outcome        `json:"outcome,omitempty"`
+		HTTPStatus   int            `json:"http_status,omitempty"`
+		ErrorCode    string         `json:"error_code,omitempty"`
+		Provider     string         `json:"provider,omitempty"`
+		Retries      int            `json:"retries,omitempty"`
+		LatencyMS    int64          `json:"latency_ms,omitempty"`
+		Detections   map[string]int `json:"detections,omitempty"`
+		Abstracted   bool           `json:"abstracted,omitempty"`
+		EntityHashes []EntityHash   `json:"entity_hashes,omitempty"`
+		Kind         string         `json:"kind,omitempty"`
+		Checkpoint   *Checkpoint    `json:"checkpoint,omitempty"`
 	}{
 		Timestamp:     record.Timestamp,
 		RequestID:     record.RequestID,
@@ -135,6 +333,17 @@ func entryHash(record Record) (string, error) {
 		ActionSummary: record.ActionSummary,
 		RiskCategory:  record.RiskCategory,
 		Route:         record.Route,
+		Outcome:       record.Outcome,
+		HTTPStatus:    record.HTTPStatus,
+		ErrorCode:     record.ErrorCode,
+		Provider:      record.Provider,
+		Retries:       record.Retries,
+		LatencyMS:     record.LatencyMS,
+		Detections:    record.Detections,
+		Abstracted:    record.Abstracted,
+		EntityHashes:  record.EntityHashes,
+		Kind:          record.Kind,
+		Checkpoint:    record.Checkpoint,
 	})
 	if err != nil {
 		return "", err
@@ -151,49 +360,118 @@ func entryHash(record Record) (string, error) {
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
+// VerifyError reports the first record that breaks the chain. Line counts
+// from 1 within Path.
+type VerifyError struct {
+	Path   string
+	Line   int
+	Reason string
+	Err    error
+}
+
+func (e *VerifyError) Error() string {
+	msg := fmt.Sprintf("%s at %s line %d", e.Reason, filepath.Base(e.Path), e.Line)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *VerifyError) Unwrap() error {
+	return e.Err
+}
+
+// VerifyChain checks the hash chain across the sealed segments of path and
+// the active segment. A chain whose oldest segments were pruned must start
+// at the prev_hash a retention checkpoint recorded. Broken chains are
+// reported as *VerifyError.
 func VerifyChain(path string) error {
-	f, err := os.Open(path)
+	_, err := verifyChain(path, nil)
+	return err
+}
+
+// VerifyChainWithKey is VerifyChain that also requires every checkpoint to
+// carry a valid signature by pub, and at least one to exist. Since a
+// signature covers the entry_hash, and so every record before it, rewriting
+// the chain is detected up to the last checkpoint. It returns how many
+// records follow that checkpoint, which only the hash chain protects.
+func VerifyChainWithKey(path string, pub ed25519.PublicKey) (int, error) {
+	return verifyChain(path, pub)
+}
+
+func verifyChain(path string, pub ed25519.PublicKey) (int, error) {
+	files, err := chainFiles(path)
 	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return nil
-		}
-		return err
-	}
-	defer func() {
-		_ = f.Close()
-	}()
-
-	scanner := bufio.NewScanner(f)
-	prev := ""
-	lineNum := 0
-	for scanner.Scan() {
-		lineNum++
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
+		return 0, err
+	}
+	v := chainVerifier{pub: pub, anchors: map[string]bool{}}
+	for _, file := range files {
+		if err := v.verifyFile(file); err != nil {
+			return 0, err
 		}
+	}
+	if v.start != "" && !v.anchors[v.start] {
+		return 0, &VerifyError{Path: v.startPath, Line: v.startLine, Reason: "chain starts without a retention checkpoint"}
+	}
+	if pub != nil && v.started && v.signed == 0 {
+		return 0, errors.New("audit chain has no signed checkpoint")
+	}
+	return v.sinceSigned, nil
+}
 
+type chainVerifier struct {
+	pub     ed25519.PublicKey
+	started bool
+	// start is the prev_hash of the oldest record still on disk.
+	start     string
+	startPath string
+	startLine int
+	prev      string
+	anchors   map[string]bool
+	// signed counts verified signatures; sinceSigned counts the records
+	// after the last one.
+	signed      int
+	sinceSigned int
+}
+
+func (v *chainVerifier) verifyFile(path string) error {
+	return scanRecords(path, func(lineNum int, line []byte) error {
 		var record Record
-		if err := json.Unmarshal([]byte(line), &record); err != nil {
-			return fmt.Errorf("invalid audit record at line %d: %w", lineNum, err)
+		if err := json.Unmarshal(line, &record); err != nil {
+			return &VerifyError{Path: path, Line: lineNum, Reason: "invalid audit record", Err: err}
 		}
 
-		if record.PrevHash != prev {
-			return fmt.Errorf("invalid prev_hash at line %d", lineNum)
+		if !v.started {
+			v.started = true
+			v.start, v.startPath, v.startLine = record.PrevHash, path, lineNum
+			v.prev = record.PrevHash
+		}
+		if record.PrevHash != v.prev {
+			return &VerifyError{Path: path, Line: lineNum, Reason: "invalid prev_hash"}
 		}
 
 		expected, err := entryHash(record)
 		if err != nil {
-			return fmt.Errorf("failed to compute entry hash at line %d: %w", lineNum, err)
+			return &VerifyError{Path: path, Line: lineNum, Reason: "failed to compute entry hash", Err: err}
 		}
 		if record.EntryHash != expected {
-			return fmt.Errorf("invalid entry_hash at line %d", lineNum)
+			return &VerifyError{Path: path, Line: lineNum, Reason: "invalid entry_hash"}
 		}
 
-		prev = record.EntryHash
-	}
-	if err := scanner.Err(); err != nil {
-		return err
-	}
-	return nil
+		v.sinceSigned++
+		if record.Kind == KindCheckpoint {
+			if record.Checkpoint != nil {
+				v.anchors[record.Checkpoint.PrunedThrough] = true
+			}
+			if v.pub != nil {
+				if !verifyEntryHash(v.pub, record.EntryHash, record.Signature) {
+					return &VerifyError{Path: path, Line: lineNum, Reason: "invalid checkpoint signature"}
+				}
+				v.signed++
+				v.sinceSigned = 0
+			}
+		}
+		v.prev = record.EntryHash
+		return nil
+	})
 }
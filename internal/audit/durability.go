@@ -0,0 +1,109 @@
+package audit
+
+import (
+	"os"
+	"time"
+)
+
+// commit returns once record number seq is on disk. Whoever finds it
+// unsynced fsyncs everything written so far, so appends that queue behind
+// an fsync in flight are covered by the next one together. Hash order is
+// unaffected because records are written in order under mu beforehand.
+func (cw *ChainWriter) commit(seq uint64) error {
+	cw.mu.Lock()
+	f, target := cw.file, cw.written
+	cw.mu.Unlock()
+
+	cw.syncMu.Lock()
+	defer cw.syncMu.Unlock()
+	if seq > cw.failedFrom && seq <= cw.failedThrough {
+		return cw.syncErr
+	}
+	if seq <= cw.synced {
+		return nil
+	}
+	// A rotation or close since the snapshot would have synced seq, so f
+	// is still open here.
+	return cw.syncThrough(f, target)
+}
+
+// syncThrough fsyncs f, which holds records up to target. Callers hold
+// syncMu.
+func (cw *ChainWriter) syncThrough(f *os.File, target uint64) error {
+	if err := f.Sync(); err != nil {
+		cw.failedFrom, cw.failedThrough, cw.syncErr = cw.synced, target, err
+		return err
+	}
+	cw.synced = target
+	return nil
+}
+
+// syncPeriodically backs SyncPeriodic. A failed fsync is reported by the
+// next Append.
+func (cw *ChainWriter) syncPeriodically(interval time.Duration) {
+	defer close(cw.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cw.stop:
+			return
+		case <-ticker.C:
+		}
+
+		cw.mu.Lock()
+		f, target := cw.file, cw.written
+		cw.mu.Unlock()
+
+		cw.syncMu.Lock()
+		var err error
+		if target > cw.synced {
+			err = cw.syncThrough(f, target)
+		}
+		cw.syncMu.Unlock()
+
+		if err != nil {
+			cw.mu.Lock()
+			cw.deferredErr = err
+			cw.mu.Unlock()
+		}
+	}
+}
+
+// closeFile fsyncs and closes the active segment, so that the next write
+// reopens it. Callers hold mu.
+func (cw *ChainWriter) closeFile() error {
+	if cw.file == nil {
+		return nil
+	}
+	cw.syncMu.Lock()
+	defer cw.syncMu.Unlock()
+
+	err := cw.syncThrough(cw.file, cw.written)
+	if closeErr := cw.file.Close(); err == nil {
+		err = closeErr
+	}
+	cw.file = nil
+	return err
+}
+
+// Close stops periodic syncing, then fsyncs and closes the active segment.
+// Appends after Close fail.
+func (cw *ChainWriter) Close() error {
+	cw.mu.Lock()
+	if cw.closed {
+		cw.mu.Unlock()
+		return nil
+	}
+	cw.closed = true
+	cw.mu.Unlock()
+
+	if cw.stop != nil {
+		close(cw.stop)
+		<-cw.done
+	}
+
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	return cw.closeFile()
+}
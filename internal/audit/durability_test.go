@@ -0,0 +1,98 @@
+package audit
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChainWriterGroupCommitKeepsChainOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	cw, err := NewChainWriterWithOptions(path, Options{MaxBytes: 4096})
+	if err != nil {
+		t.Fatalf("NewChainWriterWithOptions failed: %v", err)
+	}
+
+	const workers, perWorker = 16, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*perWorker)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				if _, err := cw.Append(Event{RequestID: fmt.Sprintf("req-%d-%d", w, i), ActionSummary: "event"}); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if err := cw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if err := VerifyChain(path); err != nil {
+		t.Fatalf("VerifyChain failed: %v", err)
+	}
+	var n int
+	if err := ReadRecords(path, func(Record) error {
+		n++
+		return nil
+	}); err != nil {
+		t.Fatalf("ReadRecords failed: %v", err)
+	}
+	if n != workers*perWorker {
+		t.Fatalf("expected %d records, got %d", workers*perWorker, n)
+	}
+}
+
+func TestChainWriterPeriodicSyncFlushesOnClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	if _, err := NewChainWriterWithOptions(path, Options{Sync: SyncPeriodic}); err == nil {
+		t.Fatal("expected SyncPeriodic without an interval to be rejected")
+	}
+	cw, err := NewChainWriterWithOptions(path, Options{Sync: SyncPeriodic, SyncInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("NewChainWriterWithOptions failed: %v", err)
+	}
+	appendEvents(t, cw, 3)
+
+	if err := cw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if cw.synced != cw.written || cw.written != 3 {
+		t.Fatalf("expected all 3 records synced on close, got %d of %d", cw.synced, cw.written)
+	}
+	if _, err := cw.Append(Event{RequestID: "late"}); err == nil {
+		t.Fatal("expected Append after Close to fail")
+	}
+}
+
+func TestChainWriterSurfacesWriteFailureAndRecovers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	cw, err := NewChainWriterWithOptions(path, Options{Sync: SyncPerRecord})
+	if err != nil {
+		t.Fatalf("NewChainWriterWithOptions failed: %v", err)
+	}
+	appendEvents(t, cw, 1)
+
+	// Closing the handle underneath the writer makes the next write fail.
+	if err := cw.file.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if _, err := cw.Append(Event{RequestID: "lost"}); err == nil {
+		t.Fatal("expected the write failure to reach the caller")
+	}
+
+	appendEvents(t, cw, 1)
+	if err := VerifyChain(path); err != nil {
+		t.Fatalf("expected the chain to resume after the failure, got %v", err)
+	}
+}
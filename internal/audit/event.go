@@ -0,0 +1,37 @@
+package audit
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Outcome classifies how a request ended.
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	// OutcomeBlocked is a policy decision: the request was refused before
+	// anything was forwarded.
+	OutcomeBlocked Outcome = "blocked"
+	// OutcomeError covers provider, abstraction, audit and client errors.
+	OutcomeError Outcome = "error"
+)
+
+// EntityHash identifies a mapped entity value without storing it, so that
+// records mentioning the same value can be correlated.
+type EntityHash struct {
+	EntityType string `json:"entity_type"`
+	HMAC       string `json:"hmac"`
+}
+
+// HashEntity returns the hex HMAC-SHA256 of value under key. The entity
+// type is part of the message, so equal values of different types do not
+// correlate.
+func HashEntity(key []byte, entityType, value string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(entityType))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,120 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// legacyLine builds a record the way writers before the structured fields
+// did, hash payload included.
+func legacyLine(t *testing.T, requestID, prevHash string) (string, string) {
+	t.Helper()
+	fields := struct {
+		Timestamp     time.Time `json:"timestamp"`
+		RequestID     string    `json:"request_id"`
+		PolicyVersion string    `json:"policy_version"`
+		ActionSummary string    `json:"action_summary"`
+		RiskCategory  string    `json:"risk_category"`
+		Route         string    `json:"route"`
+	}{
+		Timestamp:     time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		RequestID:     requestID,
+		PolicyVersion: "v2.1",
+		ActionSummary: "route=sanitized_forward category=Medium success",
+		RiskCategory:  "Medium",
+		Route:         "sanitized_forward",
+	}
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	sum := sha256.Sum256(append(payload, prevHash...))
+	hash := hex.EncodeToString(sum[:])
+
+	line, err := json.Marshal(struct {
+		Timestamp     time.Time `json:"timestamp"`
+		RequestID     string    `json:"request_id"`
+		PolicyVersion string    `json:"policy_version"`
+		ActionSummary string    `json:"action_summary"`
+		RiskCategory  string    `json:"risk_category"`
+		Route         string    `json:"route"`
+		PrevHash      string    `json:"prev_hash"`
+		EntryHash     string    `json:"entry_hash"`
+	}{fields.Timestamp, fields.RequestID, fields.PolicyVersion, fields.ActionSummary, fields.RiskCategory, fields.Route, prevHash, hash})
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	return string(line) + "\n", hash
+}
+
+func TestStructuredRecordsChainOntoLegacyLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	first, hash := legacyLine(t, "req-1", "")
+	second, _ := legacyLine(t, "req-2", hash)
+	if err := os.WriteFile(path, []byte(first+second), 0o600); err != nil {
+		t.Fatalf("write legacy log failed: %v", err)
+	}
+	if err := VerifyChain(path); err != nil {
+		t.Fatalf("expected the legacy log to verify, got %v", err)
+	}
+
+	cw, err := NewChainWriter(path)
+	if err != nil {
+		t.Fatalf("NewChainWriter failed: %v", err)
+	}
+	record, err := cw.Append(Event{
+		RequestID:    "req-3",
+		Outcome:      OutcomeError,
+		HTTPStatus:   502,
+		ErrorCode:    "ERR_PROVIDER_FAILURE",
+		Provider:     "remote",
+		Retries:      1,
+		Latency:      1500 * time.Millisecond,
+		Detections:   map[string]int{"EMAIL": 2},
+		Abstracted:   true,
+		EntityHashes: []EntityHash{{EntityType: "EMAIL", HMAC: HashEntity([]byte("key"), "EMAIL", "a@example.com")}},
+	})
+	if err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if record.LatencyMS != 1500 || record.Detections["EMAIL"] != 2 {
+		t.Fatalf("unexpected structured fields %+v", record)
+	}
+	if err := VerifyChain(path); err != nil {
+		t.Fatalf("expected the mixed log to verify, got %v", err)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read audit log failed: %v", err)
+	}
+	if strings.Contains(string(contents), "a@example.com") {
+		t.Fatal("entity hash leaked the raw value")
+	}
+	tampered := strings.Replace(string(contents), `"EMAIL":2`, `"EMAIL":1`, 1)
+	if err := os.WriteFile(path, []byte(tampered), 0o600); err != nil {
+		t.Fatalf("write audit log failed: %v", err)
+	}
+	if err := VerifyChain(path); err == nil {
+		t.Fatal("expected edited detection counts to fail verification")
+	}
+}
+
+func TestHashEntitySeparatesTypesAndKeys(t *testing.T) {
+	key := []byte("key")
+	if HashEntity(key, "EMAIL", "x") != HashEntity(key, "EMAIL", "x") {
+		t.Fatal("expected equal inputs to hash equally")
+	}
+	if HashEntity(key, "EMAIL", "x") == HashEntity(key, "PHONE", "x") {
+		t.Fatal("expected the entity type to change the hash")
+	}
+	if HashEntity(key, "EMAIL", "x") == HashEntity([]byte("other"), "EMAIL", "x") {
+		t.Fatal("expected the key to change the hash")
+	}
+}
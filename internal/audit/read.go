@@ -0,0 +1,214 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// maxRecordBytes bounds a single audit line when reading.
+const maxRecordBytes = 16 << 20
+
+// chainFiles lists the files of the chain at path in order: sealed segments
+// oldest first, then the active segment.
+func chainFiles(path string) ([]string, error) {
+	segments, err := sealedSegments(path)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(segments)+1)
+	for _, seg := range segments {
+		files = append(files, seg.path)
+	}
+	return append(files, path), nil
+}
+
+// scanRecords calls fn with every non-blank line of path and its 1-based
+// line number. A missing file has no lines.
+func scanRecords(path string, fn func(lineNum int, line []byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordBytes)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if err := fn(lineNum, line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// ReadRecords calls fn with every record of the chain at path, oldest
+// first. It does not verify the chain.
+func ReadRecords(path string, fn func(Record) error) error {
+	files, err := chainFiles(path)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := readFile(file, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readFile(path string, fn func(Record) error) error {
+	return scanRecords(path, func(lineNum int, line []byte) error {
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("invalid audit record at %s line %d: %w", path, lineNum, err)
+		}
+		return fn(record)
+	})
+}
+
+// Follow calls fn with each record appended to the active segment at path
+// after Follow starts, polling every interval until ctx is done. It carries
+// on into the new active segment after a rotation.
+func Follow(ctx context.Context, path string, interval time.Duration, fn func(Record) error) error {
+	var f *os.File
+	defer func() {
+		if f != nil {
+			_ = f.Close()
+		}
+	}()
+	open := func(fromEnd bool) error {
+		var err error
+		if f, err = os.Open(path); err != nil {
+			f = nil
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if fromEnd {
+			_, err = f.Seek(0, io.SeekEnd)
+		}
+		return err
+	}
+	if err := open(true); err != nil {
+		return err
+	}
+
+	var pending []byte
+	drain := func() error {
+		if f == nil {
+			return nil
+		}
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		pending = append(pending, data...)
+		for {
+			i := bytes.IndexByte(pending, '\n')
+			if i < 0 {
+				return nil
+			}
+			line := bytes.TrimSpace(pending[:i])
+			pending = pending[i+1:]
+			if len(line) == 0 {
+				continue
+			}
+			var record Record
+			if err := json.Unmarshal(line, &record); err != nil {
+				return fmt.Errorf("invalid audit record: %w", err)
+			}
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := drain(); err != nil {
+			return err
+		}
+		// After a rotation, path names a new file; finish the sealed one
+		// first.
+		if info, err := os.Stat(path); err == nil {
+			if f == nil {
+				if err := open(false); err != nil {
+					return err
+				}
+				continue
+			}
+			if current, err := f.Stat(); err == nil && !os.SameFile(current, info) {
+				_ = f.Close()
+				f, pending = nil, nil
+				if err := open(false); err != nil {
+					return err
+				}
+				if err := readSealedBetween(path, current, f, fn); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// readSealedBetween calls fn with the records of the segments sealed after
+// from and before the active segment active, which rotations in quick
+// succession can leave unread by Follow.
+func readSealedBetween(path string, from os.FileInfo, active *os.File, fn func(Record) error) error {
+	segments, err := sealedSegments(path)
+	if err != nil {
+		return err
+	}
+	var activeInfo os.FileInfo
+	if active != nil {
+		if activeInfo, err = active.Stat(); err != nil {
+			return err
+		}
+	}
+
+	after := false
+	for _, seg := range segments {
+		info, err := os.Stat(seg.path)
+		if err != nil {
+			continue
+		}
+		if activeInfo != nil && os.SameFile(info, activeInfo) {
+			return nil
+		}
+		if !after {
+			after = os.SameFile(info, from)
+			continue
+		}
+		if err := readFile(seg.path, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
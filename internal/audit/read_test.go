@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestVerifyChainReportsFirstBadLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	cw, err := NewChainWriter(path)
+	if err != nil {
+		t.Fatalf("NewChainWriter failed: %v", err)
+	}
+	appendEvents(t, cw, 3)
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read audit log failed: %v", err)
+	}
+	lines := strings.SplitAfter(string(contents), "\n")
+	lines[1] = strings.Replace(lines[1], `"action_summary":"event"`, `"action_summary":"edited"`, 1)
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "")), 0o600); err != nil {
+		t.Fatalf("write audit log failed: %v", err)
+	}
+
+	var verifyErr *VerifyError
+	if err := VerifyChain(path); !errors.As(err, &verifyErr) || verifyErr.Line != 2 || verifyErr.Reason != "invalid entry_hash" {
+		t.Fatalf("expected an invalid entry_hash at line 2, got %v", err)
+	}
+}
+
+func TestReadRecordsSpansSegments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	cw, err := NewChainWriterWithOptions(path, Options{MaxBytes: 1})
+	if err != nil {
+		t.Fatalf("NewChainWriterWithOptions failed: %v", err)
+	}
+	want := appendEvents(t, cw, 3)
+
+	var got []Record
+	if err := ReadRecords(path, func(record Record) error {
+		got = append(got, record)
+		return nil
+	}); err != nil {
+		t.Fatalf("ReadRecords failed: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d records, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i].EntryHash != want[i].EntryHash {
+			t.Fatalf("record %d out of order", i)
+		}
+	}
+}
+
+func TestFollowContinuesAcrossRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	cw, err := NewChainWriterWithOptions(path, Options{MaxBytes: 1})
+	if err != nil {
+		t.Fatalf("NewChainWriterWithOptions failed: %v", err)
+	}
+	appendEvents(t, cw, 1)
+
+	var mu sync.Mutex
+	var seen []string
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Follow(ctx, path, time.Millisecond, func(record Record) error {
+			mu.Lock()
+			defer mu.Unlock()
+			seen = append(seen, record.EntryHash)
+			return nil
+		})
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// Each append rotates the previous record into a sealed segment.
+	want := appendEvents(t, cw, 3)
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(seen)
+		mu.Unlock()
+		if n >= len(want) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Follow failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) != len(want) {
+		t.Fatalf("expected %d followed records, got %d", len(want), len(seen))
+	}
+	for i := range want {
+		if seen[i] != want[i].EntryHash {
+			t.Fatalf("followed record %d out of order", i)
+		}
+	}
+}
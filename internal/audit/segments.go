@@ -0,0 +1,252 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sealedLayout suffixes sealed segment names so that they sort in chain
+// order.
+const sealedLayout = "20060102T150405.000000000Z"
+
+const tailChunkSize = 4096
+
+type segment struct {
+	path   string
+	sealed time.Time
+}
+
+// sealedSegments lists the sealed segments of the active segment at path,
+// oldest first.
+func sealedSegments(path string) ([]segment, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var segments []segment
+	for _, entry := range entries {
+		suffix, ok := strings.CutPrefix(entry.Name(), base+".")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		sealed, err := time.Parse(sealedLayout, suffix)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment{path: filepath.Join(dir, entry.Name()), sealed: sealed})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].sealed.Before(segments[j].sealed) })
+	return segments, nil
+}
+
+// loadState picks the chain up where it stopped, reading only the ends of
+// the active segment. Right after a rotation the active segment may not
+// exist yet, in which case the chain continues from the newest sealed one.
+func (cw *ChainWriter) loadState() error {
+	info, err := os.Stat(cw.path)
+	switch {
+	case err == nil:
+		cw.size = info.Size()
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+
+	last, err := readLastRecord(cw.path)
+	if err != nil {
+		return err
+	}
+	if last == nil {
+		segments, err := sealedSegments(cw.path)
+		if err != nil {
+			return err
+		}
+		if len(segments) > 0 {
+			if last, err = readLastRecord(segments[len(segments)-1].path); err != nil {
+				return err
+			}
+		}
+	}
+	if last != nil {
+		cw.prevHash = last.EntryHash
+		if last.Kind != KindCheckpoint {
+			cw.sinceCheckpoint = 1
+		}
+	}
+
+	first, err := readFirstRecord(cw.path)
+	if err != nil {
+		return err
+	}
+	if first != nil {
+		cw.segmentStart = first.Timestamp
+	}
+	return nil
+}
+
+// rotateIfDue seals the active segment when it is full or old enough,
+// ending it with a signed checkpoint when a key is configured. The next
+// record opens a new segment whose prev_hash links to the sealed one.
+func (cw *ChainWriter) rotateIfDue() error {
+	if cw.size == 0 {
+		return nil
+	}
+	now := cw.now().UTC()
+	full := cw.opts.MaxBytes > 0 && cw.size >= cw.opts.MaxBytes
+	old := cw.opts.MaxAge > 0 && now.Sub(cw.segmentStart) >= cw.opts.MaxAge
+	if !full && !old {
+		return nil
+	}
+	if err := cw.checkpoint(); err != nil {
+		return err
+	}
+	if err := cw.closeFile(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(cw.path, cw.path+"."+now.Format(sealedLayout)); err != nil {
+		return err
+	}
+	cw.size = 0
+	cw.segmentStart = time.Time{}
+	return cw.prune()
+}
+
+// prune deletes sealed segments past retention. The checkpoint is written
+// first, so an interrupted prune leaves extra segments rather than a chain
+// with no anchor.
+func (cw *ChainWriter) prune() error {
+	if cw.opts.Retention <= 0 {
+		return nil
+	}
+	segments, err := sealedSegments(cw.path)
+	if err != nil {
+		return err
+	}
+	cutoff := cw.now().Add(-cw.opts.Retention)
+	expired := 0
+	for expired < len(segments) && segments[expired].sealed.Before(cutoff) {
+		expired++
+	}
+	if expired == 0 {
+		return nil
+	}
+
+	last, err := readLastRecord(segments[expired-1].path)
+	if err != nil {
+		return err
+	}
+	checkpoint := &Checkpoint{PrunedSegments: expired}
+	if last != nil {
+		checkpoint.PrunedThrough = last.EntryHash
+	}
+	if _, err := cw.write(Record{
+		Timestamp:     cw.now().UTC(),
+		ActionSummary: fmt.Sprintf("retention-prune segments=%d", expired),
+		Kind:          KindCheckpoint,
+		Checkpoint:    checkpoint,
+	}); err != nil {
+		return err
+	}
+
+	for _, seg := range segments[:expired] {
+		if err := os.Remove(seg.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// readLastRecord parses the final record of path, reading backwards from
+// the end so that startup cost does not grow with the segment.
+func readLastRecord(path string) (*Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var tail []byte
+	for offset := info.Size(); offset > 0; {
+		n := min(int64(tailChunkSize), offset)
+		offset -= n
+		chunk := make([]byte, n)
+		if _, err := f.ReadAt(chunk, offset); err != nil {
+			return nil, err
+		}
+		tail = append(chunk, tail...)
+
+		trimmed := bytes.TrimRight(tail, " \t\r\n")
+		start := bytes.LastIndexByte(trimmed, '\n')
+		if start < 0 && offset > 0 {
+			continue
+		}
+		line := bytes.TrimSpace(trimmed[start+1:])
+		if len(line) == 0 {
+			return nil, nil
+		}
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, err
+		}
+		return &record, nil
+	}
+	return nil, nil
+}
+
+func readFirstRecord(path string) (*Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			var record Record
+			if err := json.Unmarshal(trimmed, &record); err != nil {
+				return nil, err
+			}
+			return &record, nil
+		}
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func appendEvents(t *testing.T, cw *ChainWriter, n int) []Record {
+	t.Helper()
+	records := make([]Record, 0, n)
+	for i := 0; i < n; i++ {
+		record, err := cw.Append(Event{RequestID: "req", PolicyVersion: "v2.1", ActionSummary: "event", RiskCategory: "Low", Route: "raw_forward"})
+		if err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestChainWriterRotatesBySizeAndLinksSegments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	cw, err := NewChainWriterWithOptions(path, Options{MaxBytes: 1})
+	if err != nil {
+		t.Fatalf("NewChainWriterWithOptions failed: %v", err)
+	}
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	cw.now = func() time.Time { now = now.Add(time.Second); return now }
+
+	records := appendEvents(t, cw, 3)
+
+	segments, err := sealedSegments(path)
+	if err != nil {
+		t.Fatalf("sealedSegments failed: %v", err)
+	}
+	if len(segments) != 2 {
+		t.Fatalf("expected 2 sealed segments, got %d", len(segments))
+	}
+	last, err := readLastRecord(segments[1].path)
+	if err != nil || last == nil || last.EntryHash != records[1].EntryHash {
+		t.Fatalf("expected the second segment to end with the second record, got %+v %v", last, err)
+	}
+	first, err := readFirstRecord(path)
+	if err != nil || first == nil || first.PrevHash != records[1].EntryHash {
+		t.Fatalf("expected the active segment to link to the sealed one, got %+v %v", first, err)
+	}
+	if err := VerifyChain(path); err != nil {
+		t.Fatalf("VerifyChain failed across segments: %v", err)
+	}
+}
+
+func TestChainWriterRotatesByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	cw, err := NewChainWriterWithOptions(path, Options{MaxAge: time.Hour})
+	if err != nil {
+		t.Fatalf("NewChainWriterWithOptions failed: %v", err)
+	}
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	cw.now = func() time.Time { return now }
+
+	appendEvents(t, cw, 2)
+	now = now.Add(time.Hour)
+	appendEvents(t, cw, 1)
+
+	segments, err := sealedSegments(path)
+	if err != nil || len(segments) != 1 {
+		t.Fatalf("expected one sealed segment after MaxAge, got %d %v", len(segments), err)
+	}
+}
+
+func TestChainWriterRetentionWritesCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	cw, err := NewChainWriterWithOptions(path, Options{MaxAge: time.Hour, Retention: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("NewChainWriterWithOptions failed: %v", err)
+	}
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	cw.now = func() time.Time { return now }
+
+	var records []Record
+	for i := 0; i < 3; i++ {
+		records = append(records, appendEvents(t, cw, 1)...)
+		now = now.Add(20 * time.Hour)
+	}
+	// Segments are sealed at +20h, +40h and +60h; at +60h only the first
+	// is past retention.
+	appendEvents(t, cw, 1)
+
+	segments, err := sealedSegments(path)
+	if err != nil || len(segments) != 2 {
+		t.Fatalf("expected the expired segment to be pruned, got %d %v", len(segments), err)
+	}
+	first, err := readFirstRecord(path)
+	if err != nil || first == nil || first.Kind != KindCheckpoint {
+		t.Fatalf("expected the active segment to open with a checkpoint, got %+v %v", first, err)
+	}
+	if first.Checkpoint.PrunedThrough != records[0].EntryHash || first.Checkpoint.PrunedSegments != 1 {
+		t.Fatalf("unexpected checkpoint %+v", first.Checkpoint)
+	}
+	if err := VerifyChain(path); err != nil {
+		t.Fatalf("VerifyChain failed after pruning: %v", err)
+	}
+}
+
+func TestVerifyChainRejectsDeletedSegmentWithoutCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	cw, err := NewChainWriterWithOptions(path, Options{MaxBytes: 1})
+	if err != nil {
+		t.Fatalf("NewChainWriterWithOptions failed: %v", err)
+	}
+	appendEvents(t, cw, 3)
+
+	segments, err := sealedSegments(path)
+	if err != nil || len(segments) == 0 {
+		t.Fatalf("expected sealed segments, got %d %v", len(segments), err)
+	}
+	if err := os.Remove(segments[0].path); err != nil {
+		t.Fatalf("remove segment failed: %v", err)
+	}
+	if err := VerifyChain(path); err == nil {
+		t.Fatal("expected VerifyChain to reject a chain with a deleted head")
+	}
+}
+
+func TestNewChainWriterResumesFromTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	cw, err := NewChainWriterWithOptions(path, Options{MaxBytes: 1 << 20})
+	if err != nil {
+		t.Fatalf("NewChainWriterWithOptions failed: %v", err)
+	}
+	appendEvents(t, cw, 50)
+	long, err := cw.Append(Event{RequestID: "req-long", ActionSummary: strings.Repeat("x", 3*tailChunkSize)})
+	if err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	resumed, err := NewChainWriterWithOptions(path, Options{MaxBytes: 1 << 20})
+	if err != nil {
+		t.Fatalf("NewChainWriterWithOptions failed: %v", err)
+	}
+	if resumed.prevHash != long.EntryHash || resumed.size != cw.size {
+		t.Fatalf("expected to resume at %q with size %d, got %q with size %d", long.EntryHash, cw.size, resumed.prevHash, resumed.size)
+	}
+
+	// Just after a rotation the active segment does not exist yet.
+	if err := os.Rename(path, path+"."+time.Now().UTC().Format(sealedLayout)); err != nil {
+		t.Fatalf("rename failed: %v", err)
+	}
+	resumed, err = NewChainWriter(path)
+	if err != nil {
+		t.Fatalf("NewChainWriter failed: %v", err)
+	}
+	if resumed.prevHash != long.EntryHash {
+		t.Fatalf("expected to resume from the sealed segment, got %q", resumed.prevHash)
+	}
+}
@@ -0,0 +1,96 @@
+package audit
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// LoadSigningKey reads an Ed25519 private key in PKCS #8 PEM form, as
+// written by `openssl genpkey -algorithm ed25519`.
+func LoadSigningKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse signing key: %w", err)
+	}
+	signingKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("signing key is not an Ed25519 key")
+	}
+	return signingKey, nil
+}
+
+// LoadPublicKey reads an Ed25519 public key in PKIX PEM form, as written by
+// `openssl pkey -pubout`.
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse public key: %w", err)
+	}
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not an Ed25519 key")
+	}
+	return publicKey, nil
+}
+
+func readPEM(path, blockType string) (*pem.Block, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(contents)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("%s: expected a PEM %q block", path, blockType)
+	}
+	return block, nil
+}
+
+func signEntryHash(key ed25519.PrivateKey, hash string) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(hash)))
+}
+
+func verifyEntryHash(pub ed25519.PublicKey, hash, signature string) bool {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	return err == nil && ed25519.Verify(pub, []byte(hash), sig)
+}
+
+// checkpointIfDue writes a signed checkpoint once CheckpointEvery records
+// or CheckpointInterval have passed since the last one.
+func (cw *ChainWriter) checkpointIfDue() error {
+	if cw.opts.SigningKey == nil || cw.sinceCheckpoint == 0 {
+		return nil
+	}
+	byCount := cw.opts.CheckpointEvery > 0 && cw.sinceCheckpoint >= cw.opts.CheckpointEvery
+	byTime := cw.opts.CheckpointInterval > 0 && cw.now().Sub(cw.lastCheckpoint) >= cw.opts.CheckpointInterval
+	if !byCount && !byTime {
+		return nil
+	}
+	return cw.checkpoint()
+}
+
+// checkpoint signs the chain as it stands. Without a key there is nothing
+// to sign and it writes nothing.
+func (cw *ChainWriter) checkpoint() error {
+	if cw.opts.SigningKey == nil || cw.sinceCheckpoint == 0 {
+		return nil
+	}
+	_, err := cw.write(Record{
+		Timestamp:     cw.now().UTC(),
+		ActionSummary: "checkpoint",
+		Kind:          KindCheckpoint,
+	})
+	return err
+}
@@ -0,0 +1,164 @@
+package audit
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newSigningKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	return pub, priv
+}
+
+func TestChainWriterWritesSignedCheckpoints(t *testing.T) {
+	pub, priv := newSigningKey(t)
+	path := filepath.Join(t.TempDir(), "audit.log")
+	cw, err := NewChainWriterWithOptions(path, Options{SigningKey: priv, CheckpointEvery: 2})
+	if err != nil {
+		t.Fatalf("NewChainWriterWithOptions failed: %v", err)
+	}
+	appendEvents(t, cw, 5)
+
+	var checkpoints int
+	if err := ReadRecords(path, func(record Record) error {
+		if record.Kind == KindCheckpoint {
+			checkpoints++
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("ReadRecords failed: %v", err)
+	}
+	if checkpoints != 2 {
+		t.Fatalf("expected a checkpoint after every 2 records, got %d", checkpoints)
+	}
+
+	unsigned, err := VerifyChainWithKey(path, pub)
+	if err != nil {
+		t.Fatalf("VerifyChainWithKey failed: %v", err)
+	}
+	if unsigned != 1 {
+		t.Fatalf("expected 1 record after the last checkpoint, got %d", unsigned)
+	}
+
+	otherPub, _ := newSigningKey(t)
+	if _, err := VerifyChainWithKey(path, otherPub); err == nil {
+		t.Fatal("expected verification with another key to fail")
+	}
+}
+
+func TestVerifyChainWithKeyDetectsRewrittenChain(t *testing.T) {
+	pub, priv := newSigningKey(t)
+	path := filepath.Join(t.TempDir(), "audit.log")
+	cw, err := NewChainWriterWithOptions(path, Options{SigningKey: priv, CheckpointInterval: time.Minute})
+	if err != nil {
+		t.Fatalf("NewChainWriterWithOptions failed: %v", err)
+	}
+	now := time.Now()
+	cw.now = func() time.Time { return now }
+	appendEvents(t, cw, 2)
+	now = now.Add(time.Minute)
+	appendEvents(t, cw, 1)
+
+	// Rewrite every record with recomputed hashes, keeping the checkpoint
+	// and its signature.
+	var records []Record
+	if err := ReadRecords(path, func(record Record) error {
+		records = append(records, record)
+		return nil
+	}); err != nil {
+		t.Fatalf("ReadRecords failed: %v", err)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	forger, err := NewChainWriter(path)
+	if err != nil {
+		t.Fatalf("NewChainWriter failed: %v", err)
+	}
+	for _, record := range records {
+		if record.Kind != KindCheckpoint {
+			record.ActionSummary = "rewritten"
+		}
+		if _, err := forger.write(record); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+
+	if err := VerifyChain(path); err != nil {
+		t.Fatalf("expected the rewritten hash chain to be self-consistent, got %v", err)
+	}
+	if _, err := VerifyChainWithKey(path, pub); err == nil {
+		t.Fatal("expected VerifyChainWithKey to detect the rewrite")
+	}
+}
+
+func TestRetentionCheckpointIsSigned(t *testing.T) {
+	pub, priv := newSigningKey(t)
+	path := filepath.Join(t.TempDir(), "audit.log")
+	cw, err := NewChainWriterWithOptions(path, Options{MaxAge: time.Hour, Retention: 24 * time.Hour, SigningKey: priv})
+	if err != nil {
+		t.Fatalf("NewChainWriterWithOptions failed: %v", err)
+	}
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	cw.now = func() time.Time { return now }
+	for i := 0; i < 3; i++ {
+		appendEvents(t, cw, 1)
+		now = now.Add(20 * time.Hour)
+	}
+	appendEvents(t, cw, 1)
+
+	first, err := readFirstRecord(path)
+	if err != nil || first == nil || first.Checkpoint == nil {
+		t.Fatalf("expected the active segment to open with a retention checkpoint, got %+v %v", first, err)
+	}
+	if !verifyEntryHash(pub, first.EntryHash, first.Signature) {
+		t.Fatal("expected the retention checkpoint to be signed")
+	}
+	if _, err := VerifyChainWithKey(path, pub); err != nil {
+		t.Fatalf("VerifyChainWithKey failed after pruning: %v", err)
+	}
+}
+
+func TestLoadKeysFromPEM(t *testing.T) {
+	pub, priv := newSigningKey(t)
+	dir := t.TempDir()
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey failed: %v", err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey failed: %v", err)
+	}
+	privPath := filepath.Join(dir, "audit.key")
+	pubPath := filepath.Join(dir, "audit.pub")
+	if err := os.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0o600); err != nil {
+		t.Fatalf("write key failed: %v", err)
+	}
+	if err := os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0o600); err != nil {
+		t.Fatalf("write key failed: %v", err)
+	}
+
+	loadedPriv, err := LoadSigningKey(privPath)
+	if err != nil || !loadedPriv.Equal(priv) {
+		t.Fatalf("LoadSigningKey failed: %v", err)
+	}
+	loadedPub, err := LoadPublicKey(pubPath)
+	if err != nil || !loadedPub.Equal(pub) {
+		t.Fatalf("LoadPublicKey failed: %v", err)
+	}
+	if _, err := LoadSigningKey(pubPath); err == nil {
+		t.Fatal("expected a public key to be rejected as a signing key")
+	}
+}
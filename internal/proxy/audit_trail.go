@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/soloengine/lpg/internal/audit"
+	"github.com/soloengine/lpg/internal/sanitizer"
+)
+
+// auditTrail collects what a request's audit record reports while the
+// request moves through the handler. summary becomes the free-text
+// ActionSummary; the rest become the record's typed fields.
+type auditTrail struct {
+	summary      string
+	start        time.Time
+	detections   map[string]int
+	entityHashes []audit.EntityHash
+	provider     string
+	retries      int
+	abstracted   bool
+}
+
+// newAuditTrail starts the trail of a request received at start. Mapped
+// values are only ever recorded as HMACs under AuditEntityKey.
+func (h *Handler) newAuditTrail(start time.Time, summary string, mappings []sanitizer.Mapping) *auditTrail {
+	t := &auditTrail{summary: summary, start: start}
+	for _, m := range mappings {
+		if t.detections == nil {
+			t.detections = map[string]int{}
+		}
+		t.detections[m.EntityType]++
+		if len(h.auditEntityKey) > 0 {
+			t.entityHashes = append(t.entityHashes, audit.EntityHash{
+				EntityType: m.EntityType,
+				HMAC:       audit.HashEntity(h.auditEntityKey, m.EntityType, m.OriginalValue),
+			})
+		}
+	}
+	return t
+}
+
+// addHops appends the provider chain to the summary and records the last
+// provider called and the retries made across the chain.
+func (t *auditTrail) addHops(hops []ProviderHop) {
+	t.summary += providerHopSummary(hops)
+	for _, hop := range hops {
+		if hop.Attempts == 0 {
+			continue
+		}
+		t.provider = hop.Provider
+		t.retries += hop.Attempts - 1
+	}
+}
+
+func (t *auditTrail) success(suffix string) audit.Event {
+	return t.event(audit.OutcomeSuccess, http.StatusOK, "", suffix)
+}
+
+func (t *auditTrail) blocked(status int, code, suffix string) audit.Event {
+	return t.event(audit.OutcomeBlocked, status, code, suffix)
+}
+
+func (t *auditTrail) failed(status int, code, suffix string) audit.Event {
+	return t.event(audit.OutcomeError, status, code, suffix)
+}
+
+func (t *auditTrail) event(outcome audit.Outcome, status int, code, suffix string) audit.Event {
+	return audit.Event{
+		ActionSummary: t.summary + suffix,
+		Outcome:       outcome,
+		HTTPStatus:    status,
+		ErrorCode:     code,
+		Provider:      t.provider,
+		Retries:       t.retries,
+		Latency:       time.Since(t.start),
+		Detections:    t.detections,
+		Abstracted:    t.abstracted,
+		EntityHashes:  t.entityHashes,
+	}
+}
+
+// riskFailureEvent is audited when scoring fails, before a request has a
+// trail.
+func riskFailureEvent() audit.Event {
+	return audit.Event{
+		ActionSummary: "risk evaluation failed",
+		Outcome:       audit.OutcomeBlocked,
+		HTTPStatus:    http.StatusForbidden,
+		ErrorCode:     "ERR_POLICY_BLOCK",
+	}
+}
@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/soloengine/lpg/internal/audit"
+	"github.com/soloengine/lpg/internal/sanitizer"
+)
+
+func TestAuditTrailRecordsHopsAndDetections(t *testing.T) {
+	h := NewHandler(HandlerConfig{AuditEntityKey: []byte("key")})
+	trail := h.newAuditTrail(time.Now(), "route=sanitized_forward", []sanitizer.Mapping{
+		{EntityType: "EMAIL", OriginalValue: "a@example.com"},
+		{EntityType: "EMAIL", OriginalValue: "b@example.com"},
+		{EntityType: "PHONE", OriginalValue: "555-010-0000"},
+	})
+	trail.addHops([]ProviderHop{
+		{Provider: "remote", Outcome: hopCircuitOpen},
+		{Provider: "backup", Outcome: "timeout", Attempts: 3},
+		{Provider: "local", Outcome: "ok", Attempts: 1},
+	})
+
+	event := trail.failed(http.StatusBadGateway, "ERR_PROVIDER_FAILURE", " provider-failure")
+	if event.Outcome != audit.OutcomeError || event.Provider != "local" || event.Retries != 2 {
+		t.Fatalf("unexpected outcome, provider or retries: %+v", event)
+	}
+	if event.Detections["EMAIL"] != 2 || event.Detections["PHONE"] != 1 || len(event.EntityHashes) != 3 {
+		t.Fatalf("unexpected detections %v and hashes %v", event.Detections, event.EntityHashes)
+	}
+	if event.ActionSummary != "route=sanitized_forward providers=remote:circuit_open,backup:timeout/attempts=3,local:ok provider-failure" {
+		t.Fatalf("unexpected summary %q", event.ActionSummary)
+	}
+
+	if NewHandler(HandlerConfig{}).newAuditTrail(time.Now(), "", []sanitizer.Mapping{{EntityType: "EMAIL", OriginalValue: "a@example.com"}}).entityHashes != nil {
+		t.Fatal("expected no entity hashes without a key")
+	}
+}
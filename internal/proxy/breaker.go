@@ -0,0 +1,234 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+const (
+	defaultBreakerWindow        = 20
+	defaultBreakerMinRequests   = 5
+	defaultBreakerFailureRate   = 0.5
+	defaultBreakerOpenDuration  = 30 * time.Second
+	defaultBreakerProbeTimeout  = 2 * time.Second
+	defaultBreakerProbeInterval = 5 * time.Second
+)
+
+var errProviderUnavailable = errors.New("provider circuit open")
+
+// BreakerConfig tunes a CircuitBreaker. Zero values take the defaults,
+// except ProbeInterval, which must be negative to disable probes.
+type BreakerConfig struct {
+	// Window is the number of recent requests the failure rate is computed
+	// over; the circuit cannot open before MinRequests of them.
+	Window      int
+	MinRequests int
+	FailureRate float64
+	// OpenDuration is how long an open circuit fails fast before it lets a
+	// single trial request through.
+	OpenDuration time.Duration
+	// ProbeInterval spaces active health probes while the circuit is open.
+	// A healthy probe lets the trial through without waiting out
+	// OpenDuration.
+	ProbeInterval time.Duration
+	ProbeTimeout  time.Duration
+}
+
+func (c BreakerConfig) withDefaults() BreakerConfig {
+	if c.Window <= 0 {
+		c.Window = defaultBreakerWindow
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = defaultBreakerMinRequests
+	}
+	c.MinRequests = min(c.MinRequests, c.Window)
+	if c.FailureRate <= 0 || c.FailureRate > 1 {
+		c.FailureRate = defaultBreakerFailureRate
+	}
+	if c.OpenDuration <= 0 {
+		c.OpenDuration = defaultBreakerOpenDuration
+	}
+	if c.ProbeInterval == 0 {
+		c.ProbeInterval = defaultBreakerProbeInterval
+	}
+	if c.ProbeTimeout <= 0 {
+		c.ProbeTimeout = defaultBreakerProbeTimeout
+	}
+	return c
+}
+
+// HealthProber is implemented by adapters that can check that their
+// provider is reachable without sending it a prompt.
+type HealthProber interface {
+	Probe(ctx context.Context) error
+}
+
+// CircuitBreaker tracks the health of one provider adapter. Closed, it
+// counts timeouts, 5xx responses and connection errors over a sliding
+// window and opens once they reach FailureRate. Open, it rejects requests
+// so they fail fast or fail over. After OpenDuration, or as soon as an
+// active probe succeeds, it half-opens and lets one trial request decide
+// whether to close again.
+type CircuitBreaker struct {
+	cfg    BreakerConfig
+	prober HealthProber
+	now    func() time.Time
+
+	mu        sync.Mutex
+	state     BreakerState
+	outcomes  []bool // ring buffer; true is a failure
+	next      int
+	recorded  int
+	failures  int
+	openedAt  time.Time
+	trialBusy bool
+	probing   bool
+}
+
+// NewCircuitBreaker returns a closed breaker for adapter. Active probes run
+// only when adapter implements HealthProber.
+func NewCircuitBreaker(cfg BreakerConfig, adapter UpstreamAdapter) *CircuitBreaker {
+	cfg = cfg.withDefaults()
+	prober, _ := adapter.(HealthProber)
+	return &CircuitBreaker{
+		cfg:      cfg,
+		prober:   prober,
+		now:      time.Now,
+		state:    BreakerClosed,
+		outcomes: make([]bool, cfg.Window),
+	}
+}
+
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.halfOpenIfDue()
+	return b.state
+}
+
+// allow reports whether a request may be sent. In half-open state only one
+// trial is admitted at a time. A nil breaker always allows.
+func (b *CircuitBreaker) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.halfOpenIfDue()
+	switch b.state {
+	case BreakerClosed:
+		return true
+	case BreakerHalfOpen:
+		if b.trialBusy {
+			return false
+		}
+		b.trialBusy = true
+		return true
+	default:
+		return false
+	}
+}
+
+// record feeds the outcome of an allowed request back. Requests cancelled
+// by the client say nothing about the provider and are not counted.
+func (b *CircuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	cancelled := errors.Is(err, context.Canceled)
+	failed := err != nil && !cancelled && failoverEligible(err)
+	switch b.state {
+	case BreakerHalfOpen:
+		b.trialBusy = false
+		switch {
+		case cancelled:
+		case failed:
+			b.open()
+		default:
+			b.close()
+		}
+	case BreakerClosed:
+		if cancelled {
+			return
+		}
+		if b.recorded == len(b.outcomes) && b.outcomes[b.next] {
+			b.failures--
+		}
+		b.outcomes[b.next] = failed
+		b.next = (b.next + 1) % len(b.outcomes)
+		b.recorded = min(b.recorded+1, len(b.outcomes))
+		if failed {
+			b.failures++
+		}
+		if b.recorded >= b.cfg.MinRequests && float64(b.failures)/float64(b.recorded) >= b.cfg.FailureRate {
+			b.open()
+		}
+	}
+}
+
+func (b *CircuitBreaker) open() {
+	b.state = BreakerOpen
+	b.openedAt = b.now()
+	if b.prober != nil && b.cfg.ProbeInterval > 0 && !b.probing {
+		b.probing = true
+		go b.probeUntilHealthy()
+	}
+}
+
+func (b *CircuitBreaker) close() {
+	b.state = BreakerClosed
+	clear(b.outcomes)
+	b.next, b.recorded, b.failures = 0, 0, 0
+}
+
+func (b *CircuitBreaker) halfOpenIfDue() {
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.cfg.OpenDuration {
+		b.state = BreakerHalfOpen
+		b.trialBusy = false
+	}
+}
+
+// probeUntilHealthy probes the provider while the circuit stays open and
+// half-opens it on the first healthy probe.
+func (b *CircuitBreaker) probeUntilHealthy() {
+	ticker := time.NewTicker(b.cfg.ProbeInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		b.mu.Lock()
+		if b.state != BreakerOpen {
+			b.probing = false
+			b.mu.Unlock()
+			return
+		}
+		b.mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), b.cfg.ProbeTimeout)
+		err := b.prober.Probe(ctx)
+		cancel()
+		if err != nil {
+			continue
+		}
+
+		b.mu.Lock()
+		if b.state == BreakerOpen {
+			b.state = BreakerHalfOpen
+			b.trialBusy = false
+		}
+		b.probing = false
+		b.mu.Unlock()
+		return
+	}
+}
@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errUnavailable = &ProviderHTTPStatusError{StatusCode: http.StatusServiceUnavailable}
+
+func newTestBreaker(cfg BreakerConfig, adapter UpstreamAdapter) (*CircuitBreaker, *time.Time) {
+	b := NewCircuitBreaker(cfg, adapter)
+	now := time.Unix(1_700_000_000, 0)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func TestCircuitBreakerOpensAtFailureRate(t *testing.T) {
+	b, _ := newTestBreaker(BreakerConfig{Window: 4, MinRequests: 4, FailureRate: 0.5, ProbeInterval: -1}, StubUpstream{})
+
+	for _, err := range []error{nil, errUnavailable, nil} {
+		if !b.allow() {
+			t.Fatal("expected a closed circuit to allow requests")
+		}
+		b.record(err)
+	}
+	if got := b.State(); got != BreakerClosed {
+		t.Fatalf("expected the circuit to stay closed below MinRequests, got %s", got)
+	}
+
+	b.allow()
+	b.record(errUnavailable)
+	if got := b.State(); got != BreakerOpen {
+		t.Fatalf("expected 2 of 4 failures to open the circuit, got %s", got)
+	}
+	if b.allow() {
+		t.Fatal("expected an open circuit to reject requests")
+	}
+}
+
+func TestCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	b, _ := newTestBreaker(BreakerConfig{Window: 2, MinRequests: 2, FailureRate: 0.5, ProbeInterval: -1}, StubUpstream{})
+
+	for _, err := range []error{
+		&ProviderHTTPStatusError{StatusCode: http.StatusBadRequest},
+		context.Canceled,
+		errors.New("parse provider response"),
+		&ProviderHTTPStatusError{StatusCode: http.StatusTooManyRequests},
+	} {
+		b.allow()
+		b.record(err)
+	}
+	if got := b.State(); got != BreakerClosed {
+		t.Fatalf("expected non-failover errors not to open the circuit, got %s", got)
+	}
+}
+
+func TestCircuitBreakerHalfOpenAdmitsOneTrial(t *testing.T) {
+	b, now := newTestBreaker(BreakerConfig{Window: 1, MinRequests: 1, OpenDuration: time.Second, ProbeInterval: -1}, StubUpstream{})
+	b.allow()
+	b.record(errUnavailable)
+
+	*now = now.Add(time.Second)
+	if !b.allow() {
+		t.Fatal("expected a trial request once OpenDuration elapsed")
+	}
+	if b.allow() {
+		t.Fatal("expected only one trial request while half-open")
+	}
+	b.record(errUnavailable)
+	if got := b.State(); got != BreakerOpen {
+		t.Fatalf("expected a failed trial to reopen the circuit, got %s", got)
+	}
+
+	*now = now.Add(time.Second)
+	b.allow()
+	b.record(nil)
+	if got := b.State(); got != BreakerClosed {
+		t.Fatalf("expected a successful trial to close the circuit, got %s", got)
+	}
+}
+
+type probedUpstream struct {
+	StubUpstream
+	healthy atomic.Bool
+	probes  atomic.Int32
+}
+
+func (u *probedUpstream) Probe(ctx context.Context) error {
+	u.probes.Add(1)
+	if !u.healthy.Load() {
+		return errUnavailable
+	}
+	return nil
+}
+
+func TestCircuitBreakerHealthyProbeHalfOpens(t *testing.T) {
+	upstream := &probedUpstream{}
+	b := NewCircuitBreaker(BreakerConfig{Window: 1, MinRequests: 1, OpenDuration: time.Hour, ProbeInterval: time.Millisecond}, upstream)
+	b.allow()
+	b.record(errUnavailable)
+
+	deadline := time.Now().Add(time.Second)
+	for upstream.probes.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := b.State(); got != BreakerOpen {
+		t.Fatalf("expected failing probes to keep the circuit open, got %s", got)
+	}
+
+	upstream.healthy.Store(true)
+	for b.State() != BreakerHalfOpen && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := b.State(); got != BreakerHalfOpen {
+		t.Fatalf("expected a healthy probe to half-open the circuit, got %s", got)
+	}
+}
+
+func TestForwardSkipsOpenCircuit(t *testing.T) {
+	open := NewCircuitBreaker(BreakerConfig{Window: 1, MinRequests: 1, ProbeInterval: -1}, StubUpstream{})
+	open.allow()
+	open.record(errUnavailable)
+
+	h := NewHandler(HandlerConfig{Providers: []Provider{{Name: "primary", Adapter: StubUpstream{}, Breaker: open}}})
+	_, hops, err := h.forward(context.Background(), ForwardRequest{Route: "sanitized_forward"}, false)
+	if !errors.Is(err, errProviderUnavailable) {
+		t.Fatalf("expected errProviderUnavailable, got %v", err)
+	}
+	if len(hops) != 1 || hops[0].Outcome != hopCircuitOpen {
+		t.Fatalf("expected a circuit_open hop, got %+v", hops)
+	}
+
+	h = NewHandler(HandlerConfig{Providers: []Provider{
+		{Name: "primary", Adapter: StubUpstream{}, Breaker: open},
+		{Name: "secondary", Adapter: StubUpstream{}},
+	}})
+	if _, hops, err := h.forward(context.Background(), ForwardRequest{Route: "sanitized_forward"}, false); err != nil || len(hops) != 2 || hops[1].Outcome != hopOK {
+		t.Fatalf("expected the fallback provider to serve the request, got hops=%+v err=%v", hops, err)
+	}
+}
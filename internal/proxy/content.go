@@ -0,0 +1,288 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/soloengine/lpg/internal/router"
+)
+
+const (
+	contentPartText  = "text"
+	contentPartImage = "image_url"
+)
+
+// ContentPart is one element of array-form message content. Text parts are
+// sanitized like string content; image parts cannot be inspected and are
+// handled by the route's ImagePolicy instead.
+type ContentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+type ImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// UnmarshalJSON accepts content as a string, null or an array of content
+// parts. Array content is kept in Parts and leaves Content empty.
+func (m *ChatMessage) UnmarshalJSON(data []byte) error {
+	type plain ChatMessage
+	var raw struct {
+		plain
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*m = ChatMessage(raw.plain)
+
+	content := bytes.TrimSpace(raw.Content)
+	if len(content) == 0 || bytes.Equal(content, []byte("null")) {
+		return nil
+	}
+	if content[0] == '[' {
+		m.Parts = []ContentPart{}
+		if err := json.Unmarshal(content, &m.Parts); err != nil {
+			return errors.New("content must be a string or an array of content parts")
+		}
+		return nil
+	}
+	if err := json.Unmarshal(content, &m.Content); err != nil {
+		return errors.New("content must be a string or an array of content parts")
+	}
+	return nil
+}
+
+// MarshalJSON writes Parts as array content when set and Content otherwise.
+func (m ChatMessage) MarshalJSON() ([]byte, error) {
+	type plain ChatMessage
+	out := struct {
+		plain
+		Content any `json:"content"`
+	}{plain: plain(m), Content: m.Content}
+	if m.Parts != nil {
+		out.Content = m.Parts
+	}
+	return json.Marshal(out)
+}
+
+func validateContentParts(i int, m ChatMessage) error {
+	if m.Parts == nil {
+		return nil
+	}
+	if len(m.Parts) == 0 {
+		return fmt.Errorf("messages[%d].content must not be empty", i)
+	}
+	for j, part := range m.Parts {
+		switch part.Type {
+		case contentPartText:
+		case contentPartImage:
+			if m.Role != "user" {
+				return fmt.Errorf("messages[%d].content[%d]: image parts are only allowed on user messages", i, j)
+			}
+			if part.ImageURL == nil || strings.TrimSpace(part.ImageURL.URL) == "" {
+				return fmt.Errorf("messages[%d].content[%d].image_url.url is required", i, j)
+			}
+		default:
+			return fmt.Errorf("messages[%d].content[%d].type %q is not supported: must be %q or %q", i, j, part.Type, contentPartText, contentPartImage)
+		}
+	}
+	return nil
+}
+
+// hasContent reports whether m carries non-empty text or at least one image.
+func hasContent(m ChatMessage) bool {
+	if m.Parts == nil {
+		return strings.TrimSpace(m.Content) != ""
+	}
+	for _, part := range m.Parts {
+		if part.Type == contentPartImage || strings.TrimSpace(part.Text) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+type ImagePolicy string
+
+const (
+	ImagePolicyStrip ImagePolicy = "strip"
+	ImagePolicyBlock ImagePolicy = "block"
+	ImagePolicyAllow ImagePolicy = "allow"
+)
+
+// ImagePolicies maps a route to how image parts are handled on it. Routes
+// without an entry block images.
+type ImagePolicies map[router.Route]ImagePolicy
+
+// ParseImagePolicy parses the image policy for route. Images can be neither
+// sanitized nor abstracted, so allow is only accepted on raw_forward and
+// sanitized_forward: they never leave the host on high or critical routes.
+func ParseImagePolicy(route router.Route, raw string) (ImagePolicy, error) {
+	switch route {
+	case router.RouteRawForward, router.RouteSanitizedForward, router.RouteHighAbstraction, router.RouteCriticalLocalOnly:
+	default:
+		return "", fmt.Errorf("invalid route %q: must be one of %q, %q, %q, %q", route, router.RouteRawForward, router.RouteSanitizedForward, router.RouteHighAbstraction, router.RouteCriticalLocalOnly)
+	}
+
+	policy := ImagePolicy(strings.ToLower(strings.TrimSpace(raw)))
+	switch policy {
+	case ImagePolicyStrip, ImagePolicyBlock:
+		return policy, nil
+	case ImagePolicyAllow:
+		if !imagesMayLeave(route) {
+			return "", fmt.Errorf("image policy %q is not allowed on %s: images never leave the host on high or critical routes", policy, route)
+		}
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid image policy %q: must be one of %q, %q, %q", raw, ImagePolicyStrip, ImagePolicyBlock, ImagePolicyAllow)
+	}
+}
+
+// For returns the policy for route. allow is downgraded to strip on routes
+// where images may not leave the host, whatever the map says.
+func (p ImagePolicies) For(route router.Route) ImagePolicy {
+	policy, ok := p[route]
+	if !ok {
+		return ImagePolicyBlock
+	}
+	if policy == ImagePolicyAllow && !imagesMayLeave(route) {
+		return ImagePolicyStrip
+	}
+	return policy
+}
+
+func imagesMayLeave(route router.Route) bool {
+	return route == router.RouteRawForward || route == router.RouteSanitizedForward
+}
+
+func countImages(messages []ChatMessage) int {
+	images := 0
+	for _, m := range messages {
+		for _, part := range m.Parts {
+			if part.Type == contentPartImage {
+				images++
+			}
+		}
+	}
+	return images
+}
+
+// stripImages removes image parts. Messages left without any part are
+// dropped; they carried no text, so the text layout used by withTexts is
+// unchanged.
+func stripImages(messages []ChatMessage) []ChatMessage {
+	out := make([]ChatMessage, 0, len(messages))
+	for _, m := range messages {
+		if m.Parts == nil {
+			out = append(out, m)
+			continue
+		}
+		parts := make([]ContentPart, 0, len(m.Parts))
+		for _, part := range m.Parts {
+			if part.Type != contentPartImage {
+				parts = append(parts, part)
+			}
+		}
+		if len(parts) == 0 && len(m.ToolCalls) == 0 {
+			continue
+		}
+		m.Parts = parts
+		out = append(out, m)
+	}
+	return out
+}
+
+// applyImagePolicy enforces the route's image policy before anything is
+// forwarded or abstracted. It returns false once a block response has been
+// written.
+func (h *Handler) applyImagePolicy(w http.ResponseWriter, requestID string, messages []ChatMessage, decision router.Decision, trail *auditTrail) ([]ChatMessage, bool) {
+	images := countImages(messages)
+	if images == 0 {
+		return messages, true
+	}
+
+	policy := h.imagePolicies.For(decision.Route)
+	trail.summary += fmt.Sprintf(" images=%d:%s", images, policy)
+	switch policy {
+	case ImagePolicyAllow:
+		return messages, true
+	case ImagePolicyStrip:
+		stripped := stripImages(messages)
+		if len(stripped) > 0 {
+			return stripped, true
+		}
+		h.writeError(w, http.StatusForbidden, "ERR_POLICY_BLOCK", "request has no content left after stripping images", requestID)
+		_ = h.appendAudit(requestID, decision.Category, decision.Route, trail.blocked(http.StatusForbidden, "ERR_POLICY_BLOCK", " blocked"))
+		return nil, false
+	default:
+		h.writeError(w, http.StatusForbidden, "ERR_POLICY_BLOCK", "image content blocked by policy", requestID)
+		_ = h.appendAudit(requestID, decision.Category, decision.Route, trail.blocked(http.StatusForbidden, "ERR_POLICY_BLOCK", " blocked"))
+		return nil, false
+	}
+}
+
+// Content part dispositions reported by /v1/debug/explain.
+const (
+	dispositionForwarded  = "forwarded"
+	dispositionSanitized  = "sanitized"
+	dispositionAbstracted = "abstracted"
+	dispositionLocalOnly  = "local_only"
+	dispositionStripped   = "stripped"
+	dispositionBlocked    = "blocked"
+)
+
+type ExplainContentPart struct {
+	Message     int    `json:"message"`
+	Index       int    `json:"index"`
+	Type        string `json:"type"`
+	Disposition string `json:"disposition"`
+}
+
+// explainContentParts reports what happens to each part of array-form
+// content on route. A blocked image blocks the whole request, so every part
+// is reported blocked then.
+func (h *Handler) explainContentParts(messages []ChatMessage, route router.Route) []ExplainContentPart {
+	policy := h.imagePolicies.For(route)
+	blocked := route == router.RouteCriticalBlocked || (countImages(messages) > 0 && policy == ImagePolicyBlock)
+
+	textDisposition := dispositionBlocked
+	switch {
+	case blocked:
+	case route == router.RouteRawForward:
+		textDisposition = dispositionForwarded
+	case route == router.RouteSanitizedForward:
+		textDisposition = dispositionSanitized
+	case route == router.RouteHighAbstraction:
+		textDisposition = dispositionAbstracted
+	case route == router.RouteCriticalLocalOnly:
+		textDisposition = dispositionLocalOnly
+	}
+	imageDisposition := dispositionBlocked
+	switch {
+	case blocked:
+	case policy == ImagePolicyAllow:
+		imageDisposition = dispositionForwarded
+	case policy == ImagePolicyStrip:
+		imageDisposition = dispositionStripped
+	}
+
+	parts := []ExplainContentPart{}
+	for i, m := range messages {
+		for j, part := range m.Parts {
+			disposition := textDisposition
+			if part.Type == contentPartImage {
+				disposition = imageDisposition
+			}
+			parts = append(parts, ExplainContentPart{Message: i, Index: j, Type: part.Type, Disposition: disposition})
+		}
+	}
+	return parts
+}
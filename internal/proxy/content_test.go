@@ -0,0 +1,114 @@
+package proxy
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/soloengine/lpg/internal/router"
+)
+
+func TestChatMessageContentForms(t *testing.T) {
+	var messages []ChatMessage
+	body := `[{"role":"user","content":"plain"},{"role":"assistant","content":null,"tool_calls":[{"id":"c","type":"function","function":{"name":"f","arguments":"{}"}}]},{"role":"user","content":[{"type":"text","text":"look"},{"type":"image_url","image_url":{"url":"https://img.example/x.png"}}]}]`
+	if err := json.Unmarshal([]byte(body), &messages); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if messages[0].Content != "plain" || messages[0].Parts != nil {
+		t.Fatalf("expected string content, got %+v", messages[0])
+	}
+	if messages[1].Content != "" || len(messages[1].ToolCalls) != 1 {
+		t.Fatalf("expected null content with tool calls, got %+v", messages[1])
+	}
+	if len(messages[2].Parts) != 2 || messages[2].Parts[1].ImageURL.URL != "https://img.example/x.png" {
+		t.Fatalf("expected two content parts, got %+v", messages[2])
+	}
+
+	out, err := json.Marshal(messages[2])
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if got := string(out); got != `{"role":"user","content":[{"type":"text","text":"look"},{"type":"image_url","image_url":{"url":"https://img.example/x.png"}}]}` {
+		t.Fatalf("unexpected array content encoding %s", got)
+	}
+	out, err = json.Marshal(providerChatMessage{Role: "user", Parts: messages[2].Parts})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !strings.Contains(string(out), `"content":[{"type":"text"`) {
+		t.Fatalf("expected provider message to carry array content, got %s", out)
+	}
+
+	if err := json.Unmarshal([]byte(`{"role":"user","content":42}`), &ChatMessage{}); err == nil {
+		t.Fatal("expected numeric content to be rejected")
+	}
+}
+
+func TestValidateContentParts(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "empty array", body: `{"role":"user","content":[]}`, want: "messages[0].content must not be empty"},
+		{name: "unknown type", body: `{"role":"user","content":[{"type":"input_audio"}]}`, want: `messages[0].content[0].type "input_audio" is not supported`},
+		{name: "image without url", body: `{"role":"user","content":[{"type":"image_url","image_url":{}}]}`, want: "messages[0].content[0].image_url.url is required"},
+		{name: "image on system", body: `{"role":"system","content":[{"type":"image_url","image_url":{"url":"u"}}]}`, want: "image parts are only allowed on user messages"},
+		{name: "blank text only", body: `{"role":"user","content":[{"type":"text","text":" "}]}`, want: "messages[0].content is required"},
+		{name: "image only", body: `{"role":"user","content":[{"type":"image_url","image_url":{"url":"u"}}]}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var m ChatMessage
+			if err := json.Unmarshal([]byte(tc.body), &m); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			err := validateMessage(0, m)
+			if tc.want == "" {
+				if err != nil {
+					t.Fatalf("expected valid message, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestImagePoliciesNeverAllowImagesOffHostOnHighOrCritical(t *testing.T) {
+	if _, err := ParseImagePolicy(router.RouteHighAbstraction, "allow"); err == nil {
+		t.Fatal("expected allow to be rejected on high_abstraction")
+	}
+	if _, err := ParseImagePolicy(router.RouteCriticalBlocked, "strip"); err == nil {
+		t.Fatal("expected critical_blocked to be rejected")
+	}
+	if policy, err := ParseImagePolicy(router.RouteSanitizedForward, " Allow "); err != nil || policy != ImagePolicyAllow {
+		t.Fatalf("expected allow on sanitized_forward, got %q %v", policy, err)
+	}
+
+	policies := ImagePolicies{router.RouteSanitizedForward: ImagePolicyAllow, router.RouteCriticalLocalOnly: ImagePolicyAllow}
+	if got := policies.For(router.RouteCriticalLocalOnly); got != ImagePolicyStrip {
+		t.Fatalf("expected allow to be downgraded to strip on critical_local_only, got %q", got)
+	}
+	if got := policies.For(router.RouteRawForward); got != ImagePolicyBlock {
+		t.Fatalf("expected routes without a policy to block images, got %q", got)
+	}
+}
+
+func TestStripImagesKeepsTextLayout(t *testing.T) {
+	messages := []ChatMessage{
+		{Role: "user", Parts: []ContentPart{{Type: contentPartImage, ImageURL: &ImageURL{URL: "u"}}}},
+		{Role: "user", Parts: []ContentPart{{Type: contentPartText, Text: "a"}, {Type: contentPartImage, ImageURL: &ImageURL{URL: "u"}}, {Type: contentPartText, Text: "b"}}},
+	}
+
+	stripped := stripImages(messages)
+	if len(stripped) != 1 || countImages(stripped) != 0 {
+		t.Fatalf("expected image-only message dropped and images removed, got %+v", stripped)
+	}
+	if texts := messageTexts(stripped); strings.Join(texts, ",") != strings.Join(messageTexts(messages), ",") {
+		t.Fatalf("expected text layout to be unchanged, got %q", texts)
+	}
+}
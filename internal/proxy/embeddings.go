@@ -0,0 +1,242 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/soloengine/lpg/internal/risk"
+	"github.com/soloengine/lpg/internal/router"
+	"github.com/soloengine/lpg/internal/sanitizer"
+)
+
+const maxEmbeddingInputs = 2048
+
+// supportedEmbeddingsFields is the allowlist of top-level /v1/embeddings
+// fields.
+var supportedEmbeddingsFields = map[string]bool{
+	"model":           true,
+	"input":           true,
+	"encoding_format": true,
+	"dimensions":      true,
+	"user":            true,
+}
+
+type EmbeddingsRequest struct {
+	Model          string          `json:"model"`
+	Input          EmbeddingsInput `json:"input"`
+	EncodingFormat string          `json:"encoding_format,omitempty"`
+	Dimensions     *int            `json:"dimensions,omitempty"`
+	User           string          `json:"user,omitempty"`
+}
+
+// EmbeddingsInput accepts a single string or an array of strings. Token
+// arrays are rejected because they cannot be sanitized.
+type EmbeddingsInput []string
+
+func (in *EmbeddingsInput) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*in = EmbeddingsInput{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return errors.New("input must be a string or an array of strings")
+	}
+	*in = many
+	return nil
+}
+
+type EmbeddingsResponse struct {
+	Object string          `json:"object"`
+	Data   []EmbeddingData `json:"data"`
+	Model  string          `json:"model"`
+}
+
+type EmbeddingData struct {
+	Object    string    `json:"object"`
+	Index     int       `json:"index"`
+	Embedding []float64 `json:"embedding"`
+}
+
+// HandleEmbeddings serves /v1/embeddings. Each input is sanitized and scored
+// on its own, so a document embeds to the same surrogate text whatever batch
+// it arrives in, and the strictest per-input decision routes the batch.
+// Only sanitized text is ever embedded, remotely or locally, which keeps
+// vectors comparable across routes.
+func (h *Handler) HandleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	requestID := newRequestID()
+	w.Header().Set("x-lpg-request-id", requestID)
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		h.writeError(w, http.StatusMethodNotAllowed, "ERR_METHOD_NOT_ALLOWED", "method not allowed", requestID)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "ERR_VALIDATION", "invalid JSON payload", requestID)
+		return
+	}
+	field, err := unsupportedField(body, supportedEmbeddingsFields)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "ERR_VALIDATION", "invalid JSON payload", requestID)
+		return
+	}
+	if field != "" {
+		h.writeError(w, http.StatusBadRequest, "ERR_UNSUPPORTED_FIELD", fmt.Sprintf("unsupported field %q", field), requestID)
+		return
+	}
+	var req EmbeddingsRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "ERR_VALIDATION", "invalid JSON payload", requestID)
+		return
+	}
+	if err := req.validate(); err != nil {
+		h.writeError(w, http.StatusBadRequest, "ERR_VALIDATION", err.Error(), requestID)
+		return
+	}
+	if err := h.checkModel(w, requestID, req.Model); err != nil {
+		return
+	}
+
+	inputs := make([]string, 0, len(req.Input))
+	var decision router.Decision
+	var mappings []sanitizer.Mapping
+	for i, input := range req.Input {
+		sanitized, err := h.sanitizer.SanitizeConversation([]string{input})
+		if err == nil && len(sanitized.Messages) != 1 {
+			err = fmt.Errorf("sanitizer returned %d messages for 1 input", len(sanitized.Messages))
+		}
+		if err != nil {
+			h.writeError(w, http.StatusInternalServerError, "ERR_SANITIZATION_FAILURE", "sanitization failed", requestID)
+			return
+		}
+		result, err := h.scorer.EvaluateDetections(riskDetections(sanitized.Mappings), sanitized.Sanitized, minMappingConfidence(sanitized.Mappings))
+		if err != nil {
+			h.writeError(w, http.StatusForbidden, "ERR_POLICY_BLOCK", "risk evaluation failed", requestID)
+			_ = h.appendAudit(requestID, risk.CategoryCritical, router.RouteCriticalBlocked, riskFailureEvent())
+			return
+		}
+		mappings = append(mappings, sanitized.Mappings...)
+		inputDecision := h.router.Decide(result.Category, h.triggeredPolicies(sanitized.Mappings))
+		if i == 0 || routeSeverity(inputDecision.Route) > routeSeverity(decision.Route) {
+			decision = inputDecision
+		}
+		inputs = append(inputs, sanitized.Messages[0])
+	}
+	setDecisionHeaders(w, decision, len(mappings))
+
+	trail := h.newAuditTrail(start, fmt.Sprintf("route=%s category=%s", decision.Route, decision.Category)+entityPolicySummary(decision.Policies)+fmt.Sprintf(" api=embeddings inputs=%d", len(inputs)), mappings)
+
+	idempotencyKey := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	embedReq := EmbedRequest{
+		RequestID:      requestID,
+		Model:          req.Model,
+		Input:          inputs,
+		RiskCategory:   decision.Category,
+		Route:          decision.Route,
+		IdempotencyKey: idempotencyKey,
+		Dimensions:     req.Dimensions,
+		User:           pseudonymizeUser(h.userKey, req.User),
+	}
+
+	var resp EmbedResponse
+	switch decision.Route {
+	case router.RouteRawForward, router.RouteSanitizedForward:
+		trail.summary += " embeddings=remote"
+		var hops []ProviderHop
+		resp, hops, err = h.forwardEmbeddings(r.Context(), embedReq, retryAllowed(decision, idempotencyKey))
+		trail.addHops(hops)
+	case router.RouteHighAbstraction, router.RouteCriticalLocalOnly:
+		if h.localEmbeddings == nil {
+			h.writeError(w, http.StatusForbidden, "ERR_POLICY_BLOCK", "request requires a local embedding model", requestID)
+			_ = h.appendAudit(requestID, decision.Category, decision.Route, trail.blocked(http.StatusForbidden, "ERR_POLICY_BLOCK", " local-embeddings-missing"))
+			return
+		}
+		trail.summary += " embeddings=local"
+		resp, err = h.embedOnce(r.Context(), h.localEmbeddings, embedReq)
+	default:
+		h.writeError(w, http.StatusForbidden, "ERR_POLICY_BLOCK", "request blocked by policy", requestID)
+		_ = h.appendAudit(requestID, risk.CategoryCritical, router.RouteCriticalBlocked, trail.blocked(http.StatusForbidden, "ERR_POLICY_BLOCK", " blocked"))
+		return
+	}
+	if err != nil {
+		h.writeProviderError(w, r.Context(), requestID, decision, trail, err)
+		return
+	}
+
+	if err := h.appendAudit(requestID, decision.Category, decision.Route, trail.success(" success")); err != nil {
+		h.writeError(w, http.StatusInternalServerError, "ERR_AUDIT_FAILURE", "audit append failed", requestID)
+		return
+	}
+
+	data := make([]EmbeddingData, 0, len(resp.Embeddings))
+	for i, embedding := range resp.Embeddings {
+		data = append(data, EmbeddingData{Object: "embedding", Index: i, Embedding: embedding})
+	}
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(EmbeddingsResponse{
+		Object: "list",
+		Data:   data,
+		Model:  req.Model,
+	})
+}
+
+// embedOnce calls the local adapter within one ProviderTimeout, outside the
+// provider chain.
+func (h *Handler) embedOnce(ctx context.Context, adapter EmbeddingsAdapter, req EmbedRequest) (EmbedResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.providerTimeout)
+	defer cancel()
+	resp, err := embedWith(ctx, adapter, req)
+	return resp, hopError(ctx, err)
+}
+
+func (r EmbeddingsRequest) validate() error {
+	if strings.TrimSpace(r.Model) == "" {
+		return errors.New("model is required")
+	}
+	if len(r.Input) == 0 {
+		return errors.New("input is required")
+	}
+	if len(r.Input) > maxEmbeddingInputs {
+		return fmt.Errorf("input accepts at most %d strings", maxEmbeddingInputs)
+	}
+	for i, input := range r.Input {
+		if strings.TrimSpace(input) == "" {
+			return fmt.Errorf("input[%d] must not be empty", i)
+		}
+	}
+	if r.EncodingFormat != "" && r.EncodingFormat != "float" {
+		return fmt.Errorf("encoding_format %q is not supported: must be \"float\"", r.EncodingFormat)
+	}
+	if r.Dimensions != nil && *r.Dimensions <= 0 {
+		return errors.New("dimensions must be > 0")
+	}
+	return nil
+}
+
+// routeSeverity orders routes from most to least permissive so a batch
+// takes the strictest route any of its inputs needs.
+func routeSeverity(route router.Route) int {
+	switch route {
+	case router.RouteRawForward:
+		return 0
+	case router.RouteSanitizedForward:
+		return 1
+	case router.RouteHighAbstraction:
+		return 2
+	case router.RouteCriticalLocalOnly:
+		return 3
+	default:
+		return 4
+	}
+}
@@ -7,27 +7,39 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/soloengine/lpg/internal/audit"
+	"github.com/soloengine/lpg/internal/rehydrate"
 	"github.com/soloengine/lpg/internal/risk"
 	"github.com/soloengine/lpg/internal/router"
 	"github.com/soloengine/lpg/internal/sanitizer"
 )
 
-const defaultProviderTimeout = 2 * time.Second
+const (
+	defaultProviderTimeout   = 2 * time.Second
+	defaultStreamIdleTimeout = 30 * time.Second
+)
 
 type ChatMessage struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// Parts holds array-form content; when set, Content is unused.
+	Parts      []ContentPart `json:"-"`
+	Name       string        `json:"name,omitempty"`
+	ToolCalls  []ToolCall    `json:"tool_calls,omitempty"`
+	ToolCallID string        `json:"tool_call_id,omitempty"`
 }
 
 type ChatCompletionRequest struct {
 	Model    string        `json:"model"`
 	Messages []ChatMessage `json:"messages"`
+	Stream   bool          `json:"stream,omitempty"`
+	GenerationParams
 }
 
 type chatChoice struct {
@@ -39,8 +51,10 @@ type chatChoice struct {
 type ChatCompletionResponse struct {
 	ID      string       `json:"id"`
 	Object  string       `json:"object"`
+	Created int64        `json:"created"`
 	Model   string       `json:"model"`
 	Choices []chatChoice `json:"choices"`
+	Usage   Usage        `json:"usage"`
 }
 
 type errorResponse struct {
@@ -58,37 +72,96 @@ type ExplainMapping struct {
 }
 
 type ExplainResponse struct {
-	RequestID      string           `json:"request_id"`
-	PolicyVersion  string           `json:"policy_version"`
-	Model          string           `json:"model"`
-	SanitizedInput string           `json:"sanitized_input"`
-	Detections     int              `json:"detections"`
-	MinConfidence  float64          `json:"min_confidence"`
-	RiskScore      int              `json:"risk_score"`
-	RiskCategory   risk.Category    `json:"risk_category"`
-	Route          router.Route     `json:"route"`
-	Egress         bool             `json:"egress"`
-	HardBlock      bool             `json:"hard_block"`
-	Mappings       []ExplainMapping `json:"mappings"`
+	RequestID      string                `json:"request_id"`
+	PolicyVersion  string                `json:"policy_version"`
+	Model          string                `json:"model"`
+	SanitizedInput string                `json:"sanitized_input"`
+	Detections     int                   `json:"detections"`
+	MinConfidence  float64               `json:"min_confidence"`
+	RiskScore      int                   `json:"risk_score"`
+	RiskCategory   risk.Category         `json:"risk_category"`
+	RiskFactors    []risk.Factor         `json:"risk_factors"`
+	Route          router.Route          `json:"route"`
+	Egress         bool                  `json:"egress"`
+	HardBlock      bool                  `json:"hard_block"`
+	EntityActions  []router.EntityPolicy `json:"entity_actions"`
+	Mappings       []ExplainMapping      `json:"mappings"`
+	ContentParts   []ExplainContentPart  `json:"content_parts"`
 }
 
 type ForwardRequest struct {
-	RequestID       string
-	Model           string
-	SanitizedPrompt string
-	RiskCategory    risk.Category
-	Route           router.Route
-	IdempotencyKey  string
+	RequestID      string
+	Model          string
+	Messages       []ChatMessage
+	RiskCategory   risk.Category
+	Route          router.Route
+	IdempotencyKey string
+	Params         GenerationParams
+	// Responses is set for /v1/responses traffic. Providers implementing
+	// ResponsesUpstreamAdapter receive it natively; the rest get the same
+	// request as chat completions.
+	Responses bool
 }
 
 type ForwardResponse struct {
 	Content string
+	// Choices holds the provider's choices when there is more than one
+	// (n > 1) or when they carry tool calls; Content is then ignored.
+	Choices []ForwardChoice
+	Usage   Usage
+}
+
+type ForwardChoice struct {
+	Content      string
+	ToolCalls    []ToolCall
+	FinishReason string
 }
 
 type UpstreamAdapter interface {
 	ChatCompletions(ctx context.Context, req ForwardRequest) (ForwardResponse, error)
 }
 
+// ResponsesUpstreamAdapter is implemented by providers that can accept the
+// Responses API. SupportsResponses reports whether it is enabled for this
+// provider instance.
+type ResponsesUpstreamAdapter interface {
+	UpstreamAdapter
+	SupportsResponses() bool
+	Responses(ctx context.Context, req ForwardRequest) (ForwardResponse, error)
+}
+
+type StreamChunk struct {
+	Content      string
+	ToolCalls    []ToolCallDelta
+	FinishReason string
+}
+
+type StreamingUpstreamAdapter interface {
+	UpstreamAdapter
+	ChatCompletionsStream(ctx context.Context, req ForwardRequest, onChunk func(StreamChunk) error) error
+}
+
+// EmbedRequest carries already-sanitized inputs to an embeddings provider.
+type EmbedRequest struct {
+	RequestID      string
+	Model          string
+	Input          []string
+	RiskCategory   risk.Category
+	Route          router.Route
+	IdempotencyKey string
+	Dimensions     *int
+	User           string
+}
+
+// EmbedResponse holds one vector per input, in input order.
+type EmbedResponse struct {
+	Embeddings [][]float64
+}
+
+type EmbeddingsAdapter interface {
+	Embeddings(ctx context.Context, req EmbedRequest) (EmbedResponse, error)
+}
+
 type AbstractRequest struct {
 	RequestID       string
 	SanitizedPrompt string
@@ -101,7 +174,7 @@ type Abstractor interface {
 }
 
 type Sanitizer interface {
-	Sanitize(input string) (sanitizer.Result, error)
+	SanitizeConversation(messages []string) (sanitizer.Result, error)
 }
 
 type AuditWriter interface {
@@ -109,25 +182,71 @@ type AuditWriter interface {
 }
 
 type HandlerConfig struct {
-	Sanitizer       Sanitizer
-	Scorer          *risk.Scorer
-	Router          *router.Engine
-	Upstream        UpstreamAdapter
+	Sanitizer Sanitizer
+	Scorer    *risk.Scorer
+	Router    *router.Engine
+	Upstream  UpstreamAdapter
+	// Providers is the ordered fallback chain. When empty, Upstream (if
+	// set) becomes a single unconstrained provider.
+	Providers []Provider
+	// /v1/embeddings batches on egress routes go to the chain's eligible
+	// providers that can embed. LocalEmbeddings serves high and critical
+	// inputs, which never leave the host.
+	LocalEmbeddings EmbeddingsAdapter
 	Abstractor      Abstractor
 	Audit           AuditWriter
-	ProviderTimeout time.Duration
-	PolicyVersion   string
-	StrictAudit     bool
+	Rehydrator      *rehydrate.Guard
+	EntityPolicies  router.EntityPolicies
+	// RehydrateTools lists the functions whose tool-call arguments have
+	// known surrogates restored before they reach the client. Arguments
+	// of any other tool keep their surrogates.
+	RehydrateTools []string
+	// ModelAllowlist, when set, is the model catalogue: requests naming
+	// any other model are rejected before sanitization.
+	ModelAllowlist []string
+	// ImagePolicies decides what happens to image content parts on each
+	// route; routes without an entry block them.
+	ImagePolicies ImagePolicies
+	// ProviderTimeout bounds each provider attempt; Retry bounds the
+	// attempts and the request as a whole. Streams are bounded by both only
+	// until their first chunk, then by StreamIdleTimeout between chunks.
+	ProviderTimeout   time.Duration
+	StreamIdleTimeout time.Duration
+	Retry             RetryPolicy
+	// Idempotency, when set, answers replayed Idempotency-Key requests
+	// from cache.
+	Idempotency *IdempotencyCache
+	// UserPseudonymKey keys the pseudonym that replaces the end-user
+	// identifier before egress. Unset, a random key is generated, so
+	// pseudonyms change whenever the process restarts.
+	UserPseudonymKey []byte
+	// AuditEntityKey, when set, adds an HMAC of each mapped entity value
+	// to audit records. Without it only per-type counts are recorded.
+	AuditEntityKey []byte
+	PolicyVersion  string
+	StrictAudit    bool
 }
 
 type Handler struct {
 	sanitizer       Sanitizer
 	scorer          *risk.Scorer
 	router          *router.Engine
-	upstream        UpstreamAdapter
+	providers       []Provider
+	localEmbeddings EmbeddingsAdapter
 	abstractor      Abstractor
 	audit           AuditWriter
+	rehydrator      *rehydrate.Guard
+	entityPolicies  router.EntityPolicies
+	rehydrateTools  map[string]bool
+	modelAllowlist  []string
+	imagePolicies   ImagePolicies
 	providerTimeout time.Duration
+	idleTimeout     time.Duration
+	retry           RetryPolicy
+	jitter          func() float64
+	idempotency     *IdempotencyCache
+	userKey         []byte
+	auditEntityKey  []byte
 	policyVersion   string
 	strictAudit     bool
 }
@@ -137,13 +256,31 @@ func NewHandler(cfg HandlerConfig) *Handler {
 		sanitizer:       cfg.Sanitizer,
 		scorer:          cfg.Scorer,
 		router:          cfg.Router,
-		upstream:        cfg.Upstream,
+		providers:       cfg.Providers,
+		localEmbeddings: cfg.LocalEmbeddings,
 		abstractor:      cfg.Abstractor,
 		audit:           cfg.Audit,
+		rehydrator:      cfg.Rehydrator,
+		entityPolicies:  cfg.EntityPolicies,
+		rehydrateTools:  make(map[string]bool, len(cfg.RehydrateTools)),
+		modelAllowlist:  cfg.ModelAllowlist,
+		imagePolicies:   cfg.ImagePolicies,
 		providerTimeout: cfg.ProviderTimeout,
+		idleTimeout:     cfg.StreamIdleTimeout,
+		retry:           cfg.Retry.withDefaults(),
+		jitter:          defaultJitter,
+		idempotency:     cfg.Idempotency,
+		userKey:         cfg.UserPseudonymKey,
+		auditEntityKey:  cfg.AuditEntityKey,
 		policyVersion:   cfg.PolicyVersion,
 		strictAudit:     cfg.StrictAudit,
 	}
+	for _, name := range cfg.RehydrateTools {
+		h.rehydrateTools[name] = true
+	}
+	if len(h.providers) == 0 && cfg.Upstream != nil {
+		h.providers = []Provider{{Name: "upstream", Adapter: cfg.Upstream}}
+	}
 	if h.sanitizer == nil {
 		h.sanitizer = sanitizer.NewDefault()
 	}
@@ -153,9 +290,23 @@ func NewHandler(cfg HandlerConfig) *Handler {
 	if h.router == nil {
 		h.router = router.NewEngine(false)
 	}
+	if h.rehydrator == nil {
+		// Rehydration must recognise the surrogates this sanitizer emits,
+		// including templated ones from custom and built-in rules.
+		patterns, _ := h.sanitizer.(rehydrate.PatternSource)
+		h.rehydrator = rehydrate.NewGuardWithPatterns(patterns, router.RouteSanitizedForward)
+	}
 	if h.providerTimeout == 0 {
 		h.providerTimeout = defaultProviderTimeout
 	}
+	if h.idleTimeout == 0 {
+		h.idleTimeout = defaultStreamIdleTimeout
+	}
+	if len(h.userKey) == 0 {
+		// rand.Read never fails as of Go 1.24.
+		h.userKey = make([]byte, 32)
+		_, _ = rand.Read(h.userKey)
+	}
 	if h.policyVersion == "" {
 		h.policyVersion = "v2.1-phase1"
 	}
@@ -163,6 +314,7 @@ func NewHandler(cfg HandlerConfig) *Handler {
 }
 
 func (h *Handler) HandleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
 	requestID := newRequestID()
 	w.Header().Set("x-lpg-request-id", requestID)
 	w.Header().Set("Content-Type", "application/json")
@@ -172,123 +324,150 @@ func (h *Handler) HandleChatCompletions(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	req, rawPrompt, sanitized, _, _, decision, err := h.analyzeChatRequest(w, r, requestID, true)
+	req, sanitized, _, decision, err := h.analyzeChatRequest(w, r, requestID, true)
 	if err != nil {
 		return
 	}
+	setDecisionHeaders(w, decision, len(sanitized.Mappings))
 
-	summary := fmt.Sprintf("route=%s category=%s", decision.Route, decision.Category)
+	trail := h.newAuditTrail(start, fmt.Sprintf("route=%s category=%s", decision.Route, decision.Category)+entityPolicySummary(decision.Policies), sanitized.Mappings)
 	idempotencyKey := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	// Fingerprint before the image policy changes the messages.
+	fingerprint := h.fingerprint("chat", req)
+
+	var ok bool
+	req.Messages, ok = h.applyImagePolicy(w, requestID, req.Messages, decision, trail)
+	if !ok {
+		return
+	}
+
+	if req.Stream {
+		h.streamOnce(w, r, requestID, req, fingerprint, sanitized, decision, trail, idempotencyKey)
+		return
+	}
+
+	result, ok := h.completeOnce(w, r, requestID, req, fingerprint, sanitized, decision, trail, idempotencyKey, false)
+	if !ok {
+		return
+	}
+	h.writeSuccess(w, requestID, req.Model, result.choices, result.usage)
+}
+
+// completion is a finished non-streaming result.
+type completion struct {
+	// choices are rehydrated and returned to the client; sanitizedChoices
+	// are the same choices before rehydration, the only form that is
+	// cached.
+	choices          []ForwardChoice
+	sanitizedChoices []ForwardChoice
+	usage            Usage
+	abstracted       bool
+	tokensSaved      int
+}
 
+// complete runs a non-streaming request through its route, appends the
+// success audit record and sets the abstraction headers. It returns false
+// once an error response has been written. responses marks /v1/responses
+// traffic so that providers implementing ResponsesUpstreamAdapter receive
+// it natively.
+func (h *Handler) complete(w http.ResponseWriter, r *http.Request, requestID string, req ChatCompletionRequest, sanitized sanitizer.Result, decision router.Decision, trail *auditTrail, idempotencyKey string, responses bool) (completion, bool) {
 	switch decision.Route {
 	case router.RouteRawForward, router.RouteSanitizedForward:
-		if h.upstream == nil {
+		if len(h.providers) == 0 {
 			h.writeError(w, http.StatusBadGateway, "ERR_PROVIDER_FAILURE", "upstream adapter not configured", requestID)
-			_ = h.appendAudit(requestID, decision.Category, decision.Route, summary+" upstream-missing")
-			return
+			_ = h.appendAudit(requestID, decision.Category, decision.Route, trail.failed(http.StatusBadGateway, "ERR_PROVIDER_FAILURE", " upstream-missing"))
+			return completion{}, false
 		}
 
-		ctx, cancel := context.WithTimeout(r.Context(), h.providerTimeout)
-		defer cancel()
-
-		promptForRoute := sanitized.Sanitized
+		messagesForRoute := withTexts(req.Messages, sanitized.Messages)
 		if decision.Route == router.RouteRawForward {
-			promptForRoute = rawPrompt
+			messagesForRoute = req.Messages
 		}
 
 		forwardReq := ForwardRequest{
-			RequestID:       requestID,
-			Model:           req.Model,
-			SanitizedPrompt: promptForRoute,
-			RiskCategory:    decision.Category,
-			Route:           decision.Route,
-			IdempotencyKey:  idempotencyKey,
+			RequestID:      requestID,
+			Model:          req.Model,
+			Messages:       messagesForRoute,
+			RiskCategory:   decision.Category,
+			Route:          decision.Route,
+			IdempotencyKey: idempotencyKey,
+			Params:         h.paramsForRoute(decision.Route, req, sanitized),
+			Responses:      responses,
 		}
 
-		resp, err := h.upstream.ChatCompletions(ctx, forwardReq)
-		if err != nil && idempotencyKey != "" && (decision.Category == risk.CategoryLow || decision.Category == risk.CategoryMedium) {
-			resp, err = h.upstream.ChatCompletions(ctx, forwardReq)
-		}
+		resp, hops, err := h.forward(r.Context(), forwardReq, retryAllowed(decision, idempotencyKey))
+		trail.addHops(hops)
 		if err != nil {
-			if isTimeout(err) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
-				h.writeError(w, http.StatusServiceUnavailable, "ERR_PROVIDER_TIMEOUT", "provider timeout", requestID)
-				_ = h.appendAudit(requestID, decision.Category, decision.Route, summary+" provider-timeout")
-				return
-			}
-			h.writeError(w, http.StatusBadGateway, "ERR_PROVIDER_FAILURE", "provider request failed", requestID)
-			auditSummary := summary + " provider-failure"
-			if diagnostic := safeProviderDiagnostic(err); diagnostic != "" {
-				auditSummary += " " + diagnostic
-			}
-			_ = h.appendAudit(requestID, decision.Category, decision.Route, auditSummary)
-			return
+			h.writeProviderError(w, r.Context(), requestID, decision, trail, err)
+			return completion{}, false
 		}
 
-		if err := h.appendAudit(requestID, decision.Category, decision.Route, summary+" success"); err != nil {
+		choices, rehydrationSummary := h.rehydrateChoices(decision.Route, resp.choices(), sanitized.Mappings)
+		if err := h.appendAudit(requestID, decision.Category, decision.Route, trail.success(" success"+toolCallSummary(choices)+rehydrationSummary)); err != nil {
 			h.writeError(w, http.StatusInternalServerError, "ERR_AUDIT_FAILURE", "audit append failed", requestID)
-			return
+			return completion{}, false
 		}
-
-		h.writeSuccess(w, requestID, req.Model, resp.Content)
+		setAbstractionHeaders(w, false, 0)
+		return completion{choices: choices, sanitizedChoices: resp.choices(), usage: resp.Usage}, true
 	case router.RouteHighAbstraction:
-		abstraction, err := h.requireAbstraction(r.Context(), w, requestID, sanitized, decision, summary)
+		abstracted, tokensSaved, err := h.abstractMessages(r.Context(), w, requestID, req.Messages, sanitized, decision, trail)
 		if err != nil {
-			return
+			return completion{}, false
 		}
 
-		if h.upstream == nil {
+		if len(h.providers) == 0 {
 			h.writeError(w, http.StatusBadGateway, "ERR_PROVIDER_FAILURE", "upstream adapter not configured", requestID)
-			_ = h.appendAudit(requestID, decision.Category, decision.Route, summary+" upstream-missing")
-			return
+			_ = h.appendAudit(requestID, decision.Category, decision.Route, trail.failed(http.StatusBadGateway, "ERR_PROVIDER_FAILURE", " upstream-missing"))
+			return completion{}, false
 		}
 
-		ctx, cancel := context.WithTimeout(r.Context(), h.providerTimeout)
-		defer cancel()
-
-		resp, err := h.upstream.ChatCompletions(ctx, ForwardRequest{
-			RequestID:       requestID,
-			Model:           req.Model,
-			SanitizedPrompt: abstraction,
-			RiskCategory:    decision.Category,
-			Route:           decision.Route,
-			IdempotencyKey:  idempotencyKey,
-		})
+		resp, hops, err := h.forward(r.Context(), ForwardRequest{
+			RequestID:      requestID,
+			Model:          req.Model,
+			Messages:       abstracted,
+			RiskCategory:   decision.Category,
+			Route:          decision.Route,
+			IdempotencyKey: idempotencyKey,
+			Params:         h.paramsForRoute(decision.Route, req, sanitized),
+			Responses:      responses,
+		}, false)
+		trail.addHops(hops)
 		if err != nil {
-			if isTimeout(err) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
-				h.writeError(w, http.StatusServiceUnavailable, "ERR_PROVIDER_TIMEOUT", "provider timeout", requestID)
-				_ = h.appendAudit(requestID, decision.Category, decision.Route, summary+" provider-timeout")
-				return
-			}
-			h.writeError(w, http.StatusBadGateway, "ERR_PROVIDER_FAILURE", "provider request failed", requestID)
-			auditSummary := summary + " provider-failure"
-			if diagnostic := safeProviderDiagnostic(err); diagnostic != "" {
-				auditSummary += " " + diagnostic
-			}
-			_ = h.appendAudit(requestID, decision.Category, decision.Route, auditSummary)
-			return
+			h.writeProviderError(w, r.Context(), requestID, decision, trail, err)
+			return completion{}, false
 		}
 
-		if err := h.appendAudit(requestID, decision.Category, decision.Route, summary+" success"); err != nil {
+		choices, rehydrationSummary := h.rehydrateChoices(decision.Route, resp.choices(), sanitized.Mappings)
+		if err := h.appendAudit(requestID, decision.Category, decision.Route, trail.success(" success"+toolCallSummary(choices)+rehydrationSummary)); err != nil {
 			h.writeError(w, http.StatusInternalServerError, "ERR_AUDIT_FAILURE", "audit append failed", requestID)
-			return
+			return completion{}, false
 		}
-
-		h.writeSuccess(w, requestID, req.Model, resp.Content)
+		setAbstractionHeaders(w, true, tokensSaved)
+		return completion{choices: choices, sanitizedChoices: resp.choices(), usage: resp.Usage, abstracted: true, tokensSaved: tokensSaved}, true
 	case router.RouteCriticalLocalOnly:
-		abstraction, err := h.requireAbstraction(r.Context(), w, requestID, sanitized, decision, summary)
+		abstraction, err := h.requireAbstraction(r.Context(), w, requestID, messagePrompt(req, sanitized), sanitized.Mappings, decision, trail)
 		if err != nil {
-			return
+			return completion{}, false
 		}
 
-		if err := h.appendAudit(requestID, decision.Category, decision.Route, summary+" local-only-success"); err != nil {
+		content, rehydrationSummary := h.rehydrateContent(decision.Route, abstraction, sanitized.Mappings)
+		if err := h.appendAudit(requestID, decision.Category, decision.Route, trail.success(" local-only-success"+rehydrationSummary)); err != nil {
 			h.writeError(w, http.StatusInternalServerError, "ERR_AUDIT_FAILURE", "audit append failed", requestID)
-			return
+			return completion{}, false
 		}
-
-		h.writeSuccess(w, requestID, req.Model, abstraction)
+		// Nothing is sent to a provider, so the whole prompt is saved.
+		tokensSaved := estimateTokens(messagePrompt(req, sanitized))
+		setAbstractionHeaders(w, true, tokensSaved)
+		return completion{
+			choices:          []ForwardChoice{{Content: content}},
+			sanitizedChoices: []ForwardChoice{{Content: abstraction}},
+			abstracted:       true,
+			tokensSaved:      tokensSaved,
+		}, true
 	default:
 		h.writeError(w, http.StatusForbidden, "ERR_POLICY_BLOCK", "request blocked by policy", requestID)
-		_ = h.appendAudit(requestID, risk.CategoryCritical, router.RouteCriticalBlocked, summary+" blocked")
+		_ = h.appendAudit(requestID, risk.CategoryCritical, router.RouteCriticalBlocked, trail.blocked(http.StatusForbidden, "ERR_POLICY_BLOCK", " blocked"))
+		return completion{}, false
 	}
 }
 
@@ -302,7 +481,7 @@ func (h *Handler) HandleDebugExplain(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	req, _, sanitized, result, hasHardBlock, decision, err := h.analyzeChatRequest(w, r, requestID, false)
+	req, sanitized, result, decision, err := h.analyzeChatRequest(w, r, requestID, false)
 	if err != nil {
 		return
 	}
@@ -316,6 +495,15 @@ func (h *Handler) HandleDebugExplain(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
+	entityActions := decision.Policies
+	if entityActions == nil {
+		entityActions = []router.EntityPolicy{}
+	}
+	riskFactors := result.Factors
+	if riskFactors == nil {
+		riskFactors = []risk.Factor{}
+	}
+
 	w.WriteHeader(http.StatusOK)
 	_ = json.NewEncoder(w).Encode(ExplainResponse{
 		RequestID:      requestID,
@@ -326,91 +514,284 @@ func (h *Handler) HandleDebugExplain(w http.ResponseWriter, r *http.Request) {
 		MinConfidence:  minMappingConfidence(sanitized.Mappings),
 		RiskScore:      result.Score,
 		RiskCategory:   result.Category,
+		RiskFactors:    riskFactors,
 		Route:          decision.Route,
 		Egress:         decision.Egress,
-		HardBlock:      hasHardBlock,
+		HardBlock:      len(decision.Policies) > 0,
+		EntityActions:  entityActions,
 		Mappings:       mappings,
+		ContentParts:   h.explainContentParts(req.Messages, decision.Route),
 	})
 }
 
-func (h *Handler) analyzeChatRequest(w http.ResponseWriter, r *http.Request, requestID string, auditFailures bool) (ChatCompletionRequest, string, sanitizer.Result, risk.Result, bool, router.Decision, error) {
+func (h *Handler) analyzeChatRequest(w http.ResponseWriter, r *http.Request, requestID string, auditFailures bool) (ChatCompletionRequest, sanitizer.Result, risk.Result, router.Decision, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "ERR_VALIDATION", "invalid JSON payload", requestID)
+		return ChatCompletionRequest{}, sanitizer.Result{}, risk.Result{}, router.Decision{}, err
+	}
+	field, err := unsupportedField(body, supportedChatFields)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "ERR_VALIDATION", "invalid JSON payload", requestID)
+		return ChatCompletionRequest{}, sanitizer.Result{}, risk.Result{}, router.Decision{}, err
+	}
+	if field != "" {
+		h.writeError(w, http.StatusBadRequest, "ERR_UNSUPPORTED_FIELD", fmt.Sprintf("unsupported field %q", field), requestID)
+		return ChatCompletionRequest{}, sanitizer.Result{}, risk.Result{}, router.Decision{}, fmt.Errorf("unsupported field %q", field)
+	}
+
 	var req ChatCompletionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.Unmarshal(body, &req); err != nil {
 		h.writeError(w, http.StatusBadRequest, "ERR_VALIDATION", "invalid JSON payload", requestID)
-		return ChatCompletionRequest{}, "", sanitizer.Result{}, risk.Result{}, false, router.Decision{}, err
+		return ChatCompletionRequest{}, sanitizer.Result{}, risk.Result{}, router.Decision{}, err
 	}
+	return h.analyze(w, requestID, req, auditFailures)
+}
 
+// analyze validates req and runs it through sanitization, scoring and
+// routing. It writes the error response itself when it fails.
+func (h *Handler) analyze(w http.ResponseWriter, requestID string, req ChatCompletionRequest, auditFailures bool) (ChatCompletionRequest, sanitizer.Result, risk.Result, router.Decision, error) {
 	if err := validateRequest(req); err != nil {
 		h.writeError(w, http.StatusBadRequest, "ERR_VALIDATION", err.Error(), requestID)
-		return ChatCompletionRequest{}, "", sanitizer.Result{}, risk.Result{}, false, router.Decision{}, err
+		return ChatCompletionRequest{}, sanitizer.Result{}, risk.Result{}, router.Decision{}, err
+	}
+	if err := h.checkModel(w, requestID, req.Model); err != nil {
+		return ChatCompletionRequest{}, sanitizer.Result{}, risk.Result{}, router.Decision{}, err
+	}
+	if req.Stream && req.choices() > 1 {
+		h.writeError(w, http.StatusBadRequest, "ERR_UNSUPPORTED_FIELD", "n > 1 is not supported with stream", requestID)
+		return ChatCompletionRequest{}, sanitizer.Result{}, risk.Result{}, router.Decision{}, errors.New("n > 1 with stream")
 	}
 
-	rawPrompt := joinPrompt(req.Messages)
-	sanitized, err := h.sanitizer.Sanitize(rawPrompt)
+	// Parameter text is sanitized after the messages in the same
+	// conversation, so a value gets the same surrogate wherever it appears.
+	texts := messageTexts(req.Messages)
+	texts = append(texts, paramTexts(req.GenerationParams)...)
+	sanitized, err := h.sanitizer.SanitizeConversation(texts)
+	if err == nil && len(sanitized.Messages) != len(texts) {
+		err = fmt.Errorf("sanitizer returned %d messages for %d inputs", len(sanitized.Messages), len(texts))
+	}
 	if err != nil {
 		h.writeError(w, http.StatusInternalServerError, "ERR_SANITIZATION_FAILURE", "sanitization failed", requestID)
-		return ChatCompletionRequest{}, "", sanitizer.Result{}, risk.Result{}, false, router.Decision{}, err
+		return ChatCompletionRequest{}, sanitizer.Result{}, risk.Result{}, router.Decision{}, err
 	}
 
-	result, err := h.scorer.Evaluate(len(sanitized.Mappings), minMappingConfidence(sanitized.Mappings))
+	result, err := h.scorer.EvaluateDetections(riskDetections(sanitized.Mappings), sanitized.Sanitized, minMappingConfidence(sanitized.Mappings))
 	if err != nil {
 		h.writeError(w, http.StatusForbidden, "ERR_POLICY_BLOCK", "risk evaluation failed", requestID)
 		if auditFailures {
-			_ = h.appendAudit(requestID, risk.CategoryCritical, router.RouteCriticalBlocked, "risk evaluation failed")
+			_ = h.appendAudit(requestID, risk.CategoryCritical, router.RouteCriticalBlocked, riskFailureEvent())
 		}
-		return ChatCompletionRequest{}, "", sanitizer.Result{}, risk.Result{}, false, router.Decision{}, err
+		return ChatCompletionRequest{}, sanitizer.Result{}, risk.Result{}, router.Decision{}, err
+	}
+
+	decision := h.router.Decide(result.Category, h.triggeredPolicies(sanitized.Mappings))
+	return req, sanitized, result, decision, nil
+}
+
+// paramsForRoute returns the generation parameters sent to the provider.
+// Outside raw_forward, their free text is the sanitized text that follows
+// the messages in sanitized.
+func (h *Handler) paramsForRoute(route router.Route, req ChatCompletionRequest, sanitized sanitizer.Result) GenerationParams {
+	params := req.GenerationParams
+	if route != router.RouteRawForward {
+		params = withParamTexts(params, sanitized.Messages[len(messageTexts(req.Messages)):])
 	}
+	return params.forProvider(h.userKey)
+}
 
-	hasHardBlock := false
-	for _, m := range sanitized.Mappings {
-		if m.EntityType == "SSN" {
-			hasHardBlock = true
-			break
+// messagePrompt is the sanitized conversation without the parameter texts
+// that analyze sanitizes after it.
+func messagePrompt(req ChatCompletionRequest, sanitized sanitizer.Result) string {
+	return strings.Join(sanitized.Messages[:len(messageTexts(req.Messages))], "\n")
+}
+
+func (h *Handler) triggeredPolicies(mappings []sanitizer.Mapping) []router.EntityPolicy {
+	entityTypes := make([]string, 0, len(mappings))
+	hardBlock := map[string]bool{}
+	for _, m := range mappings {
+		entityTypes = append(entityTypes, m.EntityType)
+		if m.HardBlock {
+			hardBlock[m.EntityType] = true
 		}
 	}
+	return h.entityPolicies.Triggered(entityTypes, func(entityType string) bool {
+		return hardBlock[entityType]
+	})
+}
 
-	decision := h.router.Decide(result.Category, hasHardBlock)
-	return req, rawPrompt, sanitized, result, hasHardBlock, decision, nil
+func entityPolicySummary(policies []router.EntityPolicy) string {
+	if len(policies) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(policies))
+	for _, p := range policies {
+		parts = append(parts, p.EntityType+":"+string(p.Action))
+	}
+	return " entity_actions=" + strings.Join(parts, ",")
 }
 
-func (h *Handler) requireAbstraction(ctx context.Context, w http.ResponseWriter, requestID string, sanitized sanitizer.Result, decision router.Decision, summary string) (string, error) {
+// abstractMessages rewrites non-empty message content through the local
+// abstractor. Tool-call arguments stay sanitized but are not abstracted, so
+// they remain valid for the tool schema. It also returns the estimated
+// number of prompt tokens the abstraction saved.
+func (h *Handler) abstractMessages(ctx context.Context, w http.ResponseWriter, requestID string, messages []ChatMessage, sanitized sanitizer.Result, decision router.Decision, trail *auditTrail) ([]ChatMessage, int, error) {
+	var abstractErr error
+	tokensSaved := 0
+	abstracted := walkMessageTexts(withTexts(messages, sanitized.Messages), func(text string, content bool) string {
+		if abstractErr != nil || !content || strings.TrimSpace(text) == "" {
+			return text
+		}
+		abstraction, err := h.requireAbstraction(ctx, w, requestID, text, sanitized.Mappings, decision, trail)
+		if err != nil {
+			abstractErr = err
+			return text
+		}
+		tokensSaved += estimateTokens(text) - estimateTokens(abstraction)
+		return abstraction
+	})
+	if abstractErr != nil {
+		return nil, 0, abstractErr
+	}
+	return abstracted, tokensSaved, nil
+}
+
+func (h *Handler) requireAbstraction(ctx context.Context, w http.ResponseWriter, requestID, prompt string, mappings []sanitizer.Mapping, decision router.Decision, trail *auditTrail) (string, error) {
 	if h.abstractor == nil {
 		h.writeError(w, http.StatusServiceUnavailable, "ERR_ABSTRACTION_UNAVAILABLE", "local abstraction is not enabled", requestID)
-		_ = h.appendAudit(requestID, decision.Category, decision.Route, summary+" abstraction-unavailable")
+		_ = h.appendAudit(requestID, decision.Category, decision.Route, trail.failed(http.StatusServiceUnavailable, "ERR_ABSTRACTION_UNAVAILABLE", " abstraction-unavailable"))
 		return "", errors.New("abstraction unavailable")
 	}
 
 	abstraction, err := h.abstractor.Abstract(ctx, AbstractRequest{
 		RequestID:       requestID,
-		SanitizedPrompt: sanitized.Sanitized,
-		Mappings:        sanitized.Mappings,
+		SanitizedPrompt: prompt,
+		Mappings:        mappings,
 		Route:           decision.Route,
 	})
 	if err != nil {
 		h.writeError(w, http.StatusServiceUnavailable, "ERR_ABSTRACTION_UNAVAILABLE", "local abstraction failed", requestID)
-		_ = h.appendAudit(requestID, decision.Category, decision.Route, summary+" abstraction-failed")
+		_ = h.appendAudit(requestID, decision.Category, decision.Route, trail.failed(http.StatusServiceUnavailable, "ERR_ABSTRACTION_UNAVAILABLE", " abstraction-failed"))
 		return "", err
 	}
+	trail.abstracted = true
 	return abstraction, nil
 }
 
-func (h *Handler) appendAudit(requestID string, category risk.Category, route router.Route, actionSummary string) error {
+func (h *Handler) rehydrateContent(route router.Route, content string, mappings []sanitizer.Mapping) (string, string) {
+	if !h.rehydrator.Enabled(route) || len(mappings) == 0 {
+		return content, ""
+	}
+
+	result := h.rehydrator.Rehydrate(content, mappings)
+	return result.Content, rehydrationAuditSummary(result)
+}
+
+// rehydrateChoices rehydrates each choice independently and reports the
+// combined counts in a single audit fragment. Content follows the route's
+// rehydration setting; tool-call arguments also need RehydrateTools.
+func (h *Handler) rehydrateChoices(route router.Route, choices []ForwardChoice, mappings []sanitizer.Mapping) ([]ForwardChoice, string) {
+	if len(mappings) == 0 {
+		return choices, ""
+	}
+
+	rehydrateContent := h.rehydrator.Enabled(route)
+	var results []*rehydrate.Result
+	out := make([]ForwardChoice, 0, len(choices))
+	for _, choice := range choices {
+		if rehydrateContent {
+			result := h.rehydrator.Rehydrate(choice.Content, mappings)
+			choice.Content = result.Content
+			results = append(results, &result)
+		}
+		var toolResult *rehydrate.Result
+		choice.ToolCalls, toolResult = h.rehydrateToolCalls(route, choice.ToolCalls, mappings)
+		results = append(results, toolResult)
+		out = append(out, choice)
+	}
+	return out, combinedRehydrationSummary(results...)
+}
+
+// rehydrateToolCalls rehydrates the arguments of calls to RehydrateTools
+// functions on routes that rehydrate at all. The result is nil when no
+// argument was rehydrated.
+func (h *Handler) rehydrateToolCalls(route router.Route, calls []ToolCall, mappings []sanitizer.Mapping) ([]ToolCall, *rehydrate.Result) {
+	if len(calls) == 0 || len(mappings) == 0 || !h.rehydrator.Enabled(route) {
+		return calls, nil
+	}
+
+	var combined *rehydrate.Result
+	rehydrateText := func(text string) string {
+		result := h.rehydrator.Rehydrate(text, mappings)
+		if combined == nil {
+			combined = &rehydrate.Result{}
+		}
+		combined.Replaced += result.Replaced
+		combined.Ignored += result.Ignored
+		combined.Collisions = append(combined.Collisions, result.Collisions...)
+		return result.Content
+	}
+	out := make([]ToolCall, 0, len(calls))
+	for _, call := range calls {
+		if h.rehydrateTools[call.Function.Name] {
+			call.Function.Arguments = rewriteArguments(call.Function.Arguments, rehydrateText)
+		}
+		out = append(out, call)
+	}
+	return out, combined
+}
+
+func toolCallSummary(choices []ForwardChoice) string {
+	calls := 0
+	for _, choice := range choices {
+		calls += len(choice.ToolCalls)
+	}
+	if calls == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" tool_calls=%d", calls)
+}
+
+func (h *Handler) appendAudit(requestID string, category risk.Category, route router.Route, event audit.Event) error {
 	if h.audit == nil {
 		return nil
 	}
-	_, err := h.audit.Append(audit.Event{
-		RequestID:     requestID,
-		PolicyVersion: h.policyVersion,
-		ActionSummary: actionSummary,
-		RiskCategory:  string(category),
-		Route:         string(route),
-	})
+	event.RequestID = requestID
+	event.PolicyVersion = h.policyVersion
+	event.RiskCategory = string(category)
+	event.Route = string(route)
+	_, err := h.audit.Append(event)
 	if err != nil && !h.strictAudit {
 		return nil
 	}
 	return err
 }
 
+func (h *Handler) writeProviderError(w http.ResponseWriter, ctx context.Context, requestID string, decision router.Decision, trail *auditTrail, err error) {
+	status, code, message, auditSuffix := providerErrorDetails(ctx, err)
+	h.writeError(w, status, code, message, requestID)
+	_ = h.appendAudit(requestID, decision.Category, decision.Route, trail.failed(status, code, auditSuffix))
+}
+
+// providerErrorDetails maps a provider error to the response status, code
+// and message, and the suffix for the audit summary.
+func providerErrorDetails(ctx context.Context, err error) (int, string, string, string) {
+	if errors.Is(err, errNoEligibleProvider) {
+		return http.StatusBadGateway, "ERR_PROVIDER_FAILURE", "no eligible provider for route", " no-eligible-provider"
+	}
+	if errors.Is(err, errProviderUnavailable) {
+		return http.StatusServiceUnavailable, "ERR_PROVIDER_UNAVAILABLE", "provider unavailable", " provider-unavailable"
+	}
+	if isTimeout(err) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return http.StatusServiceUnavailable, "ERR_PROVIDER_TIMEOUT", "provider timeout", " provider-timeout"
+	}
+	auditSuffix := " provider-failure"
+	if diagnostic := safeProviderDiagnostic(err); diagnostic != "" {
+		auditSuffix += " " + diagnostic
+	}
+	return http.StatusBadGateway, "ERR_PROVIDER_FAILURE", "provider request failed", auditSuffix
+}
+
 func (h *Handler) writeError(w http.ResponseWriter, status int, code, message, requestID string) {
 	w.WriteHeader(status)
 	resp := errorResponse{RequestID: requestID}
@@ -419,25 +800,49 @@ func (h *Handler) writeError(w http.ResponseWriter, status int, code, message, r
 	_ = json.NewEncoder(w).Encode(resp)
 }
 
-func (h *Handler) writeSuccess(w http.ResponseWriter, requestID, model, content string) {
+func (h *Handler) writeSuccess(w http.ResponseWriter, requestID, model string, forwarded []ForwardChoice, usage Usage) {
+	choices := make([]chatChoice, 0, len(forwarded))
+	for i, choice := range forwarded {
+		finishReason := choice.FinishReason
+		if finishReason == "" {
+			finishReason = "stop"
+			if len(choice.ToolCalls) > 0 {
+				finishReason = "tool_calls"
+			}
+		}
+		choices = append(choices, chatChoice{
+			Index: i,
+			Message: ChatMessage{
+				Role:      roleAssistant,
+				Content:   choice.Content,
+				ToolCalls: choice.ToolCalls,
+			},
+			FinishReason: finishReason,
+		})
+	}
+
 	w.WriteHeader(http.StatusOK)
 	_ = json.NewEncoder(w).Encode(ChatCompletionResponse{
-		ID:     requestID,
-		Object: "chat.completion",
-		Model:  model,
-		Choices: []chatChoice{
-			{
-				Index: 0,
-				Message: ChatMessage{
-					Role:    "assistant",
-					Content: content,
-				},
-				FinishReason: "stop",
-			},
-		},
+		ID:      requestID,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: choices,
+		Usage:   usage,
 	})
 }
 
+func (r ForwardResponse) choices() []ForwardChoice {
+	if len(r.Choices) > 0 {
+		return r.Choices
+	}
+	return []ForwardChoice{{Content: r.Content}}
+}
+
+func retryAllowed(decision router.Decision, idempotencyKey string) bool {
+	return idempotencyKey != "" && (decision.Category == risk.CategoryLow || decision.Category == risk.CategoryMedium)
+}
+
 func validateRequest(req ChatCompletionRequest) error {
 	if strings.TrimSpace(req.Model) == "" {
 		return errors.New("model is required")
@@ -449,19 +854,24 @@ func validateRequest(req ChatCompletionRequest) error {
 		if strings.TrimSpace(m.Role) == "" {
 			return fmt.Errorf("messages[%d].role is required", i)
 		}
-		if strings.TrimSpace(m.Content) == "" {
-			return fmt.Errorf("messages[%d].content is required", i)
+		if err := validateMessage(i, m); err != nil {
+			return err
 		}
 	}
-	return nil
+	return req.GenerationParams.validate()
 }
 
-func joinPrompt(messages []ChatMessage) string {
-	parts := make([]string, 0, len(messages))
-	for _, m := range messages {
-		parts = append(parts, m.Content)
+// riskDetections keys each detection by its placeholder, which the sanitizer
+// reuses for repeats of the same value, so raw values never reach the scorer.
+func riskDetections(mappings []sanitizer.Mapping) []risk.Detection {
+	detections := make([]risk.Detection, 0, len(mappings))
+	for _, m := range mappings {
+		detections = append(detections, risk.Detection{
+			EntityType: m.EntityType,
+			Key:        m.Placeholder,
+		})
 	}
-	return strings.Join(parts, "\n")
+	return detections
 }
 
 func minMappingConfidence(mappings []sanitizer.Mapping) float64 {
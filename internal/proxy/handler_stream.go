@@ -0,0 +1,361 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/soloengine/lpg/internal/rehydrate"
+	"github.com/soloengine/lpg/internal/risk"
+	"github.com/soloengine/lpg/internal/router"
+	"github.com/soloengine/lpg/internal/sanitizer"
+)
+
+type chatChunkDelta struct {
+	Role      string          `json:"role,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	ToolCalls []ToolCallDelta `json:"tool_calls,omitempty"`
+}
+
+type chatChunkChoice struct {
+	Index        int            `json:"index"`
+	Delta        chatChunkDelta `json:"delta"`
+	FinishReason *string        `json:"finish_reason"`
+}
+
+type ChatCompletionChunk struct {
+	ID      string            `json:"id"`
+	Object  string            `json:"object"`
+	Created int64             `json:"created"`
+	Model   string            `json:"model"`
+	Choices []chatChunkChoice `json:"choices"`
+}
+
+type chatStreamWriter struct {
+	w            http.ResponseWriter
+	flusher      http.Flusher
+	requestID    string
+	model        string
+	created      int64
+	started      bool
+	finishReason string
+}
+
+type contentFilter interface {
+	Write(delta string) string
+	Flush() string
+}
+
+type passthroughFilter struct{}
+
+func (passthroughFilter) Write(delta string) string { return delta }
+
+func (passthroughFilter) Flush() string { return "" }
+
+func newChatStreamWriter(w http.ResponseWriter, requestID, model string) *chatStreamWriter {
+	flusher, _ := w.(http.Flusher)
+	return &chatStreamWriter{w: w, flusher: flusher, requestID: requestID, model: model, created: time.Now().Unix()}
+}
+
+func (s *chatStreamWriter) start() error {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	s.w.Header().Set("Content-Type", "text/event-stream")
+	s.w.Header().Set("Cache-Control", "no-cache")
+	s.w.Header().Set("Connection", "keep-alive")
+	s.w.WriteHeader(http.StatusOK)
+	return s.writeChunk(chatChunkDelta{Role: "assistant"}, nil)
+}
+
+func (s *chatStreamWriter) writeDelta(content string) error {
+	if err := s.start(); err != nil {
+		return err
+	}
+	if content == "" {
+		return nil
+	}
+	return s.writeChunk(chatChunkDelta{Content: content}, nil)
+}
+
+// writeToolCalls sends each call whole, as the single fragment of its
+// index.
+func (s *chatStreamWriter) writeToolCalls(calls []ToolCall) error {
+	if err := s.start(); err != nil {
+		return err
+	}
+	if len(calls) == 0 {
+		return nil
+	}
+	return s.writeChunk(chatChunkDelta{ToolCalls: toolCallDeltas(calls)}, nil)
+}
+
+func (s *chatStreamWriter) finish() error {
+	if err := s.start(); err != nil {
+		return err
+	}
+	finishReason := s.finishReason
+	if finishReason == "" {
+		finishReason = "stop"
+	}
+	if err := s.writeChunk(chatChunkDelta{}, &finishReason); err != nil {
+		return err
+	}
+	return s.writeEvent([]byte("[DONE]"))
+}
+
+func (s *chatStreamWriter) writeErrorEvent(code, message string) error {
+	resp := errorResponse{RequestID: s.requestID}
+	resp.Error.Code = code
+	resp.Error.Message = message
+	payload, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	return s.writeEvent(payload)
+}
+
+func (s *chatStreamWriter) writeChunk(delta chatChunkDelta, finishReason *string) error {
+	payload, err := json.Marshal(ChatCompletionChunk{
+		ID:      s.requestID,
+		Object:  "chat.completion.chunk",
+		Created: s.created,
+		Model:   s.model,
+		Choices: []chatChunkChoice{
+			{Index: 0, Delta: delta, FinishReason: finishReason},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return s.writeEvent(payload)
+}
+
+func (s *chatStreamWriter) writeEvent(payload []byte) error {
+	if _, err := fmt.Fprintf(s.w, "data: %s\n\n", payload); err != nil {
+		return err
+	}
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+	return nil
+}
+
+// streamChatCompletions streams a request through its route. Like
+// complete, it returns false when the request failed; the completion it
+// returns holds only the sanitized choice, for the idempotency cache.
+func (h *Handler) streamChatCompletions(w http.ResponseWriter, r *http.Request, requestID string, req ChatCompletionRequest, sanitized sanitizer.Result, decision router.Decision, trail *auditTrail, idempotencyKey string) (completion, bool) {
+	var messages []ChatMessage
+	var abstracted bool
+	var tokensSaved int
+	switch decision.Route {
+	case router.RouteRawForward:
+		messages = req.Messages
+		setAbstractionHeaders(w, false, 0)
+	case router.RouteSanitizedForward:
+		messages = withTexts(req.Messages, sanitized.Messages)
+		setAbstractionHeaders(w, false, 0)
+	case router.RouteHighAbstraction:
+		abstractedMessages, saved, err := h.abstractMessages(r.Context(), w, requestID, req.Messages, sanitized, decision, trail)
+		if err != nil {
+			return completion{}, false
+		}
+		messages = abstractedMessages
+		abstracted, tokensSaved = true, saved
+		setAbstractionHeaders(w, true, tokensSaved)
+	case router.RouteCriticalLocalOnly:
+		return h.streamLocalOnly(w, r, requestID, req, sanitized, decision, trail)
+	default:
+		h.writeError(w, http.StatusForbidden, "ERR_POLICY_BLOCK", "request blocked by policy", requestID)
+		_ = h.appendAudit(requestID, risk.CategoryCritical, router.RouteCriticalBlocked, trail.blocked(http.StatusForbidden, "ERR_POLICY_BLOCK", " blocked"))
+		return completion{}, false
+	}
+
+	if len(h.providers) == 0 {
+		h.writeError(w, http.StatusBadGateway, "ERR_PROVIDER_FAILURE", "upstream adapter not configured", requestID)
+		_ = h.appendAudit(requestID, decision.Category, decision.Route, trail.failed(http.StatusBadGateway, "ERR_PROVIDER_FAILURE", " upstream-missing"))
+		return completion{}, false
+	}
+
+	forwardReq := ForwardRequest{
+		RequestID:      requestID,
+		Model:          req.Model,
+		Messages:       messages,
+		RiskCategory:   decision.Category,
+		Route:          decision.Route,
+		IdempotencyKey: idempotencyKey,
+		Params:         h.paramsForRoute(decision.Route, req, sanitized),
+	}
+
+	stream := newChatStreamWriter(w, requestID, req.Model)
+	filter, rehydration := h.newContentFilter(decision.Route, sanitized.Mappings)
+	// Tool calls are held until the stream ends, since their arguments can
+	// only be rehydrated once complete.
+	var toolCalls toolCallBuffer
+	var content strings.Builder
+	onChunk := func(chunk StreamChunk) error {
+		if chunk.FinishReason != "" {
+			stream.finishReason = chunk.FinishReason
+		}
+		toolCalls.add(chunk.ToolCalls)
+		content.WriteString(chunk.Content)
+		return stream.writeDelta(filter.Write(chunk.Content))
+	}
+
+	started := func() bool { return stream.started }
+	hops, err := h.forwardStream(r.Context(), forwardReq, retryAllowed(decision, idempotencyKey), started, onChunk)
+	trail.addHops(hops)
+	if err != nil {
+		if !stream.started {
+			h.writeProviderError(w, r.Context(), requestID, decision, trail, err)
+			return completion{}, false
+		}
+		// The client already has a 200; the error travels as an event.
+		_, code, message, auditSuffix := providerErrorDetails(r.Context(), err)
+		_ = stream.writeErrorEvent(code, message)
+		_ = h.appendAudit(requestID, decision.Category, decision.Route, trail.failed(http.StatusOK, code, auditSuffix+" stream-interrupted"))
+		return completion{}, false
+	}
+
+	calls, toolRehydration := h.rehydrateToolCalls(decision.Route, toolCalls.calls, sanitized.Mappings)
+	if err := stream.writeDelta(filter.Flush()); err != nil {
+		_ = h.appendAudit(requestID, decision.Category, decision.Route, trail.failed(http.StatusOK, "", " stream-write-failed"))
+		return completion{}, false
+	}
+	if err := stream.writeToolCalls(calls); err != nil {
+		_ = h.appendAudit(requestID, decision.Category, decision.Route, trail.failed(http.StatusOK, "", " stream-write-failed"))
+		return completion{}, false
+	}
+	summary := " stream-success" + toolCallSummary([]ForwardChoice{{ToolCalls: calls}}) + combinedRehydrationSummary(rehydration(), toolRehydration)
+	if err := h.appendAudit(requestID, decision.Category, decision.Route, trail.success(summary)); err != nil {
+		_ = stream.writeErrorEvent("ERR_AUDIT_FAILURE", "audit append failed")
+		return completion{}, false
+	}
+	if err := stream.finish(); err != nil {
+		return completion{}, false
+	}
+	return completion{
+		sanitizedChoices: []ForwardChoice{{Content: content.String(), ToolCalls: toolCalls.calls, FinishReason: stream.finishReason}},
+		abstracted:       abstracted,
+		tokensSaved:      tokensSaved,
+	}, true
+}
+
+func (h *Handler) streamLocalOnly(w http.ResponseWriter, r *http.Request, requestID string, req ChatCompletionRequest, sanitized sanitizer.Result, decision router.Decision, trail *auditTrail) (completion, bool) {
+	abstraction, err := h.requireAbstraction(r.Context(), w, requestID, messagePrompt(req, sanitized), sanitized.Mappings, decision, trail)
+	if err != nil {
+		return completion{}, false
+	}
+
+	content, rehydrationSummary := h.rehydrateContent(decision.Route, abstraction, sanitized.Mappings)
+	if err := h.appendAudit(requestID, decision.Category, decision.Route, trail.success(" local-only-stream-success"+rehydrationSummary)); err != nil {
+		h.writeError(w, http.StatusInternalServerError, "ERR_AUDIT_FAILURE", "audit append failed", requestID)
+		return completion{}, false
+	}
+
+	tokensSaved := estimateTokens(messagePrompt(req, sanitized))
+	setAbstractionHeaders(w, true, tokensSaved)
+	stream := newChatStreamWriter(w, requestID, req.Model)
+	if err := stream.writeDelta(content); err != nil {
+		return completion{}, false
+	}
+	if err := stream.finish(); err != nil {
+		return completion{}, false
+	}
+	return completion{sanitizedChoices: []ForwardChoice{{Content: abstraction}}, abstracted: true, tokensSaved: tokensSaved}, true
+}
+
+// streamDeadline is a deadline that can be moved or lifted, which a
+// context deadline cannot. Streams use it to bound the wait for the first
+// chunk and then the gaps between chunks, rather than the whole response.
+// When it fires, the context is cancelled with context.DeadlineExceeded as
+// its cause.
+type streamDeadline struct {
+	cancel context.CancelCauseFunc
+	timer  *time.Timer
+}
+
+// withStreamDeadline arms a deadline d from now; d <= 0 arms none.
+func withStreamDeadline(ctx context.Context, d time.Duration) (context.Context, *streamDeadline) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	sd := &streamDeadline{cancel: cancel}
+	if d > 0 {
+		sd.timer = time.AfterFunc(d, func() { cancel(context.DeadlineExceeded) })
+	}
+	return ctx, sd
+}
+
+// reset moves the deadline to d from now.
+func (sd *streamDeadline) reset(d time.Duration) {
+	if sd.timer == nil {
+		sd.timer = time.AfterFunc(d, func() { sd.cancel(context.DeadlineExceeded) })
+		return
+	}
+	sd.timer.Reset(d)
+}
+
+// lift removes the deadline without cancelling the context.
+func (sd *streamDeadline) lift() {
+	if sd.timer != nil {
+		sd.timer.Stop()
+	}
+}
+
+// release lifts the deadline and cancels the context.
+func (sd *streamDeadline) release() {
+	sd.lift()
+	sd.cancel(context.Canceled)
+}
+
+// newContentFilter returns the filter streamed content goes through and a
+// func reporting what it rehydrated, nil when content is not rehydrated.
+func (h *Handler) newContentFilter(route router.Route, mappings []sanitizer.Mapping) (contentFilter, func() *rehydrate.Result) {
+	if !h.rehydrator.Enabled(route) || len(mappings) == 0 {
+		return passthroughFilter{}, func() *rehydrate.Result { return nil }
+	}
+	stream := h.rehydrator.NewStream(mappings)
+	return stream, func() *rehydrate.Result {
+		result := stream.Result()
+		return &result
+	}
+}
+
+// combinedRehydrationSummary reports results as one audit fragment,
+// skipping nil ones. It is empty when every result is nil.
+func combinedRehydrationSummary(results ...*rehydrate.Result) string {
+	var combined *rehydrate.Result
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		if combined == nil {
+			combined = &rehydrate.Result{}
+		}
+		combined.Replaced += result.Replaced
+		combined.Ignored += result.Ignored
+		combined.Collisions = append(combined.Collisions, result.Collisions...)
+	}
+	if combined == nil {
+		return ""
+	}
+	return rehydrationAuditSummary(*combined)
+}
+
+func rehydrationAuditSummary(result rehydrate.Result) string {
+	auditSummary := fmt.Sprintf(" rehydrated=%d", result.Replaced)
+	if result.Ignored > 0 {
+		auditSummary += fmt.Sprintf(" rehydration_ignored=%d", result.Ignored)
+	}
+	if len(result.Collisions) > 0 {
+		parts := make([]string, 0, len(result.Collisions))
+		for _, c := range result.Collisions {
+			parts = append(parts, c.Reason+":"+c.Placeholder+"("+strings.Join(c.EntityTypes, "|")+")")
+		}
+		auditSummary += " rehydration_collisions=" + strings.Join(parts, ",")
+	}
+	return auditSummary
+}
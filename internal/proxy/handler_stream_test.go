@@ -0,0 +1,346 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/soloengine/lpg/internal/risk"
+	"github.com/soloengine/lpg/internal/router"
+	"github.com/soloengine/lpg/internal/sanitizer"
+)
+
+type chunkedUpstream struct {
+	chunks []string
+	// gap is waited before every chunk after the first.
+	gap time.Duration
+	// toolCalls are sent one fragment per chunk after the content.
+	toolCalls []ToolCallDelta
+	err       error
+	last      ForwardRequest
+	calls     int
+}
+
+func (u *chunkedUpstream) ChatCompletions(ctx context.Context, req ForwardRequest) (ForwardResponse, error) {
+	return ForwardResponse{}, errors.New("non-streaming call not expected")
+}
+
+func (u *chunkedUpstream) ChatCompletionsStream(ctx context.Context, req ForwardRequest, onChunk func(StreamChunk) error) error {
+	u.last = req
+	u.calls++
+	for i, c := range u.chunks {
+		if i > 0 && u.gap > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(u.gap):
+			}
+		}
+		if err := onChunk(StreamChunk{Content: c}); err != nil {
+			return err
+		}
+	}
+	for _, d := range u.toolCalls {
+		if err := onChunk(StreamChunk{ToolCalls: []ToolCallDelta{d}}); err != nil {
+			return err
+		}
+	}
+	if u.err != nil {
+		return u.err
+	}
+	return onChunk(StreamChunk{FinishReason: "stop"})
+}
+
+func readSSE(t *testing.T, body []byte) ([]ChatCompletionChunk, bool) {
+	t.Helper()
+
+	chunks := make([]ChatCompletionChunk, 0)
+	done := false
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		data := strings.TrimPrefix(line, "data: ")
+		if data == "[DONE]" {
+			done = true
+			continue
+		}
+		var chunk ChatCompletionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("failed to parse SSE chunk %q: %v", data, err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, done
+}
+
+func streamedContent(chunks []ChatCompletionChunk) string {
+	var b strings.Builder
+	for _, c := range chunks {
+		for _, choice := range c.Choices {
+			b.WriteString(choice.Delta.Content)
+		}
+	}
+	return b.String()
+}
+
+func TestStreamChatCompletionsEmitsChunksAndRehydratesSplitSurrogates(t *testing.T) {
+	upstream := &chunkedUpstream{chunks: []string{"Sure, I will email per", "son1@exam", "ple.net today."}}
+	h := NewHandler(HandlerConfig{
+		Sanitizer: sanitizer.NewDefault(),
+		Scorer:    risk.NewScorer(0.70),
+		Router:    router.NewEngine(false),
+		Upstream:  upstream,
+	})
+
+	body := []byte(`{"model":"gpt-test","stream":true,"messages":[{"role":"user","content":"email alice@example.com"}]}`)
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	h.HandleChatCompletions(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream content type, got %q", ct)
+	}
+	if strings.Contains(upstream.last.Messages[0].Content, "alice@example.com") {
+		t.Fatalf("raw email leaked to streaming upstream: %q", upstream.last.Messages[0].Content)
+	}
+
+	chunks, done := readSSE(t, rec.Body.Bytes())
+	if !done {
+		t.Fatal("expected [DONE] terminator")
+	}
+	if len(chunks) < 3 {
+		t.Fatalf("expected role, content and finish chunks, got %d", len(chunks))
+	}
+	if chunks[0].Object != "chat.completion.chunk" || chunks[0].Choices[0].Delta.Role != "assistant" {
+		t.Fatalf("unexpected first chunk %+v", chunks[0])
+	}
+	last := chunks[len(chunks)-1]
+	if last.Choices[0].FinishReason == nil || *last.Choices[0].FinishReason != "stop" {
+		t.Fatalf("expected final chunk with finish_reason stop, got %+v", last)
+	}
+	if got := streamedContent(chunks); got != "Sure, I will email alice@example.com today." {
+		t.Fatalf("unexpected streamed content %q", got)
+	}
+	if strings.Contains(rec.Body.String(), "person1@example.net") {
+		t.Fatalf("surrogate split across chunks leaked to client: %q", rec.Body.String())
+	}
+}
+
+func TestStreamChatCompletionsFallsBackForNonStreamingUpstream(t *testing.T) {
+	h := NewHandler(HandlerConfig{
+		Sanitizer: sanitizer.NewDefault(),
+		Scorer:    risk.NewScorer(0.70),
+		Router:    router.NewEngine(false),
+		Upstream:  &countingUpstreamAdapter{},
+	})
+
+	body := []byte(`{"model":"gpt-test","stream":true,"messages":[{"role":"user","content":"hello"}]}`)
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	h.HandleChatCompletions(rec, req)
+
+	chunks, done := readSSE(t, rec.Body.Bytes())
+	if !done {
+		t.Fatal("expected [DONE] terminator")
+	}
+	if got := streamedContent(chunks); got != "unexpected" {
+		t.Fatalf("unexpected streamed content %q", got)
+	}
+}
+
+func TestStreamChatCompletionsReturnsJSONErrorBeforeFirstChunk(t *testing.T) {
+	h := NewHandler(HandlerConfig{
+		Sanitizer: sanitizer.NewDefault(),
+		Scorer:    risk.NewScorer(0.70),
+		Router:    router.NewEngine(false),
+		Upstream:  &chunkedUpstream{err: errors.New("boom")},
+	})
+
+	body := []byte(`{"model":"gpt-test","stream":true,"messages":[{"role":"user","content":"hello"}]}`)
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	h.HandleChatCompletions(rec, req)
+
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected status %d, got %d", http.StatusBadGateway, rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("expected JSON error response, got content type %q", ct)
+	}
+}
+
+func TestStreamChatCompletionsEmitsErrorEventAfterInterruption(t *testing.T) {
+	h := NewHandler(HandlerConfig{
+		Sanitizer: sanitizer.NewDefault(),
+		Scorer:    risk.NewScorer(0.70),
+		Router:    router.NewEngine(false),
+		Upstream:  &chunkedUpstream{chunks: []string{"partial "}, err: errors.New("connection reset")},
+	})
+
+	body := []byte(`{"model":"gpt-test","stream":true,"messages":[{"role":"user","content":"hello"}]}`)
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	h.HandleChatCompletions(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected stream to have started with status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "[DONE]") {
+		t.Fatal("expected interrupted stream not to emit [DONE]")
+	}
+	if !strings.Contains(rec.Body.String(), `"code":"ERR_PROVIDER_FAILURE"`) {
+		t.Fatalf("expected provider failure error event, got %q", rec.Body.String())
+	}
+}
+
+func TestStreamOutlivesProviderTimeoutAndBudgetWhileChunksKeepComing(t *testing.T) {
+	h := NewHandler(HandlerConfig{
+		Upstream:          &chunkedUpstream{chunks: []string{"a", "b", "c", "d", "e"}, gap: 30 * time.Millisecond},
+		ProviderTimeout:   50 * time.Millisecond,
+		StreamIdleTimeout: 100 * time.Millisecond,
+		Retry:             RetryPolicy{Budget: 60 * time.Millisecond},
+	})
+
+	body := []byte(`{"model":"gpt-test","stream":true,"messages":[{"role":"user","content":"hello"}]}`)
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body)))
+
+	chunks, done := readSSE(t, rec.Body.Bytes())
+	if !done {
+		t.Fatalf("expected the stream to complete, got %q", rec.Body.String())
+	}
+	if got := streamedContent(chunks); got != "abcde" {
+		t.Fatalf("unexpected streamed content %q", got)
+	}
+}
+
+func TestStreamIsCutOffAfterIdleTimeout(t *testing.T) {
+	h := NewHandler(HandlerConfig{
+		Upstream:          &chunkedUpstream{chunks: []string{"a", "b"}, gap: 200 * time.Millisecond},
+		ProviderTimeout:   time.Second,
+		StreamIdleTimeout: 30 * time.Millisecond,
+	})
+
+	body := []byte(`{"model":"gpt-test","stream":true,"messages":[{"role":"user","content":"hello"}]}`)
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body)))
+
+	if strings.Contains(rec.Body.String(), "[DONE]") {
+		t.Fatal("expected a stalled stream not to complete")
+	}
+	if !strings.Contains(rec.Body.String(), `"code":"ERR_PROVIDER_TIMEOUT"`) {
+		t.Fatalf("expected a timeout error event, got %q", rec.Body.String())
+	}
+}
+
+func TestStreamReassemblesToolCallsAndRehydratesAllowlistedArguments(t *testing.T) {
+	upstream := &chunkedUpstream{toolCalls: []ToolCallDelta{
+		{Index: 0, ID: "call_1", Type: toolTypeFunction, Function: ToolCallFunction{Name: "send_email", Arguments: `{"to":"person1@exa`}},
+		{Index: 1, ID: "call_2", Type: toolTypeFunction, Function: ToolCallFunction{Name: "log_event", Arguments: `{"note":"person1@example.net"}`}},
+		{Index: 0, Function: ToolCallFunction{Arguments: `mple.net"}`}},
+	}}
+	h := NewHandler(HandlerConfig{
+		Sanitizer:      sanitizer.NewDefault(),
+		Scorer:         risk.NewScorerWithModel(0.70, risk.Model{DefaultWeight: risk.DefaultEntityWeight}),
+		Router:         router.NewEngine(false),
+		Upstream:       upstream,
+		RehydrateTools: []string{"send_email"},
+	})
+
+	body := []byte(`{"model":"gpt-test","stream":true,"messages":[{"role":"user","content":"email alice@example.com"}],
+		"tools":[{"type":"function","function":{"name":"send_email","description":"mails alice@example.com by default"}},{"type":"function","function":{"name":"log_event"}}]}`)
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if got := upstream.last.Params.Tools[0].Function.Description; got != "mails person1@example.net by default" {
+		t.Fatalf("expected the tool description to be sanitized, got %q", got)
+	}
+
+	chunks, done := readSSE(t, rec.Body.Bytes())
+	if !done {
+		t.Fatal("expected [DONE] terminator")
+	}
+	var calls []ToolCallDelta
+	for _, c := range chunks {
+		calls = append(calls, c.Choices[0].Delta.ToolCalls...)
+	}
+	if len(calls) != 2 || calls[0].Index != 0 || calls[1].Index != 1 {
+		t.Fatalf("expected two whole tool calls, got %+v", calls)
+	}
+	if calls[0].ID != "call_1" || calls[0].Function.Arguments != `{"to":"alice@example.com"}` {
+		t.Fatalf("expected allowlisted arguments to be reassembled and rehydrated, got %+v", calls[0])
+	}
+	if calls[1].Function.Arguments != `{"note":"person1@example.net"}` {
+		t.Fatalf("expected other arguments to keep the surrogate, got %+v", calls[1])
+	}
+}
+
+func TestStreamIsReplayedFromIdempotencyCache(t *testing.T) {
+	cache, err := NewIdempotencyCache(time.Minute, 8)
+	if err != nil {
+		t.Fatalf("NewIdempotencyCache failed: %v", err)
+	}
+	upstream := &chunkedUpstream{chunks: []string{"Sure, I will email per", "son1@exam", "ple.net today."}}
+	h := NewHandler(HandlerConfig{
+		Sanitizer:   sanitizer.NewDefault(),
+		Scorer:      risk.NewScorer(0.70),
+		Router:      router.NewEngine(false),
+		Upstream:    upstream,
+		Idempotency: cache,
+	})
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", "k")
+		rec := httptest.NewRecorder()
+		h.HandleChatCompletions(rec, req)
+		return rec
+	}
+	body := `{"model":"gpt-test","stream":true,"messages":[{"role":"user","content":"email alice@example.com"}]}`
+	first := send(body)
+	replayed := send(body)
+
+	if upstream.calls != 1 {
+		t.Fatalf("expected one upstream call, got %d", upstream.calls)
+	}
+	if replayed.Header().Get(headerIdempotentReplay) != "true" {
+		t.Fatalf("expected the second stream to be a replay, headers %v", replayed.Header())
+	}
+	firstChunks, _ := readSSE(t, first.Body.Bytes())
+	replayedChunks, done := readSSE(t, replayed.Body.Bytes())
+	if !done {
+		t.Fatal("expected [DONE] terminator on the replay")
+	}
+	if got, want := streamedContent(replayedChunks), streamedContent(firstChunks); got != want {
+		t.Fatalf("replayed content %q, want %q", got, want)
+	}
+	last := replayedChunks[len(replayedChunks)-1]
+	if last.Choices[0].FinishReason == nil || *last.Choices[0].FinishReason != "stop" {
+		t.Fatalf("expected replay to finish with stop, got %+v", last)
+	}
+
+	nonStream := send(`{"model":"gpt-test","messages":[{"role":"user","content":"email alice@example.com"}]}`)
+	if nonStream.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected a non-streaming request under the same key to get 422, got %d", nonStream.Code)
+	}
+}
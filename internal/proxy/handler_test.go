@@ -26,8 +26,10 @@ type fixedSanitizer struct {
 	result sanitizer.Result
 }
 
-func (s fixedSanitizer) Sanitize(input string) (sanitizer.Result, error) {
-	return s.result, nil
+func (s fixedSanitizer) SanitizeConversation(messages []string) (sanitizer.Result, error) {
+	result := s.result
+	result.Messages = []string{result.Sanitized}
+	return result, nil
 }
 
 func TestCriticalLocalOnlyReturnsAbstractionWithoutRemoteEgress(t *testing.T) {
@@ -39,7 +41,7 @@ func TestCriticalLocalOnlyReturnsAbstractionWithoutRemoteEgress(t *testing.T) {
 				{Placeholder: "person1@example.net", OriginalValue: "a@example.com", EntityType: "EMAIL", ConfidenceScore: 0.99},
 				{Placeholder: "person2@example.net", OriginalValue: "b@example.com", EntityType: "EMAIL", ConfidenceScore: 0.99},
 				{Placeholder: "555-010-0001", OriginalValue: "555-123-4567", EntityType: "PHONE", ConfidenceScore: 0.99},
-				{Placeholder: "900-00-0001", OriginalValue: "123-45-6789", EntityType: "SSN", ConfidenceScore: 0.99},
+				{Placeholder: "900-00-0001", OriginalValue: "123-45-6789", EntityType: "SSN", ConfidenceScore: 0.99, HardBlock: true},
 			},
 		}},
 		Scorer:     risk.NewScorer(0.70),
@@ -81,7 +83,7 @@ func TestCriticalLocalOnlyFailsWhenAbstractionMissing(t *testing.T) {
 				{Placeholder: "person1@example.net", OriginalValue: "a@example.com", EntityType: "EMAIL", ConfidenceScore: 0.99},
 				{Placeholder: "person2@example.net", OriginalValue: "b@example.com", EntityType: "EMAIL", ConfidenceScore: 0.99},
 				{Placeholder: "555-010-0001", OriginalValue: "555-123-4567", EntityType: "PHONE", ConfidenceScore: 0.99},
-				{Placeholder: "900-00-0001", OriginalValue: "123-45-6789", EntityType: "SSN", ConfidenceScore: 0.99},
+				{Placeholder: "900-00-0001", OriginalValue: "123-45-6789", EntityType: "SSN", ConfidenceScore: 0.99, HardBlock: true},
 			},
 		}},
 		Scorer: risk.NewScorer(0.70),
@@ -156,6 +158,99 @@ func TestHandleDebugExplainReturnsRoutingExplanation(t *testing.T) {
 	}
 }
 
+func TestHandleDebugExplainReportsTriggeredEntityActions(t *testing.T) {
+	h := NewHandler(HandlerConfig{
+		Sanitizer:      sanitizer.NewDefault(),
+		Scorer:         risk.NewScorer(0.70),
+		Router:         router.NewEngine(true),
+		EntityPolicies: router.EntityPolicies{"SSN": router.ActionBlock, "EMAIL": router.ActionEscalateOneBand},
+	})
+
+	body := []byte(`{"model":"gpt-test","messages":[{"role":"user","content":"ssn 123-45-6789 for alice@example.com"}]}`)
+	rec := httptest.NewRecorder()
+	h.HandleDebugExplain(rec, httptest.NewRequest(http.MethodPost, "/v1/debug/explain", bytes.NewReader(body)))
+
+	var payload ExplainResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to unmarshal explain response: %v", err)
+	}
+	expected := []router.EntityPolicy{
+		{EntityType: "EMAIL", Action: router.ActionEscalateOneBand},
+		{EntityType: "SSN", Action: router.ActionBlock},
+	}
+	if len(payload.EntityActions) != len(expected) {
+		t.Fatalf("expected entity actions %+v, got %+v", expected, payload.EntityActions)
+	}
+	for i := range expected {
+		if payload.EntityActions[i] != expected[i] {
+			t.Fatalf("expected entity actions %+v, got %+v", expected, payload.EntityActions)
+		}
+	}
+	if !payload.HardBlock || payload.Route != router.RouteCriticalBlocked || payload.Egress {
+		t.Fatalf("expected strictest action to block without egress, got hard_block=%t route=%s egress=%t", payload.HardBlock, payload.Route, payload.Egress)
+	}
+}
+
+func TestHandleDebugExplainReportsRiskFactors(t *testing.T) {
+	h := NewHandler(HandlerConfig{
+		Sanitizer: sanitizer.NewDefault(),
+		Scorer: risk.NewScorerWithModel(0.70, risk.Model{
+			DefaultWeight:   25,
+			Weights:         map[string]float64{"EMAIL": 5},
+			RepeatDecay:     0,
+			ContextKeywords: map[string]float64{"salary": 25},
+		}),
+		Router: router.NewEngine(true),
+	})
+
+	body := []byte(`{"model":"gpt-test","messages":[{"role":"user","content":"salary for alice@example.com"},{"role":"user","content":"cc alice@example.com"}]}`)
+	rec := httptest.NewRecorder()
+	h.HandleDebugExplain(rec, httptest.NewRequest(http.MethodPost, "/v1/debug/explain", bytes.NewReader(body)))
+
+	var payload ExplainResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to unmarshal explain response: %v", err)
+	}
+	expected := []risk.Factor{
+		{Kind: risk.FactorEntity, Name: "EMAIL", Count: 2, Points: 5},
+		{Kind: risk.FactorContextKeyword, Name: "salary", Count: 1, Points: 25},
+	}
+	if len(payload.RiskFactors) != len(expected) {
+		t.Fatalf("expected risk factors %+v, got %+v", expected, payload.RiskFactors)
+	}
+	for i := range expected {
+		if payload.RiskFactors[i] != expected[i] {
+			t.Fatalf("expected risk factors %+v, got %+v", expected, payload.RiskFactors)
+		}
+	}
+	if payload.RiskScore != 30 || payload.RiskCategory != risk.CategoryMedium {
+		t.Fatalf("expected 30/Medium, got %d/%s", payload.RiskScore, payload.RiskCategory)
+	}
+}
+
+func TestEntityForceLocalOnlyKeepsLowRiskRequestLocal(t *testing.T) {
+	upstream := &countingUpstreamAdapter{}
+	h := NewHandler(HandlerConfig{
+		Sanitizer:      sanitizer.NewDefault(),
+		Scorer:         risk.NewScorer(0.70),
+		Router:         router.NewEngine(true),
+		Upstream:       upstream,
+		Abstractor:     PassthroughAbstractor{},
+		EntityPolicies: router.EntityPolicies{"PHONE": router.ActionForceLocalOnly},
+	})
+
+	body := []byte(`{"model":"gpt-test","messages":[{"role":"user","content":"call 555-123-4567"}]}`)
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if upstream.calls != 0 {
+		t.Fatalf("expected no upstream calls for force_local_only entity, got %d", upstream.calls)
+	}
+}
+
 func TestHandleDebugExplainRejectsInvalidMethod(t *testing.T) {
 	h := NewHandler(HandlerConfig{})
 	req := httptest.NewRequest(http.MethodGet, "/v1/debug/explain", nil)
@@ -166,3 +261,28 @@ func TestHandleDebugExplainRejectsInvalidMethod(t *testing.T) {
 		t.Fatalf("expected status %d, got %d", http.StatusMethodNotAllowed, rec.Code)
 	}
 }
+
+type echoUpstreamAdapter struct{}
+
+func (echoUpstreamAdapter) ChatCompletions(ctx context.Context, req ForwardRequest) (ForwardResponse, error) {
+	return ForwardResponse{Content: "reply to " + req.Messages[len(req.Messages)-1].Content}, nil
+}
+
+func TestDefaultHandlerRehydratesTemplatedSurrogates(t *testing.T) {
+	h := NewHandler(HandlerConfig{Upstream: echoUpstreamAdapter{}, Abstractor: PassthroughAbstractor{}})
+
+	body := []byte(`{"model":"gpt-test","messages":[{"role":"user","content":"charge 4111 1111 1111 1111"}]}`)
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var resp ChatCompletionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if got := resp.Choices[0].Message.Content; got != "reply to charge 4111 1111 1111 1111" {
+		t.Fatalf("expected the card surrogate to be rehydrated, got %q", got)
+	}
+}
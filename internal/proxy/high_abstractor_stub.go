@@ -48,9 +48,13 @@ func (a *OpenAICompatibleAbstractor) Abstract(ctx context.Context, req AbstractR
 		prompt = "Rewrite the sanitized text by jumbling word order while preserving intent. Keep surrogate entities unchanged.\n\n" + req.SanitizedPrompt
 	}
 
-	resp, err := a.client.chatCompletions(ctx, a.model, prompt, "")
+	resp, err := a.client.chatCompletions(ctx, a.model, []ChatMessage{{Role: "user", Content: prompt}}, GenerationParams{}, "")
 	if err != nil {
 		return "", err
 	}
 	return resp.Content, nil
 }
+
+func (a *OpenAICompatibleAbstractor) ListModels(ctx context.Context) ([]string, error) {
+	return configuredModel(a.model), nil
+}
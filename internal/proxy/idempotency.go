@@ -0,0 +1,303 @@
+package proxy
+
+import (
+	"container/list"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/soloengine/lpg/internal/router"
+	"github.com/soloengine/lpg/internal/sanitizer"
+)
+
+var errIdempotencyMismatch = errors.New("idempotency key reused with a different request")
+
+// statusClientClosedRequest is the de facto status for a request the client
+// abandoned. The client is usually gone, but the audit record keeps it.
+const statusClientClosedRequest = 499
+
+// IdempotencyCache keeps the final response of each Idempotency-Key for TTL
+// so that replays are answered without calling the provider again. Only
+// content from before rehydration is stored; a replay has the same body, so
+// sanitizing it again yields the mappings needed to rehydrate. Request
+// bodies are kept only as keyed fingerprints. Keys are scoped to the
+// caller, so two clients that pick the same key never share a response.
+type IdempotencyCache struct {
+	ttl        time.Duration
+	maxEntries int
+	secret     []byte
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+	// order holds keys oldest first for eviction.
+	order *list.List
+}
+
+type idempotencyEntry struct {
+	key         string
+	fingerprint string
+	// done is closed once the owning request finishes. result stays nil
+	// while in flight; a failed request removes its entry instead.
+	done    chan struct{}
+	result  *completion
+	expires time.Time
+	element *list.Element
+}
+
+// NewIdempotencyCache returns a cache holding at most maxEntries keys, each
+// for ttl after its request completed.
+func NewIdempotencyCache(ttl time.Duration, maxEntries int) (*IdempotencyCache, error) {
+	if ttl <= 0 {
+		return nil, errors.New("idempotency ttl must be > 0")
+	}
+	if maxEntries <= 0 {
+		return nil, errors.New("idempotency max entries must be > 0")
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return &IdempotencyCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		secret:     secret,
+		now:        time.Now,
+		entries:    make(map[string]*idempotencyEntry),
+		order:      list.New(),
+	}, nil
+}
+
+// fingerprint identifies a request by its parsed form, so formatting and
+// key order do not matter. api keeps chat and Responses requests apart.
+func (c *IdempotencyCache) fingerprint(api string, req ChatCompletionRequest) string {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return ""
+	}
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(api))
+	mac.Write([]byte{0})
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// scopedKey qualifies key with the caller's identity: a keyed hash of the
+// request's Authorization header, so credentials are never held. Callers
+// without one share an anonymous scope.
+func (c *IdempotencyCache) scopedKey(r *http.Request, key string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte("caller"))
+	mac.Write([]byte{0})
+	mac.Write([]byte(r.Header.Get("Authorization")))
+	return hex.EncodeToString(mac.Sum(nil)) + ":" + key
+}
+
+// acquire returns the stored result for key, or claims key for the caller,
+// who must then call release exactly once. A request already in flight
+// under key is waited for. Both results are nil when the cache is full of
+// in-flight requests; the caller then proceeds uncached.
+func (c *IdempotencyCache) acquire(ctx context.Context, key, fingerprint string) (*completion, func(*completion), error) {
+	for {
+		c.mu.Lock()
+		c.evictExpired()
+		entry, ok := c.entries[key]
+		if !ok {
+			if len(c.entries) >= c.maxEntries && !c.evictOldestCompleted() {
+				c.mu.Unlock()
+				return nil, nil, nil
+			}
+			entry = &idempotencyEntry{key: key, fingerprint: fingerprint, done: make(chan struct{})}
+			entry.element = c.order.PushBack(entry)
+			c.entries[key] = entry
+			c.mu.Unlock()
+			return nil, func(result *completion) { c.release(entry, result) }, nil
+		}
+		if entry.fingerprint != fingerprint {
+			c.mu.Unlock()
+			return nil, nil, errIdempotencyMismatch
+		}
+		if entry.result != nil {
+			result := entry.result
+			c.mu.Unlock()
+			return result, nil, nil
+		}
+		done := entry.done
+		c.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-done:
+		}
+	}
+}
+
+// release stores result for the entry's TTL, or forgets the key when the
+// request failed so that the next attempt runs again.
+func (c *IdempotencyCache) release(entry *idempotencyEntry, result *completion) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if result == nil {
+		c.remove(entry)
+	} else {
+		entry.result = result
+		entry.expires = c.now().Add(c.ttl)
+	}
+	close(entry.done)
+}
+
+func (c *IdempotencyCache) evictExpired() {
+	now := c.now()
+	for e := c.order.Front(); e != nil; {
+		next := e.Next()
+		entry := e.Value.(*idempotencyEntry)
+		if entry.result != nil && !now.Before(entry.expires) {
+			c.remove(entry)
+		}
+		e = next
+	}
+}
+
+func (c *IdempotencyCache) evictOldestCompleted() bool {
+	for e := c.order.Front(); e != nil; e = e.Next() {
+		if entry := e.Value.(*idempotencyEntry); entry.result != nil {
+			c.remove(entry)
+			return true
+		}
+	}
+	return false
+}
+
+func (c *IdempotencyCache) remove(entry *idempotencyEntry) {
+	if c.entries[entry.key] == entry {
+		delete(c.entries, entry.key)
+	}
+	c.order.Remove(entry.element)
+}
+
+// fingerprint is IdempotencyCache.fingerprint, empty without a cache.
+func (h *Handler) fingerprint(api string, req ChatCompletionRequest) string {
+	if h.idempotency == nil {
+		return ""
+	}
+	return h.idempotency.fingerprint(api, req)
+}
+
+// completeOnce is complete behind the idempotency cache.
+func (h *Handler) completeOnce(w http.ResponseWriter, r *http.Request, requestID string, req ChatCompletionRequest, fingerprint string, sanitized sanitizer.Result, decision router.Decision, trail *auditTrail, idempotencyKey string, responses bool) (completion, bool) {
+	return h.once(w, r, requestID, fingerprint, decision, trail, idempotencyKey, func() (completion, bool) {
+		return h.complete(w, r, requestID, req, sanitized, decision, trail, idempotencyKey, responses)
+	}, func(cached completion) (completion, bool) {
+		return h.replay(w, requestID, cached, sanitized, decision, trail)
+	})
+}
+
+// streamOnce is streamChatCompletions behind the idempotency cache. A
+// replay is streamed from the cached result.
+func (h *Handler) streamOnce(w http.ResponseWriter, r *http.Request, requestID string, req ChatCompletionRequest, fingerprint string, sanitized sanitizer.Result, decision router.Decision, trail *auditTrail, idempotencyKey string) {
+	h.once(w, r, requestID, fingerprint, decision, trail, idempotencyKey, func() (completion, bool) {
+		return h.streamChatCompletions(w, r, requestID, req, sanitized, decision, trail, idempotencyKey)
+	}, func(cached completion) (completion, bool) {
+		return h.replayStream(w, requestID, req.Model, cached, sanitized, decision, trail)
+	})
+}
+
+// once runs run behind the idempotency cache, or answers with replay when
+// key already has a result. Requests without a key, or handlers without a
+// cache, always run.
+func (h *Handler) once(w http.ResponseWriter, r *http.Request, requestID, fingerprint string, decision router.Decision, trail *auditTrail, idempotencyKey string, run func() (completion, bool), replay func(completion) (completion, bool)) (completion, bool) {
+	if h.idempotency == nil || idempotencyKey == "" {
+		return run()
+	}
+
+	cached, release, err := h.idempotency.acquire(r.Context(), h.idempotency.scopedKey(r, idempotencyKey), fingerprint)
+	switch {
+	case errors.Is(err, errIdempotencyMismatch):
+		h.writeError(w, http.StatusUnprocessableEntity, "ERR_IDEMPOTENCY_KEY_MISMATCH", "Idempotency-Key was already used with a different request", requestID)
+		_ = h.appendAudit(requestID, decision.Category, decision.Route, trail.failed(http.StatusUnprocessableEntity, "ERR_IDEMPOTENCY_KEY_MISMATCH", " idempotency-mismatch"))
+		return completion{}, false
+	case err != nil:
+		// The caller gave up while another request held the key.
+		h.writeCancelled(w, requestID, decision, trail, err)
+		return completion{}, false
+	case cached != nil:
+		return replay(*cached)
+	case release == nil:
+		return run()
+	}
+
+	result, ok := run()
+	if !ok {
+		release(nil)
+		return completion{}, false
+	}
+	release(&completion{
+		sanitizedChoices: result.sanitizedChoices,
+		usage:            result.usage,
+		abstracted:       result.abstracted,
+		tokensSaved:      result.tokensSaved,
+	})
+	return result, true
+}
+
+// writeCancelled answers a request whose context ended before it could be
+// served: a deadline is a timeout, anything else a client cancellation.
+func (h *Handler) writeCancelled(w http.ResponseWriter, requestID string, decision router.Decision, trail *auditTrail, err error) {
+	status, code, message, suffix := statusClientClosedRequest, "ERR_REQUEST_CANCELLED", "request cancelled", " request-cancelled"
+	if errors.Is(err, context.DeadlineExceeded) {
+		status, code, message, suffix = http.StatusServiceUnavailable, "ERR_PROVIDER_TIMEOUT", "provider timeout", " provider-timeout"
+	}
+	h.writeError(w, status, code, message, requestID)
+	_ = h.appendAudit(requestID, decision.Category, decision.Route, trail.failed(status, code, suffix+" idempotency-wait"))
+}
+
+// replay rehydrates a cached result with this request's own mappings.
+func (h *Handler) replay(w http.ResponseWriter, requestID string, cached completion, sanitized sanitizer.Result, decision router.Decision, trail *auditTrail) (completion, bool) {
+	cached, ok := h.replayed(w, requestID, cached, sanitized, decision, trail)
+	if !ok {
+		h.writeError(w, http.StatusInternalServerError, "ERR_AUDIT_FAILURE", "audit append failed", requestID)
+	}
+	return cached, ok
+}
+
+// replayStream is replay for a streaming request: the cached content is
+// sent as a single delta, followed by its tool calls.
+func (h *Handler) replayStream(w http.ResponseWriter, requestID, model string, cached completion, sanitized sanitizer.Result, decision router.Decision, trail *auditTrail) (completion, bool) {
+	cached, ok := h.replayed(w, requestID, cached, sanitized, decision, trail)
+	if !ok {
+		h.writeError(w, http.StatusInternalServerError, "ERR_AUDIT_FAILURE", "audit append failed", requestID)
+		return completion{}, false
+	}
+	choice := cached.choices[0]
+	stream := newChatStreamWriter(w, requestID, model)
+	stream.finishReason = choice.FinishReason
+	if err := stream.writeDelta(choice.Content); err != nil {
+		return completion{}, false
+	}
+	if err := stream.writeToolCalls(choice.ToolCalls); err != nil {
+		return completion{}, false
+	}
+	return cached, stream.finish() == nil
+}
+
+// replayed rehydrates cached, audits the replay and sets its headers. It
+// returns false when the audit record could not be appended.
+func (h *Handler) replayed(w http.ResponseWriter, requestID string, cached completion, sanitized sanitizer.Result, decision router.Decision, trail *auditTrail) (completion, bool) {
+	choices, rehydrationSummary := h.rehydrateChoices(decision.Route, cached.sanitizedChoices, sanitized.Mappings)
+	trail.abstracted = cached.abstracted
+	if err := h.appendAudit(requestID, decision.Category, decision.Route, trail.success(" idempotent-replay"+rehydrationSummary)); err != nil {
+		return completion{}, false
+	}
+	setAbstractionHeaders(w, cached.abstracted, cached.tokensSaved)
+	w.Header().Set(headerIdempotentReplay, "true")
+	cached.choices = choices
+	return cached, true
+}
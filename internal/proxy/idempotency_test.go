@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyCacheExpiresEntriesAfterTTL(t *testing.T) {
+	cache, err := NewIdempotencyCache(time.Minute, 8)
+	if err != nil {
+		t.Fatalf("NewIdempotencyCache failed: %v", err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	cache.now = func() time.Time { return now }
+
+	_, release, err := cache.acquire(context.Background(), "k", "fp")
+	if err != nil || release == nil {
+		t.Fatalf("expected to claim a new key, got release=%v err=%v", release != nil, err)
+	}
+	release(&completion{sanitizedChoices: []ForwardChoice{{Content: "cached"}}})
+
+	cached, _, err := cache.acquire(context.Background(), "k", "fp")
+	if err != nil || cached == nil || cached.sanitizedChoices[0].Content != "cached" {
+		t.Fatalf("expected the stored result, got %+v err=%v", cached, err)
+	}
+
+	now = now.Add(time.Minute)
+	cached, release, err = cache.acquire(context.Background(), "k", "other")
+	if err != nil || cached != nil || release == nil {
+		t.Fatalf("expected an expired key to be claimable again, got cached=%+v err=%v", cached, err)
+	}
+}
+
+func TestIdempotencyCacheRejectsFingerprintMismatch(t *testing.T) {
+	cache, err := NewIdempotencyCache(time.Minute, 8)
+	if err != nil {
+		t.Fatalf("NewIdempotencyCache failed: %v", err)
+	}
+	if _, _, err := cache.acquire(context.Background(), "k", "fp"); err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+	if _, _, err := cache.acquire(context.Background(), "k", "other"); !errors.Is(err, errIdempotencyMismatch) {
+		t.Fatalf("expected errIdempotencyMismatch while in flight, got %v", err)
+	}
+}
+
+func TestIdempotencyCacheEvictsOldestCompletedEntry(t *testing.T) {
+	cache, err := NewIdempotencyCache(time.Minute, 2)
+	if err != nil {
+		t.Fatalf("NewIdempotencyCache failed: %v", err)
+	}
+	_, releaseA, _ := cache.acquire(context.Background(), "a", "fp")
+	releaseA(&completion{})
+	_, releaseB, _ := cache.acquire(context.Background(), "b", "fp")
+
+	_, releaseC, err := cache.acquire(context.Background(), "c", "fp")
+	if err != nil || releaseC == nil {
+		t.Fatalf("expected the completed entry to make room, got err=%v", err)
+	}
+	if _, ok := cache.entries["a"]; ok {
+		t.Fatal("expected the oldest completed entry to be evicted")
+	}
+
+	cached, releaseD, err := cache.acquire(context.Background(), "d", "fp")
+	if err != nil || cached != nil || releaseD != nil {
+		t.Fatalf("expected to proceed uncached while every entry is in flight, got cached=%+v claimed=%t err=%v", cached, releaseD != nil, err)
+	}
+	releaseB(nil)
+	if _, ok := cache.entries["b"]; ok {
+		t.Fatal("expected a failed request to forget its key")
+	}
+}
+
+func TestIdempotencyCacheWaiterGivesUpWithItsContext(t *testing.T) {
+	cache, err := NewIdempotencyCache(time.Minute, 8)
+	if err != nil {
+		t.Fatalf("NewIdempotencyCache failed: %v", err)
+	}
+	if _, _, err := cache.acquire(context.Background(), "k", "fp"); err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := cache.acquire(ctx, "k", "fp"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the waiter to stop at its deadline, got %v", err)
+	}
+}
+
+func TestIdempotencyCacheHoldsContentBeforeRehydration(t *testing.T) {
+	cache, err := NewIdempotencyCache(time.Minute, 8)
+	if err != nil {
+		t.Fatalf("NewIdempotencyCache failed: %v", err)
+	}
+	h := NewHandler(HandlerConfig{Upstream: echoUpstreamAdapter{}, Idempotency: cache})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-test","messages":[{"role":"user","content":"Write to alice@example.com"}]}`))
+	req.Header.Set("Idempotency-Key", "k")
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, req)
+
+	if !strings.Contains(rec.Body.String(), "alice@example.com") {
+		t.Fatalf("expected the client to get rehydrated content, got %s", rec.Body.String())
+	}
+	entry := cache.entries[cache.scopedKey(req, "k")]
+	if entry == nil || entry.result == nil {
+		t.Fatal("expected the response to be cached")
+	}
+	for _, choice := range entry.result.sanitizedChoices {
+		if strings.Contains(choice.Content, "alice@example.com") {
+			t.Fatalf("cached content holds the raw email: %q", choice.Content)
+		}
+	}
+	if entry.result.choices != nil {
+		t.Fatal("expected rehydrated choices not to be cached")
+	}
+}
+
+func TestIdempotencyCacheScopesKeysByCaller(t *testing.T) {
+	cache, err := NewIdempotencyCache(time.Minute, 8)
+	if err != nil {
+		t.Fatalf("NewIdempotencyCache failed: %v", err)
+	}
+	alice := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	alice.Header.Set("Authorization", "Bearer alice-key")
+	bob := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	bob.Header.Set("Authorization", "Bearer bob-key")
+
+	if cache.scopedKey(alice, "k") == cache.scopedKey(bob, "k") {
+		t.Fatal("expected different callers to get different keys")
+	}
+	if cache.scopedKey(alice, "k") != cache.scopedKey(alice, "k") {
+		t.Fatal("expected the same caller to get the same key")
+	}
+	if strings.Contains(cache.scopedKey(alice, "k"), "alice-key") {
+		t.Fatal("scoped key holds the raw credential")
+	}
+}
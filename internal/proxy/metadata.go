@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/soloengine/lpg/internal/router"
+)
+
+// Usage is the token accounting reported by the provider that answered.
+// It stays zero when no provider was called or the provider did not report
+// usage.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// withTotal fills TotalTokens for providers that only report its parts.
+func (u Usage) withTotal() Usage {
+	if u.TotalTokens == 0 {
+		u.TotalTokens = u.PromptTokens + u.CompletionTokens
+	}
+	return u
+}
+
+// Response headers carrying the routing outcome, so clients can react
+// without calling /v1/debug/explain.
+const (
+	headerRoute        = "x-lpg-route"
+	headerRiskCategory = "x-lpg-risk-category"
+	headerDetections   = "x-lpg-detections"
+	headerAbstraction  = "x-lpg-abstraction"
+	headerTokensSaved  = "x-lpg-tokens-saved"
+	// headerIdempotentReplay marks a response served from the
+	// idempotency cache.
+	headerIdempotentReplay = "x-lpg-idempotent-replay"
+)
+
+// setDecisionHeaders must run before the status line is written.
+func setDecisionHeaders(w http.ResponseWriter, decision router.Decision, detections int) {
+	w.Header().Set(headerRoute, string(decision.Route))
+	w.Header().Set(headerRiskCategory, string(decision.Category))
+	w.Header().Set(headerDetections, strconv.Itoa(detections))
+}
+
+// setAbstractionHeaders reports whether local abstraction rewrote the
+// request and roughly how many prompt tokens it kept from the provider.
+func setAbstractionHeaders(w http.ResponseWriter, abstracted bool, tokensSaved int) {
+	w.Header().Set(headerAbstraction, strconv.FormatBool(abstracted))
+	if abstracted {
+		w.Header().Set(headerTokensSaved, strconv.Itoa(max(tokensSaved, 0)))
+	}
+}
+
+// estimateTokens approximates a token count at four characters per token.
+// It is only used for the tokens-saved header, never for billing.
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
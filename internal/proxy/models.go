@@ -0,0 +1,171 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/soloengine/lpg/internal/router"
+)
+
+const localAbstractionProviderName = "local_abstraction"
+
+// ModelLister is implemented by adapters that can report the models they
+// serve. Adapters that cannot are simply left out of /v1/models.
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+type ModelsResponse struct {
+	Object string       `json:"object"`
+	Data   []ModelEntry `json:"data"`
+}
+
+// ModelEntry is an OpenAI model object with LPG annotations: the providers
+// that list it, the routes on which they may serve it and whether any of
+// them is remote, so that serving the model may involve egress.
+type ModelEntry struct {
+	ID        string         `json:"id"`
+	Object    string         `json:"object"`
+	Created   int64          `json:"created"`
+	OwnedBy   string         `json:"owned_by"`
+	Providers []string       `json:"providers"`
+	Routes    []router.Route `json:"routes"`
+	Egress    bool           `json:"egress"`
+}
+
+// HandleModels serves /v1/models. Each provider and the local abstractor
+// are asked for their models under providerTimeout; one that fails is left
+// out rather than failing the whole catalogue. With a model allowlist the
+// catalogue is exactly the allowlist, and allowlisted models no provider
+// reports are annotated with the whole chain, since any provider may be
+// asked to serve them.
+func (h *Handler) HandleModels(w http.ResponseWriter, r *http.Request) {
+	requestID := newRequestID()
+	w.Header().Set("x-lpg-request-id", requestID)
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		h.writeError(w, http.StatusMethodNotAllowed, "ERR_METHOD_NOT_ALLOWED", "method not allowed", requestID)
+		return
+	}
+
+	entries := map[string]*ModelEntry{}
+	add := func(id, provider string, routes []router.Route, egress bool) {
+		if !h.modelAllowed(id) {
+			return
+		}
+		entry, ok := entries[id]
+		if !ok {
+			entry = &ModelEntry{ID: id, Object: "model", OwnedBy: provider, Routes: []router.Route{}}
+			entries[id] = entry
+		}
+		if containsString(entry.Providers, provider) {
+			return
+		}
+		entry.Providers = append(entry.Providers, provider)
+		entry.Routes = mergeRoutes(entry.Routes, routes)
+		entry.Egress = entry.Egress || egress
+	}
+
+	for _, p := range h.providers {
+		lister, ok := p.Adapter.(ModelLister)
+		if !ok {
+			continue
+		}
+		models, err := h.listModels(r.Context(), lister)
+		if err != nil {
+			continue
+		}
+		for _, id := range models {
+			add(id, p.Name, h.providerRoutes(p), !p.Local)
+		}
+	}
+	if lister, ok := h.abstractor.(ModelLister); ok {
+		if models, err := h.listModels(r.Context(), lister); err == nil {
+			for _, id := range models {
+				add(id, localAbstractionProviderName, []router.Route{router.RouteHighAbstraction, router.RouteCriticalLocalOnly}, false)
+			}
+		}
+	}
+	for _, id := range h.modelAllowlist {
+		if _, ok := entries[id]; ok {
+			continue
+		}
+		for _, p := range h.providers {
+			add(id, p.Name, h.providerRoutes(p), !p.Local)
+		}
+	}
+
+	data := make([]ModelEntry, 0, len(entries))
+	for _, entry := range entries {
+		data = append(data, *entry)
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].ID < data[j].ID })
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(ModelsResponse{Object: "list", Data: data})
+}
+
+func (h *Handler) listModels(ctx context.Context, lister ModelLister) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.providerTimeout)
+	defer cancel()
+	return lister.ListModels(ctx)
+}
+
+// modelAllowed reports whether requests may name model. Without an
+// allowlist every model is allowed.
+func (h *Handler) modelAllowed(model string) bool {
+	return len(h.modelAllowlist) == 0 || containsString(h.modelAllowlist, model)
+}
+
+// checkModel rejects models outside the allowlist with 404
+// ERR_MODEL_NOT_FOUND.
+func (h *Handler) checkModel(w http.ResponseWriter, requestID, model string) error {
+	if h.modelAllowed(model) {
+		return nil
+	}
+	h.writeError(w, http.StatusNotFound, "ERR_MODEL_NOT_FOUND", fmt.Sprintf("model %q is not in the model catalogue", model), requestID)
+	return fmt.Errorf("model %q not allowed", model)
+}
+
+// providerRoutes lists the forwarding routes p may serve under the current
+// routing settings, in routeSeverity order.
+func (h *Handler) providerRoutes(p Provider) []router.Route {
+	candidates := []router.Route{router.RouteSanitizedForward, router.RouteHighAbstraction, router.RouteCriticalLocalOnly}
+	if h.router.AllowsRawForwarding() {
+		candidates = append([]router.Route{router.RouteRawForward}, candidates...)
+	}
+	routes := make([]router.Route, 0, len(candidates))
+	for _, route := range candidates {
+		if route == router.RouteCriticalLocalOnly && !p.Local {
+			continue
+		}
+		if len(p.Routes) > 0 && !containsRoute(p.Routes, route) {
+			continue
+		}
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func mergeRoutes(routes, more []router.Route) []router.Route {
+	for _, route := range more {
+		if !containsRoute(routes, route) {
+			routes = append(routes, route)
+		}
+	}
+	sort.SliceStable(routes, func(i, j int) bool { return routeSeverity(routes[i]) < routeSeverity(routes[j]) })
+	return routes
+}
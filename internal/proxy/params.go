@@ -0,0 +1,183 @@
+package proxy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	maxChoices       = 8
+	maxStopSequences = 4
+)
+
+// supportedChatFields is the allowlist of top-level request fields. Anything
+// else is rejected with ERR_UNSUPPORTED_FIELD rather than silently dropped.
+var supportedChatFields = map[string]bool{
+	"model":           true,
+	"messages":        true,
+	"stream":          true,
+	"temperature":     true,
+	"max_tokens":      true,
+	"top_p":           true,
+	"stop":            true,
+	"seed":            true,
+	"response_format": true,
+	"n":               true,
+	"user":            true,
+	"tools":           true,
+	"tool_choice":     true,
+}
+
+// GenerationParams are the standard OpenAI request parameters other than
+// model and messages that are forwarded to providers. Nil pointers and empty
+// values are omitted so providers apply their own defaults.
+type GenerationParams struct {
+	Temperature    *float64        `json:"temperature,omitempty"`
+	MaxTokens      *int            `json:"max_tokens,omitempty"`
+	TopP           *float64        `json:"top_p,omitempty"`
+	Stop           StopSequences   `json:"stop,omitempty"`
+	Seed           *int64          `json:"seed,omitempty"`
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	N              *int            `json:"n,omitempty"`
+	User           string          `json:"user,omitempty"`
+	Tools          []Tool          `json:"tools,omitempty"`
+	ToolChoice     json.RawMessage `json:"tool_choice,omitempty"`
+}
+
+// StopSequences accepts either a single string or an array of strings.
+type StopSequences []string
+
+func (s *StopSequences) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*s = StopSequences{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return errors.New("stop must be a string or an array of strings")
+	}
+	*s = many
+	return nil
+}
+
+type ResponseFormat struct {
+	Type       string          `json:"type"`
+	JSONSchema json.RawMessage `json:"json_schema,omitempty"`
+}
+
+// unsupportedField returns the first (alphabetically) top-level field that
+// is not in supported, or "" when every field is supported.
+func unsupportedField(body []byte, supported map[string]bool) (string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return "", err
+	}
+	unsupported := make([]string, 0)
+	for name := range fields {
+		if !supported[name] {
+			unsupported = append(unsupported, name)
+		}
+	}
+	if len(unsupported) == 0 {
+		return "", nil
+	}
+	sort.Strings(unsupported)
+	return unsupported[0], nil
+}
+
+func (p GenerationParams) validate() error {
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+		return errors.New("temperature must be between 0 and 2")
+	}
+	if p.TopP != nil && (*p.TopP < 0 || *p.TopP > 1) {
+		return errors.New("top_p must be between 0 and 1")
+	}
+	if p.MaxTokens != nil && *p.MaxTokens <= 0 {
+		return errors.New("max_tokens must be > 0")
+	}
+	if p.N != nil && (*p.N < 1 || *p.N > maxChoices) {
+		return fmt.Errorf("n must be between 1 and %d", maxChoices)
+	}
+	if len(p.Stop) > maxStopSequences {
+		return fmt.Errorf("stop accepts at most %d sequences", maxStopSequences)
+	}
+	if p.ResponseFormat != nil {
+		switch p.ResponseFormat.Type {
+		case "text", "json_object":
+		case "json_schema":
+			if len(p.ResponseFormat.JSONSchema) == 0 {
+				return errors.New("response_format.json_schema is required when type is \"json_schema\"")
+			}
+		default:
+			return fmt.Errorf("response_format.type %q is not supported: must be \"text\", \"json_object\" or \"json_schema\"", p.ResponseFormat.Type)
+		}
+	}
+	return validateTools(p.Tools, p.ToolChoice)
+}
+
+func (p GenerationParams) choices() int {
+	if p.N == nil {
+		return 1
+	}
+	return *p.N
+}
+
+// forProvider returns the parameters as sent upstream. The end-user
+// identifier is often an email address or account ID, so it is replaced by
+// a stable pseudonym that still lets providers correlate abuse reports.
+func (p GenerationParams) forProvider(userKey []byte) GenerationParams {
+	p.User = pseudonymizeUser(userKey, p.User)
+	return p
+}
+
+// pseudonymizeUser is keyed so that the pseudonym cannot be reversed by
+// hashing a list of likely emails or account IDs.
+func pseudonymizeUser(key []byte, user string) string {
+	if strings.TrimSpace(user) == "" {
+		return user
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strings.TrimSpace(user)))
+	return "lpg-user-" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// walkParamTexts visits the free text params send upstream besides the
+// messages: each stop sequence, then the tool and response schemas in
+// walkSchemaTexts order. fn's return value replaces the visited text in the
+// returned copy.
+func walkParamTexts(params GenerationParams, fn func(string) string) GenerationParams {
+	if len(params.Stop) > 0 {
+		stop := make(StopSequences, 0, len(params.Stop))
+		for _, s := range params.Stop {
+			stop = append(stop, fn(s))
+		}
+		params.Stop = stop
+	}
+	return walkSchemaTexts(params, fn)
+}
+
+func paramTexts(params GenerationParams) []string {
+	var texts []string
+	walkParamTexts(params, func(text string) string {
+		texts = append(texts, text)
+		return text
+	})
+	return texts
+}
+
+// withParamTexts rebuilds params from texts laid out by paramTexts.
+func withParamTexts(params GenerationParams, texts []string) GenerationParams {
+	next := 0
+	return walkParamTexts(params, func(string) string {
+		text := texts[next]
+		next++
+		return text
+	})
+}
@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestStopSequencesAcceptStringOrArray(t *testing.T) {
+	var single GenerationParams
+	if err := json.Unmarshal([]byte(`{"stop":"END"}`), &single); err != nil {
+		t.Fatalf("unmarshal string stop: %v", err)
+	}
+	var many GenerationParams
+	if err := json.Unmarshal([]byte(`{"stop":["END","STOP"]}`), &many); err != nil {
+		t.Fatalf("unmarshal array stop: %v", err)
+	}
+	if len(single.Stop) != 1 || single.Stop[0] != "END" || len(many.Stop) != 2 {
+		t.Fatalf("unexpected stop sequences: %v %v", single.Stop, many.Stop)
+	}
+
+	var invalid GenerationParams
+	if err := json.Unmarshal([]byte(`{"stop":42}`), &invalid); err == nil {
+		t.Fatal("expected numeric stop to be rejected")
+	}
+}
+
+func TestGenerationParamsValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "temperature too high", body: `{"temperature":2.5}`, want: "temperature must be between 0 and 2"},
+		{name: "negative top_p", body: `{"top_p":-0.1}`, want: "top_p must be between 0 and 1"},
+		{name: "zero max_tokens", body: `{"max_tokens":0}`, want: "max_tokens must be > 0"},
+		{name: "too many choices", body: `{"n":9}`, want: "n must be between 1 and 8"},
+		{name: "too many stop sequences", body: `{"stop":["a","b","c","d","e"]}`, want: "stop accepts at most 4 sequences"},
+		{name: "unknown response format", body: `{"response_format":{"type":"xml"}}`, want: `response_format.type "xml" is not supported`},
+		{name: "json schema without schema", body: `{"response_format":{"type":"json_schema"}}`, want: "response_format.json_schema is required"},
+		{name: "valid", body: `{"temperature":0,"top_p":1,"max_tokens":64,"n":2,"seed":7,"response_format":{"type":"json_schema","json_schema":{"name":"x"}}}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var params GenerationParams
+			if err := json.Unmarshal([]byte(tc.body), &params); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			err := params.validate()
+			if tc.want == "" {
+				if err != nil {
+					t.Fatalf("expected valid params, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tc.want) {
+				t.Fatalf("expected error starting with %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestGenerationParamsPseudonymizeUser(t *testing.T) {
+	key := []byte("pseudonym-key")
+	params := GenerationParams{User: "alice@example.com"}.forProvider(key)
+	again := GenerationParams{User: "alice@example.com"}.forProvider(key)
+
+	if strings.Contains(params.User, "alice") || !strings.HasPrefix(params.User, "lpg-user-") {
+		t.Fatalf("expected pseudonymous user, got %q", params.User)
+	}
+	if params.User != again.User {
+		t.Fatalf("expected stable pseudonym, got %q and %q", params.User, again.User)
+	}
+	sum := sha256.Sum256([]byte("alice@example.com"))
+	if params.User == "lpg-user-"+hex.EncodeToString(sum[:8]) {
+		t.Fatal("expected the pseudonym to be keyed, not a bare hash")
+	}
+	if other := (GenerationParams{User: "alice@example.com"}).forProvider([]byte("other-key")); other.User == params.User {
+		t.Fatal("expected the key to change the pseudonym")
+	}
+	if (GenerationParams{}).forProvider(key).User != "" {
+		t.Fatal("expected empty user to stay empty")
+	}
+}
+
+func TestParamTextsRoundTripStopSequences(t *testing.T) {
+	params := GenerationParams{Stop: StopSequences{"END", "alice@example.com"}}
+	texts := paramTexts(params)
+	if strings.Join(texts, "|") != "END|alice@example.com" {
+		t.Fatalf("unexpected texts %q", texts)
+	}
+	rebuilt := withParamTexts(params, []string{"END", "person1@example.net"})
+	if rebuilt.Stop[1] != "person1@example.net" || params.Stop[1] != "alice@example.com" {
+		t.Fatalf("expected a rebuilt copy, got %q from %q", rebuilt.Stop, params.Stop)
+	}
+}
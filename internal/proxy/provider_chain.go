@@ -0,0 +1,335 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/soloengine/lpg/internal/risk"
+	"github.com/soloengine/lpg/internal/router"
+)
+
+// Hop outcomes recorded in the audit summary.
+const (
+	hopOK              = "ok"
+	hopTimeout         = "timeout"
+	hopConnectionError = "connection_error"
+	hopFailure         = "failure"
+	hopCircuitOpen     = "circuit_open"
+)
+
+// Provider is one entry of the ordered fallback chain. Empty Routes or
+// Categories mean the provider may serve any forwarding route or category.
+// Only Local providers are ever eligible for critical_local_only traffic,
+// whatever Routes says. Breaker is optional and sits beside Adapter rather
+// than wrapping it, so the adapter's optional interfaces stay visible.
+type Provider struct {
+	Name       string
+	Adapter    UpstreamAdapter
+	Local      bool
+	Routes     []router.Route
+	Categories []risk.Category
+	Breaker    *CircuitBreaker
+}
+
+// ProviderHop records the attempts against one provider in the chain.
+// Outcome is that of the last attempt.
+type ProviderHop struct {
+	Provider string
+	Outcome  string
+	Attempts int
+}
+
+var errNoEligibleProvider = errors.New("no eligible provider for route")
+
+func (p Provider) serves(route router.Route, category risk.Category) bool {
+	if route == router.RouteCriticalLocalOnly && !p.Local {
+		return false
+	}
+	if len(p.Routes) > 0 && !containsRoute(p.Routes, route) {
+		return false
+	}
+	if len(p.Categories) > 0 && !containsCategory(p.Categories, category) {
+		return false
+	}
+	return true
+}
+
+func (h *Handler) eligibleProviders(route router.Route, category risk.Category) []Provider {
+	eligible := make([]Provider, 0, len(h.providers))
+	for _, p := range h.providers {
+		if p.serves(route, category) {
+			eligible = append(eligible, p)
+		}
+	}
+	return eligible
+}
+
+// forward sends req through the eligible providers in order, within the
+// retry policy's overall budget. Each provider is retried per the policy
+// when retry is set; only timeouts, 5xx responses and connection errors
+// move on to the next provider. Providers with an open circuit are skipped,
+// and errProviderUnavailable is returned when no provider was tried.
+func (h *Handler) forward(ctx context.Context, req ForwardRequest, retry bool) (ForwardResponse, []ProviderHop, error) {
+	providers := h.eligibleProviders(req.Route, req.RiskCategory)
+	if len(providers) == 0 {
+		return ForwardResponse{}, nil, errNoEligibleProvider
+	}
+	ctx, cancel := h.withBudget(ctx)
+	defer cancel()
+
+	hops := make([]ProviderHop, 0, len(providers))
+	var err error
+	for _, p := range providers {
+		if !p.Breaker.allow() {
+			hops = append(hops, ProviderHop{Provider: p.Name, Outcome: hopCircuitOpen})
+			if err == nil {
+				err = errProviderUnavailable
+			}
+			continue
+		}
+		var resp ForwardResponse
+		var attempts int
+		resp, attempts, err = h.callProvider(ctx, p, req, retry)
+		p.Breaker.record(err)
+		hops = append(hops, ProviderHop{Provider: p.Name, Outcome: hopOutcome(err), Attempts: attempts})
+		if err == nil {
+			return resp, hops, nil
+		}
+		if !failoverEligible(err) || ctx.Err() != nil {
+			break
+		}
+	}
+	return ForwardResponse{}, hops, err
+}
+
+func (h *Handler) callProvider(ctx context.Context, p Provider, req ForwardRequest, retry bool) (ForwardResponse, int, error) {
+	var resp ForwardResponse
+	attempts, err := h.attempt(ctx, retry, func() bool { return true }, func(attemptCtx context.Context) error {
+		var err error
+		resp, err = callAdapter(attemptCtx, p.Adapter, req)
+		return err
+	})
+	return resp, attempts, err
+}
+
+// callAdapter sends /v1/responses traffic natively when the adapter supports
+// it and translates everything else to chat completions.
+func callAdapter(ctx context.Context, adapter UpstreamAdapter, req ForwardRequest) (ForwardResponse, error) {
+	if req.Responses {
+		if native, ok := adapter.(ResponsesUpstreamAdapter); ok && native.SupportsResponses() {
+			return native.Responses(ctx, req)
+		}
+	}
+	return adapter.ChatCompletions(ctx, req)
+}
+
+// forwardStream is forward for streaming requests. Failover stops once the
+// first chunk has reached the client, since a partial response cannot be
+// retracted. The retry budget only covers the wait for that first chunk;
+// after it, the stream runs for as long as chunks keep arriving.
+func (h *Handler) forwardStream(ctx context.Context, req ForwardRequest, retry bool, started func() bool, onChunk func(StreamChunk) error) ([]ProviderHop, error) {
+	providers := h.eligibleProviders(req.Route, req.RiskCategory)
+	if len(providers) == 0 {
+		return nil, errNoEligibleProvider
+	}
+	ctx, budget := withStreamDeadline(ctx, h.retry.Budget)
+	defer budget.release()
+	deliver := onChunk
+	onChunk = func(chunk StreamChunk) error {
+		budget.lift()
+		return deliver(chunk)
+	}
+
+	hops := make([]ProviderHop, 0, len(providers))
+	var err error
+	for _, p := range providers {
+		if !p.Breaker.allow() {
+			hops = append(hops, ProviderHop{Provider: p.Name, Outcome: hopCircuitOpen})
+			if err == nil {
+				err = errProviderUnavailable
+			}
+			continue
+		}
+		var attempts int
+		attempts, err = h.streamProvider(ctx, p, req, retry, started, onChunk)
+		p.Breaker.record(err)
+		hops = append(hops, ProviderHop{Provider: p.Name, Outcome: hopOutcome(err), Attempts: attempts})
+		if err == nil {
+			return hops, nil
+		}
+		if started() || !failoverEligible(err) || ctx.Err() != nil {
+			break
+		}
+	}
+	return hops, err
+}
+
+// streamProvider never retries once a chunk has reached the client. Each
+// attempt has providerTimeout to produce its first chunk, and then
+// idleTimeout between chunks; the clock stops while a chunk is being
+// handed to the client.
+func (h *Handler) streamProvider(ctx context.Context, p Provider, req ForwardRequest, retry bool, started func() bool, onChunk func(StreamChunk) error) (int, error) {
+	notStarted := func() bool { return !started() }
+	return h.attempts(ctx, retry, notStarted, func(ctx context.Context) error {
+		attemptCtx, deadline := withStreamDeadline(ctx, h.providerTimeout)
+		defer deadline.release()
+		err := streamFrom(attemptCtx, p.Adapter, req, func(chunk StreamChunk) error {
+			deadline.lift()
+			if err := onChunk(chunk); err != nil {
+				return err
+			}
+			deadline.reset(h.idleTimeout)
+			return nil
+		})
+		return hopError(attemptCtx, err)
+	})
+}
+
+func streamFrom(ctx context.Context, adapter UpstreamAdapter, req ForwardRequest, onChunk func(StreamChunk) error) error {
+	if streamer, ok := adapter.(StreamingUpstreamAdapter); ok {
+		return streamer.ChatCompletionsStream(ctx, req, onChunk)
+	}
+
+	resp, err := adapter.ChatCompletions(ctx, req)
+	if err != nil {
+		return err
+	}
+	choice := resp.choices()[0]
+	if err := onChunk(StreamChunk{Content: choice.Content, ToolCalls: toolCallDeltas(choice.ToolCalls)}); err != nil {
+		return err
+	}
+	finishReason := choice.FinishReason
+	if finishReason == "" {
+		finishReason = "stop"
+		if len(choice.ToolCalls) > 0 {
+			finishReason = "tool_calls"
+		}
+	}
+	return onChunk(StreamChunk{FinishReason: finishReason})
+}
+
+// forwardEmbeddings is forward for /v1/embeddings. Only eligible providers
+// whose adapter can embed are tried, under the same breakers and budget.
+func (h *Handler) forwardEmbeddings(ctx context.Context, req EmbedRequest, retry bool) (EmbedResponse, []ProviderHop, error) {
+	providers := make([]Provider, 0, len(h.providers))
+	for _, p := range h.eligibleProviders(req.Route, req.RiskCategory) {
+		if _, ok := p.Adapter.(EmbeddingsAdapter); ok {
+			providers = append(providers, p)
+		}
+	}
+	if len(providers) == 0 {
+		return EmbedResponse{}, nil, errNoEligibleProvider
+	}
+	ctx, cancel := h.withBudget(ctx)
+	defer cancel()
+
+	hops := make([]ProviderHop, 0, len(providers))
+	var err error
+	for _, p := range providers {
+		if !p.Breaker.allow() {
+			hops = append(hops, ProviderHop{Provider: p.Name, Outcome: hopCircuitOpen})
+			if err == nil {
+				err = errProviderUnavailable
+			}
+			continue
+		}
+		adapter := p.Adapter.(EmbeddingsAdapter)
+		var resp EmbedResponse
+		var attempts int
+		attempts, err = h.attempt(ctx, retry, func() bool { return true }, func(attemptCtx context.Context) error {
+			var err error
+			resp, err = embedWith(attemptCtx, adapter, req)
+			return err
+		})
+		p.Breaker.record(err)
+		hops = append(hops, ProviderHop{Provider: p.Name, Outcome: hopOutcome(err), Attempts: attempts})
+		if err == nil {
+			return resp, hops, nil
+		}
+		if !failoverEligible(err) || ctx.Err() != nil {
+			break
+		}
+	}
+	return EmbedResponse{}, hops, err
+}
+
+// embedWith embeds req and checks that every input got a vector.
+func embedWith(ctx context.Context, adapter EmbeddingsAdapter, req EmbedRequest) (EmbedResponse, error) {
+	resp, err := adapter.Embeddings(ctx, req)
+	if err == nil && len(resp.Embeddings) != len(req.Input) {
+		err = fmt.Errorf("provider returned %d embeddings for %d inputs", len(resp.Embeddings), len(req.Input))
+	}
+	return resp, err
+}
+
+// hopError makes a hop that ran out of its own budget report as a timeout
+// even when the adapter returned a less specific error.
+func hopError(hopCtx context.Context, err error) error {
+	if err != nil && !isTimeout(err) && errors.Is(context.Cause(hopCtx), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %v", context.DeadlineExceeded, err)
+	}
+	return err
+}
+
+func failoverEligible(err error) bool {
+	switch hopOutcome(err) {
+	case hopTimeout, hopConnectionError:
+		return true
+	}
+	var statusErr *ProviderHTTPStatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode >= 500
+}
+
+func hopOutcome(err error) string {
+	if err == nil {
+		return hopOK
+	}
+	if isTimeout(err) {
+		return hopTimeout
+	}
+	var statusErr *ProviderHTTPStatusError
+	if errors.As(err, &statusErr) {
+		return fmt.Sprintf("status_%d", statusErr.StatusCode)
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return hopConnectionError
+	}
+	return hopFailure
+}
+
+func providerHopSummary(hops []ProviderHop) string {
+	if len(hops) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(hops))
+	for _, hop := range hops {
+		part := hop.Provider + ":" + hop.Outcome
+		if hop.Attempts > 1 {
+			part += fmt.Sprintf("/attempts=%d", hop.Attempts)
+		}
+		parts = append(parts, part)
+	}
+	return " providers=" + strings.Join(parts, ",")
+}
+
+func containsRoute(routes []router.Route, route router.Route) bool {
+	for _, r := range routes {
+		if r == route {
+			return true
+		}
+	}
+	return false
+}
+
+func containsCategory(categories []risk.Category, category risk.Category) bool {
+	for _, c := range categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
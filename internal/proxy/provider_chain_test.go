@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/soloengine/lpg/internal/risk"
+	"github.com/soloengine/lpg/internal/router"
+)
+
+func TestProviderServesRespectsConstraints(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider Provider
+		route    router.Route
+		category risk.Category
+		want     bool
+	}{
+		{name: "unconstrained", provider: Provider{}, route: router.RouteSanitizedForward, category: risk.CategoryMedium, want: true},
+		{name: "route mismatch", provider: Provider{Routes: []router.Route{router.RouteRawForward}}, route: router.RouteSanitizedForward, category: risk.CategoryLow, want: false},
+		{name: "category mismatch", provider: Provider{Categories: []risk.Category{risk.CategoryLow}}, route: router.RouteHighAbstraction, category: risk.CategoryHigh, want: false},
+		{name: "remote never local-only", provider: Provider{}, route: router.RouteCriticalLocalOnly, category: risk.CategoryCritical, want: false},
+		{name: "local may serve local-only", provider: Provider{Local: true}, route: router.RouteCriticalLocalOnly, category: risk.CategoryCritical, want: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.provider.serves(tc.route, tc.category); got != tc.want {
+				t.Fatalf("serves(%s, %s) = %t, want %t", tc.route, tc.category, got, tc.want)
+			}
+		})
+	}
+}
+
+type scriptedStreamUpstream struct {
+	calls  int
+	chunks []StreamChunk
+	err    error
+}
+
+func (u *scriptedStreamUpstream) ChatCompletions(ctx context.Context, req ForwardRequest) (ForwardResponse, error) {
+	return ForwardResponse{}, u.err
+}
+
+func (u *scriptedStreamUpstream) ChatCompletionsStream(ctx context.Context, req ForwardRequest, onChunk func(StreamChunk) error) error {
+	u.calls++
+	for _, chunk := range u.chunks {
+		if err := onChunk(chunk); err != nil {
+			return err
+		}
+	}
+	return u.err
+}
+
+func TestForwardStreamFailsOverOnlyBeforeFirstChunk(t *testing.T) {
+	unavailable := &ProviderHTTPStatusError{StatusCode: http.StatusServiceUnavailable}
+	req := ForwardRequest{Route: router.RouteSanitizedForward, RiskCategory: risk.CategoryMedium}
+
+	primary := &scriptedStreamUpstream{err: unavailable}
+	fallback := &scriptedStreamUpstream{chunks: []StreamChunk{{Content: "ok"}, {FinishReason: "stop"}}}
+	h := NewHandler(HandlerConfig{Providers: []Provider{
+		{Name: "primary", Adapter: primary},
+		{Name: "fallback", Adapter: fallback},
+	}})
+	started := false
+	hops, err := h.forwardStream(context.Background(), req, false, func() bool { return started }, func(StreamChunk) error {
+		started = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected failover to succeed, got %v", err)
+	}
+	if got := providerHopSummary(hops); got != " providers=primary:status_503,fallback:ok" {
+		t.Fatalf("unexpected hops %q", got)
+	}
+
+	primary = &scriptedStreamUpstream{chunks: []StreamChunk{{Content: "partial"}}, err: unavailable}
+	fallback = &scriptedStreamUpstream{}
+	h = NewHandler(HandlerConfig{Providers: []Provider{
+		{Name: "primary", Adapter: primary},
+		{Name: "fallback", Adapter: fallback},
+	}})
+	started = false
+	if _, err := h.forwardStream(context.Background(), req, false, func() bool { return started }, func(StreamChunk) error {
+		started = true
+		return nil
+	}); err == nil {
+		t.Fatal("expected interrupted stream to return an error")
+	}
+	if fallback.calls != 0 {
+		t.Fatalf("expected no failover after the first chunk, got %d fallback calls", fallback.calls)
+	}
+}
+
+func TestForwardEmbeddingsFollowsChainConstraintsAndBreakers(t *testing.T) {
+	open := NewCircuitBreaker(BreakerConfig{Window: 1, MinRequests: 1, ProbeInterval: -1}, StubUpstream{})
+	open.allow()
+	open.record(errUnavailable)
+
+	req := EmbedRequest{Input: []string{"hello"}, Route: router.RouteSanitizedForward, RiskCategory: risk.CategoryMedium}
+	h := NewHandler(HandlerConfig{Providers: []Provider{
+		{Name: "chat-only", Adapter: &scriptedStreamUpstream{}},
+		{Name: "raw-only", Adapter: StubUpstream{}, Routes: []router.Route{router.RouteRawForward}},
+		{Name: "open", Adapter: StubUpstream{}, Breaker: open},
+		{Name: "embedder", Adapter: StubUpstream{}},
+	}})
+	resp, hops, err := h.forwardEmbeddings(context.Background(), req, false)
+	if err != nil || len(resp.Embeddings) != 1 {
+		t.Fatalf("expected the eligible embedder to serve the batch, got %+v err=%v", resp, err)
+	}
+	if got := providerHopSummary(hops); got != " providers=open:circuit_open,embedder:ok" {
+		t.Fatalf("unexpected hops %q", got)
+	}
+
+	h = NewHandler(HandlerConfig{Providers: []Provider{
+		{Name: "chat-only", Adapter: &scriptedStreamUpstream{}},
+		{Name: "high-only", Adapter: StubUpstream{}, Categories: []risk.Category{risk.CategoryHigh}},
+	}})
+	if _, _, err := h.forwardEmbeddings(context.Background(), req, false); !errors.Is(err, errNoEligibleProvider) {
+		t.Fatalf("expected errNoEligibleProvider, got %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const roleSystem = "system"
+
+// supportedResponsesFields is the allowlist of top-level /v1/responses
+// fields, rejected with ERR_UNSUPPORTED_FIELD like the chat allowlist.
+var supportedResponsesFields = map[string]bool{
+	"model":             true,
+	"input":             true,
+	"instructions":      true,
+	"temperature":       true,
+	"max_output_tokens": true,
+	"top_p":             true,
+	"user":              true,
+}
+
+// ResponsesRequest is the accepted subset of the OpenAI Responses API.
+type ResponsesRequest struct {
+	Model           string         `json:"model"`
+	Input           ResponsesInput `json:"input"`
+	Instructions    string         `json:"instructions,omitempty"`
+	Temperature     *float64       `json:"temperature,omitempty"`
+	MaxOutputTokens *int           `json:"max_output_tokens,omitempty"`
+	TopP            *float64       `json:"top_p,omitempty"`
+	User            string         `json:"user,omitempty"`
+}
+
+// ResponsesInput accepts either a plain string, treated as one user message,
+// or an array of input items.
+type ResponsesInput []ResponsesInputItem
+
+func (in *ResponsesInput) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*in = ResponsesInput{{Type: "message", Role: "user", Content: ResponsesContent{{Type: "input_text", Text: text}}}}
+		return nil
+	}
+	var items []ResponsesInputItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return errors.New("input must be a string or an array of input items")
+	}
+	*in = items
+	return nil
+}
+
+type ResponsesInputItem struct {
+	Type    string           `json:"type,omitempty"`
+	Role    string           `json:"role"`
+	Content ResponsesContent `json:"content"`
+}
+
+// ResponsesContent accepts either a string or an array of content parts.
+type ResponsesContent []ResponsesContentPart
+
+func (c *ResponsesContent) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*c = ResponsesContent{{Type: "input_text", Text: text}}
+		return nil
+	}
+	var parts []ResponsesContentPart
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return errors.New("content must be a string or an array of content parts")
+	}
+	*c = parts
+	return nil
+}
+
+type ResponsesContentPart struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type ResponsesResponse struct {
+	ID        string                `json:"id"`
+	Object    string                `json:"object"`
+	CreatedAt int64                 `json:"created_at"`
+	Status    string                `json:"status"`
+	Model     string                `json:"model"`
+	Output    []ResponsesOutputItem `json:"output"`
+	Usage     ResponsesUsage        `json:"usage"`
+}
+
+type ResponsesUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+type ResponsesOutputItem struct {
+	Type    string                `json:"type"`
+	ID      string                `json:"id"`
+	Status  string                `json:"status"`
+	Role    string                `json:"role"`
+	Content []ResponsesOutputText `json:"content"`
+}
+
+type ResponsesOutputText struct {
+	Type        string `json:"type"`
+	Text        string `json:"text"`
+	Annotations []any  `json:"annotations"`
+}
+
+// HandleResponses serves /v1/responses. The request is translated to the
+// internal chat form so it takes exactly the same sanitize, score, route and
+// abstract path as /v1/chat/completions.
+func (h *Handler) HandleResponses(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	requestID := newRequestID()
+	w.Header().Set("x-lpg-request-id", requestID)
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		h.writeError(w, http.StatusMethodNotAllowed, "ERR_METHOD_NOT_ALLOWED", "method not allowed", requestID)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "ERR_VALIDATION", "invalid JSON payload", requestID)
+		return
+	}
+	field, err := unsupportedField(body, supportedResponsesFields)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "ERR_VALIDATION", "invalid JSON payload", requestID)
+		return
+	}
+	if field != "" {
+		h.writeError(w, http.StatusBadRequest, "ERR_UNSUPPORTED_FIELD", fmt.Sprintf("unsupported field %q", field), requestID)
+		return
+	}
+	var responsesReq ResponsesRequest
+	if err := json.Unmarshal(body, &responsesReq); err != nil {
+		h.writeError(w, http.StatusBadRequest, "ERR_VALIDATION", "invalid JSON payload", requestID)
+		return
+	}
+	chatReq, err := responsesReq.chatRequest()
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "ERR_VALIDATION", err.Error(), requestID)
+		return
+	}
+
+	req, sanitized, _, decision, err := h.analyze(w, requestID, chatReq, true)
+	if err != nil {
+		return
+	}
+	setDecisionHeaders(w, decision, len(sanitized.Mappings))
+
+	trail := h.newAuditTrail(start, fmt.Sprintf("route=%s category=%s", decision.Route, decision.Category)+entityPolicySummary(decision.Policies)+" api=responses", sanitized.Mappings)
+	idempotencyKey := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	result, ok := h.completeOnce(w, r, requestID, req, h.fingerprint("responses", req), sanitized, decision, trail, idempotencyKey, true)
+	if !ok {
+		return
+	}
+	h.writeResponsesSuccess(w, requestID, req.Model, result.choices[0].Content, result.usage)
+}
+
+// chatRequest translates the request to the internal chat form:
+// instructions become a leading system message, developer messages become
+// system messages and text parts are joined with newlines.
+func (r ResponsesRequest) chatRequest() (ChatCompletionRequest, error) {
+	req := ChatCompletionRequest{
+		Model: r.Model,
+		GenerationParams: GenerationParams{
+			Temperature: r.Temperature,
+			MaxTokens:   r.MaxOutputTokens,
+			TopP:        r.TopP,
+			User:        r.User,
+		},
+	}
+	if strings.TrimSpace(r.Instructions) != "" {
+		req.Messages = append(req.Messages, ChatMessage{Role: roleSystem, Content: r.Instructions})
+	}
+	if len(r.Input) == 0 {
+		return ChatCompletionRequest{}, errors.New("input is required")
+	}
+	for i, item := range r.Input {
+		if item.Type != "" && item.Type != "message" {
+			return ChatCompletionRequest{}, fmt.Errorf("input[%d].type %q is not supported: must be \"message\"", i, item.Type)
+		}
+		role := item.Role
+		switch role {
+		case "user", roleAssistant, roleSystem:
+		case "developer":
+			role = roleSystem
+		default:
+			return ChatCompletionRequest{}, fmt.Errorf("input[%d].role %q is not supported: must be \"user\", \"assistant\", \"system\" or \"developer\"", i, item.Role)
+		}
+		texts := make([]string, 0, len(item.Content))
+		for j, part := range item.Content {
+			switch part.Type {
+			case "input_text", "output_text":
+				texts = append(texts, part.Text)
+			default:
+				return ChatCompletionRequest{}, fmt.Errorf("input[%d].content[%d].type %q is not supported: must be \"input_text\" or \"output_text\"", i, j, part.Type)
+			}
+		}
+		content := strings.Join(texts, "\n")
+		if strings.TrimSpace(content) == "" {
+			return ChatCompletionRequest{}, fmt.Errorf("input[%d].content is required", i)
+		}
+		req.Messages = append(req.Messages, ChatMessage{Role: role, Content: content})
+	}
+	return req, nil
+}
+
+func (h *Handler) writeResponsesSuccess(w http.ResponseWriter, requestID, model, content string, usage Usage) {
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(ResponsesResponse{
+		ID:        "resp_" + requestID,
+		Object:    "response",
+		CreatedAt: time.Now().Unix(),
+		Status:    "completed",
+		Model:     model,
+		Output: []ResponsesOutputItem{{
+			Type:   "message",
+			ID:     "msg_" + requestID,
+			Status: "completed",
+			Role:   roleAssistant,
+			Content: []ResponsesOutputText{{
+				Type:        "output_text",
+				Text:        content,
+				Annotations: []any{},
+			}},
+		}},
+		Usage: ResponsesUsage{
+			InputTokens:  usage.PromptTokens,
+			OutputTokens: usage.CompletionTokens,
+			TotalTokens:  usage.TotalTokens,
+		},
+	})
+}
@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestResponsesRequestTranslatesToChat(t *testing.T) {
+	var req ResponsesRequest
+	body := `{
+		"model":"gpt-test",
+		"instructions":"Answer tersely",
+		"input":[
+			{"role":"developer","content":"No markdown"},
+			{"type":"message","role":"user","content":[{"type":"input_text","text":"first"},{"type":"input_text","text":"second"}]},
+			{"role":"assistant","content":[{"type":"output_text","text":"ok"}]}
+		],
+		"max_output_tokens":32
+	}`
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	chat, err := req.chatRequest()
+	if err != nil {
+		t.Fatalf("chatRequest: %v", err)
+	}
+	want := []ChatMessage{
+		{Role: "system", Content: "Answer tersely"},
+		{Role: "system", Content: "No markdown"},
+		{Role: "user", Content: "first\nsecond"},
+		{Role: "assistant", Content: "ok"},
+	}
+	if len(chat.Messages) != len(want) {
+		t.Fatalf("expected %d messages, got %+v", len(want), chat.Messages)
+	}
+	for i := range want {
+		if chat.Messages[i].Role != want[i].Role || chat.Messages[i].Content != want[i].Content {
+			t.Fatalf("message %d\nwant: %+v\n got: %+v", i, want[i], chat.Messages[i])
+		}
+	}
+	if chat.MaxTokens == nil || *chat.MaxTokens != 32 {
+		t.Fatalf("expected max_output_tokens to map to max_tokens, got %v", chat.MaxTokens)
+	}
+}
+
+func TestResponsesRequestStringInputIsUserMessage(t *testing.T) {
+	var req ResponsesRequest
+	if err := json.Unmarshal([]byte(`{"model":"m","input":"hello"}`), &req); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	chat, err := req.chatRequest()
+	if err != nil {
+		t.Fatalf("chatRequest: %v", err)
+	}
+	if len(chat.Messages) != 1 || chat.Messages[0].Role != "user" || chat.Messages[0].Content != "hello" {
+		t.Fatalf("unexpected messages %+v", chat.Messages)
+	}
+}
+
+func TestResponsesRequestRejectsUnsupportedInput(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "missing input", body: `{"model":"m"}`, want: "input is required"},
+		{name: "function call item", body: `{"model":"m","input":[{"type":"function_call_output","role":"user","content":"x"}]}`, want: `input[0].type "function_call_output" is not supported`},
+		{name: "image part", body: `{"model":"m","input":[{"role":"user","content":[{"type":"input_image"}]}]}`, want: `input[0].content[0].type "input_image" is not supported`},
+		{name: "unknown role", body: `{"model":"m","input":[{"role":"tool","content":"x"}]}`, want: `input[0].role "tool" is not supported`},
+		{name: "empty content", body: `{"model":"m","input":[{"role":"user","content":" "}]}`, want: "input[0].content is required"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var req ResponsesRequest
+			if err := json.Unmarshal([]byte(tc.body), &req); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			_, err := req.chatRequest()
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
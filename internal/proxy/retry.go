@@ -0,0 +1,161 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRetryMaxAttempts = 2
+	defaultRetryBaseDelay   = 200 * time.Millisecond
+	defaultRetryMaxDelay    = 2 * time.Second
+)
+
+// RetryPolicy controls how a provider is retried once retry is allowed for
+// a request (Low/Medium with an Idempotency-Key). Each attempt gets its own
+// ProviderTimeout; Budget bounds the whole request, failover included.
+type RetryPolicy struct {
+	// MaxAttempts counts the first call; 1 disables retries.
+	MaxAttempts int
+	// BaseDelay doubles after every failed attempt up to MaxDelay. The wait
+	// is jittered between half and all of that value.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Budget is the overall deadline for every attempt, backoff and
+	// failover hop of one request. Zero leaves only the per-attempt timeout
+	// and the client's own deadline.
+	Budget time.Duration
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaultRetryMaxAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = defaultRetryBaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = defaultRetryMaxDelay
+	}
+	if p.MaxDelay < p.BaseDelay {
+		p.MaxDelay = p.BaseDelay
+	}
+	return p
+}
+
+// delay returns the wait before retry number n (1-based). A Retry-After
+// value is honoured as a floor; false means the provider asked for a longer
+// wait than MaxDelay, so the attempt is not retried.
+func (p RetryPolicy) delay(n int, err error, jitter func() float64) (time.Duration, bool) {
+	backoff := p.BaseDelay
+	for i := 1; i < n && backoff < p.MaxDelay; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, p.MaxDelay)
+	wait := backoff/2 + time.Duration(jitter()*float64(backoff/2))
+
+	if after, ok := retryAfter(err, time.Now()); ok {
+		if after > p.MaxDelay {
+			return 0, false
+		}
+		wait = max(wait, after)
+	}
+	return wait, true
+}
+
+// retryable reports whether err is worth another attempt against the same
+// provider: timeouts, 429 and the gateway-style 502-504 responses. Other
+// failures would fail the same way again.
+func retryable(err error) bool {
+	if isTimeout(err) {
+		return true
+	}
+	var statusErr *ProviderHTTPStatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	switch statusErr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter reads the Retry-After header captured on a provider status
+// error, in either delay-seconds or HTTP-date form.
+func retryAfter(err error, now time.Time) (time.Duration, bool) {
+	var statusErr *ProviderHTTPStatusError
+	if !errors.As(err, &statusErr) {
+		return 0, false
+	}
+	value := strings.TrimSpace(statusErr.ResponseHeaders["retry-after"])
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// attempt runs call against one provider, retrying retryable failures while
+// retry is set, canRetry still holds and the wait fits in ctx's deadline.
+// Each call gets its own providerTimeout. It returns the number of attempts
+// made.
+func (h *Handler) attempt(ctx context.Context, retry bool, canRetry func() bool, call func(context.Context) error) (int, error) {
+	return h.attempts(ctx, retry, canRetry, func(ctx context.Context) error {
+		attemptCtx, cancel := context.WithTimeout(ctx, h.providerTimeout)
+		defer cancel()
+		return hopError(attemptCtx, call(attemptCtx))
+	})
+}
+
+// attempts is the retry loop behind attempt; run bounds each attempt
+// itself.
+func (h *Handler) attempts(ctx context.Context, retry bool, canRetry func() bool, run func(context.Context) error) (int, error) {
+	attempts := 1
+	if retry {
+		attempts = h.retry.MaxAttempts
+	}
+	for n := 1; ; n++ {
+		err := run(ctx)
+		if err == nil || n >= attempts || ctx.Err() != nil || !canRetry() || !retryable(err) {
+			return n, err
+		}
+
+		wait, ok := h.retry.delay(n, err, h.jitter)
+		if !ok {
+			return n, err
+		}
+		if deadline, has := ctx.Deadline(); has && time.Until(deadline) <= wait {
+			return n, err
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return n, err
+		case <-timer.C:
+		}
+	}
+}
+
+// withBudget applies the overall retry budget to a forward call.
+func (h *Handler) withBudget(ctx context.Context) (context.Context, context.CancelFunc) {
+	if h.retry.Budget <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, h.retry.Budget)
+}
+
+func defaultJitter() float64 {
+	return rand.Float64()
+}
@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelayBacksOffWithJitter(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: 350 * time.Millisecond}.withDefaults()
+	err := &ProviderHTTPStatusError{StatusCode: http.StatusServiceUnavailable}
+
+	for _, tc := range []struct {
+		n      int
+		jitter float64
+		want   time.Duration
+	}{
+		{n: 1, jitter: 0, want: 50 * time.Millisecond},
+		{n: 1, jitter: 1, want: 100 * time.Millisecond},
+		{n: 2, jitter: 1, want: 200 * time.Millisecond},
+		{n: 3, jitter: 0.5, want: 262500 * time.Microsecond},
+		{n: 8, jitter: 1, want: 350 * time.Millisecond},
+	} {
+		got, ok := p.delay(tc.n, err, func() float64 { return tc.jitter })
+		if !ok || got != tc.want {
+			t.Fatalf("delay(%d) with jitter %.1f = %s, %t; want %s", tc.n, tc.jitter, got, ok, tc.want)
+		}
+	}
+}
+
+func TestRetryPolicyDelayHonorsRetryAfter(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 5 * time.Second}.withDefaults()
+	noJitter := func() float64 { return 0 }
+	withRetryAfter := func(value string) error {
+		return &ProviderHTTPStatusError{StatusCode: http.StatusTooManyRequests, ResponseHeaders: map[string]string{"retry-after": value}}
+	}
+
+	if got, ok := p.delay(1, withRetryAfter("2"), noJitter); !ok || got != 2*time.Second {
+		t.Fatalf("expected delay-seconds Retry-After to set the wait, got %s, %t", got, ok)
+	}
+	date := time.Now().Add(3 * time.Second).UTC().Format(http.TimeFormat)
+	if got, ok := p.delay(1, withRetryAfter(date), noJitter); !ok || got < time.Second || got > 3*time.Second {
+		t.Fatalf("expected HTTP-date Retry-After to set the wait, got %s, %t", got, ok)
+	}
+	if _, ok := p.delay(1, withRetryAfter("60"), noJitter); ok {
+		t.Fatal("expected Retry-After beyond MaxDelay to stop retrying")
+	}
+	if got, ok := p.delay(1, withRetryAfter("soon"), noJitter); !ok || got != 5*time.Millisecond {
+		t.Fatalf("expected an unparseable Retry-After to be ignored, got %s, %t", got, ok)
+	}
+}
+
+func TestRetryableClasses(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{err: context.DeadlineExceeded, want: true},
+		{err: fmt.Errorf("wrapped: %w", context.DeadlineExceeded), want: true},
+		{err: &ProviderHTTPStatusError{StatusCode: http.StatusTooManyRequests}, want: true},
+		{err: &ProviderHTTPStatusError{StatusCode: http.StatusBadGateway}, want: true},
+		{err: &ProviderHTTPStatusError{StatusCode: http.StatusServiceUnavailable}, want: true},
+		{err: &ProviderHTTPStatusError{StatusCode: http.StatusGatewayTimeout}, want: true},
+		{err: &ProviderHTTPStatusError{StatusCode: http.StatusInternalServerError}, want: false},
+		{err: &ProviderHTTPStatusError{StatusCode: http.StatusBadRequest}, want: false},
+		{err: errors.New("parse provider response"), want: false},
+	} {
+		if got := retryable(tc.err); got != tc.want {
+			t.Fatalf("retryable(%v) = %t, want %t", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestAttemptStopsWhenStreamHasStarted(t *testing.T) {
+	h := NewHandler(HandlerConfig{Retry: RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}})
+	started := false
+	calls := 0
+
+	attempts, err := h.attempt(context.Background(), true, func() bool { return !started }, func(ctx context.Context) error {
+		calls++
+		started = true
+		return &ProviderHTTPStatusError{StatusCode: http.StatusServiceUnavailable}
+	})
+	if err == nil || attempts != 1 || calls != 1 {
+		t.Fatalf("expected a single attempt once output started, got attempts=%d calls=%d err=%v", attempts, calls, err)
+	}
+}
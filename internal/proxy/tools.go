@@ -0,0 +1,311 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	roleAssistant    = "assistant"
+	roleTool         = "tool"
+	toolTypeFunction = "function"
+)
+
+// toolNamePattern is the OpenAI function name format. Names are forwarded
+// unsanitized, so it also keeps free text out of them and out of
+// tool_choice, which must name one of them.
+var toolNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+type ToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+type ToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction carries the call arguments as the JSON-encoded string
+// the OpenAI API uses.
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolCallDelta is one streamed fragment of a tool call. Index identifies
+// the call across fragments; ID, Type and the function name arrive with the
+// first fragment, and Arguments is split across them.
+type ToolCallDelta struct {
+	Index    int              `json:"index"`
+	ID       string           `json:"id,omitempty"`
+	Type     string           `json:"type,omitempty"`
+	Function ToolCallFunction `json:"function"`
+}
+
+// toolCallBuffer joins streamed tool-call fragments. Arguments can only be
+// rehydrated once complete, so calls are held until the stream ends.
+type toolCallBuffer struct {
+	calls   []ToolCall
+	byIndex map[int]int
+}
+
+func (b *toolCallBuffer) add(deltas []ToolCallDelta) {
+	for _, d := range deltas {
+		i, ok := b.byIndex[d.Index]
+		if !ok {
+			if b.byIndex == nil {
+				b.byIndex = map[int]int{}
+			}
+			i = len(b.calls)
+			b.byIndex[d.Index] = i
+			b.calls = append(b.calls, ToolCall{Type: toolTypeFunction})
+		}
+		call := &b.calls[i]
+		if d.ID != "" {
+			call.ID = d.ID
+		}
+		if d.Type != "" {
+			call.Type = d.Type
+		}
+		call.Function.Name += d.Function.Name
+		call.Function.Arguments += d.Function.Arguments
+	}
+}
+
+// toolCallDeltas sends whole calls as one fragment each.
+func toolCallDeltas(calls []ToolCall) []ToolCallDelta {
+	if len(calls) == 0 {
+		return nil
+	}
+	deltas := make([]ToolCallDelta, 0, len(calls))
+	for i, call := range calls {
+		deltas = append(deltas, ToolCallDelta{Index: i, ID: call.ID, Type: call.Type, Function: call.Function})
+	}
+	return deltas
+}
+
+func validateMessage(i int, m ChatMessage) error {
+	if err := validateContentParts(i, m); err != nil {
+		return err
+	}
+	switch {
+	case m.Role == roleTool:
+		if strings.TrimSpace(m.ToolCallID) == "" {
+			return fmt.Errorf("messages[%d].tool_call_id is required", i)
+		}
+	case len(m.ToolCalls) > 0:
+		if m.Role != roleAssistant {
+			return fmt.Errorf("messages[%d].tool_calls is only allowed on assistant messages", i)
+		}
+		for j, call := range m.ToolCalls {
+			if strings.TrimSpace(call.ID) == "" {
+				return fmt.Errorf("messages[%d].tool_calls[%d].id is required", i, j)
+			}
+			if call.Type != toolTypeFunction {
+				return fmt.Errorf("messages[%d].tool_calls[%d].type must be %q", i, j, toolTypeFunction)
+			}
+			if strings.TrimSpace(call.Function.Name) == "" {
+				return fmt.Errorf("messages[%d].tool_calls[%d].function.name is required", i, j)
+			}
+		}
+	default:
+		if !hasContent(m) {
+			return fmt.Errorf("messages[%d].content is required", i)
+		}
+	}
+	return nil
+}
+
+func validateTools(tools []Tool, toolChoice json.RawMessage) error {
+	names := make(map[string]bool, len(tools))
+	for i, tool := range tools {
+		if tool.Type != toolTypeFunction {
+			return fmt.Errorf("tools[%d].type must be %q", i, toolTypeFunction)
+		}
+		name := strings.TrimSpace(tool.Function.Name)
+		if name == "" {
+			return fmt.Errorf("tools[%d].function.name is required", i)
+		}
+		if !toolNamePattern.MatchString(name) {
+			return fmt.Errorf("tools[%d].function.name %q must be 1-64 letters, digits, underscores or dashes", i, name)
+		}
+		if names[name] {
+			return fmt.Errorf("tools[%d].function.name %q is duplicated", i, name)
+		}
+		names[name] = true
+	}
+
+	if len(toolChoice) == 0 {
+		return nil
+	}
+	if len(tools) == 0 {
+		return errors.New("tool_choice requires tools")
+	}
+	var mode string
+	if err := json.Unmarshal(toolChoice, &mode); err == nil {
+		switch mode {
+		case "none", "auto", "required":
+			return nil
+		default:
+			return fmt.Errorf("tool_choice %q is not supported: must be \"none\", \"auto\", \"required\" or a function", mode)
+		}
+	}
+	var named struct {
+		Type     string `json:"type"`
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(toolChoice, &named); err != nil || named.Type != toolTypeFunction {
+		return errors.New("tool_choice must be a string or {\"type\":\"function\",\"function\":{\"name\":...}}")
+	}
+	if !names[named.Function.Name] {
+		return fmt.Errorf("tool_choice names unknown function %q", named.Function.Name)
+	}
+	return nil
+}
+
+// walkMessageTexts visits every sanitizable text in messages in a fixed
+// order: each message's content (or each text part of array content), then
+// the JSON string values of its tool-call arguments. fn's return value replaces the visited text in the
+// returned copy. Sanitization, abstraction and rebuilding all rely on this
+// order, which keeps tool results and arguments in the same mapping table
+// as ordinary content.
+func walkMessageTexts(messages []ChatMessage, fn func(text string, content bool) string) []ChatMessage {
+	out := make([]ChatMessage, 0, len(messages))
+	for _, m := range messages {
+		if m.Parts != nil {
+			parts := make([]ContentPart, 0, len(m.Parts))
+			for _, part := range m.Parts {
+				if part.Type == contentPartText {
+					part.Text = fn(part.Text, true)
+				}
+				parts = append(parts, part)
+			}
+			m.Parts = parts
+		} else {
+			m.Content = fn(m.Content, true)
+		}
+		if len(m.ToolCalls) > 0 {
+			calls := make([]ToolCall, 0, len(m.ToolCalls))
+			for _, call := range m.ToolCalls {
+				call.Function.Arguments = rewriteArguments(call.Function.Arguments, func(text string) string {
+					return fn(text, false)
+				})
+				calls = append(calls, call)
+			}
+			m.ToolCalls = calls
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func messageTexts(messages []ChatMessage) []string {
+	texts := make([]string, 0, len(messages))
+	walkMessageTexts(messages, func(text string, _ bool) string {
+		texts = append(texts, text)
+		return text
+	})
+	return texts
+}
+
+// withTexts rebuilds messages from texts laid out by messageTexts.
+func withTexts(messages []ChatMessage, texts []string) []ChatMessage {
+	next := 0
+	return walkMessageTexts(messages, func(string, bool) string {
+		text := texts[next]
+		next++
+		return text
+	})
+}
+
+// walkSchemaTexts visits each tool's description, then every JSON string
+// value of its parameters, then every JSON string value of
+// response_format.json_schema. Object keys are kept as they are, since they
+// name the fields the model must produce. fn's return value replaces the
+// visited text in the returned copy.
+func walkSchemaTexts(params GenerationParams, fn func(string) string) GenerationParams {
+	if len(params.Tools) > 0 {
+		tools := make([]Tool, 0, len(params.Tools))
+		for _, tool := range params.Tools {
+			tool.Function.Description = fn(tool.Function.Description)
+			tool.Function.Parameters = rewriteSchema(tool.Function.Parameters, fn)
+			tools = append(tools, tool)
+		}
+		params.Tools = tools
+	}
+	if params.ResponseFormat != nil && len(params.ResponseFormat.JSONSchema) > 0 {
+		format := *params.ResponseFormat
+		format.JSONSchema = rewriteSchema(format.JSONSchema, fn)
+		params.ResponseFormat = &format
+	}
+	return params
+}
+
+func rewriteSchema(schema json.RawMessage, fn func(string) string) json.RawMessage {
+	if len(schema) == 0 {
+		return schema
+	}
+	return json.RawMessage(rewriteArguments(string(schema), fn))
+}
+
+// rewriteArguments applies fn to every string value of a JSON arguments
+// document, visiting object keys in sorted order. Arguments that are not
+// valid JSON are treated as a single string so they are still covered.
+func rewriteArguments(arguments string, fn func(string) string) string {
+	if strings.TrimSpace(arguments) == "" {
+		return arguments
+	}
+	decoder := json.NewDecoder(strings.NewReader(arguments))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil || decoder.More() {
+		return fn(arguments)
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(rewriteJSONStrings(doc, fn)); err != nil {
+		return fn(arguments)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func rewriteJSONStrings(value any, fn func(string) string) any {
+	switch v := value.(type) {
+	case string:
+		return fn(v)
+	case []any:
+		for i := range v {
+			v[i] = rewriteJSONStrings(v[i], fn)
+		}
+		return v
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			v[key] = rewriteJSONStrings(v[key], fn)
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,170 @@
+package proxy
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidateToolsAndToolChoice(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "tool choice without tools", body: `{"tool_choice":"auto"}`, want: "tool_choice requires tools"},
+		{name: "non-function tool", body: `{"tools":[{"type":"retrieval","function":{"name":"x"}}]}`, want: `tools[0].type must be "function"`},
+		{name: "unnamed tool", body: `{"tools":[{"type":"function","function":{}}]}`, want: "tools[0].function.name is required"},
+		{name: "free-text tool name", body: `{"tools":[{"type":"function","function":{"name":"mail alice@example.com"}}]}`, want: "must be 1-64 letters, digits, underscores or dashes"},
+		{name: "duplicate tool", body: `{"tools":[{"type":"function","function":{"name":"x"}},{"type":"function","function":{"name":"x"}}]}`, want: `tools[1].function.name "x" is duplicated`},
+		{name: "unknown mode", body: `{"tools":[{"type":"function","function":{"name":"x"}}],"tool_choice":"always"}`, want: `tool_choice "always" is not supported`},
+		{name: "unknown function", body: `{"tools":[{"type":"function","function":{"name":"x"}}],"tool_choice":{"type":"function","function":{"name":"y"}}}`, want: `tool_choice names unknown function "y"`},
+		{name: "named function", body: `{"tools":[{"type":"function","function":{"name":"x"}}],"tool_choice":{"type":"function","function":{"name":"x"}}}`},
+		{name: "required", body: `{"tools":[{"type":"function","function":{"name":"x"}}],"tool_choice":"required"}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var params GenerationParams
+			if err := json.Unmarshal([]byte(tc.body), &params); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			err := params.validate()
+			if tc.want == "" {
+				if err != nil {
+					t.Fatalf("expected valid params, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestValidateMessageToolRoles(t *testing.T) {
+	call := ToolCall{ID: "call_1", Type: toolTypeFunction, Function: ToolCallFunction{Name: "lookup", Arguments: "{}"}}
+	tests := []struct {
+		name    string
+		message ChatMessage
+		want    string
+	}{
+		{name: "assistant tool call without content", message: ChatMessage{Role: roleAssistant, ToolCalls: []ToolCall{call}}},
+		{name: "tool result", message: ChatMessage{Role: roleTool, ToolCallID: "call_1", Content: "ok"}},
+		{name: "tool result without id", message: ChatMessage{Role: roleTool, Content: "ok"}, want: "messages[0].tool_call_id is required"},
+		{name: "user tool calls", message: ChatMessage{Role: "user", ToolCalls: []ToolCall{call}}, want: "messages[0].tool_calls is only allowed on assistant messages"},
+		{name: "tool call without id", message: ChatMessage{Role: roleAssistant, ToolCalls: []ToolCall{{Type: toolTypeFunction, Function: ToolCallFunction{Name: "lookup"}}}}, want: "messages[0].tool_calls[0].id is required"},
+		{name: "empty content", message: ChatMessage{Role: "user"}, want: "messages[0].content is required"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateMessage(0, tc.message)
+			if tc.want == "" {
+				if err != nil {
+					t.Fatalf("expected valid message, got %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.want {
+				t.Fatalf("expected %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestMessageTextsRoundTripIncludesToolArguments(t *testing.T) {
+	messages := []ChatMessage{
+		{Role: "user", Content: "email bob"},
+		{Role: roleAssistant, ToolCalls: []ToolCall{{
+			ID:       "call_1",
+			Type:     toolTypeFunction,
+			Function: ToolCallFunction{Name: "send", Arguments: `{"to":"bob@example.com","cc":["a","b"],"priority":3}`},
+		}}},
+		{Role: roleTool, ToolCallID: "call_1", Content: "sent"},
+	}
+
+	texts := messageTexts(messages)
+	want := []string{"email bob", "", "a", "b", "bob@example.com", "sent"}
+	if strings.Join(texts, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected texts %q", texts)
+	}
+
+	upper := make([]string, len(texts))
+	for i, text := range texts {
+		upper[i] = strings.ToUpper(text)
+	}
+	rebuilt := withTexts(messages, upper)
+	if rebuilt[0].Content != "EMAIL BOB" || rebuilt[2].Content != "SENT" {
+		t.Fatalf("unexpected rebuilt content %+v", rebuilt)
+	}
+	if got := rebuilt[1].ToolCalls[0].Function.Arguments; got != `{"cc":["A","B"],"priority":3,"to":"BOB@EXAMPLE.COM"}` {
+		t.Fatalf("unexpected rebuilt arguments %s", got)
+	}
+	if messages[1].ToolCalls[0].Function.Arguments != `{"to":"bob@example.com","cc":["a","b"],"priority":3}` {
+		t.Fatal("expected original messages to be left untouched")
+	}
+}
+
+func TestParamTextsRoundTripKeepsSchemaKeys(t *testing.T) {
+	params := GenerationParams{
+		Tools: []Tool{{Type: toolTypeFunction, Function: ToolFunction{
+			Name:        "send",
+			Description: "mail bob",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"to":{"description":"e.g. bob@example.com"}}}`),
+		}}},
+		ResponseFormat: &ResponseFormat{Type: "json_schema", JSONSchema: json.RawMessage(`{"name":"reply","strict":true}`)},
+	}
+
+	texts := paramTexts(params)
+	want := []string{"mail bob", "e.g. bob@example.com", "object", "reply"}
+	if strings.Join(texts, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected texts %q", texts)
+	}
+
+	upper := make([]string, len(texts))
+	for i, text := range texts {
+		upper[i] = strings.ToUpper(text)
+	}
+	rebuilt := withParamTexts(params, upper)
+	if got := rebuilt.Tools[0].Function.Description; got != "MAIL BOB" {
+		t.Fatalf("unexpected rebuilt description %q", got)
+	}
+	if got := string(rebuilt.Tools[0].Function.Parameters); got != `{"properties":{"to":{"description":"E.G. BOB@EXAMPLE.COM"}},"type":"OBJECT"}` {
+		t.Fatalf("unexpected rebuilt parameters %s", got)
+	}
+	if got := string(rebuilt.ResponseFormat.JSONSchema); got != `{"name":"REPLY","strict":true}` {
+		t.Fatalf("unexpected rebuilt schema %s", got)
+	}
+	if params.Tools[0].Function.Description != "mail bob" || string(params.ResponseFormat.JSONSchema) != `{"name":"reply","strict":true}` {
+		t.Fatal("expected original params to be left untouched")
+	}
+}
+
+func TestToolCallBufferJoinsFragmentsByIndex(t *testing.T) {
+	var b toolCallBuffer
+	b.add([]ToolCallDelta{{Index: 1, ID: "call_b", Type: toolTypeFunction, Function: ToolCallFunction{Name: "log", Arguments: `{"n`}}})
+	b.add([]ToolCallDelta{{Index: 0, ID: "call_a", Function: ToolCallFunction{Name: "send", Arguments: `{}`}}})
+	b.add([]ToolCallDelta{{Index: 1, Function: ToolCallFunction{Arguments: `":1}`}}})
+
+	if len(b.calls) != 2 {
+		t.Fatalf("expected two calls, got %+v", b.calls)
+	}
+	if b.calls[0].ID != "call_b" || b.calls[0].Function.Arguments != `{"n":1}` {
+		t.Fatalf("unexpected first call %+v", b.calls[0])
+	}
+	if b.calls[1].ID != "call_a" || b.calls[1].Type != toolTypeFunction || b.calls[1].Function.Name != "send" {
+		t.Fatalf("unexpected second call %+v", b.calls[1])
+	}
+}
+
+func TestRewriteArgumentsTreatsInvalidJSONAsText(t *testing.T) {
+	got := rewriteArguments("not json <b>", strings.ToUpper)
+	if got != "NOT JSON <B>" {
+		t.Fatalf("unexpected rewrite %q", got)
+	}
+	if got := rewriteArguments(`{"html":"<b>"}`, func(s string) string { return s }); got != `{"html":"<b>"}` {
+		t.Fatalf("expected HTML to stay unescaped, got %s", got)
+	}
+}
@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type providerEmbeddingsRequest struct {
+	Model          string   `json:"model"`
+	Input          []string `json:"input"`
+	EncodingFormat string   `json:"encoding_format"`
+	Dimensions     *int     `json:"dimensions,omitempty"`
+	User           string   `json:"user,omitempty"`
+}
+
+type providerEmbeddingsResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+}
+
+func (c *providerHTTPClient) embeddings(ctx context.Context, model string, req EmbedRequest) (EmbedResponse, error) {
+	if len(req.Input) == 0 {
+		return EmbedResponse{}, fmt.Errorf("at least one input is required")
+	}
+
+	body, err := json.Marshal(providerEmbeddingsRequest{
+		Model:          model,
+		Input:          req.Input,
+		EncodingFormat: "float",
+		Dimensions:     req.Dimensions,
+		User:           req.User,
+	})
+	if err != nil {
+		return EmbedResponse{}, fmt.Errorf("marshal provider request: %w", err)
+	}
+
+	httpResp, err := c.post(ctx, c.embeddingsPath, body, false, req.IdempotencyKey)
+	if err != nil {
+		return EmbedResponse{}, err
+	}
+	defer func() {
+		_ = httpResp.Body.Close()
+	}()
+
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return EmbedResponse{}, fmt.Errorf("read provider response: %w", err)
+	}
+
+	var parsed providerEmbeddingsResponse
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		return EmbedResponse{}, fmt.Errorf("parse provider response: %w", err)
+	}
+	if len(parsed.Data) != len(req.Input) {
+		return EmbedResponse{}, fmt.Errorf("provider returned %d embeddings for %d inputs", len(parsed.Data), len(req.Input))
+	}
+
+	// Providers may return data out of order; index is authoritative.
+	embeddings := make([][]float64, len(req.Input))
+	for _, item := range parsed.Data {
+		if item.Index < 0 || item.Index >= len(embeddings) || embeddings[item.Index] != nil {
+			return EmbedResponse{}, fmt.Errorf("provider response has invalid embedding index %d", item.Index)
+		}
+		if len(item.Embedding) == 0 {
+			return EmbedResponse{}, fmt.Errorf("provider response missing embedding %d", item.Index)
+		}
+		embeddings[item.Index] = item.Embedding
+	}
+	return EmbedResponse{Embeddings: embeddings}, nil
+}
+
+// OpenAICompatibleEmbeddings always embeds with its configured model, for
+// local embedding servers whose model names differ from what clients ask for.
+type OpenAICompatibleEmbeddings struct {
+	client *providerHTTPClient
+	model  string
+}
+
+func NewOpenAICompatibleEmbeddings(cfg OpenAICompatibleConfig) (*OpenAICompatibleEmbeddings, error) {
+	model := strings.TrimSpace(cfg.Model)
+	if model == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+
+	client, err := newProviderHTTPClientWithConfig(providerHTTPConfig{
+		BaseURL:        cfg.BaseURL,
+		APIKey:         cfg.APIKey,
+		APIKeyHeader:   cfg.APIKeyHeader,
+		APIKeyPrefix:   cfg.APIKeyPrefix,
+		EmbeddingsPath: cfg.EmbeddingsPath,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &OpenAICompatibleEmbeddings{
+		client: client,
+		model:  model,
+	}, nil
+}
+
+func (e *OpenAICompatibleEmbeddings) Embeddings(ctx context.Context, req EmbedRequest) (EmbedResponse, error) {
+	return e.client.embeddings(ctx, e.model, req)
+}
@@ -1,6 +1,7 @@
 package proxy
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -11,9 +12,17 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/soloengine/lpg/internal/sanitizer"
 )
 
-const defaultProviderChatCompletionsPath = "/v1/chat/completions"
+const (
+	defaultProviderChatCompletionsPath = "/v1/chat/completions"
+	defaultProviderEmbeddingsPath      = "/v1/embeddings"
+	defaultProviderModelsPath          = "/v1/models"
+	maxProviderStreamLineBytes         = 1 << 20
+	maxProviderErrorBodyBytes          = 64 << 10
+)
 
 type providerHTTPClient struct {
 	baseURL      string
@@ -21,7 +30,11 @@ type providerHTTPClient struct {
 	apiKeyHeader string
 	apiKeyPrefix string
 	chatPath     string
-	client       *http.Client
+	// responsesPath is empty unless the provider accepts the Responses API.
+	responsesPath  string
+	embeddingsPath string
+	modelsPath     string
+	client         *http.Client
 }
 
 type ProviderHTTPStatusError struct {
@@ -30,7 +43,7 @@ type ProviderHTTPStatusError struct {
 	ResponseHeaders map[string]string
 }
 
-var redactionPatterns = []*regexp.Regexp{
+var redactionPatterns = append([]*regexp.Regexp{
 	regexp.MustCompile(`(?i)"api[-_]?key"\s*:\s*"[^"]+"`),
 	regexp.MustCompile(`(?i)"access[-_]?token"\s*:\s*"[^"]+"`),
 	regexp.MustCompile(`(?i)"token"\s*:\s*"[^"]+"`),
@@ -39,7 +52,7 @@ var redactionPatterns = []*regexp.Regexp{
 	regexp.MustCompile(`(?i)[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,}`),
 	regexp.MustCompile(`\b\d{3}[-.\s]?\d{3}[-.\s]?\d{4}\b`),
 	regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
-}
+}, sanitizer.SecretPatterns()...)
 
 func (e *ProviderHTTPStatusError) Error() string {
 	if e == nil {
@@ -57,24 +70,67 @@ type providerHTTPConfig struct {
 	APIKeyHeader string
 	APIKeyPrefix string
 	ChatPath     string
+	// ResponsesPath enables native /v1/responses forwarding when set.
+	ResponsesPath  string
+	EmbeddingsPath string
+	ModelsPath     string
 }
 
 type providerChatRequest struct {
 	Model    string                `json:"model"`
 	Messages []providerChatMessage `json:"messages"`
+	Stream   bool                  `json:"stream,omitempty"`
+	GenerationParams
 }
 
 type providerChatMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string        `json:"role"`
+	Content    string        `json:"content"`
+	Parts      []ContentPart `json:"-"`
+	Name       string        `json:"name,omitempty"`
+	ToolCalls  []ToolCall    `json:"tool_calls,omitempty"`
+	ToolCallID string        `json:"tool_call_id,omitempty"`
+}
+
+// MarshalJSON sends Parts as array content when set. Responses are decoded
+// with the default rules, since providers answer with string content.
+func (m providerChatMessage) MarshalJSON() ([]byte, error) {
+	type plain providerChatMessage
+	out := struct {
+		plain
+		Content any `json:"content"`
+	}{plain: plain(m), Content: m.Content}
+	if m.Parts != nil {
+		out.Content = m.Parts
+	}
+	return json.Marshal(out)
 }
 
 type providerChatResponse struct {
 	Choices []providerChatChoice `json:"choices"`
+	Usage   Usage                `json:"usage"`
 }
 
 type providerChatChoice struct {
-	Message providerChatMessage `json:"message"`
+	Message      providerChatMessage `json:"message"`
+	FinishReason string              `json:"finish_reason"`
+}
+
+type providerStreamChunk struct {
+	Choices []providerStreamChoice `json:"choices"`
+	Error   *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+type providerStreamChoice struct {
+	Delta        providerStreamDelta `json:"delta"`
+	FinishReason *string             `json:"finish_reason"`
+}
+
+type providerStreamDelta struct {
+	Content   string          `json:"content"`
+	ToolCalls []ToolCallDelta `json:"tool_calls,omitempty"`
 }
 
 func newProviderHTTPClient(baseURL, apiKey string) (*providerHTTPClient, error) {
@@ -108,35 +164,192 @@ func newProviderHTTPClientWithConfig(cfg providerHTTPConfig) (*providerHTTPClien
 
 	apiKeyPrefix := strings.TrimSpace(cfg.APIKeyPrefix)
 
+	responsesPath := strings.TrimSpace(cfg.ResponsesPath)
+	if responsesPath != "" && !strings.HasPrefix(responsesPath, "/") {
+		responsesPath = "/" + responsesPath
+	}
+
+	embeddingsPath := strings.TrimSpace(cfg.EmbeddingsPath)
+	if embeddingsPath == "" {
+		embeddingsPath = defaultProviderEmbeddingsPath
+	}
+	if !strings.HasPrefix(embeddingsPath, "/") {
+		embeddingsPath = "/" + embeddingsPath
+	}
+
+	modelsPath := strings.TrimSpace(cfg.ModelsPath)
+	if modelsPath == "" {
+		modelsPath = defaultProviderModelsPath
+	}
+	if !strings.HasPrefix(modelsPath, "/") {
+		modelsPath = "/" + modelsPath
+	}
+
 	return &providerHTTPClient{
-		baseURL:      base,
-		apiKey:       strings.TrimSpace(cfg.APIKey),
-		apiKeyHeader: apiKeyHeader,
-		apiKeyPrefix: apiKeyPrefix,
-		chatPath:     chatPath,
-		client:       &http.Client{},
+		baseURL:        base,
+		apiKey:         strings.TrimSpace(cfg.APIKey),
+		apiKeyHeader:   apiKeyHeader,
+		apiKeyPrefix:   apiKeyPrefix,
+		chatPath:       chatPath,
+		responsesPath:  responsesPath,
+		embeddingsPath: embeddingsPath,
+		modelsPath:     modelsPath,
+		client:         &http.Client{},
 	}, nil
 }
 
-func (c *providerHTTPClient) chatCompletions(ctx context.Context, model, prompt, idempotencyKey string) (ForwardResponse, error) {
+func (c *providerHTTPClient) chatCompletions(ctx context.Context, model string, messages []ChatMessage, params GenerationParams, idempotencyKey string) (ForwardResponse, error) {
+	httpResp, err := c.postChat(ctx, model, messages, params, false, idempotencyKey)
+	if err != nil {
+		return ForwardResponse{}, err
+	}
+	defer func() {
+		_ = httpResp.Body.Close()
+	}()
+
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return ForwardResponse{}, fmt.Errorf("read provider response: %w", err)
+	}
+
+	var parsed providerChatResponse
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		return ForwardResponse{}, fmt.Errorf("parse provider response: %w", err)
+	}
+	if len(parsed.Choices) == 0 {
+		return ForwardResponse{}, fmt.Errorf("provider response missing choices")
+	}
+	choices := make([]ForwardChoice, 0, len(parsed.Choices))
+	toolCalls := false
+	for _, choice := range parsed.Choices {
+		if strings.TrimSpace(choice.Message.Content) == "" && len(choice.Message.ToolCalls) == 0 {
+			return ForwardResponse{}, fmt.Errorf("provider response missing choice content")
+		}
+		toolCalls = toolCalls || len(choice.Message.ToolCalls) > 0
+		choices = append(choices, ForwardChoice{
+			Content:      choice.Message.Content,
+			ToolCalls:    choice.Message.ToolCalls,
+			FinishReason: choice.FinishReason,
+		})
+	}
+
+	resp := ForwardResponse{Content: choices[0].Content, Usage: parsed.Usage.withTotal()}
+	if len(choices) > 1 || toolCalls {
+		resp.Choices = choices
+	}
+	return resp, nil
+}
+
+func (c *providerHTTPClient) chatCompletionsStream(ctx context.Context, model string, messages []ChatMessage, params GenerationParams, idempotencyKey string, onChunk func(StreamChunk) error) error {
+	httpResp, err := c.postChat(ctx, model, messages, params, true, idempotencyKey)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = httpResp.Body.Close()
+	}()
+
+	scanner := bufio.NewScanner(httpResp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxProviderStreamLineBytes)
+	finished := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			return nil
+		}
+
+		var parsed providerStreamChunk
+		if err := json.Unmarshal([]byte(data), &parsed); err != nil {
+			return fmt.Errorf("parse provider stream chunk: %w", err)
+		}
+		if parsed.Error != nil {
+			return fmt.Errorf("provider stream error: %s", safeBodySnippet([]byte(parsed.Error.Message)))
+		}
+		if len(parsed.Choices) == 0 {
+			continue
+		}
+
+		choice := parsed.Choices[0]
+		chunk := StreamChunk{Content: choice.Delta.Content, ToolCalls: choice.Delta.ToolCalls}
+		if choice.FinishReason != nil {
+			chunk.FinishReason = *choice.FinishReason
+			finished = true
+		}
+		if chunk.Content == "" && len(chunk.ToolCalls) == 0 && chunk.FinishReason == "" {
+			continue
+		}
+		if err := onChunk(chunk); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read provider stream: %w", err)
+	}
+	if !finished {
+		return fmt.Errorf("provider stream ended before completion")
+	}
+	return nil
+}
+
+func (c *providerHTTPClient) postChat(ctx context.Context, model string, messages []ChatMessage, params GenerationParams, stream bool, idempotencyKey string) (*http.Response, error) {
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("at least one message is required")
+	}
+
+	providerMessages := make([]providerChatMessage, 0, len(messages))
+	for _, m := range messages {
+		providerMessages = append(providerMessages, providerChatMessage{
+			Role:       m.Role,
+			Content:    m.Content,
+			Parts:      m.Parts,
+			Name:       m.Name,
+			ToolCalls:  m.ToolCalls,
+			ToolCallID: m.ToolCallID,
+		})
+	}
+
 	body, err := json.Marshal(providerChatRequest{
-		Model: model,
-		Messages: []providerChatMessage{
-			{Role: "user", Content: prompt},
-		},
+		Model:            model,
+		Messages:         providerMessages,
+		Stream:           stream,
+		GenerationParams: params,
 	})
 	if err != nil {
-		return ForwardResponse{}, fmt.Errorf("marshal provider request: %w", err)
+		return nil, fmt.Errorf("marshal provider request: %w", err)
 	}
+	return c.post(ctx, c.chatPath, body, stream, idempotencyKey)
+}
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+c.chatPath, bytes.NewReader(body))
+func (c *providerHTTPClient) post(ctx context.Context, path string, body []byte, stream bool, idempotencyKey string) (*http.Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
 	if err != nil {
-		return ForwardResponse{}, fmt.Errorf("create provider request: %w", err)
+		return nil, fmt.Errorf("create provider request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
 	if idempotencyKey != "" {
 		httpReq.Header.Set("Idempotency-Key", idempotencyKey)
 	}
+	return c.send(httpReq)
+}
+
+func (c *providerHTTPClient) get(ctx context.Context, path string) (*http.Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create provider request: %w", err)
+	}
+	return c.send(httpReq)
+}
+
+// send authenticates httpReq and turns non-2xx responses into
+// ProviderHTTPStatusError.
+func (c *providerHTTPClient) send(httpReq *http.Request) (*http.Response, error) {
 	if c.apiKey != "" {
 		authValue := c.apiKey
 		if c.apiKeyPrefix != "" {
@@ -147,36 +360,23 @@ func (c *providerHTTPClient) chatCompletions(ctx context.Context, model, prompt,
 
 	httpResp, err := c.client.Do(httpReq)
 	if err != nil {
-		return ForwardResponse{}, fmt.Errorf("send provider request: %w", err)
-	}
-	defer func() {
-		_ = httpResp.Body.Close()
-	}()
-
-	respBody, err := io.ReadAll(httpResp.Body)
-	if err != nil {
-		return ForwardResponse{}, fmt.Errorf("read provider response: %w", err)
+		return nil, fmt.Errorf("send provider request: %w", err)
 	}
 	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
-		return ForwardResponse{}, &ProviderHTTPStatusError{
+		defer func() {
+			_ = httpResp.Body.Close()
+		}()
+		respBody, err := io.ReadAll(io.LimitReader(httpResp.Body, maxProviderErrorBodyBytes))
+		if err != nil {
+			return nil, fmt.Errorf("read provider response: %w", err)
+		}
+		return nil, &ProviderHTTPStatusError{
 			StatusCode:      httpResp.StatusCode,
 			BodySnippet:     safeBodySnippet(respBody),
 			ResponseHeaders: sanitizeResponseHeaders(httpResp.Header),
 		}
 	}
-
-	var parsed providerChatResponse
-	if err := json.Unmarshal(respBody, &parsed); err != nil {
-		return ForwardResponse{}, fmt.Errorf("parse provider response: %w", err)
-	}
-	if len(parsed.Choices) == 0 {
-		return ForwardResponse{}, fmt.Errorf("provider response missing choices")
-	}
-	if strings.TrimSpace(parsed.Choices[0].Message.Content) == "" {
-		return ForwardResponse{}, fmt.Errorf("provider response missing choice content")
-	}
-
-	return ForwardResponse{Content: parsed.Choices[0].Message.Content}, nil
+	return httpResp, nil
 }
 
 func safeBodySnippet(body []byte) string {
@@ -31,12 +31,38 @@ func NewMimoUpstream(baseURL, apiKey, defaultModel string) (*MimoUpstream, error
 }
 
 func (u *MimoUpstream) ChatCompletions(ctx context.Context, req ForwardRequest) (ForwardResponse, error) {
+	model, err := u.model(req)
+	if err != nil {
+		return ForwardResponse{}, err
+	}
+	return u.client.chatCompletions(ctx, model, req.Messages, req.Params, req.IdempotencyKey)
+}
+
+func (u *MimoUpstream) ChatCompletionsStream(ctx context.Context, req ForwardRequest, onChunk func(StreamChunk) error) error {
+	model, err := u.model(req)
+	if err != nil {
+		return err
+	}
+	return u.client.chatCompletionsStream(ctx, model, req.Messages, req.Params, req.IdempotencyKey, onChunk)
+}
+
+func (u *MimoUpstream) model(req ForwardRequest) (string, error) {
 	model := strings.TrimSpace(u.defaultModel)
 	if model == "" {
 		model = strings.TrimSpace(req.Model)
 	}
 	if model == "" {
-		return ForwardResponse{}, fmt.Errorf("model is required")
+		return "", fmt.Errorf("model is required")
 	}
-	return u.client.chatCompletions(ctx, model, req.SanitizedPrompt, req.IdempotencyKey)
+	return model, nil
+}
+
+// ListModels reports the configured model, since it replaces whatever model
+// a request names.
+func (u *MimoUpstream) ListModels(ctx context.Context) ([]string, error) {
+	return configuredModel(u.defaultModel), nil
+}
+
+func (u *MimoUpstream) Probe(ctx context.Context) error {
+	return u.client.probe(ctx)
 }
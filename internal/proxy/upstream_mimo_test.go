@@ -40,8 +40,8 @@ func TestMimoUpstreamAddsAuthorizationHeaderAndUsesFallbackModel(t *testing.T) {
 	}
 
 	resp, err := upstream.ChatCompletions(context.Background(), ForwardRequest{
-		SanitizedPrompt: "hello mimo",
-		IdempotencyKey:  "idem-mimo",
+		Messages:       []ChatMessage{{Role: "user", Content: "hello mimo"}},
+		IdempotencyKey: "idem-mimo",
 	})
 	if err != nil {
 		t.Fatalf("ChatCompletions failed: %v", err)
@@ -83,7 +83,7 @@ func TestMimoUpstreamUsesConfiguredModelEvenWhenRequestModelProvided(t *testing.
 		t.Fatalf("NewMimoUpstream failed: %v", err)
 	}
 
-	_, err = upstream.ChatCompletions(context.Background(), ForwardRequest{Model: "qwen2.5:3b", SanitizedPrompt: "hi"})
+	_, err = upstream.ChatCompletions(context.Background(), ForwardRequest{Model: "qwen2.5:3b", Messages: []ChatMessage{{Role: "user", Content: "hi"}}})
 	if err != nil {
 		t.Fatalf("ChatCompletions failed: %v", err)
 	}
@@ -111,7 +111,7 @@ func TestMimoUpstreamUsesRequestModelWhenConfiguredModelMissing(t *testing.T) {
 		t.Fatalf("NewMimoUpstream failed: %v", err)
 	}
 
-	_, err = upstream.ChatCompletions(context.Background(), ForwardRequest{Model: "request-model", SanitizedPrompt: "hi"})
+	_, err = upstream.ChatCompletions(context.Background(), ForwardRequest{Model: "request-model", Messages: []ChatMessage{{Role: "user", Content: "hi"}}})
 	if err != nil {
 		t.Fatalf("ChatCompletions failed: %v", err)
 	}
@@ -132,7 +132,7 @@ func TestMimoUpstreamReturnsErrorOnProviderFailures(t *testing.T) {
 			t.Fatalf("NewMimoUpstream failed: %v", err)
 		}
 
-		if _, err := upstream.ChatCompletions(context.Background(), ForwardRequest{SanitizedPrompt: "hi"}); err == nil {
+		if _, err := upstream.ChatCompletions(context.Background(), ForwardRequest{Messages: []ChatMessage{{Role: "user", Content: "hi"}}}); err == nil {
 			t.Fatal("expected error on non-2xx")
 		}
 	})
@@ -148,7 +148,7 @@ func TestMimoUpstreamReturnsErrorOnProviderFailures(t *testing.T) {
 			t.Fatalf("NewMimoUpstream failed: %v", err)
 		}
 
-		if _, err := upstream.ChatCompletions(context.Background(), ForwardRequest{SanitizedPrompt: "hi"}); err == nil {
+		if _, err := upstream.ChatCompletions(context.Background(), ForwardRequest{Messages: []ChatMessage{{Role: "user", Content: "hi"}}}); err == nil {
 			t.Fatal("expected error on invalid JSON")
 		}
 	})
@@ -169,7 +169,7 @@ func TestMimoUpstreamReturnsErrorOnContextTimeout(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
 	defer cancel()
 
-	if _, err := upstream.ChatCompletions(ctx, ForwardRequest{SanitizedPrompt: "hi"}); err == nil {
+	if _, err := upstream.ChatCompletions(ctx, ForwardRequest{Messages: []ChatMessage{{Role: "user", Content: "hi"}}}); err == nil {
 		t.Fatal("expected timeout error")
 	}
 }
@@ -185,7 +185,7 @@ func TestMimoUpstreamReturnsErrorWhenModelMissing(t *testing.T) {
 		t.Fatalf("NewMimoUpstream failed: %v", err)
 	}
 
-	if _, err := upstream.ChatCompletions(context.Background(), ForwardRequest{SanitizedPrompt: "hi"}); err == nil {
+	if _, err := upstream.ChatCompletions(context.Background(), ForwardRequest{Messages: []ChatMessage{{Role: "user", Content: "hi"}}}); err == nil {
 		t.Fatal("expected error when request and fallback model are both missing")
 	}
 }
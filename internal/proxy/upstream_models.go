@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type providerModelsResponse struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+// models fetches the provider's model list from its OpenAI-style models
+// endpoint.
+func (c *providerHTTPClient) models(ctx context.Context) ([]string, error) {
+	httpResp, err := c.get(ctx, c.modelsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = httpResp.Body.Close()
+	}()
+
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read provider response: %w", err)
+	}
+
+	var parsed providerModelsResponse
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		return nil, fmt.Errorf("parse provider response: %w", err)
+	}
+	models := make([]string, 0, len(parsed.Data))
+	for _, item := range parsed.Data {
+		if id := strings.TrimSpace(item.ID); id != "" {
+			models = append(models, id)
+		}
+	}
+	return models, nil
+}
+
+// probe checks that the provider answers on its models endpoint. Only
+// connection errors, timeouts and 5xx responses count as unhealthy; a 404
+// or 401 still proves the provider is up.
+func (c *providerHTTPClient) probe(ctx context.Context) error {
+	httpResp, err := c.get(ctx, c.modelsPath)
+	if err != nil {
+		if failoverEligible(err) {
+			return err
+		}
+		return nil
+	}
+	return httpResp.Body.Close()
+}
+
+// configuredModel lists a fixed configured model, for adapters that always
+// use it whatever the request asks for.
+func configuredModel(model string) []string {
+	if model = strings.TrimSpace(model); model == "" {
+		return nil
+	}
+	return []string{model}
+}
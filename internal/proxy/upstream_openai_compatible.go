@@ -18,15 +18,25 @@ type OpenAICompatibleConfig struct {
 	APIKeyHeader string
 	APIKeyPrefix string
 	ChatPath     string
+	// ResponsesPath enables native /v1/responses forwarding. When empty,
+	// Responses traffic is translated to chat completions.
+	ResponsesPath string
+	// EmbeddingsPath defaults to /v1/embeddings and ModelsPath to
+	// /v1/models.
+	EmbeddingsPath string
+	ModelsPath     string
 }
 
 func NewOpenAICompatibleUpstream(cfg OpenAICompatibleConfig) (*OpenAICompatibleUpstream, error) {
 	client, err := newProviderHTTPClientWithConfig(providerHTTPConfig{
-		BaseURL:      cfg.BaseURL,
-		APIKey:       cfg.APIKey,
-		APIKeyHeader: cfg.APIKeyHeader,
-		APIKeyPrefix: cfg.APIKeyPrefix,
-		ChatPath:     cfg.ChatPath,
+		BaseURL:        cfg.BaseURL,
+		APIKey:         cfg.APIKey,
+		APIKeyHeader:   cfg.APIKeyHeader,
+		APIKeyPrefix:   cfg.APIKeyPrefix,
+		ChatPath:       cfg.ChatPath,
+		ResponsesPath:  cfg.ResponsesPath,
+		EmbeddingsPath: cfg.EmbeddingsPath,
+		ModelsPath:     cfg.ModelsPath,
 	})
 	if err != nil {
 		return nil, err
@@ -39,12 +49,56 @@ func NewOpenAICompatibleUpstream(cfg OpenAICompatibleConfig) (*OpenAICompatibleU
 }
 
 func (u *OpenAICompatibleUpstream) ChatCompletions(ctx context.Context, req ForwardRequest) (ForwardResponse, error) {
+	model, err := u.model(req)
+	if err != nil {
+		return ForwardResponse{}, err
+	}
+	return u.client.chatCompletions(ctx, model, req.Messages, req.Params, req.IdempotencyKey)
+}
+
+func (u *OpenAICompatibleUpstream) ChatCompletionsStream(ctx context.Context, req ForwardRequest, onChunk func(StreamChunk) error) error {
+	model, err := u.model(req)
+	if err != nil {
+		return err
+	}
+	return u.client.chatCompletionsStream(ctx, model, req.Messages, req.Params, req.IdempotencyKey, onChunk)
+}
+
+func (u *OpenAICompatibleUpstream) SupportsResponses() bool {
+	return u.client.responsesPath != ""
+}
+
+func (u *OpenAICompatibleUpstream) Responses(ctx context.Context, req ForwardRequest) (ForwardResponse, error) {
+	model, err := u.model(req)
+	if err != nil {
+		return ForwardResponse{}, err
+	}
+	return u.client.responses(ctx, model, req.Messages, req.Params, req.IdempotencyKey)
+}
+
+func (u *OpenAICompatibleUpstream) Embeddings(ctx context.Context, req EmbedRequest) (EmbedResponse, error) {
+	model, err := u.model(ForwardRequest{Model: req.Model})
+	if err != nil {
+		return EmbedResponse{}, err
+	}
+	return u.client.embeddings(ctx, model, req)
+}
+
+func (u *OpenAICompatibleUpstream) model(req ForwardRequest) (string, error) {
 	model := strings.TrimSpace(req.Model)
 	if model == "" {
 		model = strings.TrimSpace(u.defaultModel)
 	}
 	if model == "" {
-		return ForwardResponse{}, fmt.Errorf("model is required")
+		return "", fmt.Errorf("model is required")
 	}
-	return u.client.chatCompletions(ctx, model, req.SanitizedPrompt, req.IdempotencyKey)
+	return model, nil
+}
+
+func (u *OpenAICompatibleUpstream) ListModels(ctx context.Context) ([]string, error) {
+	return u.client.models(ctx)
+}
+
+func (u *OpenAICompatibleUpstream) Probe(ctx context.Context) error {
+	return u.client.probe(ctx)
 }
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 )
 
@@ -46,8 +47,8 @@ func TestOpenAICompatibleUpstreamSupportsCustomHeadersAndPath(t *testing.T) {
 	}
 
 	resp, err := upstream.ChatCompletions(context.Background(), ForwardRequest{
-		SanitizedPrompt: "hello generic",
-		IdempotencyKey:  "idem-generic",
+		Messages:       []ChatMessage{{Role: "user", Content: "hello generic"}},
+		IdempotencyKey: "idem-generic",
 	})
 	if err != nil {
 		t.Fatalf("ChatCompletions failed: %v", err)
@@ -89,7 +90,7 @@ func TestOpenAICompatibleUpstreamSupportsRawAPIKeyHeaderWithoutPrefix(t *testing
 		t.Fatalf("NewOpenAICompatibleUpstream failed: %v", err)
 	}
 
-	if _, err := upstream.ChatCompletions(context.Background(), ForwardRequest{SanitizedPrompt: "hi"}); err != nil {
+	if _, err := upstream.ChatCompletions(context.Background(), ForwardRequest{Messages: []ChatMessage{{Role: "user", Content: "hi"}}}); err != nil {
 		t.Fatalf("ChatCompletions failed: %v", err)
 	}
 	if authHeader != "secret-key" {
@@ -114,10 +115,200 @@ func TestOpenAICompatibleUpstreamNormalizesChatPath(t *testing.T) {
 		t.Fatalf("NewOpenAICompatibleUpstream failed: %v", err)
 	}
 
-	if _, err := upstream.ChatCompletions(context.Background(), ForwardRequest{SanitizedPrompt: "hi"}); err != nil {
+	if _, err := upstream.ChatCompletions(context.Background(), ForwardRequest{Messages: []ChatMessage{{Role: "user", Content: "hi"}}}); err != nil {
 		t.Fatalf("ChatCompletions failed: %v", err)
 	}
 	if path != "/relative/chat" {
 		t.Fatalf("expected normalized path, got %q", path)
 	}
 }
+
+func TestOpenAICompatibleUpstreamForwardsGenerationParamsAndChoices(t *testing.T) {
+	var captured map[string]json.RawMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&captured); err != nil {
+			t.Fatalf("failed to decode request body: %v", err)
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"first"}},{"message":{"role":"assistant","content":"second"}}]}`))
+	}))
+	defer srv.Close()
+
+	upstream, err := NewOpenAICompatibleUpstream(OpenAICompatibleConfig{BaseURL: srv.URL, Model: "m"})
+	if err != nil {
+		t.Fatalf("NewOpenAICompatibleUpstream failed: %v", err)
+	}
+
+	temperature := 0.2
+	n := 2
+	resp, err := upstream.ChatCompletions(context.Background(), ForwardRequest{
+		Messages: []ChatMessage{{Role: "user", Content: "hello"}},
+		Params: GenerationParams{
+			Temperature:    &temperature,
+			N:              &n,
+			Stop:           StopSequences{"END"},
+			ResponseFormat: &ResponseFormat{Type: "json_object"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletions failed: %v", err)
+	}
+
+	for field, want := range map[string]string{
+		"temperature":     `0.2`,
+		"n":               `2`,
+		"stop":            `["END"]`,
+		"response_format": `{"type":"json_object"}`,
+	} {
+		if got := string(captured[field]); got != want {
+			t.Fatalf("expected %s=%s in provider request, got %s", field, want, got)
+		}
+	}
+	for _, field := range []string{"max_tokens", "top_p", "seed", "user"} {
+		if _, ok := captured[field]; ok {
+			t.Fatalf("expected unset %s to be omitted from provider request", field)
+		}
+	}
+	if resp.Content != "first" || len(resp.Choices) != 2 || resp.Choices[1].Content != "second" {
+		t.Fatalf("expected both choices, got %+v", resp)
+	}
+}
+
+func TestOpenAICompatibleUpstreamSendsResponsesNatively(t *testing.T) {
+	var path string
+	var captured map[string]json.RawMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&captured); err != nil {
+			t.Fatalf("failed to decode request body: %v", err)
+		}
+		_, _ = w.Write([]byte(`{"output":[{"type":"reasoning","content":[]},{"type":"message","content":[{"type":"output_text","text":"native "},{"type":"output_text","text":"reply"}]}]}`))
+	}))
+	defer srv.Close()
+
+	chatOnly, err := NewOpenAICompatibleUpstream(OpenAICompatibleConfig{BaseURL: srv.URL, Model: "m"})
+	if err != nil {
+		t.Fatalf("NewOpenAICompatibleUpstream failed: %v", err)
+	}
+	if chatOnly.SupportsResponses() {
+		t.Fatal("expected Responses to be disabled without a responses path")
+	}
+
+	upstream, err := NewOpenAICompatibleUpstream(OpenAICompatibleConfig{BaseURL: srv.URL, Model: "m", ResponsesPath: "v1/responses"})
+	if err != nil {
+		t.Fatalf("NewOpenAICompatibleUpstream failed: %v", err)
+	}
+	if !upstream.SupportsResponses() {
+		t.Fatal("expected Responses to be enabled with a responses path")
+	}
+
+	maxTokens := 64
+	resp, err := upstream.Responses(context.Background(), ForwardRequest{
+		Messages: []ChatMessage{{Role: "system", Content: "be brief"}, {Role: "user", Content: "hello"}},
+		Params:   GenerationParams{MaxTokens: &maxTokens},
+	})
+	if err != nil {
+		t.Fatalf("Responses failed: %v", err)
+	}
+	if path != "/v1/responses" {
+		t.Fatalf("expected normalized responses path, got %q", path)
+	}
+	if got := string(captured["input"]); got != `[{"type":"message","role":"system","content":"be brief"},{"type":"message","role":"user","content":"hello"}]` {
+		t.Fatalf("unexpected responses input %s", got)
+	}
+	if got := string(captured["max_output_tokens"]); got != "64" {
+		t.Fatalf("expected max_tokens to map to max_output_tokens, got %s", got)
+	}
+	if resp.Content != "native reply" {
+		t.Fatalf("unexpected content %q", resp.Content)
+	}
+}
+
+func TestOpenAICompatibleUpstreamParsesUsage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/responses" {
+			_, _ = w.Write([]byte(`{"output":[{"type":"message","content":[{"type":"output_text","text":"hi"}]}],"usage":{"input_tokens":7,"output_tokens":2,"total_tokens":9}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"hi"}}],"usage":{"prompt_tokens":12,"completion_tokens":3}}`))
+	}))
+	defer srv.Close()
+
+	upstream, err := NewOpenAICompatibleUpstream(OpenAICompatibleConfig{BaseURL: srv.URL, Model: "m", ResponsesPath: "/v1/responses"})
+	if err != nil {
+		t.Fatalf("NewOpenAICompatibleUpstream failed: %v", err)
+	}
+	req := ForwardRequest{Messages: []ChatMessage{{Role: "user", Content: "hello"}}}
+
+	chat, err := upstream.ChatCompletions(context.Background(), req)
+	if err != nil {
+		t.Fatalf("ChatCompletions failed: %v", err)
+	}
+	if want := (Usage{PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15}); chat.Usage != want {
+		t.Fatalf("expected chat usage %+v with derived total, got %+v", want, chat.Usage)
+	}
+
+	responses, err := upstream.Responses(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Responses failed: %v", err)
+	}
+	if want := (Usage{PromptTokens: 7, CompletionTokens: 2, TotalTokens: 9}); responses.Usage != want {
+		t.Fatalf("expected responses usage %+v, got %+v", want, responses.Usage)
+	}
+}
+
+func TestOpenAICompatibleEmbeddingsOrdersResultsByIndex(t *testing.T) {
+	var path string
+	var captured map[string]json.RawMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&captured); err != nil {
+			t.Fatalf("failed to decode request body: %v", err)
+		}
+		_, _ = w.Write([]byte(`{"object":"list","data":[{"index":1,"embedding":[0.3,0.4]},{"index":0,"embedding":[0.1,0.2]}]}`))
+	}))
+	defer srv.Close()
+
+	embeddings, err := NewOpenAICompatibleEmbeddings(OpenAICompatibleConfig{BaseURL: srv.URL, Model: "local-embed", EmbeddingsPath: "embed"})
+	if err != nil {
+		t.Fatalf("NewOpenAICompatibleEmbeddings failed: %v", err)
+	}
+
+	resp, err := embeddings.Embeddings(context.Background(), EmbedRequest{Model: "client-model", Input: []string{"first", "second"}})
+	if err != nil {
+		t.Fatalf("Embeddings failed: %v", err)
+	}
+	if path != "/embed" {
+		t.Fatalf("expected normalized embeddings path, got %q", path)
+	}
+	if got := string(captured["model"]); got != `"local-embed"` {
+		t.Fatalf("expected configured model to be used, got %s", got)
+	}
+	if !reflect.DeepEqual(resp.Embeddings, [][]float64{{0.1, 0.2}, {0.3, 0.4}}) {
+		t.Fatalf("expected embeddings ordered by index, got %v", resp.Embeddings)
+	}
+}
+
+func TestOpenAICompatibleUpstreamListsModels(t *testing.T) {
+	var method, path, auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, auth = r.Method, r.URL.Path, r.Header.Get("X-API-Key")
+		_, _ = w.Write([]byte(`{"object":"list","data":[{"id":"gpt-a","object":"model"},{"id":" "},{"id":"gpt-b","object":"model"}]}`))
+	}))
+	defer srv.Close()
+
+	upstream, err := NewOpenAICompatibleUpstream(OpenAICompatibleConfig{BaseURL: srv.URL, APIKey: "secret", APIKeyHeader: "X-API-Key", ModelsPath: "catalogue"})
+	if err != nil {
+		t.Fatalf("NewOpenAICompatibleUpstream failed: %v", err)
+	}
+
+	models, err := upstream.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels failed: %v", err)
+	}
+	if method != http.MethodGet || path != "/catalogue" || auth != "secret" {
+		t.Fatalf("unexpected models request %s %s auth=%q", method, path, auth)
+	}
+	if !reflect.DeepEqual(models, []string{"gpt-a", "gpt-b"}) {
+		t.Fatalf("unexpected models %v", models)
+	}
+}
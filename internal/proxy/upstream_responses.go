@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type providerResponsesRequest struct {
+	Model           string                  `json:"model"`
+	Input           []providerResponsesItem `json:"input"`
+	Temperature     *float64                `json:"temperature,omitempty"`
+	MaxOutputTokens *int                    `json:"max_output_tokens,omitempty"`
+	TopP            *float64                `json:"top_p,omitempty"`
+	User            string                  `json:"user,omitempty"`
+}
+
+type providerResponsesItem struct {
+	Type    string `json:"type"`
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type providerResponsesResponse struct {
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+		TotalTokens  int `json:"total_tokens"`
+	} `json:"usage"`
+	Output []struct {
+		Type    string `json:"type"`
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	} `json:"output"`
+}
+
+// responses posts already-routed messages to the provider's Responses API.
+// Instructions arrive as system messages and are sent back as system input
+// items, so the provider sees the same sanitized text as on the chat path.
+func (c *providerHTTPClient) responses(ctx context.Context, model string, messages []ChatMessage, params GenerationParams, idempotencyKey string) (ForwardResponse, error) {
+	if len(messages) == 0 {
+		return ForwardResponse{}, fmt.Errorf("at least one message is required")
+	}
+
+	input := make([]providerResponsesItem, 0, len(messages))
+	for _, m := range messages {
+		input = append(input, providerResponsesItem{Type: "message", Role: m.Role, Content: m.Content})
+	}
+	body, err := json.Marshal(providerResponsesRequest{
+		Model:           model,
+		Input:           input,
+		Temperature:     params.Temperature,
+		MaxOutputTokens: params.MaxTokens,
+		TopP:            params.TopP,
+		User:            params.User,
+	})
+	if err != nil {
+		return ForwardResponse{}, fmt.Errorf("marshal provider request: %w", err)
+	}
+
+	httpResp, err := c.post(ctx, c.responsesPath, body, false, idempotencyKey)
+	if err != nil {
+		return ForwardResponse{}, err
+	}
+	defer func() {
+		_ = httpResp.Body.Close()
+	}()
+
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return ForwardResponse{}, fmt.Errorf("read provider response: %w", err)
+	}
+
+	var parsed providerResponsesResponse
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		return ForwardResponse{}, fmt.Errorf("parse provider response: %w", err)
+	}
+	var text strings.Builder
+	for _, item := range parsed.Output {
+		if item.Type != "message" {
+			continue
+		}
+		for _, part := range item.Content {
+			if part.Type == "output_text" {
+				text.WriteString(part.Text)
+			}
+		}
+	}
+	if strings.TrimSpace(text.String()) == "" {
+		return ForwardResponse{}, fmt.Errorf("provider response missing output text")
+	}
+	usage := Usage{
+		PromptTokens:     parsed.Usage.InputTokens,
+		CompletionTokens: parsed.Usage.OutputTokens,
+		TotalTokens:      parsed.Usage.TotalTokens,
+	}
+	return ForwardResponse{Content: text.String(), Usage: usage.withTotal()}, nil
+}
@@ -1,6 +1,11 @@
 package proxy
 
-import "context"
+import (
+	"context"
+	"crypto/sha256"
+)
+
+const stubEmbeddingDimensions = 8
 
 type StubUpstream struct{}
 
@@ -13,3 +18,42 @@ func (StubUpstream) ChatCompletions(ctx context.Context, req ForwardRequest) (Fo
 
 	return ForwardResponse{Content: "stub completion"}, nil
 }
+
+func (StubUpstream) ChatCompletionsStream(ctx context.Context, req ForwardRequest, onChunk func(StreamChunk) error) error {
+	for _, chunk := range []StreamChunk{{Content: "stub "}, {Content: "completion"}, {FinishReason: "stop"}} {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if err := onChunk(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Embeddings returns a deterministic unit-range vector derived from each
+// input's hash, so equal inputs embed identically.
+func (StubUpstream) Embeddings(ctx context.Context, req EmbedRequest) (EmbedResponse, error) {
+	select {
+	case <-ctx.Done():
+		return EmbedResponse{}, ctx.Err()
+	default:
+	}
+
+	embeddings := make([][]float64, 0, len(req.Input))
+	for _, input := range req.Input {
+		sum := sha256.Sum256([]byte(input))
+		vector := make([]float64, stubEmbeddingDimensions)
+		for i := range vector {
+			vector[i] = float64(sum[i])/127.5 - 1
+		}
+		embeddings = append(embeddings, vector)
+	}
+	return EmbedResponse{Embeddings: embeddings}, nil
+}
+
+func (StubUpstream) ListModels(ctx context.Context) ([]string, error) {
+	return []string{"stub"}, nil
+}
@@ -23,12 +23,36 @@ func NewVLLMUpstream(baseURL, defaultModel string) (*VLLMUpstream, error) {
 }
 
 func (u *VLLMUpstream) ChatCompletions(ctx context.Context, req ForwardRequest) (ForwardResponse, error) {
+	model, err := u.model(req)
+	if err != nil {
+		return ForwardResponse{}, err
+	}
+	return u.client.chatCompletions(ctx, model, req.Messages, req.Params, req.IdempotencyKey)
+}
+
+func (u *VLLMUpstream) ChatCompletionsStream(ctx context.Context, req ForwardRequest, onChunk func(StreamChunk) error) error {
+	model, err := u.model(req)
+	if err != nil {
+		return err
+	}
+	return u.client.chatCompletionsStream(ctx, model, req.Messages, req.Params, req.IdempotencyKey, onChunk)
+}
+
+func (u *VLLMUpstream) model(req ForwardRequest) (string, error) {
 	model := strings.TrimSpace(req.Model)
 	if model == "" {
 		model = strings.TrimSpace(u.defaultModel)
 	}
 	if model == "" {
-		return ForwardResponse{}, fmt.Errorf("model is required")
+		return "", fmt.Errorf("model is required")
 	}
-	return u.client.chatCompletions(ctx, model, req.SanitizedPrompt, req.IdempotencyKey)
+	return model, nil
+}
+
+func (u *VLLMUpstream) ListModels(ctx context.Context) ([]string, error) {
+	return u.client.models(ctx)
+}
+
+func (u *VLLMUpstream) Probe(ctx context.Context) error {
+	return u.client.probe(ctx)
 }
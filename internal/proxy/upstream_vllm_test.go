@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -41,9 +42,9 @@ func TestVLLMUpstreamSendsExpectedRequestAndUsesRequestModel(t *testing.T) {
 	}
 
 	resp, err := upstream.ChatCompletions(context.Background(), ForwardRequest{
-		Model:           "request-model",
-		SanitizedPrompt: "hello world",
-		IdempotencyKey:  "idem-1",
+		Model:          "request-model",
+		Messages:       []ChatMessage{{Role: "user", Content: "hello world"}},
+		IdempotencyKey: "idem-1",
 	})
 	if err != nil {
 		t.Fatalf("ChatCompletions failed: %v", err)
@@ -88,7 +89,7 @@ func TestVLLMUpstreamUsesFallbackModelWhenRequestModelMissing(t *testing.T) {
 		t.Fatalf("NewVLLMUpstream failed: %v", err)
 	}
 
-	_, err = upstream.ChatCompletions(context.Background(), ForwardRequest{SanitizedPrompt: "ping"})
+	_, err = upstream.ChatCompletions(context.Background(), ForwardRequest{Messages: []ChatMessage{{Role: "user", Content: "ping"}}})
 	if err != nil {
 		t.Fatalf("ChatCompletions failed: %v", err)
 	}
@@ -108,7 +109,7 @@ func TestVLLMUpstreamReturnsErrorOnProviderNon2xx(t *testing.T) {
 		t.Fatalf("NewVLLMUpstream failed: %v", err)
 	}
 
-	if _, err := upstream.ChatCompletions(context.Background(), ForwardRequest{SanitizedPrompt: "ping"}); err == nil {
+	if _, err := upstream.ChatCompletions(context.Background(), ForwardRequest{Messages: []ChatMessage{{Role: "user", Content: "ping"}}}); err == nil {
 		t.Fatal("expected error on non-2xx response")
 	}
 }
@@ -124,7 +125,7 @@ func TestVLLMUpstreamReturnsErrorOnInvalidJSON(t *testing.T) {
 		t.Fatalf("NewVLLMUpstream failed: %v", err)
 	}
 
-	if _, err := upstream.ChatCompletions(context.Background(), ForwardRequest{SanitizedPrompt: "ping"}); err == nil {
+	if _, err := upstream.ChatCompletions(context.Background(), ForwardRequest{Messages: []ChatMessage{{Role: "user", Content: "ping"}}}); err == nil {
 		t.Fatal("expected error on invalid JSON response")
 	}
 }
@@ -144,7 +145,7 @@ func TestVLLMUpstreamReturnsErrorOnContextTimeout(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
 	defer cancel()
 
-	if _, err := upstream.ChatCompletions(ctx, ForwardRequest{SanitizedPrompt: "ping"}); err == nil {
+	if _, err := upstream.ChatCompletions(ctx, ForwardRequest{Messages: []ChatMessage{{Role: "user", Content: "ping"}}}); err == nil {
 		t.Fatal("expected timeout error")
 	}
 }
@@ -160,7 +161,44 @@ func TestVLLMUpstreamReturnsErrorWhenModelMissing(t *testing.T) {
 		t.Fatalf("NewVLLMUpstream failed: %v", err)
 	}
 
-	if _, err := upstream.ChatCompletions(context.Background(), ForwardRequest{SanitizedPrompt: "ping"}); err == nil {
+	if _, err := upstream.ChatCompletions(context.Background(), ForwardRequest{Messages: []ChatMessage{{Role: "user", Content: "ping"}}}); err == nil {
 		t.Fatal("expected error when request and fallback model are both missing")
 	}
 }
+
+func TestVLLMUpstreamForwardsMessageRolesInOrder(t *testing.T) {
+	var captured struct {
+		Messages []ChatMessage `json:"messages"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&captured); err != nil {
+			t.Fatalf("failed to decode request body: %v", err)
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+
+	upstream, err := NewVLLMUpstream(srv.URL, "fallback-model")
+	if err != nil {
+		t.Fatalf("NewVLLMUpstream failed: %v", err)
+	}
+
+	messages := []ChatMessage{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "hi"},
+		{Role: "assistant", Content: "hello"},
+		{Role: "user", Content: "summarize"},
+	}
+	if _, err := upstream.ChatCompletions(context.Background(), ForwardRequest{Messages: messages}); err != nil {
+		t.Fatalf("ChatCompletions failed: %v", err)
+	}
+
+	if len(captured.Messages) != len(messages) {
+		t.Fatalf("expected %d messages, got %d", len(messages), len(captured.Messages))
+	}
+	for i := range messages {
+		if !reflect.DeepEqual(captured.Messages[i], messages[i]) {
+			t.Fatalf("message %d\nwant: %+v\n got: %+v", i, messages[i], captured.Messages[i])
+		}
+	}
+}
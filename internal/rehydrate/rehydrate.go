@@ -0,0 +1,256 @@
+package rehydrate
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/soloengine/lpg/internal/router"
+	"github.com/soloengine/lpg/internal/sanitizer"
+)
+
+const (
+	ReasonAmbiguousMapping   = "ambiguous_mapping"
+	ReasonEntityTypeMismatch = "entity_type_mismatch"
+)
+
+type Collision struct {
+	Placeholder string
+	EntityTypes []string
+	Reason      string
+}
+
+type Result struct {
+	Content    string
+	Replaced   int
+	Ignored    int
+	Collisions []Collision
+}
+
+// PatternSource resolves the surrogate shape produced for an entity type.
+// *sanitizer.Sanitizer satisfies it, including for custom rules.
+type PatternSource interface {
+	SurrogatePattern(entityType string) *regexp.Regexp
+}
+
+type builtinPatterns struct{}
+
+func (builtinPatterns) SurrogatePattern(entityType string) *regexp.Regexp {
+	return sanitizer.SurrogatePattern(entityType)
+}
+
+type Guard struct {
+	routes   map[router.Route]bool
+	patterns PatternSource
+}
+
+type entry struct {
+	mapping  sanitizer.Mapping
+	types    []string
+	collided bool
+}
+
+type candidate struct {
+	start      int
+	end        int
+	entityType string
+}
+
+type table struct {
+	patterns    PatternSource
+	entries     map[string]*entry
+	entityTypes []string
+	collisions  []Collision
+	maxLen      int
+}
+
+func NewGuard(routes ...router.Route) *Guard {
+	return NewGuardWithPatterns(builtinPatterns{}, routes...)
+}
+
+func NewGuardWithPatterns(patterns PatternSource, routes ...router.Route) *Guard {
+	if patterns == nil {
+		patterns = builtinPatterns{}
+	}
+	g := &Guard{routes: make(map[router.Route]bool, len(routes)), patterns: patterns}
+	for _, route := range routes {
+		g.routes[route] = true
+	}
+	return g
+}
+
+func (g *Guard) Enabled(route router.Route) bool {
+	if g == nil {
+		return false
+	}
+	return g.routes[route]
+}
+
+func (g *Guard) Routes() []router.Route {
+	if g == nil {
+		return nil
+	}
+	routes := make([]router.Route, 0, len(g.routes))
+	for route := range g.routes {
+		routes = append(routes, route)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i] < routes[j]
+	})
+	return routes
+}
+
+func (g *Guard) Rehydrate(content string, mappings []sanitizer.Mapping) Result {
+	t := buildTable(g.patterns, mappings)
+	result := Result{Collisions: t.collisions}
+	result.Content = t.replace(content, &result)
+	return result
+}
+
+func buildTable(patterns PatternSource, mappings []sanitizer.Mapping) *table {
+	t := &table{
+		patterns:   patterns,
+		entries:    make(map[string]*entry, len(mappings)),
+		collisions: make([]Collision, 0),
+	}
+	order := make([]string, 0, len(mappings))
+
+	for _, m := range mappings {
+		if m.Placeholder == "" {
+			continue
+		}
+		if !fullMatch(patterns.SurrogatePattern(m.EntityType), m.Placeholder) {
+			t.collisions = append(t.collisions, Collision{
+				Placeholder: m.Placeholder,
+				EntityTypes: []string{m.EntityType},
+				Reason:      ReasonEntityTypeMismatch,
+			})
+			continue
+		}
+
+		e, ok := t.entries[m.Placeholder]
+		if !ok {
+			t.entries[m.Placeholder] = &entry{mapping: m, types: []string{m.EntityType}}
+			order = append(order, m.Placeholder)
+			continue
+		}
+		if e.mapping.EntityType == m.EntityType && e.mapping.OriginalValue == m.OriginalValue {
+			continue
+		}
+		e.collided = true
+		if !containsString(e.types, m.EntityType) {
+			e.types = append(e.types, m.EntityType)
+		}
+	}
+
+	seenTypes := map[string]bool{}
+	for _, placeholder := range order {
+		e := t.entries[placeholder]
+		if e.collided {
+			types := append([]string(nil), e.types...)
+			sort.Strings(types)
+			t.collisions = append(t.collisions, Collision{
+				Placeholder: placeholder,
+				EntityTypes: types,
+				Reason:      ReasonAmbiguousMapping,
+			})
+			continue
+		}
+		if len(placeholder) > t.maxLen {
+			t.maxLen = len(placeholder)
+		}
+		if !seenTypes[e.mapping.EntityType] {
+			seenTypes[e.mapping.EntityType] = true
+			t.entityTypes = append(t.entityTypes, e.mapping.EntityType)
+		}
+	}
+	sort.Strings(t.entityTypes)
+	return t
+}
+
+func (t *table) replace(content string, result *Result) string {
+	if len(t.entityTypes) == 0 {
+		return content
+	}
+
+	candidates := make([]candidate, 0)
+	for _, entityType := range t.entityTypes {
+		for _, idx := range t.patterns.SurrogatePattern(entityType).FindAllStringIndex(content, -1) {
+			candidates = append(candidates, candidate{start: idx[0], end: idx[1], entityType: entityType})
+		}
+	}
+	if len(candidates) == 0 {
+		return content
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].start != candidates[j].start {
+			return candidates[i].start < candidates[j].start
+		}
+		return candidates[i].end-candidates[i].start > candidates[j].end-candidates[j].start
+	})
+
+	var b strings.Builder
+	b.Grow(len(content))
+	cursor := 0
+	for _, c := range candidates {
+		if c.start < cursor {
+			continue
+		}
+		e, ok := t.entries[content[c.start:c.end]]
+		if !ok || !tokenBoundary(content, c.start, c.end) || e.mapping.EntityType != c.entityType {
+			result.Ignored++
+			continue
+		}
+		if e.collided {
+			continue
+		}
+		b.WriteString(content[cursor:c.start])
+		b.WriteString(e.mapping.OriginalValue)
+		cursor = c.end
+		result.Replaced++
+	}
+	b.WriteString(content[cursor:])
+	return b.String()
+}
+
+func fullMatch(pattern *regexp.Regexp, placeholder string) bool {
+	loc := pattern.FindStringIndex(placeholder)
+	return loc != nil && loc[0] == 0 && loc[1] == len(placeholder)
+}
+
+func tokenBoundary(content string, start, end int) bool {
+	if start > 0 && isTokenByte(content[start-1]) {
+		return false
+	}
+	if end < len(content) {
+		next := content[end]
+		if next == '.' {
+			return end+1 >= len(content) || !isTokenByte(content[end+1])
+		}
+		if isTokenByte(next) {
+			return false
+		}
+	}
+	return true
+}
+
+func isTokenByte(b byte) bool {
+	if (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') {
+		return true
+	}
+	switch b {
+	case '.', '_', '%', '+', '-', '@':
+		return true
+	default:
+		return false
+	}
+}
+
+func containsString(values []string, needle string) bool {
+	for _, v := range values {
+		if v == needle {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package rehydrate
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/soloengine/lpg/internal/router"
+	"github.com/soloengine/lpg/internal/sanitizer"
+)
+
+func defaultMappings() []sanitizer.Mapping {
+	return []sanitizer.Mapping{
+		{Placeholder: "person1@example.net", OriginalValue: "alice@example.com", EntityType: "EMAIL", ConfidenceScore: 0.99},
+		{Placeholder: "555-010-0001", OriginalValue: "555-123-4567", EntityType: "PHONE", ConfidenceScore: 0.99},
+	}
+}
+
+func TestGuardEnabledPerRoute(t *testing.T) {
+	g := NewGuard(router.RouteSanitizedForward)
+	if !g.Enabled(router.RouteSanitizedForward) {
+		t.Fatal("expected sanitized_forward to be enabled")
+	}
+	if g.Enabled(router.RouteHighAbstraction) {
+		t.Fatal("expected high_abstraction to be disabled")
+	}
+
+	var nilGuard *Guard
+	if nilGuard.Enabled(router.RouteSanitizedForward) {
+		t.Fatal("expected nil guard to be disabled")
+	}
+}
+
+func TestRehydrateRestoresKnownSurrogates(t *testing.T) {
+	g := NewGuard(router.RouteSanitizedForward)
+	result := g.Rehydrate("Reply to person1@example.net or call 555-010-0001.", defaultMappings())
+
+	expected := "Reply to alice@example.com or call 555-123-4567."
+	if result.Content != expected {
+		t.Fatalf("unexpected rehydrated content\nwant: %q\n got: %q", expected, result.Content)
+	}
+	if result.Replaced != 2 {
+		t.Fatalf("expected 2 replacements, got %d", result.Replaced)
+	}
+	if len(result.Collisions) != 0 {
+		t.Fatalf("expected no collisions, got %v", result.Collisions)
+	}
+}
+
+func TestRehydrateIgnoresSpoofedSurrogates(t *testing.T) {
+	g := NewGuard(router.RouteSanitizedForward)
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "unknown index", content: "contact person2@example.net"},
+		{name: "longer index prefix", content: "contact person12@example.net"},
+		{name: "prefixed local part", content: "contact xperson1@example.net"},
+		{name: "extended domain", content: "contact person1@example.network"},
+		{name: "subdomain suffix", content: "contact person1@example.net.evil.io"},
+		{name: "phone with extra digits", content: "call 555-010-00012"},
+		{name: "ssn shaped token without mapping", content: "ssn 900-00-0001"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := g.Rehydrate(tc.content, defaultMappings())
+			if result.Content != tc.content {
+				t.Fatalf("expected spoofed content to pass through unchanged\nwant: %q\n got: %q", tc.content, result.Content)
+			}
+			if result.Replaced != 0 {
+				t.Fatalf("expected no replacements, got %d", result.Replaced)
+			}
+		})
+	}
+}
+
+func TestRehydrateRejectsEntityTypeMismatch(t *testing.T) {
+	g := NewGuard(router.RouteSanitizedForward)
+	mappings := []sanitizer.Mapping{
+		{Placeholder: "person1@example.net", OriginalValue: "123-45-6789", EntityType: "SSN", ConfidenceScore: 0.99},
+	}
+
+	result := g.Rehydrate("hello person1@example.net", mappings)
+	if result.Content != "hello person1@example.net" {
+		t.Fatalf("expected mismatched mapping to be skipped, got %q", result.Content)
+	}
+	if len(result.Collisions) != 1 || result.Collisions[0].Reason != ReasonEntityTypeMismatch {
+		t.Fatalf("expected entity type mismatch collision, got %v", result.Collisions)
+	}
+}
+
+func TestRehydrateReportsAmbiguousMappings(t *testing.T) {
+	g := NewGuard(router.RouteSanitizedForward)
+	mappings := append(defaultMappings(), sanitizer.Mapping{
+		Placeholder: "person1@example.net", OriginalValue: "mallory@example.com", EntityType: "EMAIL", ConfidenceScore: 0.99,
+	})
+
+	result := g.Rehydrate("mail person1@example.net call 555-010-0001", mappings)
+	if result.Content != "mail person1@example.net call 555-123-4567" {
+		t.Fatalf("expected ambiguous surrogate to stay masked, got %q", result.Content)
+	}
+	if len(result.Collisions) != 1 {
+		t.Fatalf("expected 1 collision, got %d", len(result.Collisions))
+	}
+	if result.Collisions[0].Placeholder != "person1@example.net" || result.Collisions[0].Reason != ReasonAmbiguousMapping {
+		t.Fatalf("unexpected collision %+v", result.Collisions[0])
+	}
+}
+
+func TestRehydrateDoesNotChainSubstitutions(t *testing.T) {
+	g := NewGuard(router.RouteSanitizedForward)
+	mappings := []sanitizer.Mapping{
+		{Placeholder: "person1@example.net", OriginalValue: "person2@example.net", EntityType: "EMAIL", ConfidenceScore: 0.99},
+		{Placeholder: "person2@example.net", OriginalValue: "bob@example.com", EntityType: "EMAIL", ConfidenceScore: 0.99},
+	}
+
+	result := g.Rehydrate("person1@example.net", mappings)
+	if result.Content != "person2@example.net" {
+		t.Fatalf("expected single-pass substitution, got %q", result.Content)
+	}
+}
+
+func TestRehydrateUsesSanitizerPatternsForCustomRules(t *testing.T) {
+	s, err := sanitizer.New(sanitizer.Rule{
+		EntityType:        "EMPLOYEE_ID",
+		Regex:             regexp.MustCompile(`\bE\d{6}\b`),
+		Confidence:        0.95,
+		SurrogateTemplate: "EMP-{n}",
+	})
+	if err != nil {
+		t.Fatalf("sanitizer.New failed: %v", err)
+	}
+	sanitized, err := s.Sanitize("ask E123456")
+	if err != nil {
+		t.Fatalf("sanitize failed: %v", err)
+	}
+
+	content := "EMP-1 approved; EMP-12 and EMP-1x are unknown"
+
+	builtinOnly := NewGuard(router.RouteSanitizedForward).Rehydrate(content, sanitized.Mappings)
+	if builtinOnly.Content != content || len(builtinOnly.Collisions) != 1 {
+		t.Fatalf("expected built-in guard to reject custom surrogate shape, got %+v", builtinOnly)
+	}
+
+	result := NewGuardWithPatterns(s, router.RouteSanitizedForward).Rehydrate(content, sanitized.Mappings)
+	expected := "E123456 approved; EMP-12 and EMP-1x are unknown"
+	if result.Content != expected {
+		t.Fatalf("unexpected rehydrated content\nwant: %q\n got: %q", expected, result.Content)
+	}
+	if result.Replaced != 1 {
+		t.Fatalf("expected 1 replacement, got %d", result.Replaced)
+	}
+}
@@ -0,0 +1,88 @@
+package rehydrate
+
+import (
+	"strings"
+
+	"github.com/soloengine/lpg/internal/sanitizer"
+)
+
+type Stream struct {
+	table     *table
+	pending   string
+	inLongRun bool
+	result    Result
+}
+
+func (g *Guard) NewStream(mappings []sanitizer.Mapping) *Stream {
+	t := buildTable(g.patterns, mappings)
+	return &Stream{
+		table:  t,
+		result: Result{Collisions: t.collisions},
+	}
+}
+
+func (s *Stream) Write(delta string) string {
+	if len(s.table.entityTypes) == 0 {
+		return delta
+	}
+
+	s.pending += delta
+	var out strings.Builder
+
+	if s.inLongRun {
+		idx := -1
+		for i := 0; i < len(s.pending); i++ {
+			if !isTokenByte(s.pending[i]) {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			out.WriteString(s.pending)
+			s.pending = ""
+			return out.String()
+		}
+		out.WriteString(s.pending[:idx])
+		s.pending = s.pending[idx:]
+		s.inLongRun = false
+	}
+
+	cut := -1
+	for i := len(s.pending) - 1; i >= 0; i-- {
+		if !isTokenByte(s.pending[i]) {
+			cut = i
+			break
+		}
+	}
+	if cut >= 0 {
+		out.WriteString(s.table.replace(s.pending[:cut+1], &s.result))
+		s.pending = s.pending[cut+1:]
+	}
+
+	if len(s.pending) > s.table.maxLen+1 {
+		out.WriteString(s.pending)
+		s.pending = ""
+		s.inLongRun = true
+	}
+	return out.String()
+}
+
+func (s *Stream) Flush() string {
+	if s.pending == "" {
+		return ""
+	}
+	pending := s.pending
+	s.pending = ""
+	if s.inLongRun {
+		return pending
+	}
+	return s.table.replace(pending, &s.result)
+}
+
+func (s *Stream) Result() Result {
+	return Result{
+		Replaced:   s.result.Replaced,
+		Ignored:    s.result.Ignored,
+		Collisions: s.result.Collisions,
+	}
+}
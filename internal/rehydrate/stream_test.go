@@ -0,0 +1,74 @@
+package rehydrate
+
+import (
+	"testing"
+
+	"github.com/soloengine/lpg/internal/router"
+)
+
+func streamAll(s *Stream, deltas []string) string {
+	out := ""
+	for _, d := range deltas {
+		out += s.Write(d)
+	}
+	return out + s.Flush()
+}
+
+func TestStreamRehydratesSurrogateSplitAcrossChunks(t *testing.T) {
+	g := NewGuard(router.RouteSanitizedForward)
+	s := g.NewStream(defaultMappings())
+
+	got := streamAll(s, []string{"Reply to per", "son1@exam", "ple.net or call 555-0", "10-0001."})
+	expected := "Reply to alice@example.com or call 555-123-4567."
+	if got != expected {
+		t.Fatalf("unexpected streamed content\nwant: %q\n got: %q", expected, got)
+	}
+	if s.Result().Replaced != 2 {
+		t.Fatalf("expected 2 replacements, got %d", s.Result().Replaced)
+	}
+}
+
+func TestStreamMatchesNonStreamingForEveryChunkBoundary(t *testing.T) {
+	g := NewGuard(router.RouteSanitizedForward)
+	inputs := []string{
+		"contact person1@example.net, or person12@example.net.",
+		"xperson1@example.net and person1@example.net.evil.io and person1@example.net.",
+		"call 555-010-0001 not 555-010-00012 thanks",
+		"ünïcödé person1@example.net ✓",
+	}
+
+	for _, input := range inputs {
+		expected := g.Rehydrate(input, defaultMappings()).Content
+		for split := 0; split <= len(input); split++ {
+			s := g.NewStream(defaultMappings())
+			got := streamAll(s, []string{input[:split], input[split:]})
+			if got != expected {
+				t.Fatalf("split at %d of %q\nwant: %q\n got: %q", split, input, expected, got)
+			}
+		}
+	}
+}
+
+func TestStreamEmitsLongTokenRunsWithoutUnboundedBuffering(t *testing.T) {
+	g := NewGuard(router.RouteSanitizedForward)
+	s := g.NewStream(defaultMappings())
+
+	long := "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	if out := s.Write(long); out != long {
+		t.Fatalf("expected long token run to be released immediately, got %q", out)
+	}
+	if out := s.Write("person1@example.net more"); out != "person1@example.net " {
+		t.Fatalf("expected surrogate glued to long run to stay masked, got %q", out)
+	}
+	if out := s.Flush(); out != "more" {
+		t.Fatalf("unexpected flushed tail %q", out)
+	}
+}
+
+func TestStreamPassesThroughWithoutMappings(t *testing.T) {
+	g := NewGuard(router.RouteSanitizedForward)
+	s := g.NewStream(nil)
+	if out := s.Write("person1@example.net"); out != "person1@example.net" {
+		t.Fatalf("expected passthrough, got %q", out)
+	}
+}
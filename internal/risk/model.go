@@ -0,0 +1,168 @@
+package risk
+
+import (
+	"math"
+	"regexp"
+	"sort"
+)
+
+// DefaultEntityWeight is the score one detection contributes when the model
+// has no explicit weight for its entity type.
+const DefaultEntityWeight = 25.0
+
+// Factor kinds reported in Result.Factors.
+const (
+	FactorEntity         = "entity"
+	FactorCoOccurrence   = "co_occurrence"
+	FactorContextKeyword = "context_keyword"
+)
+
+// Detection is one masked entity as seen by the scorer. Key identifies the
+// underlying value (the sanitizer's placeholder works) so repeats of the
+// same value can be discounted without the scorer ever seeing raw PII.
+type Detection struct {
+	EntityType string
+	Key        string
+}
+
+// CoOccurrence adds Bonus when every listed entity type appears in the same
+// request, e.g. a name alongside an SSN and a date of birth.
+type CoOccurrence struct {
+	Name        string
+	EntityTypes []string
+	Bonus       float64
+}
+
+// Model is the scoring policy. The zero value of every field except
+// DefaultWeight and RepeatDecay means "off"; DefaultModel reproduces the
+// original 25-points-per-detection scoring.
+type Model struct {
+	DefaultWeight float64
+	Weights       map[string]float64
+	// RepeatDecay multiplies the weight of each further occurrence of the
+	// same value: the k-th repeat scores weight*RepeatDecay^k. 1 disables
+	// diminishing returns, 0 counts each distinct value once.
+	RepeatDecay     float64
+	CoOccurrences   []CoOccurrence
+	ContextKeywords map[string]float64
+}
+
+// Factor is one line of the score breakdown.
+type Factor struct {
+	Kind   string  `json:"kind"`
+	Name   string  `json:"name"`
+	Count  int     `json:"count,omitempty"`
+	Points float64 `json:"points"`
+}
+
+func DefaultModel() Model {
+	return Model{DefaultWeight: DefaultEntityWeight, RepeatDecay: 1}
+}
+
+func (m Model) weight(entityType string) float64 {
+	if w, ok := m.Weights[entityType]; ok {
+		return w
+	}
+	return m.DefaultWeight
+}
+
+// keywordMatcher is a context keyword compiled once per scorer.
+type keywordMatcher struct {
+	keyword string
+	bonus   float64
+	re      *regexp.Regexp
+}
+
+// compileKeywords builds the context keyword matchers in alphabetical
+// order. Keywords match whole words case-insensitively.
+func (m Model) compileKeywords() []keywordMatcher {
+	matchers := make([]keywordMatcher, 0, len(m.ContextKeywords))
+	for _, keyword := range sortedFloatKeys(m.ContextKeywords) {
+		matchers = append(matchers, keywordMatcher{
+			keyword: keyword,
+			bonus:   m.ContextKeywords[keyword],
+			re:      regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(keyword) + `\b`),
+		})
+	}
+	return matchers
+}
+
+// score returns the uncapped total and its breakdown. Entity factors are
+// ordered by entity type, then co-occurrence and keyword factors in
+// configuration and alphabetical order respectively. keywords must be
+// m.compileKeywords().
+func (m Model) score(detections []Detection, text string, keywords []keywordMatcher) (float64, []Factor) {
+	type entityTally struct {
+		count  int
+		points float64
+	}
+	tallies := map[string]*entityTally{}
+	seen := map[string]int{}
+	for _, d := range detections {
+		tally, ok := tallies[d.EntityType]
+		if !ok {
+			tally = &entityTally{}
+			tallies[d.EntityType] = tally
+		}
+		tally.count++
+		valueKey := d.EntityType + "\x00" + d.Key
+		repeats := seen[valueKey]
+		seen[valueKey] = repeats + 1
+		if d.Key == "" {
+			repeats = 0
+		}
+		tally.points += m.weight(d.EntityType) * math.Pow(m.RepeatDecay, float64(repeats))
+	}
+
+	entityTypes := make([]string, 0, len(tallies))
+	for entityType := range tallies {
+		entityTypes = append(entityTypes, entityType)
+	}
+	sort.Strings(entityTypes)
+
+	total := 0.0
+	factors := make([]Factor, 0, len(entityTypes))
+	for _, entityType := range entityTypes {
+		tally := tallies[entityType]
+		total += tally.points
+		factors = append(factors, Factor{Kind: FactorEntity, Name: entityType, Count: tally.count, Points: roundPoints(tally.points)})
+	}
+
+	for _, c := range m.CoOccurrences {
+		present := true
+		for _, entityType := range c.EntityTypes {
+			if tallies[entityType] == nil {
+				present = false
+				break
+			}
+		}
+		if present {
+			total += c.Bonus
+			factors = append(factors, Factor{Kind: FactorCoOccurrence, Name: c.Name, Points: roundPoints(c.Bonus)})
+		}
+	}
+
+	// A keyword contributes its bonus once however often it appears.
+	if text != "" {
+		for _, k := range keywords {
+			if count := len(k.re.FindAllStringIndex(text, -1)); count > 0 {
+				total += k.bonus
+				factors = append(factors, Factor{Kind: FactorContextKeyword, Name: k.keyword, Count: count, Points: roundPoints(k.bonus)})
+			}
+		}
+	}
+	return total, factors
+}
+
+func roundPoints(points float64) float64 {
+	return math.Round(points*100) / 100
+}
+
+func sortedFloatKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
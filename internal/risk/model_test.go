@@ -0,0 +1,116 @@
+package risk
+
+import "testing"
+
+func TestDefaultModelMatchesDetectionCountScoring(t *testing.T) {
+	s := NewScorer(0.70)
+	detections := []Detection{
+		{EntityType: "EMAIL", Key: "user_1@example.net"},
+		{EntityType: "EMAIL", Key: "user_1@example.net"},
+		{EntityType: "SSN", Key: "000-00-0001"},
+	}
+
+	weighted, err := s.EvaluateDetections(detections, "", 0.99)
+	if err != nil {
+		t.Fatalf("EvaluateDetections returned error: %v", err)
+	}
+	counted, err := s.Evaluate(len(detections), 0.99)
+	if err != nil {
+		t.Fatalf("Evaluate returned error: %v", err)
+	}
+	if weighted.Score != counted.Score || weighted.Category != counted.Category {
+		t.Fatalf("default model diverged from count scoring: weighted=%+v counted=%+v", weighted, counted)
+	}
+}
+
+func TestWeightedModelScoresEntitiesDifferently(t *testing.T) {
+	s := NewScorerWithModel(0.70, Model{
+		DefaultWeight: 25,
+		Weights:       map[string]float64{"EMAIL": 5, "SSN": 40},
+		RepeatDecay:   1,
+	})
+
+	emails := make([]Detection, 0, 5)
+	ssns := make([]Detection, 0, 5)
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		emails = append(emails, Detection{EntityType: "EMAIL", Key: key})
+		ssns = append(ssns, Detection{EntityType: "SSN", Key: key})
+	}
+
+	mailingList, err := s.EvaluateDetections(emails, "", 0.99)
+	if err != nil {
+		t.Fatalf("EvaluateDetections returned error: %v", err)
+	}
+	identities, err := s.EvaluateDetections(ssns, "", 0.99)
+	if err != nil {
+		t.Fatalf("EvaluateDetections returned error: %v", err)
+	}
+	if mailingList.Score != 25 || mailingList.Category != CategoryMedium {
+		t.Fatalf("expected five emails to score 25/Medium, got %d/%s", mailingList.Score, mailingList.Category)
+	}
+	if identities.Score != 100 || identities.Category != CategoryCritical {
+		t.Fatalf("expected five SSNs to cap at 100/Critical, got %d/%s", identities.Score, identities.Category)
+	}
+}
+
+func TestRepeatDecayDiscountsSameValue(t *testing.T) {
+	s := NewScorerWithModel(0.70, Model{DefaultWeight: 20, RepeatDecay: 0.5})
+	detections := []Detection{
+		{EntityType: "EMAIL", Key: "user_1@example.net"},
+		{EntityType: "EMAIL", Key: "user_1@example.net"},
+		{EntityType: "EMAIL", Key: "user_1@example.net"},
+		{EntityType: "EMAIL", Key: "user_2@example.net"},
+	}
+
+	result, err := s.EvaluateDetections(detections, "", 0.99)
+	if err != nil {
+		t.Fatalf("EvaluateDetections returned error: %v", err)
+	}
+	// 20 + 10 + 5 for the repeated value, 20 for the distinct one.
+	if result.Score != 55 {
+		t.Fatalf("expected score 55, got %d", result.Score)
+	}
+	if len(result.Factors) != 1 || result.Factors[0] != (Factor{Kind: FactorEntity, Name: "EMAIL", Count: 4, Points: 55}) {
+		t.Fatalf("unexpected factors: %+v", result.Factors)
+	}
+}
+
+func TestCoOccurrenceAndContextKeywordsAddBonuses(t *testing.T) {
+	s := NewScorerWithModel(0.70, Model{
+		DefaultWeight: 10,
+		RepeatDecay:   1,
+		CoOccurrences: []CoOccurrence{
+			{Name: "identity_bundle", EntityTypes: []string{"NAME", "SSN", "DOB"}, Bonus: 30},
+			{Name: "contact_bundle", EntityTypes: []string{"EMAIL", "PHONE"}, Bonus: 50},
+		},
+		ContextKeywords: map[string]float64{"diagnosis": 15, "salary": 10},
+	})
+	detections := []Detection{
+		{EntityType: "SSN", Key: "1"},
+		{EntityType: "NAME", Key: "2"},
+		{EntityType: "DOB", Key: "3"},
+	}
+
+	result, err := s.EvaluateDetections(detections, "Diagnosis for <NAME_1>; diagnosis confirmed. Salaryband unchanged.", 0.99)
+	if err != nil {
+		t.Fatalf("EvaluateDetections returned error: %v", err)
+	}
+	if result.Score != 75 || result.Category != CategoryCritical {
+		t.Fatalf("expected 75/Critical, got %d/%s", result.Score, result.Category)
+	}
+	expected := []Factor{
+		{Kind: FactorEntity, Name: "DOB", Count: 1, Points: 10},
+		{Kind: FactorEntity, Name: "NAME", Count: 1, Points: 10},
+		{Kind: FactorEntity, Name: "SSN", Count: 1, Points: 10},
+		{Kind: FactorCoOccurrence, Name: "identity_bundle", Points: 30},
+		{Kind: FactorContextKeyword, Name: "diagnosis", Count: 2, Points: 15},
+	}
+	if len(result.Factors) != len(expected) {
+		t.Fatalf("expected factors %+v, got %+v", expected, result.Factors)
+	}
+	for i := range expected {
+		if result.Factors[i] != expected[i] {
+			t.Fatalf("expected factors %+v, got %+v", expected, result.Factors)
+		}
+	}
+}
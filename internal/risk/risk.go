@@ -1,6 +1,9 @@
 package risk
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+)
 
 type Category string
 
@@ -15,21 +18,43 @@ type Result struct {
 	Score      int
 	Category   Category
 	Confidence float64
+	// Factors explains Score. Only EvaluateDetections populates it.
+	Factors []Factor
 }
 
 type Scorer struct {
 	confidenceThreshold float64
+	model               Model
+	keywords            []keywordMatcher
 }
 
 func NewScorer(confidenceThreshold float64) *Scorer {
+	return NewScorerWithModel(confidenceThreshold, DefaultModel())
+}
+
+// NewScorerWithModel scores detections with a weighted model. The model is
+// used as given; configuration loading is responsible for validating it.
+func NewScorerWithModel(confidenceThreshold float64, model Model) *Scorer {
 	if confidenceThreshold <= 0 {
 		confidenceThreshold = 0.70
 	}
-	return &Scorer{confidenceThreshold: confidenceThreshold}
+	return &Scorer{confidenceThreshold: confidenceThreshold, model: model, keywords: model.compileKeywords()}
 }
 
+// Evaluate scores a bare detection count at 25 points each, ignoring the
+// configured model.
 func (s *Scorer) Evaluate(detections int, confidence float64) (Result, error) {
-	score := detections * 25
+	return s.result(detections*25, confidence, nil)
+}
+
+// EvaluateDetections scores detections with the scorer's model. text is
+// searched for context keywords and should already be sanitized.
+func (s *Scorer) EvaluateDetections(detections []Detection, text string, confidence float64) (Result, error) {
+	total, factors := s.model.score(detections, text, s.keywords)
+	return s.result(int(math.Round(math.Min(total, 100))), confidence, factors)
+}
+
+func (s *Scorer) result(score int, confidence float64, factors []Factor) (Result, error) {
 	if score > 100 {
 		score = 100
 	}
@@ -41,13 +66,14 @@ func (s *Scorer) Evaluate(detections int, confidence float64) (Result, error) {
 
 	finalCategory := baseCategory
 	if confidence < s.confidenceThreshold {
-		finalCategory = escalate(baseCategory)
+		finalCategory = Escalate(baseCategory)
 	}
 
 	return Result{
 		Score:      score,
 		Category:   finalCategory,
 		Confidence: confidence,
+		Factors:    factors,
 	}, nil
 }
 
@@ -66,7 +92,8 @@ func categoryForScore(score int) (Category, error) {
 	}
 }
 
-func escalate(category Category) Category {
+// Escalate returns the next stricter category; Critical stays Critical.
+func Escalate(category Category) Category {
 	switch category {
 	case CategoryLow:
 		return CategoryMedium
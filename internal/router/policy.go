@@ -0,0 +1,99 @@
+package router
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Action is what a hard-block entity does to routing when it is detected.
+type Action string
+
+const (
+	// ActionMaskOnly masks the entity and disables raw forwarding.
+	ActionMaskOnly Action = "mask_only"
+	// ActionEscalateOneBand raises the risk category by one band.
+	ActionEscalateOneBand Action = "escalate_one_band"
+	// ActionForceLocalOnly keeps the request local regardless of score.
+	ActionForceLocalOnly Action = "force_local_only"
+	// ActionBlock rejects the request without egress.
+	ActionBlock Action = "block"
+)
+
+// DefaultHardBlockAction applies to hard-block entities without an explicit
+// policy and matches the historical "never raw forward" behavior.
+const DefaultHardBlockAction = ActionMaskOnly
+
+// EntityPolicy records that an entity type was detected and which action its
+// policy triggered.
+type EntityPolicy struct {
+	EntityType string `json:"entity_type"`
+	Action     Action `json:"action"`
+}
+
+func ParseAction(raw string) (Action, error) {
+	switch Action(raw) {
+	case ActionMaskOnly, ActionEscalateOneBand, ActionForceLocalOnly, ActionBlock:
+		return Action(raw), nil
+	default:
+		return "", fmt.Errorf("invalid action %q: must be one of %q, %q, %q, %q", raw, ActionBlock, ActionForceLocalOnly, ActionEscalateOneBand, ActionMaskOnly)
+	}
+}
+
+// severity orders actions so the strictest triggered policy wins.
+func (a Action) severity() int {
+	switch a {
+	case ActionBlock:
+		return 4
+	case ActionForceLocalOnly:
+		return 3
+	case ActionEscalateOneBand:
+		return 2
+	case ActionMaskOnly:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// StrictestAction returns the most restrictive action among policies, or ""
+// when none are triggered.
+func StrictestAction(policies []EntityPolicy) Action {
+	var strictest Action
+	for _, p := range policies {
+		if p.Action.severity() > strictest.severity() {
+			strictest = p.Action
+		}
+	}
+	return strictest
+}
+
+// EntityPolicies maps entity types to their hard-block action.
+type EntityPolicies map[string]Action
+
+// Triggered resolves the policy for each detected entity type. Entities with
+// an explicit action always trigger it; other entities trigger the default
+// action only when hardBlock reports them as hard-block. The result is
+// de-duplicated and sorted by entity type.
+func (p EntityPolicies) Triggered(entityTypes []string, hardBlock func(entityType string) bool) []EntityPolicy {
+	seen := map[string]bool{}
+	triggered := make([]EntityPolicy, 0)
+	for _, entityType := range entityTypes {
+		if seen[entityType] {
+			continue
+		}
+		seen[entityType] = true
+
+		action, ok := p[entityType]
+		if !ok {
+			if hardBlock == nil || !hardBlock(entityType) {
+				continue
+			}
+			action = DefaultHardBlockAction
+		}
+		triggered = append(triggered, EntityPolicy{EntityType: entityType, Action: action})
+	}
+	sort.Slice(triggered, func(i, j int) bool {
+		return triggered[i].EntityType < triggered[j].EntityType
+	})
+	return triggered
+}
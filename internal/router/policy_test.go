@@ -0,0 +1,82 @@
+package router
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/soloengine/lpg/internal/risk"
+)
+
+func TestEngineDecideAppliesStrictestEntityAction(t *testing.T) {
+	tests := []struct {
+		name     string
+		category risk.Category
+		actions  []Action
+		route    Route
+		egress   bool
+		cat      risk.Category
+	}{
+		{name: "mask only keeps band but disables raw", category: risk.CategoryLow, actions: []Action{ActionMaskOnly}, route: RouteSanitizedForward, egress: true, cat: risk.CategoryLow},
+		{name: "escalate low to medium", category: risk.CategoryLow, actions: []Action{ActionEscalateOneBand}, route: RouteSanitizedForward, egress: true, cat: risk.CategoryMedium},
+		{name: "escalate medium to high", category: risk.CategoryMedium, actions: []Action{ActionEscalateOneBand}, route: RouteHighAbstraction, egress: true, cat: risk.CategoryHigh},
+		{name: "escalate high to critical", category: risk.CategoryHigh, actions: []Action{ActionEscalateOneBand}, route: RouteCriticalBlocked, egress: false, cat: risk.CategoryCritical},
+		{name: "force local only overrides low score", category: risk.CategoryLow, actions: []Action{ActionForceLocalOnly}, route: RouteCriticalLocalOnly, egress: false, cat: risk.CategoryCritical},
+		{name: "block overrides low score", category: risk.CategoryLow, actions: []Action{ActionBlock}, route: RouteCriticalBlocked, egress: false, cat: risk.CategoryCritical},
+		{name: "strictest action wins", category: risk.CategoryMedium, actions: []Action{ActionMaskOnly, ActionBlock, ActionEscalateOneBand}, route: RouteCriticalBlocked, egress: false, cat: risk.CategoryCritical},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			policies := make([]EntityPolicy, 0, len(tc.actions))
+			for _, action := range tc.actions {
+				policies = append(policies, EntityPolicy{EntityType: "X_" + string(action), Action: action})
+			}
+
+			decision := NewEngine(true).Decide(tc.category, policies)
+			if decision.Route != tc.route || decision.Egress != tc.egress || decision.Category != tc.cat {
+				t.Fatalf("expected %s/%t/%s, got %s/%t/%s", tc.route, tc.egress, tc.cat, decision.Route, decision.Egress, decision.Category)
+			}
+			if !reflect.DeepEqual(decision.Policies, policies) {
+				t.Fatalf("expected triggered policies %+v on decision, got %+v", policies, decision.Policies)
+			}
+		})
+	}
+}
+
+func TestEntityPoliciesTriggered(t *testing.T) {
+	policies := EntityPolicies{
+		"SSN":   ActionBlock,
+		"EMAIL": ActionEscalateOneBand,
+	}
+	hardBlock := map[string]bool{"SECRET_JWT": true, "SSN": true}
+
+	triggered := policies.Triggered(
+		[]string{"SSN", "PHONE", "SECRET_JWT", "EMAIL", "SSN"},
+		func(entityType string) bool { return hardBlock[entityType] },
+	)
+
+	expected := []EntityPolicy{
+		{EntityType: "EMAIL", Action: ActionEscalateOneBand},
+		{EntityType: "SECRET_JWT", Action: DefaultHardBlockAction},
+		{EntityType: "SSN", Action: ActionBlock},
+	}
+	if !reflect.DeepEqual(triggered, expected) {
+		t.Fatalf("unexpected triggered policies\nwant: %+v\n got: %+v", expected, triggered)
+	}
+
+	var none EntityPolicies
+	if got := none.Triggered([]string{"PHONE"}, nil); len(got) != 0 {
+		t.Fatalf("expected no policies, got %+v", got)
+	}
+}
+
+func TestParseAction(t *testing.T) {
+	for _, raw := range []string{"block", "force_local_only", "escalate_one_band", "mask_only"} {
+		if _, err := ParseAction(raw); err != nil {
+			t.Fatalf("ParseAction(%q) failed: %v", raw, err)
+		}
+	}
+	if _, err := ParseAction("allow"); err == nil {
+		t.Fatal("expected error for unknown action")
+	}
+}
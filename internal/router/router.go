@@ -16,6 +16,7 @@ type Decision struct {
 	Category risk.Category
 	Route    Route
 	Egress   bool
+	Policies []EntityPolicy
 }
 
 type Engine struct {
@@ -34,10 +35,37 @@ func NewEngineWithCriticalLocalOnly(allowRawForwarding, criticalLocalOnly bool)
 	}
 }
 
-func (e *Engine) Decide(category risk.Category, hasHardBlock bool) Decision {
+// AllowsRawForwarding reports whether Low requests without triggered
+// entity policies may be forwarded unsanitized.
+func (e *Engine) AllowsRawForwarding() bool {
+	return e.allowRawForwarding
+}
+
+// Decide picks the route for a scored request. The strictest triggered
+// entity policy is applied on top of the score: block and force_local_only
+// override the band, escalate_one_band raises it, and any triggered policy
+// disables raw forwarding.
+func (e *Engine) Decide(category risk.Category, policies []EntityPolicy) Decision {
+	decision := e.decideCategory(category, StrictestAction(policies))
+	if len(policies) > 0 {
+		decision.Policies = append([]EntityPolicy(nil), policies...)
+	}
+	return decision
+}
+
+func (e *Engine) decideCategory(category risk.Category, action Action) Decision {
+	switch action {
+	case ActionBlock:
+		return Decision{Category: risk.CategoryCritical, Route: RouteCriticalBlocked, Egress: false}
+	case ActionForceLocalOnly:
+		return Decision{Category: risk.CategoryCritical, Route: RouteCriticalLocalOnly, Egress: false}
+	case ActionEscalateOneBand:
+		category = risk.Escalate(category)
+	}
+
 	switch category {
 	case risk.CategoryLow:
-		if e.allowRawForwarding && !hasHardBlock {
+		if e.allowRawForwarding && action == "" {
 			return Decision{Category: category, Route: RouteRawForward, Egress: true}
 		}
 		return Decision{Category: category, Route: RouteSanitizedForward, Egress: true}
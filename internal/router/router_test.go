@@ -11,7 +11,7 @@ func TestEngineDecide(t *testing.T) {
 		name           string
 		allowRaw       bool
 		category       risk.Category
-		hasHardBlock   bool
+		policies       []EntityPolicy
 		expectedRoute  Route
 		expectedEgress bool
 		expectedCat    risk.Category
@@ -20,7 +20,6 @@ func TestEngineDecide(t *testing.T) {
 			name:           "low with allow raw and no hard block",
 			allowRaw:       true,
 			category:       risk.CategoryLow,
-			hasHardBlock:   false,
 			expectedRoute:  RouteRawForward,
 			expectedEgress: true,
 			expectedCat:    risk.CategoryLow,
@@ -29,7 +28,7 @@ func TestEngineDecide(t *testing.T) {
 			name:           "low with hard block becomes sanitized",
 			allowRaw:       true,
 			category:       risk.CategoryLow,
-			hasHardBlock:   true,
+			policies:       []EntityPolicy{{EntityType: "SSN", Action: ActionMaskOnly}},
 			expectedRoute:  RouteSanitizedForward,
 			expectedEgress: true,
 			expectedCat:    risk.CategoryLow,
@@ -38,7 +37,6 @@ func TestEngineDecide(t *testing.T) {
 			name:           "medium sanitized",
 			allowRaw:       false,
 			category:       risk.CategoryMedium,
-			hasHardBlock:   false,
 			expectedRoute:  RouteSanitizedForward,
 			expectedEgress: true,
 			expectedCat:    risk.CategoryMedium,
@@ -47,7 +45,6 @@ func TestEngineDecide(t *testing.T) {
 			name:           "high abstraction allows remote egress after local abstraction",
 			allowRaw:       false,
 			category:       risk.CategoryHigh,
-			hasHardBlock:   false,
 			expectedRoute:  RouteHighAbstraction,
 			expectedEgress: true,
 			expectedCat:    risk.CategoryHigh,
@@ -56,7 +53,6 @@ func TestEngineDecide(t *testing.T) {
 			name:           "critical blocked by default",
 			allowRaw:       false,
 			category:       risk.CategoryCritical,
-			hasHardBlock:   false,
 			expectedRoute:  RouteCriticalBlocked,
 			expectedEgress: false,
 			expectedCat:    risk.CategoryCritical,
@@ -66,7 +62,7 @@ func TestEngineDecide(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			e := NewEngine(tc.allowRaw)
-			decision := e.Decide(tc.category, tc.hasHardBlock)
+			decision := e.Decide(tc.category, tc.policies)
 			if decision.Route != tc.expectedRoute {
 				t.Fatalf("expected route %s, got %s", tc.expectedRoute, decision.Route)
 			}
@@ -82,7 +78,7 @@ func TestEngineDecide(t *testing.T) {
 
 func TestEngineWithCriticalLocalOnly(t *testing.T) {
 	e := NewEngineWithCriticalLocalOnly(false, true)
-	decision := e.Decide(risk.CategoryCritical, false)
+	decision := e.Decide(risk.CategoryCritical, nil)
 
 	if decision.Route != RouteCriticalLocalOnly {
 		t.Fatalf("expected route %s, got %s", RouteCriticalLocalOnly, decision.Route)
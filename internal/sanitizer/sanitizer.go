@@ -5,6 +5,7 @@ import (
 	"regexp"
 	"sort"
 	"strconv"
+	"strings"
 )
 
 type Mapping struct {
@@ -12,38 +13,288 @@ type Mapping struct {
 	OriginalValue   string  `json:"original_value"`
 	EntityType      string  `json:"entity_type"`
 	ConfidenceScore float64 `json:"confidence_score"`
+	HardBlock       bool    `json:"hard_block,omitempty"`
 }
 
 type Result struct {
 	Sanitized string
+	Messages  []string
 	Mappings  []Mapping
 }
 
+// Rule detects one entity type. SurrogateTemplate must contain the
+// SurrogateCounter token exactly once; it is required for custom rules and
+// optional for built-ins, which keep their historical surrogate formats.
+//
+// When Validator is set, Confidence applies to matches that pass it and
+// UnvalidatedConfidence to matches that fail; a zero UnvalidatedConfidence
+// discards failing matches entirely. If Regex has a capture group named
+// "value", only that group is masked.
 type Rule struct {
-	EntityType string
-	Regex      *regexp.Regexp
-	Confidence float64
+	EntityType            string
+	Regex                 *regexp.Regexp
+	Confidence            float64
+	SurrogateTemplate     string
+	HardBlock             bool
+	Validator             Validator
+	UnvalidatedConfidence float64
 }
 
+const SurrogateCounter = "{n}"
+
 type match struct {
-	start int
-	end   int
-	value string
-	rule  Rule
+	start      int
+	end        int
+	value      string
+	rule       Rule
+	confidence float64
 }
 
 type Sanitizer struct {
-	rules []Rule
+	rules    []Rule
+	patterns map[string]*regexp.Regexp
+}
+
+type session struct {
+	rules                     []Rule
+	counts                    map[string]int
+	surrogateByEntityAndValue map[string]map[string]string
 }
 
+// Checksum-validated built-ins drop matches that fail validation. A 16-digit
+// order number is not a card, and keeping it as a low-confidence mapping
+// would escalate the request on confidence alone.
+var builtinRules = append([]Rule{
+	{EntityType: "EMAIL", Regex: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), Confidence: 0.99},
+	{EntityType: "PHONE", Regex: regexp.MustCompile(`\b\d{3}-\d{3}-\d{4}\b`), Confidence: 0.99},
+	{EntityType: "SSN", Regex: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), Confidence: 0.99, HardBlock: true},
+	{
+		EntityType:        "PAN",
+		Regex:             regexp.MustCompile(`\b\d{4}(?:[ -]?\d{4}){2}[ -]?\d{1,7}\b`),
+		Confidence:        0.99,
+		SurrogateTemplate: "card-{n}",
+		Validator:         ValidLuhn,
+	},
+	{
+		EntityType:        "IBAN",
+		Regex:             regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,3})?\b`),
+		Confidence:        0.99,
+		SurrogateTemplate: "iban-{n}",
+		Validator:         ValidIBAN,
+	},
+	{
+		EntityType:        "ABA_ROUTING",
+		Regex:             regexp.MustCompile(`\b\d{9}\b`),
+		Confidence:        0.90,
+		SurrogateTemplate: "routing-{n}",
+		Validator:         ValidABARouting,
+	},
+	{
+		EntityType:        "UK_NINO",
+		Regex:             regexp.MustCompile(`\b[A-CEGHJ-PR-TW-Z][A-CEGHJ-NPR-TW-Z] ?\d{2} ?\d{2} ?\d{2} ?[A-D]\b`),
+		Confidence:        0.95,
+		SurrogateTemplate: "nino-{n}",
+		Validator:         ValidNINO,
+	},
+	{
+		EntityType:        "AADHAAR",
+		Regex:             regexp.MustCompile(`\b[2-9]\d{3} ?\d{4} ?\d{4}\b`),
+		Confidence:        0.99,
+		SurrogateTemplate: "aadhaar-{n}",
+		Validator:         ValidVerhoeff,
+	},
+}, secretRules...)
+
+var (
+	entityTypePattern        = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+	surrogateTemplateCharset = regexp.MustCompile(`^[A-Za-z0-9._%+@-]*$`)
+)
+
+// NewDefault builds a sanitizer with every built-in rule, compiled through
+// New so that templated surrogates such as card-{n} resolve to their own
+// patterns.
 func NewDefault() *Sanitizer {
-	return &Sanitizer{
-		rules: []Rule{
-			{EntityType: "EMAIL", Regex: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), Confidence: 0.99},
-			{EntityType: "PHONE", Regex: regexp.MustCompile(`\b\d{3}-\d{3}-\d{4}\b`), Confidence: 0.99},
-			{EntityType: "SSN", Regex: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), Confidence: 0.99},
-		},
+	s, err := New(builtinRules...)
+	if err != nil {
+		panic(fmt.Sprintf("sanitizer: built-in rules are invalid: %v", err))
+	}
+	return s
+}
+
+func NewWithEntities(entityTypes ...string) (*Sanitizer, error) {
+	rules, err := BuiltinRules(entityTypes...)
+	if err != nil {
+		return nil, err
+	}
+	return New(rules...)
+}
+
+// New builds a sanitizer from an explicit rule set. Rules are validated so
+// that every entity type produces surrogates with a distinct, rehydratable
+// shape; overlapping detections are resolved longest-span-first regardless
+// of rule order.
+func New(rules ...Rule) (*Sanitizer, error) {
+	s := &Sanitizer{
+		rules:    make([]Rule, 0, len(rules)),
+		patterns: map[string]*regexp.Regexp{},
+	}
+	seen := map[string]bool{}
+	for _, rule := range rules {
+		if !entityTypePattern.MatchString(rule.EntityType) {
+			return nil, fmt.Errorf("invalid entity type %q: must match %s", rule.EntityType, entityTypePattern)
+		}
+		if seen[rule.EntityType] {
+			return nil, fmt.Errorf("duplicate entity type %q", rule.EntityType)
+		}
+		seen[rule.EntityType] = true
+
+		if rule.Regex == nil {
+			return nil, fmt.Errorf("rule %s: regex is required", rule.EntityType)
+		}
+		if rule.Regex.MatchString("") {
+			return nil, fmt.Errorf("rule %s: regex must not match the empty string", rule.EntityType)
+		}
+		if rule.Confidence <= 0 || rule.Confidence > 1 {
+			return nil, fmt.Errorf("rule %s: confidence must be > 0 and <= 1, got %v", rule.EntityType, rule.Confidence)
+		}
+		if rule.UnvalidatedConfidence != 0 {
+			if rule.Validator == nil {
+				return nil, fmt.Errorf("rule %s: unvalidated confidence requires a validator", rule.EntityType)
+			}
+			if rule.UnvalidatedConfidence < 0 || rule.UnvalidatedConfidence > rule.Confidence {
+				return nil, fmt.Errorf("rule %s: unvalidated confidence must be >= 0 and <= confidence, got %v", rule.EntityType, rule.UnvalidatedConfidence)
+			}
+		}
+
+		if rule.SurrogateTemplate == "" {
+			if _, ok := surrogatePatterns[rule.EntityType]; !ok {
+				return nil, fmt.Errorf("rule %s: surrogate template is required", rule.EntityType)
+			}
+		} else {
+			pattern, err := compileSurrogateTemplate(rule.SurrogateTemplate)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: %w", rule.EntityType, err)
+			}
+			s.patterns[rule.EntityType] = pattern
+		}
+		s.rules = append(s.rules, rule)
+	}
+
+	for _, rule := range s.rules {
+		sample := surrogateFor(rule, 1)
+		for _, other := range s.rules {
+			if other.EntityType != rule.EntityType && fullMatch(s.SurrogatePattern(other.EntityType), sample) {
+				return nil, fmt.Errorf("rule %s: surrogate %q is indistinguishable from %s surrogates", rule.EntityType, sample, other.EntityType)
+			}
+		}
+	}
+	return s, nil
+}
+
+// BuiltinRules returns the built-in rules for the requested entity types in
+// the order given. SecretFamily expands to every SECRET_* rule.
+func BuiltinRules(entityTypes ...string) ([]Rule, error) {
+	expanded := make([]string, 0, len(entityTypes))
+	for _, entityType := range entityTypes {
+		if entityType != SecretFamily {
+			expanded = append(expanded, entityType)
+			continue
+		}
+		for _, rule := range secretRules {
+			expanded = append(expanded, rule.EntityType)
+		}
+	}
+
+	rules := make([]Rule, 0, len(expanded))
+	seen := map[string]bool{}
+	for _, entityType := range expanded {
+		if seen[entityType] {
+			return nil, fmt.Errorf("duplicate built-in entity type %q", entityType)
+		}
+		seen[entityType] = true
+
+		found := false
+		for _, rule := range builtinRules {
+			if rule.EntityType == entityType {
+				rules = append(rules, rule)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown built-in entity type %q", entityType)
+		}
+	}
+	return rules, nil
+}
+
+func BuiltinEntityTypes() []string {
+	types := make([]string, 0, len(builtinRules))
+	for _, rule := range builtinRules {
+		types = append(types, rule.EntityType)
+	}
+	return types
+}
+
+var surrogatePatterns = map[string]*regexp.Regexp{
+	"EMAIL": regexp.MustCompile(`person\d+@example\.net`),
+	"PHONE": regexp.MustCompile(`555-010-\d{4}`),
+	"SSN":   regexp.MustCompile(`900-00-\d{4}`),
+}
+
+var defaultSurrogatePattern = regexp.MustCompile(`redacted-\d+`)
+
+// SurrogatePattern returns the shape of built-in surrogates for entityType.
+// Use (*Sanitizer).SurrogatePattern when custom rules may be configured.
+func SurrogatePattern(entityType string) *regexp.Regexp {
+	if pattern, ok := surrogatePatterns[entityType]; ok {
+		return pattern
+	}
+	return defaultSurrogatePattern
+}
+
+// EntityTypes lists the entity types this sanitizer detects, in rule order.
+func (s *Sanitizer) EntityTypes() []string {
+	types := make([]string, 0, len(s.rules))
+	for _, rule := range s.rules {
+		types = append(types, rule.EntityType)
+	}
+	return types
+}
+
+func (s *Sanitizer) SurrogatePattern(entityType string) *regexp.Regexp {
+	if pattern, ok := s.patterns[entityType]; ok {
+		return pattern
+	}
+	return SurrogatePattern(entityType)
+}
+
+func compileSurrogateTemplate(template string) (*regexp.Regexp, error) {
+	if strings.Count(template, SurrogateCounter) != 1 {
+		return nil, fmt.Errorf("surrogate template %q must contain %s exactly once", template, SurrogateCounter)
+	}
+	prefix, suffix, _ := strings.Cut(template, SurrogateCounter)
+	if !surrogateTemplateCharset.MatchString(prefix + suffix) {
+		return nil, fmt.Errorf("surrogate template %q may only contain letters, digits and ._%%+@-", template)
+	}
+	if !strings.ContainsFunc(prefix+suffix, func(r rune) bool {
+		return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+	}) {
+		return nil, fmt.Errorf("surrogate template %q must contain at least one letter", template)
 	}
+	return regexp.MustCompile(regexp.QuoteMeta(prefix) + `\d+` + regexp.QuoteMeta(suffix)), nil
+}
+
+func fullMatch(pattern *regexp.Regexp, value string) bool {
+	loc := pattern.FindStringIndex(value)
+	return loc != nil && loc[0] == 0 && loc[1] == len(value)
+}
+
+func surrogateFor(rule Rule, index int) string {
+	if rule.SurrogateTemplate != "" {
+		return strings.Replace(rule.SurrogateTemplate, SurrogateCounter, strconv.Itoa(index), 1)
+	}
+	return surrogateForEntity(rule.EntityType, index)
 }
 
 func surrogateForEntity(entityType string, index int) string {
@@ -61,16 +312,59 @@ func surrogateForEntity(entityType string, index int) string {
 }
 
 func (s *Sanitizer) Sanitize(input string) (Result, error) {
+	return s.newSession().sanitize(input)
+}
+
+func (s *Sanitizer) SanitizeConversation(messages []string) (Result, error) {
+	sess := s.newSession()
+	result := Result{
+		Messages: make([]string, 0, len(messages)),
+		Mappings: make([]Mapping, 0),
+	}
+	for i, message := range messages {
+		sanitized, err := sess.sanitize(message)
+		if err != nil {
+			return Result{}, fmt.Errorf("message %d: %w", i, err)
+		}
+		result.Messages = append(result.Messages, sanitized.Sanitized)
+		result.Mappings = append(result.Mappings, sanitized.Mappings...)
+	}
+	result.Sanitized = strings.Join(result.Messages, "\n")
+	return result, nil
+}
+
+func (s *Sanitizer) newSession() *session {
+	return &session{
+		rules:                     s.rules,
+		counts:                    map[string]int{},
+		surrogateByEntityAndValue: map[string]map[string]string{},
+	}
+}
+
+func (s *session) sanitize(input string) (Result, error) {
 	matches := make([]match, 0)
 
 	for _, rule := range s.rules {
-		indexes := rule.Regex.FindAllStringIndex(input, -1)
-		for _, idx := range indexes {
+		group := rule.Regex.SubexpIndex("value")
+		for _, submatches := range rule.Regex.FindAllStringSubmatchIndex(input, -1) {
+			idx := submatches[:2]
+			if group > 0 && submatches[2*group] >= 0 {
+				idx = submatches[2*group : 2*group+2]
+			}
+			value := input[idx[0]:idx[1]]
+			confidence := rule.Confidence
+			if rule.Validator != nil && !rule.Validator(value) {
+				confidence = rule.UnvalidatedConfidence
+				if confidence == 0 {
+					continue
+				}
+			}
 			matches = append(matches, match{
-				start: idx[0],
-				end:   idx[1],
-				value: input[idx[0]:idx[1]],
-				rule:  rule,
+				start:      idx[0],
+				end:        idx[1],
+				value:      value,
+				rule:       rule,
+				confidence: confidence,
 			})
 		}
 	}
@@ -85,7 +379,10 @@ func (s *Sanitizer) Sanitize(input string) (Result, error) {
 		if lenI != lenJ {
 			return lenI > lenJ
 		}
-		return matches[i].start < matches[j].start
+		if matches[i].start != matches[j].start {
+			return matches[i].start < matches[j].start
+		}
+		return matches[i].confidence > matches[j].confidence
 	})
 
 	accepted := make([]match, 0, len(matches))
@@ -111,8 +408,6 @@ func (s *Sanitizer) Sanitize(input string) (Result, error) {
 		return accepted[i].start < accepted[j].start
 	})
 
-	counts := map[string]int{}
-	surrogateByEntityAndValue := map[string]map[string]string{}
 	replacements := make([]struct {
 		start       int
 		end         int
@@ -121,15 +416,15 @@ func (s *Sanitizer) Sanitize(input string) (Result, error) {
 	}, 0, len(accepted))
 
 	for _, m := range accepted {
-		byValue, ok := surrogateByEntityAndValue[m.rule.EntityType]
+		byValue, ok := s.surrogateByEntityAndValue[m.rule.EntityType]
 		if !ok {
 			byValue = map[string]string{}
-			surrogateByEntityAndValue[m.rule.EntityType] = byValue
+			s.surrogateByEntityAndValue[m.rule.EntityType] = byValue
 		}
 		surrogate := byValue[m.value]
 		if surrogate == "" {
-			counts[m.rule.EntityType]++
-			surrogate = surrogateForEntity(m.rule.EntityType, counts[m.rule.EntityType])
+			s.counts[m.rule.EntityType]++
+			surrogate = surrogateFor(m.rule, s.counts[m.rule.EntityType])
 			byValue[m.value] = surrogate
 		}
 
@@ -146,7 +441,8 @@ func (s *Sanitizer) Sanitize(input string) (Result, error) {
 				Placeholder:     surrogate,
 				OriginalValue:   m.value,
 				EntityType:      m.rule.EntityType,
-				ConfidenceScore: m.rule.Confidence,
+				ConfidenceScore: m.confidence,
+				HardBlock:       m.rule.HardBlock,
 			},
 		})
 	}
@@ -1,6 +1,9 @@
 package sanitizer
 
-import "testing"
+import (
+	"regexp"
+	"testing"
+)
 
 func TestTVDET001MappingEmissionAndDeterminism(t *testing.T) {
 	s := NewDefault()
@@ -60,3 +63,217 @@ func indexOf(s, needle string) int {
 	}
 	return -1
 }
+
+func TestSanitizeConversationSharesMappingTableAcrossMessages(t *testing.T) {
+	s := NewDefault()
+
+	result, err := s.SanitizeConversation([]string{
+		"You are a support agent for alice@example.com",
+		"Noted, I will contact bob@example.com",
+		"Please email alice@example.com again",
+	})
+	if err != nil {
+		t.Fatalf("SanitizeConversation failed: %v", err)
+	}
+
+	expected := []string{
+		"You are a support agent for person1@example.net",
+		"Noted, I will contact person2@example.net",
+		"Please email person1@example.net again",
+	}
+	if len(result.Messages) != len(expected) {
+		t.Fatalf("expected %d messages, got %d", len(expected), len(result.Messages))
+	}
+	for i := range expected {
+		if result.Messages[i] != expected[i] {
+			t.Fatalf("message %d\nwant: %q\n got: %q", i, expected[i], result.Messages[i])
+		}
+	}
+	if len(result.Mappings) != 3 {
+		t.Fatalf("expected 3 mappings, got %d", len(result.Mappings))
+	}
+	if result.Sanitized != expected[0]+"\n"+expected[1]+"\n"+expected[2] {
+		t.Fatalf("unexpected joined sanitized text %q", result.Sanitized)
+	}
+}
+
+func TestNewWithEntitiesSelectsBuiltinRules(t *testing.T) {
+	s, err := NewWithEntities("EMAIL")
+	if err != nil {
+		t.Fatalf("NewWithEntities failed: %v", err)
+	}
+
+	result, err := s.Sanitize("alice@example.com 555-123-4567")
+	if err != nil {
+		t.Fatalf("sanitize failed: %v", err)
+	}
+	if result.Sanitized != "person1@example.net 555-123-4567" {
+		t.Fatalf("expected only email to be masked, got %q", result.Sanitized)
+	}
+
+	if _, err := NewWithEntities("PASSPORT"); err == nil {
+		t.Fatal("expected error for unknown built-in entity type")
+	}
+	if _, err := NewWithEntities("EMAIL", "EMAIL"); err == nil {
+		t.Fatal("expected error for duplicate entity type")
+	}
+}
+
+func customRules(t *testing.T) []Rule {
+	t.Helper()
+	rules, err := BuiltinRules(BuiltinEntityTypes()...)
+	if err != nil {
+		t.Fatalf("BuiltinRules failed: %v", err)
+	}
+	return append(rules,
+		Rule{EntityType: "EMPLOYEE_ID", Regex: regexp.MustCompile(`\bE\d{6}\b`), Confidence: 0.95, SurrogateTemplate: "EMP-{n}"},
+		Rule{EntityType: "ACCOUNT", Regex: regexp.MustCompile(`\bACCT-\d{4}-\d{4}-\d{4}\b`), Confidence: 0.9, SurrogateTemplate: "account-{n}-x", HardBlock: true},
+		Rule{EntityType: "CODENAME", Regex: regexp.MustCompile(`\bProject (?:Falcon|Heron)\b`), Confidence: 0.8, SurrogateTemplate: "codename{n}"},
+	)
+}
+
+func TestNewAppliesCustomRulesWithTemplates(t *testing.T) {
+	s, err := New(customRules(t)...)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	result, err := s.Sanitize("E123456 owns ACCT-1111-2222-3333 on Project Falcon; E654321 reviews, E123456 approves")
+	if err != nil {
+		t.Fatalf("sanitize failed: %v", err)
+	}
+
+	expected := "EMP-1 owns account-1-x on codename1; EMP-2 reviews, EMP-1 approves"
+	if result.Sanitized != expected {
+		t.Fatalf("unexpected sanitized output\nwant: %q\n got: %q", expected, result.Sanitized)
+	}
+	if len(result.Mappings) != 5 {
+		t.Fatalf("expected 5 mappings, got %d", len(result.Mappings))
+	}
+	for _, m := range result.Mappings {
+		if m.HardBlock != (m.EntityType == "ACCOUNT") {
+			t.Fatalf("unexpected hard block flag on %+v", m)
+		}
+		if !s.SurrogatePattern(m.EntityType).MatchString(m.Placeholder) {
+			t.Fatalf("surrogate %q does not match pattern for %s", m.Placeholder, m.EntityType)
+		}
+	}
+}
+
+func TestCustomRulesJoinLongestSpanFirstOverlapResolution(t *testing.T) {
+	rules := append(customRules(t),
+		// Shorter rule that overlaps the built-in SSN span; SSN must win.
+		Rule{EntityType: "TICKET", Regex: regexp.MustCompile(`\d{2}-\d{4}`), Confidence: 0.7, SurrogateTemplate: "TICKET-{n}"},
+		// Longer rule that swallows an embedded employee ID.
+		Rule{EntityType: "BADGE", Regex: regexp.MustCompile(`BADGE:E\d{6}`), Confidence: 0.9, SurrogateTemplate: "badge-{n}"},
+	)
+	s, err := New(rules...)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	result, err := s.Sanitize("ssn 123-45-6789 BADGE:E123456")
+	if err != nil {
+		t.Fatalf("sanitize failed: %v", err)
+	}
+	if result.Sanitized != "ssn 900-00-0001 badge-1" {
+		t.Fatalf("unexpected overlap resolution %q", result.Sanitized)
+	}
+	if result.Mappings[0].EntityType != "SSN" || !result.Mappings[0].HardBlock {
+		t.Fatalf("expected hard-blocked SSN mapping first, got %+v", result.Mappings[0])
+	}
+}
+
+func TestNewRejectsInvalidRules(t *testing.T) {
+	valid := Rule{EntityType: "EMPLOYEE_ID", Regex: regexp.MustCompile(`E\d{6}`), Confidence: 0.9, SurrogateTemplate: "EMP-{n}"}
+	tests := []struct {
+		name  string
+		rules []Rule
+	}{
+		{name: "lowercase entity type", rules: []Rule{{EntityType: "employee", Regex: valid.Regex, Confidence: 0.9, SurrogateTemplate: "EMP-{n}"}}},
+		{name: "duplicate entity type", rules: []Rule{valid, valid}},
+		{name: "missing regex", rules: []Rule{{EntityType: "X", Confidence: 0.9, SurrogateTemplate: "x-{n}"}}},
+		{name: "regex matches empty string", rules: []Rule{{EntityType: "X", Regex: regexp.MustCompile(`\d*`), Confidence: 0.9, SurrogateTemplate: "x-{n}"}}},
+		{name: "confidence out of range", rules: []Rule{{EntityType: "X", Regex: valid.Regex, Confidence: 1.2, SurrogateTemplate: "x-{n}"}}},
+		{name: "missing template", rules: []Rule{{EntityType: "X", Regex: valid.Regex, Confidence: 0.9}}},
+		{name: "template without counter", rules: []Rule{{EntityType: "X", Regex: valid.Regex, Confidence: 0.9, SurrogateTemplate: "x"}}},
+		{name: "template with two counters", rules: []Rule{{EntityType: "X", Regex: valid.Regex, Confidence: 0.9, SurrogateTemplate: "x{n}{n}"}}},
+		{name: "template with spaces", rules: []Rule{{EntityType: "X", Regex: valid.Regex, Confidence: 0.9, SurrogateTemplate: "x {n}"}}},
+		{name: "template without letters", rules: []Rule{{EntityType: "X", Regex: valid.Regex, Confidence: 0.9, SurrogateTemplate: "00-{n}"}}},
+		{name: "template collides with built-in", rules: append(customRules(t), Rule{EntityType: "X", Regex: valid.Regex, Confidence: 0.9, SurrogateTemplate: "person{n}@example.net"})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.rules...); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestChecksumDetectorsSetConfidenceFromValidation(t *testing.T) {
+	s := NewDefault()
+
+	tests := []struct {
+		name       string
+		input      string
+		entityType string
+		confidence float64
+		sanitized  string
+	}{
+		{name: "valid card", input: "card 4111 1111 1111 1111", entityType: "PAN", confidence: 0.99, sanitized: "card card-1"},
+		{name: "valid iban", input: "iban GB82 WEST 1234 5698 7654 32", entityType: "IBAN", confidence: 0.99, sanitized: "iban iban-1"},
+		{name: "valid routing number", input: "routing 021000021", entityType: "ABA_ROUTING", confidence: 0.90, sanitized: "routing routing-1"},
+		{name: "valid nino", input: "nino AB123456C", entityType: "UK_NINO", confidence: 0.95, sanitized: "nino nino-1"},
+		{name: "valid aadhaar", input: "aadhaar 2345 6789 0124", entityType: "AADHAAR", confidence: 0.99, sanitized: "aadhaar aadhaar-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := s.Sanitize(tt.input)
+			if err != nil {
+				t.Fatalf("sanitize failed: %v", err)
+			}
+			if result.Sanitized != tt.sanitized {
+				t.Fatalf("unexpected sanitized output\nwant: %q\n got: %q", tt.sanitized, result.Sanitized)
+			}
+			if len(result.Mappings) != 1 {
+				t.Fatalf("expected 1 mapping, got %+v", result.Mappings)
+			}
+			m := result.Mappings[0]
+			if m.EntityType != tt.entityType || m.ConfidenceScore != tt.confidence {
+				t.Fatalf("expected %s at %.2f, got %s at %.2f", tt.entityType, tt.confidence, m.EntityType, m.ConfidenceScore)
+			}
+		})
+	}
+}
+
+func TestChecksumDetectorsDropMatchesFailingValidation(t *testing.T) {
+	s := NewDefault()
+	for _, input := range []string{
+		"order 4111111111111112 shipped",
+		"iban GB83WEST12345698765432 closed",
+		"ticket 123456789 is open",
+		"aadhaar 2345 6789 0123 rejected",
+	} {
+		result, err := s.Sanitize(input)
+		if err != nil {
+			t.Fatalf("sanitize failed: %v", err)
+		}
+		if result.Sanitized != input || len(result.Mappings) != 0 {
+			t.Fatalf("expected checksum-invalid %q to pass through, got %q %+v", input, result.Sanitized, result.Mappings)
+		}
+	}
+}
+
+func TestNewRejectsUnvalidatedConfidenceWithoutValidator(t *testing.T) {
+	_, err := New(Rule{EntityType: "X", Regex: regexp.MustCompile(`x\d+`), Confidence: 0.9, SurrogateTemplate: "x-{n}", UnvalidatedConfidence: 0.5})
+	if err == nil {
+		t.Fatal("expected error for unvalidated confidence without validator")
+	}
+	_, err = New(Rule{EntityType: "X", Regex: regexp.MustCompile(`x\d+`), Confidence: 0.5, SurrogateTemplate: "x-{n}", Validator: ValidLuhn, UnvalidatedConfidence: 0.9})
+	if err == nil {
+		t.Fatal("expected error for unvalidated confidence above confidence")
+	}
+}
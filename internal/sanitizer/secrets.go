@@ -0,0 +1,118 @@
+package sanitizer
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+// SecretFamily selects every SECRET_* built-in rule when passed to
+// BuiltinRules or NewWithEntities.
+const SecretFamily = "SECRET"
+
+const minSecretEntropyBits = 4.0
+
+// secretRules detect credentials. They are hard-block by default so the
+// router never treats a request carrying one as eligible for raw
+// forwarding. Rules with a "value" capture group mask only that group, which
+// keeps variable names such as DB_PASSWORD visible to the model.
+var secretRules = []Rule{
+	{
+		EntityType:        "SECRET_AWS_ACCESS_KEY",
+		Regex:             regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[0-9A-Z]{16}\b`),
+		Confidence:        0.99,
+		SurrogateTemplate: "aws-akid-{n}",
+		HardBlock:         true,
+	},
+	{
+		EntityType:        "SECRET_AWS_SECRET_KEY",
+		Regex:             regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?(?P<value>[A-Za-z0-9/+=]{40})\b`),
+		Confidence:        0.99,
+		SurrogateTemplate: "aws-secret-{n}",
+		HardBlock:         true,
+	},
+	{
+		EntityType:        "SECRET_GITHUB_TOKEN",
+		Regex:             regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`),
+		Confidence:        0.99,
+		SurrogateTemplate: "gh-token-{n}",
+		HardBlock:         true,
+	},
+	{
+		EntityType:        "SECRET_JWT",
+		Regex:             regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{10,}`),
+		Confidence:        0.99,
+		SurrogateTemplate: "jwt-{n}",
+		HardBlock:         true,
+	},
+	{
+		EntityType:        "SECRET_PRIVATE_KEY",
+		Regex:             regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY-----[\s\S]+?-----END (?:[A-Z0-9]+ )*PRIVATE KEY-----`),
+		Confidence:        0.99,
+		SurrogateTemplate: "pem-key-{n}",
+		HardBlock:         true,
+	},
+	{
+		EntityType:        "SECRET_ENV_VALUE",
+		Regex:             regexp.MustCompile(`(?m)^[ \t]*(?:export[ \t]+)?[A-Za-z_][A-Za-z0-9_]*(?i:secret|token|passw(?:or)?d|pwd|api_?key|private_?key|access_?key|credentials?)[A-Za-z0-9_]*[ \t]*[=:][ \t]*["']?(?P<value>[^\s"']{6,})`),
+		Confidence:        0.90,
+		SurrogateTemplate: "env-value-{n}",
+		HardBlock:         true,
+	},
+	{
+		// Heuristic for unlabelled keys: long base64/hex-like tokens that
+		// mix letters and digits with high Shannon entropy.
+		EntityType:        "SECRET_HIGH_ENTROPY",
+		Regex:             regexp.MustCompile(`\b[A-Za-z0-9+/_-]{32,}={0,2}`),
+		Confidence:        0.80,
+		SurrogateTemplate: "high-entropy-{n}",
+		HardBlock:         true,
+		Validator:         highEntropySecret,
+	},
+}
+
+// SecretPatterns returns the labelled credential patterns (excluding the
+// entropy heuristic) for callers that redact rather than mask.
+func SecretPatterns() []*regexp.Regexp {
+	patterns := make([]*regexp.Regexp, 0, len(secretRules))
+	for _, rule := range secretRules {
+		if rule.Validator == nil {
+			patterns = append(patterns, rule.Regex)
+		}
+	}
+	return patterns
+}
+
+func IsSecretEntity(entityType string) bool {
+	return strings.HasPrefix(entityType, SecretFamily+"_")
+}
+
+func highEntropySecret(value string) bool {
+	hasLetter := strings.ContainsFunc(value, func(r rune) bool {
+		return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+	})
+	hasDigit := strings.ContainsFunc(value, func(r rune) bool {
+		return r >= '0' && r <= '9'
+	})
+	return hasLetter && hasDigit && shannonEntropy(value) >= minSecretEntropyBits
+}
+
+func shannonEntropy(value string) float64 {
+	if value == "" {
+		return 0
+	}
+	var counts [256]int
+	for i := 0; i < len(value); i++ {
+		counts[value[i]]++
+	}
+	entropy := 0.0
+	n := float64(len(value))
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
@@ -0,0 +1,165 @@
+package sanitizer
+
+import (
+	"sort"
+	"strings"
+)
+
+// Validator reports whether a regex match is structurally valid (for
+// example, its checksum holds). Validators see the raw matched text and
+// ignore separators themselves.
+type Validator func(value string) bool
+
+var validators = map[string]Validator{
+	"luhn":     ValidLuhn,
+	"mod97":    ValidIBAN,
+	"aba":      ValidABARouting,
+	"nino":     ValidNINO,
+	"verhoeff": ValidVerhoeff,
+}
+
+// LookupValidator returns a built-in validator by name so that custom rules
+// can reuse checksum logic from configuration.
+func LookupValidator(name string) (Validator, bool) {
+	v, ok := validators[name]
+	return v, ok
+}
+
+func ValidatorNames() []string {
+	names := make([]string, 0, len(validators))
+	for name := range validators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidLuhn checks payment card numbers (ISO/IEC 7812 mod-10).
+func ValidLuhn(value string) bool {
+	digits := digitsOf(value)
+	if len(digits) < 12 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// ValidIBAN checks the ISO 13616 mod-97 check digits.
+func ValidIBAN(value string) bool {
+	iban := strings.ToUpper(strings.ReplaceAll(value, " ", ""))
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+	rearranged := iban[4:] + iban[:4]
+	remainder := 0
+	for i := 0; i < len(rearranged); i++ {
+		c := rearranged[i]
+		switch {
+		case c >= '0' && c <= '9':
+			remainder = (remainder*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			remainder = (remainder*100 + int(c-'A') + 10) % 97
+		default:
+			return false
+		}
+	}
+	return remainder == 1
+}
+
+// ValidABARouting checks US ABA routing numbers: a valid Federal Reserve
+// prefix plus the 3-7-1 weighted checksum.
+func ValidABARouting(value string) bool {
+	digits := digitsOf(value)
+	if len(digits) != 9 {
+		return false
+	}
+	prefix := int(digits[0]-'0')*10 + int(digits[1]-'0')
+	switch {
+	case prefix <= 12, prefix >= 21 && prefix <= 32, prefix >= 61 && prefix <= 72, prefix == 80:
+	default:
+		return false
+	}
+	weights := [3]int{3, 7, 1}
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(digits[i]-'0') * weights[i%3]
+	}
+	return sum%10 == 0
+}
+
+var invalidNINOPrefixes = map[string]bool{
+	"BG": true, "GB": true, "KN": true, "NK": true, "NT": true, "TN": true, "ZZ": true,
+}
+
+// ValidNINO checks UK National Insurance numbers. NINOs carry no checksum,
+// so validation rejects the administratively unallocated prefixes.
+func ValidNINO(value string) bool {
+	nino := strings.ToUpper(strings.ReplaceAll(value, " ", ""))
+	if len(nino) != 9 {
+		return false
+	}
+	return !invalidNINOPrefixes[nino[:2]]
+}
+
+var (
+	verhoeffMultiplication = [10][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 2, 3, 4, 0, 6, 7, 8, 9, 5},
+		{2, 3, 4, 0, 1, 7, 8, 9, 5, 6},
+		{3, 4, 0, 1, 2, 8, 9, 5, 6, 7},
+		{4, 0, 1, 2, 3, 9, 5, 6, 7, 8},
+		{5, 9, 8, 7, 6, 0, 4, 3, 2, 1},
+		{6, 5, 9, 8, 7, 1, 0, 4, 3, 2},
+		{7, 6, 5, 9, 8, 2, 1, 0, 4, 3},
+		{8, 7, 6, 5, 9, 3, 2, 1, 0, 4},
+		{9, 8, 7, 6, 5, 4, 3, 2, 1, 0},
+	}
+	verhoeffPermutation = [8][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 5, 7, 6, 2, 8, 3, 0, 9, 4},
+		{5, 8, 0, 3, 7, 9, 6, 1, 4, 2},
+		{8, 9, 1, 6, 0, 4, 3, 5, 2, 7},
+		{9, 4, 5, 3, 1, 2, 6, 8, 7, 0},
+		{4, 2, 8, 6, 5, 7, 3, 9, 0, 1},
+		{2, 7, 9, 3, 8, 0, 6, 4, 1, 5},
+		{7, 0, 4, 6, 9, 1, 3, 2, 5, 8},
+	}
+)
+
+// ValidVerhoeff checks the Verhoeff check digit used by Indian Aadhaar
+// numbers.
+func ValidVerhoeff(value string) bool {
+	digits := digitsOf(value)
+	if len(digits) == 0 {
+		return false
+	}
+	c := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		c = verhoeffMultiplication[c][verhoeffPermutation[i%8][d]]
+	}
+	return c == 0
+}
+
+func digitsOf(value string) string {
+	var b strings.Builder
+	b.Grow(len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] >= '0' && value[i] <= '9' {
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
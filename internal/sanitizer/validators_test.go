@@ -0,0 +1,46 @@
+package sanitizer
+
+import "testing"
+
+func TestValidators(t *testing.T) {
+	tests := []struct {
+		name      string
+		validator Validator
+		value     string
+		want      bool
+	}{
+		{name: "luhn visa", validator: ValidLuhn, value: "4111 1111 1111 1111", want: true},
+		{name: "luhn amex", validator: ValidLuhn, value: "378282246310005", want: true},
+		{name: "luhn bad check digit", validator: ValidLuhn, value: "4111-1111-1111-1112", want: false},
+		{name: "luhn too short", validator: ValidLuhn, value: "0", want: false},
+		{name: "iban gb", validator: ValidIBAN, value: "GB82 WEST 1234 5698 7654 32", want: true},
+		{name: "iban de", validator: ValidIBAN, value: "DE89370400440532013000", want: true},
+		{name: "iban bad check digits", validator: ValidIBAN, value: "GB83WEST12345698765432", want: false},
+		{name: "aba valid", validator: ValidABARouting, value: "021000021", want: true},
+		{name: "aba bad checksum", validator: ValidABARouting, value: "021000022", want: false},
+		{name: "aba bad prefix", validator: ValidABARouting, value: "500000005", want: false},
+		{name: "nino valid", validator: ValidNINO, value: "AB 12 34 56 C", want: true},
+		{name: "nino unallocated prefix", validator: ValidNINO, value: "GB123456A", want: false},
+		{name: "verhoeff valid", validator: ValidVerhoeff, value: "2345 6789 0124", want: true},
+		{name: "verhoeff invalid", validator: ValidVerhoeff, value: "2345 6789 0123", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.validator(tt.value); got != tt.want {
+				t.Fatalf("validator(%q) = %t, want %t", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupValidator(t *testing.T) {
+	for _, name := range ValidatorNames() {
+		if _, ok := LookupValidator(name); !ok {
+			t.Fatalf("expected validator %q to resolve", name)
+		}
+	}
+	if _, ok := LookupValidator("crc32"); ok {
+		t.Fatal("expected unknown validator lookup to fail")
+	}
+}
@@ -0,0 +1,172 @@
+package integration_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/soloengine/lpg/internal/proxy"
+	"github.com/soloengine/lpg/internal/risk"
+	"github.com/soloengine/lpg/internal/router"
+	"github.com/soloengine/lpg/internal/sanitizer"
+)
+
+// embeddingsRecorder returns one fixed vector per input and keeps the last
+// request it saw.
+type embeddingsRecorder struct {
+	calls int
+	last  proxy.EmbedRequest
+}
+
+func (e *embeddingsRecorder) Embeddings(ctx context.Context, req proxy.EmbedRequest) (proxy.EmbedResponse, error) {
+	e.calls++
+	e.last = req
+	out := make([][]float64, len(req.Input))
+	for i := range out {
+		out[i] = []float64{float64(i), 1}
+	}
+	return proxy.EmbedResponse{Embeddings: out}, nil
+}
+
+// embeddingProvider is a chain provider that can also embed.
+type embeddingProvider struct {
+	proxy.StubUpstream
+	*embeddingsRecorder
+}
+
+func (p embeddingProvider) Embeddings(ctx context.Context, req proxy.EmbedRequest) (proxy.EmbedResponse, error) {
+	return p.embeddingsRecorder.Embeddings(ctx, req)
+}
+
+func newEmbeddingsHandler(remote *embeddingsRecorder, local proxy.EmbeddingsAdapter) *proxy.Handler {
+	return proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer:       sanitizer.NewDefault(),
+		Scorer:          risk.NewScorer(0.70),
+		Router:          router.NewEngine(false),
+		Upstream:        embeddingProvider{embeddingsRecorder: remote},
+		LocalEmbeddings: local,
+	})
+}
+
+func postEmbeddings(h *proxy.Handler, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.HandleEmbeddings(rec, httptest.NewRequest(http.MethodPost, "/v1/embeddings", bytes.NewBufferString(body)))
+	return rec
+}
+
+func TestPRD66EmbeddingsSendsOnlySanitizedTextToRemoteProvider(t *testing.T) {
+	remote, local := &embeddingsRecorder{}, &embeddingsRecorder{}
+	h := newEmbeddingsHandler(remote, local)
+
+	rec := postEmbeddings(h, `{"model":"text-embedding-test","input":["Contact alice@example.com","plain text"]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if remote.calls != 1 || local.calls != 0 {
+		t.Fatalf("expected one remote call and no local call, got remote=%d local=%d", remote.calls, local.calls)
+	}
+	if len(remote.last.Input) != 2 || strings.Contains(remote.last.Input[0], "alice@example.com") || remote.last.Input[1] != "plain text" {
+		t.Fatalf("expected sanitized inputs in order, got %q", remote.last.Input)
+	}
+	if remote.last.Route != router.RouteSanitizedForward {
+		t.Fatalf("expected sanitized_forward, got %s", remote.last.Route)
+	}
+
+	var resp proxy.EmbeddingsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if resp.Object != "list" || resp.Model != "text-embedding-test" || len(resp.Data) != 2 || resp.Data[1].Index != 1 || resp.Data[1].Embedding[0] != 1 {
+		t.Fatalf("unexpected embeddings response %+v", resp)
+	}
+}
+
+func TestPRD66EmbeddingsHighRiskInputStaysLocal(t *testing.T) {
+	const body = `{"model":"text-embedding-test","input":["hello","SSN 123-45-6789 and card 4111 1111 1111 1111"]}`
+
+	remote := &embeddingsRecorder{}
+	rec := postEmbeddings(newEmbeddingsHandler(remote, nil), body)
+	var payload prd66ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to parse error response: %v", err)
+	}
+	if rec.Code != http.StatusForbidden || payload.Error.Code != "ERR_POLICY_BLOCK" {
+		t.Fatalf("expected 403 ERR_POLICY_BLOCK without a local model, got %d %+v", rec.Code, payload.Error)
+	}
+	if remote.calls != 0 {
+		t.Fatal("expected high-risk batch not to reach the remote provider")
+	}
+
+	local := &embeddingsRecorder{}
+	rec = postEmbeddings(newEmbeddingsHandler(remote, local), body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if remote.calls != 0 || local.calls != 1 {
+		t.Fatalf("expected the whole batch to embed locally, got remote=%d local=%d", remote.calls, local.calls)
+	}
+	if strings.Contains(local.last.Input[1], "123-45-6789") {
+		t.Fatalf("expected local model to embed sanitized text, got %q", local.last.Input[1])
+	}
+}
+
+func TestPRD66EmbeddingsCriticalInputIsBlocked(t *testing.T) {
+	remote, local := &embeddingsRecorder{}, &embeddingsRecorder{}
+	h := newEmbeddingsHandler(remote, local)
+
+	rec := postEmbeddings(h, `{"model":"text-embedding-test","input":"SSN 123-45-6789, card 4111 1111 1111 1111, mail bob@example.com, phone 415-555-0100"}`)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusForbidden, rec.Code, rec.Body.String())
+	}
+	if remote.calls != 0 || local.calls != 0 {
+		t.Fatalf("expected no embedding call, got remote=%d local=%d", remote.calls, local.calls)
+	}
+}
+
+func TestPRD66EmbeddingsRejectsTokenArraysAndUnsupportedFields(t *testing.T) {
+	h := newEmbeddingsHandler(&embeddingsRecorder{}, nil)
+
+	cases := []struct {
+		body string
+		code string
+	}{
+		{`{"model":"text-embedding-test","input":[[1,2,3]]}`, "ERR_VALIDATION"},
+		{`{"model":"text-embedding-test","input":"hi","encoding_format":"base64"}`, "ERR_VALIDATION"},
+		{`{"model":"text-embedding-test","input":"hi","stream":true}`, "ERR_UNSUPPORTED_FIELD"},
+	}
+	for _, tc := range cases {
+		rec := postEmbeddings(h, tc.body)
+		var payload prd66ErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+			t.Fatalf("failed to parse error response: %v", err)
+		}
+		if rec.Code != http.StatusBadRequest || payload.Error.Code != tc.code {
+			t.Fatalf("%s: expected 400 %s, got %d %+v", tc.body, tc.code, rec.Code, payload.Error)
+		}
+	}
+}
+
+func TestPRD66EmbeddingsUseTheRouteFilteredProviderChain(t *testing.T) {
+	rawOnly, remote := &embeddingsRecorder{}, &embeddingsRecorder{}
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer: sanitizer.NewDefault(),
+		Scorer:    risk.NewScorer(0.70),
+		Router:    router.NewEngine(false),
+		Providers: []proxy.Provider{
+			{Name: "raw-only", Adapter: embeddingProvider{embeddingsRecorder: rawOnly}, Routes: []router.Route{router.RouteRawForward}},
+			{Name: "remote", Adapter: embeddingProvider{embeddingsRecorder: remote}},
+		},
+	})
+
+	rec := postEmbeddings(h, `{"model":"text-embedding-test","input":"Contact alice@example.com"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if rawOnly.calls != 0 || remote.calls != 1 {
+		t.Fatalf("expected only the provider serving sanitized_forward to embed, got raw-only=%d remote=%d", rawOnly.calls, remote.calls)
+	}
+}
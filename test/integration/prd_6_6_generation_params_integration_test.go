@@ -0,0 +1,149 @@
+package integration_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/soloengine/lpg/internal/proxy"
+	"github.com/soloengine/lpg/internal/risk"
+	"github.com/soloengine/lpg/internal/router"
+	"github.com/soloengine/lpg/internal/sanitizer"
+)
+
+type paramsCapturingUpstream struct {
+	last proxy.ForwardRequest
+}
+
+func (u *paramsCapturingUpstream) ChatCompletions(ctx context.Context, req proxy.ForwardRequest) (proxy.ForwardResponse, error) {
+	u.last = req
+	choices := make([]proxy.ForwardChoice, 0)
+	if req.Params.N != nil {
+		for i := 0; i < *req.Params.N; i++ {
+			choices = append(choices, proxy.ForwardChoice{Content: "reply to " + req.Messages[0].Content})
+		}
+	}
+	if len(choices) > 1 {
+		return proxy.ForwardResponse{Content: choices[0].Content, Choices: choices}, nil
+	}
+	return proxy.ForwardResponse{Content: "reply to " + req.Messages[0].Content}, nil
+}
+
+func TestPRD66UnsupportedFieldReturnsCompatibilityError(t *testing.T) {
+	upstream := &paramsCapturingUpstream{}
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer: sanitizer.NewDefault(),
+		Scorer:    risk.NewScorer(0.70),
+		Router:    router.NewEngine(false),
+		Upstream:  upstream,
+	})
+
+	body := `{"model":"gpt-test","messages":[{"role":"user","content":"hello"}],"logprobs":true}`
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body)))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	var payload prd66ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to parse error response: %v", err)
+	}
+	if payload.Error.Code != "ERR_UNSUPPORTED_FIELD" || payload.Error.Message != `unsupported field "logprobs"` {
+		t.Fatalf("unexpected compatibility error %+v", payload.Error)
+	}
+	if upstream.last.RequestID != "" {
+		t.Fatal("expected request with unsupported field not to reach the provider")
+	}
+}
+
+func TestPRD66GenerationParamsForwardedAndNMapsToChoices(t *testing.T) {
+	upstream := &paramsCapturingUpstream{}
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer: sanitizer.NewDefault(),
+		Scorer:    risk.NewScorer(0.70),
+		Router:    router.NewEngine(false),
+		Upstream:  upstream,
+	})
+
+	body := `{"model":"gpt-test","messages":[{"role":"user","content":"email alice@example.com"}],"temperature":0.3,"max_tokens":50,"seed":11,"n":3,"user":"alice@example.com"}`
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	params := upstream.last.Params
+	if params.Temperature == nil || *params.Temperature != 0.3 || params.MaxTokens == nil || *params.MaxTokens != 50 || params.Seed == nil || *params.Seed != 11 {
+		t.Fatalf("expected generation params to be forwarded, got %+v", params)
+	}
+	if strings.Contains(params.User, "alice") {
+		t.Fatalf("expected user to be pseudonymized before egress, got %q", params.User)
+	}
+
+	var resp proxy.ChatCompletionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if len(resp.Choices) != 3 {
+		t.Fatalf("expected 3 choices, got %d", len(resp.Choices))
+	}
+	for i, choice := range resp.Choices {
+		if choice.Index != i {
+			t.Fatalf("expected choice index %d, got %d", i, choice.Index)
+		}
+		if choice.Message.Content != "reply to email alice@example.com" {
+			t.Fatalf("expected each choice to be rehydrated, got %q", choice.Message.Content)
+		}
+	}
+}
+
+func TestPRD66StreamWithMultipleChoicesIsRejected(t *testing.T) {
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer: sanitizer.NewDefault(),
+		Scorer:    risk.NewScorer(0.70),
+		Router:    router.NewEngine(false),
+		Upstream:  &paramsCapturingUpstream{},
+	})
+
+	body := `{"model":"gpt-test","messages":[{"role":"user","content":"hello"}],"stream":true,"n":2}`
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body)))
+
+	var payload prd66ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to parse error response: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest || payload.Error.Code != "ERR_UNSUPPORTED_FIELD" {
+		t.Fatalf("expected 400 ERR_UNSUPPORTED_FIELD, got %d %+v", rec.Code, payload.Error)
+	}
+}
+
+func TestPRD66StopSequencesAreSanitizedWithSharedMappings(t *testing.T) {
+	upstream := &paramsCapturingUpstream{}
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer: sanitizer.NewDefault(),
+		Scorer:    risk.NewScorerWithModel(0.70, risk.Model{DefaultWeight: risk.DefaultEntityWeight}),
+		Router:    router.NewEngine(false),
+		Upstream:  upstream,
+	})
+
+	body := `{"model":"gpt-test","messages":[{"role":"user","content":"email alice@example.com"}],"stop":["END","alice@example.com"]}`
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if upstream.last.Route != router.RouteSanitizedForward {
+		t.Fatalf("expected sanitized_forward, got %s", upstream.last.Route)
+	}
+	surrogate := strings.TrimPrefix(upstream.last.Messages[0].Content, "email ")
+	if stop := upstream.last.Params.Stop; len(stop) != 2 || stop[0] != "END" || stop[1] != surrogate {
+		t.Fatalf("expected the message's surrogate in place of the stop sequence, got %q", stop)
+	}
+}
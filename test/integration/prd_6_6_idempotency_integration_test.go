@@ -0,0 +1,197 @@
+package integration_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/soloengine/lpg/internal/proxy"
+	"github.com/soloengine/lpg/internal/risk"
+	"github.com/soloengine/lpg/internal/router"
+	"github.com/soloengine/lpg/internal/sanitizer"
+)
+
+// echoUpstream echoes the last message back after an optional delay, so
+// rehydration is observable on the reply.
+type echoUpstream struct {
+	calls atomic.Int32
+	delay time.Duration
+}
+
+func (u *echoUpstream) ChatCompletions(ctx context.Context, req proxy.ForwardRequest) (proxy.ForwardResponse, error) {
+	u.calls.Add(1)
+	if u.delay > 0 {
+		time.Sleep(u.delay)
+	}
+	return proxy.ForwardResponse{Content: "reply to " + lastContent(req)}, nil
+}
+
+func newIdempotencyHandler(t *testing.T, upstream proxy.UpstreamAdapter) *proxy.Handler {
+	t.Helper()
+	cache, err := proxy.NewIdempotencyCache(time.Minute, 16)
+	if err != nil {
+		t.Fatalf("NewIdempotencyCache failed: %v", err)
+	}
+	return proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer:   sanitizer.NewDefault(),
+		Scorer:      risk.NewScorer(0.70),
+		Router:      router.NewEngine(false),
+		Upstream:    upstream,
+		Idempotency: cache,
+	})
+}
+
+func postIdempotent(h *proxy.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body))
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, req)
+	return rec
+}
+
+func chatReply(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var resp proxy.ChatCompletionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	return resp.Choices[0].Message.Content
+}
+
+const idempotentBody = `{"model":"gpt-test","messages":[{"role":"user","content":"Write to alice@example.com"}]}`
+
+func TestPRD66IdempotencyReplayIsServedFromCache(t *testing.T) {
+	upstream := &echoUpstream{}
+	h := newIdempotencyHandler(t, upstream)
+
+	first := postIdempotent(h, "order-1", idempotentBody)
+	// Same request, different formatting.
+	replay := postIdempotent(h, "order-1", `{"messages":[{"content":"Write to alice@example.com","role":"user"}], "model":"gpt-test"}`)
+
+	if first.Code != http.StatusOK || replay.Code != http.StatusOK {
+		t.Fatalf("expected both requests to succeed, got %d and %d: %s", first.Code, replay.Code, replay.Body.String())
+	}
+	if got := upstream.calls.Load(); got != 1 {
+		t.Fatalf("expected the replay not to reach the provider, got %d calls", got)
+	}
+	if got, want := chatReply(t, replay), chatReply(t, first); got != want || !strings.Contains(got, "alice@example.com") {
+		t.Fatalf("expected the replay to match the rehydrated original %q, got %q", want, got)
+	}
+	if replay.Header().Get("x-lpg-idempotent-replay") != "true" || first.Header().Get("x-lpg-idempotent-replay") != "" {
+		t.Fatal("expected only the replay to carry x-lpg-idempotent-replay")
+	}
+	if replay.Header().Get("x-lpg-route") != string(router.RouteSanitizedForward) {
+		t.Fatalf("expected routing headers on the replay, got %q", replay.Header().Get("x-lpg-route"))
+	}
+}
+
+func TestPRD66IdempotencyKeyReusedWithDifferentBodyIsRejected(t *testing.T) {
+	upstream := &echoUpstream{}
+	h := newIdempotencyHandler(t, upstream)
+
+	if rec := postIdempotent(h, "order-2", idempotentBody); rec.Code != http.StatusOK {
+		t.Fatalf("expected first request to succeed, got %d", rec.Code)
+	}
+	rec := postIdempotent(h, "order-2", `{"model":"gpt-test","messages":[{"role":"user","content":"Write to bob@example.com"}]}`)
+
+	var payload prd66ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to parse error response: %v", err)
+	}
+	if rec.Code != http.StatusUnprocessableEntity || payload.Error.Code != "ERR_IDEMPOTENCY_KEY_MISMATCH" {
+		t.Fatalf("expected 422 ERR_IDEMPOTENCY_KEY_MISMATCH, got %d %+v", rec.Code, payload.Error)
+	}
+	if got := upstream.calls.Load(); got != 1 {
+		t.Fatalf("expected the mismatched request not to reach the provider, got %d calls", got)
+	}
+}
+
+func TestPRD66IdempotencyConcurrentDuplicateWaitsForFirst(t *testing.T) {
+	upstream := &echoUpstream{delay: 50 * time.Millisecond}
+	h := newIdempotencyHandler(t, upstream)
+
+	recs := make([]*httptest.ResponseRecorder, 3)
+	var wg sync.WaitGroup
+	for i := range recs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recs[i] = postIdempotent(h, "order-3", idempotentBody)
+		}()
+	}
+	wg.Wait()
+
+	for i, rec := range recs {
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected status %d, got %d: %s", i, http.StatusOK, rec.Code, rec.Body.String())
+		}
+	}
+	if got := upstream.calls.Load(); got != 1 {
+		t.Fatalf("expected concurrent duplicates to share one provider call, got %d", got)
+	}
+}
+
+func TestPRD66IdempotencyRequestWithoutKeyBypassesCache(t *testing.T) {
+	upstream := &echoUpstream{}
+	h := newIdempotencyHandler(t, upstream)
+
+	postIdempotent(h, "order-4", idempotentBody)
+	postIdempotent(h, "", idempotentBody)
+
+	if got := upstream.calls.Load(); got != 2 {
+		t.Fatalf("expected a request without a key to bypass the cache, got %d calls", got)
+	}
+}
+
+func TestPRD66IdempotencyKeysAreScopedToTheCaller(t *testing.T) {
+	upstream := &echoUpstream{}
+	h := newIdempotencyHandler(t, upstream)
+
+	for _, token := range []string{"Bearer team-a", "Bearer team-b"} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(idempotentBody))
+		req.Header.Set("Idempotency-Key", "order-5")
+		req.Header.Set("Authorization", token)
+		rec := httptest.NewRecorder()
+		h.HandleChatCompletions(rec, req)
+		if rec.Code != http.StatusOK || rec.Header().Get("x-lpg-idempotent-replay") != "" {
+			t.Fatalf("expected a fresh response for %q, got %d replay=%q", token, rec.Code, rec.Header().Get("x-lpg-idempotent-replay"))
+		}
+	}
+	if got := upstream.calls.Load(); got != 2 {
+		t.Fatalf("expected each caller to reach the provider, got %d calls", got)
+	}
+}
+
+func TestPRD66IdempotencyWaiterThatDisconnectsIsCancelled(t *testing.T) {
+	upstream := &echoUpstream{delay: 200 * time.Millisecond}
+	h := newIdempotencyHandler(t, upstream)
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() { first <- postIdempotent(h, "order-6", idempotentBody) }()
+	for upstream.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(idempotentBody)).WithContext(ctx)
+	req.Header.Set("Idempotency-Key", "order-6")
+	rec := httptest.NewRecorder()
+	time.AfterFunc(20*time.Millisecond, cancel)
+	h.HandleChatCompletions(rec, req)
+
+	if rec.Code != 499 || !strings.Contains(rec.Body.String(), "ERR_REQUEST_CANCELLED") {
+		t.Fatalf("expected the disconnected waiter to be cancelled, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := <-first; got.Code != http.StatusOK {
+		t.Fatalf("expected the first request to finish, got %d", got.Code)
+	}
+}
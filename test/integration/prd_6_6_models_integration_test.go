@@ -0,0 +1,149 @@
+package integration_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/soloengine/lpg/internal/proxy"
+	"github.com/soloengine/lpg/internal/router"
+	"github.com/soloengine/lpg/internal/sanitizer"
+)
+
+// listingUpstream is a remote provider that reports a fixed model list.
+type listingUpstream struct {
+	models []string
+	err    error
+}
+
+func (u listingUpstream) ChatCompletions(ctx context.Context, req proxy.ForwardRequest) (proxy.ForwardResponse, error) {
+	return proxy.ForwardResponse{Content: "ok"}, nil
+}
+
+func (u listingUpstream) ListModels(ctx context.Context) ([]string, error) {
+	return u.models, u.err
+}
+
+// countingSanitizer records how often sanitization ran.
+type countingSanitizer struct {
+	calls int
+}
+
+func (s *countingSanitizer) SanitizeConversation(messages []string) (sanitizer.Result, error) {
+	s.calls++
+	return sanitizer.NewDefault().SanitizeConversation(messages)
+}
+
+func getModels(t *testing.T, h *proxy.Handler) proxy.ModelsResponse {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.HandleModels(rec, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var resp proxy.ModelsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse models response: %v", err)
+	}
+	return resp
+}
+
+func TestPRD66ModelsAggregatesProvidersWithRouteAnnotations(t *testing.T) {
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Router: router.NewEngineWithCriticalLocalOnly(false, true),
+		Providers: []proxy.Provider{
+			{Name: "remote", Adapter: listingUpstream{models: []string{"gpt-test", "stub"}}, Routes: []router.Route{router.RouteSanitizedForward}},
+			{Name: "broken", Adapter: listingUpstream{err: errors.New("down")}},
+			{Name: "local", Adapter: proxy.StubUpstream{}, Local: true},
+		},
+	})
+
+	resp := getModels(t, h)
+	if resp.Object != "list" || len(resp.Data) != 2 {
+		t.Fatalf("expected a list of two models, got %+v", resp)
+	}
+
+	remote := resp.Data[0]
+	if remote.ID != "gpt-test" || remote.Object != "model" || remote.OwnedBy != "remote" || !remote.Egress {
+		t.Fatalf("unexpected remote model %+v", remote)
+	}
+	if !reflect.DeepEqual(remote.Routes, []router.Route{router.RouteSanitizedForward}) {
+		t.Fatalf("expected remote model to be limited to sanitized_forward, got %v", remote.Routes)
+	}
+
+	shared := resp.Data[1]
+	if shared.ID != "stub" || !reflect.DeepEqual(shared.Providers, []string{"remote", "local"}) || !shared.Egress {
+		t.Fatalf("expected stub to merge both providers, got %+v", shared)
+	}
+	want := []router.Route{router.RouteSanitizedForward, router.RouteHighAbstraction, router.RouteCriticalLocalOnly}
+	if !reflect.DeepEqual(shared.Routes, want) {
+		t.Fatalf("expected merged routes %v, got %v", want, shared.Routes)
+	}
+}
+
+func TestPRD66ModelsIncludesLocalAbstractorWithoutEgress(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatalf("expected the abstractor to report its configured model without a request, got %s", r.URL.Path)
+	}))
+	defer srv.Close()
+
+	abstractor, err := proxy.NewOpenAICompatibleAbstractor(proxy.OpenAICompatibleConfig{BaseURL: srv.URL, Model: "qwen2.5:3b"})
+	if err != nil {
+		t.Fatalf("NewOpenAICompatibleAbstractor failed: %v", err)
+	}
+	h := proxy.NewHandler(proxy.HandlerConfig{Abstractor: abstractor})
+
+	resp := getModels(t, h)
+	if len(resp.Data) != 1 {
+		t.Fatalf("expected only the abstractor model, got %+v", resp.Data)
+	}
+	if got := resp.Data[0]; got.ID != "qwen2.5:3b" || got.OwnedBy != "local_abstraction" || got.Egress {
+		t.Fatalf("unexpected abstractor model %+v", got)
+	}
+}
+
+func TestPRD66ModelAllowlistFiltersCatalogueAndRejectsBeforeSanitization(t *testing.T) {
+	spy := &countingSanitizer{}
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer:      spy,
+		Providers:      []proxy.Provider{{Name: "remote", Adapter: listingUpstream{models: []string{"gpt-test", "gpt-other"}}}},
+		ModelAllowlist: []string{"gpt-test", "gpt-unlisted"},
+	})
+
+	resp := getModels(t, h)
+	if len(resp.Data) != 2 || resp.Data[0].ID != "gpt-test" || resp.Data[1].ID != "gpt-unlisted" {
+		t.Fatalf("expected exactly the allowlist, got %+v", resp.Data)
+	}
+	if !reflect.DeepEqual(resp.Data[1].Providers, []string{"remote"}) {
+		t.Fatalf("expected an unlisted allowlisted model to be annotated with the chain, got %+v", resp.Data[1])
+	}
+
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(`{"model":"gpt-other","messages":[{"role":"user","content":"mail alice@example.com"}]}`)))
+	var payload prd66ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to parse error response: %v", err)
+	}
+	if rec.Code != http.StatusNotFound || payload.Error.Code != "ERR_MODEL_NOT_FOUND" {
+		t.Fatalf("expected 404 ERR_MODEL_NOT_FOUND, got %d %+v", rec.Code, payload.Error)
+	}
+
+	rec = postEmbeddings(h, `{"model":"gpt-other","input":"hello"}`)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected embeddings to enforce the allowlist, got %d", rec.Code)
+	}
+	if spy.calls != 0 {
+		t.Fatalf("expected no sanitization for rejected models, got %d calls", spy.calls)
+	}
+
+	rec = httptest.NewRecorder()
+	h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(`{"model":"gpt-test","messages":[{"role":"user","content":"hello"}]}`)))
+	if rec.Code != http.StatusOK || spy.calls != 1 {
+		t.Fatalf("expected allowlisted model to be served, got %d (sanitizer calls %d)", rec.Code, spy.calls)
+	}
+}
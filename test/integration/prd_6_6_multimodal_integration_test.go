@@ -0,0 +1,145 @@
+package integration_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/soloengine/lpg/internal/proxy"
+	"github.com/soloengine/lpg/internal/risk"
+	"github.com/soloengine/lpg/internal/router"
+	"github.com/soloengine/lpg/internal/sanitizer"
+)
+
+type multimodalUpstream struct {
+	calls int
+	last  proxy.ForwardRequest
+}
+
+func (u *multimodalUpstream) ChatCompletions(ctx context.Context, req proxy.ForwardRequest) (proxy.ForwardResponse, error) {
+	u.calls++
+	u.last = req
+	return proxy.ForwardResponse{Content: "described"}, nil
+}
+
+func newMultimodalHandler(upstream proxy.UpstreamAdapter, policies proxy.ImagePolicies) *proxy.Handler {
+	// Counting each distinct value once keeps a repeated address medium.
+	return proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer:     sanitizer.NewDefault(),
+		Scorer:        risk.NewScorerWithModel(0.70, risk.Model{DefaultWeight: risk.DefaultEntityWeight}),
+		Router:        router.NewEngine(false),
+		Upstream:      upstream,
+		Abstractor:    proxy.PassthroughAbstractor{},
+		ImagePolicies: policies,
+	})
+}
+
+func postMultimodal(h *proxy.Handler, path, content string) *httptest.ResponseRecorder {
+	body := `{"model":"gpt-test","messages":[{"role":"user","content":` + content + `}]}`
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+	if path == "/v1/debug/explain" {
+		h.HandleDebugExplain(rec, req)
+	} else {
+		h.HandleChatCompletions(rec, req)
+	}
+	return rec
+}
+
+const imagePart = `{"type":"image_url","image_url":{"url":"https://img.example/receipt.png"}}`
+
+func TestPRD66TextPartsAreSanitizedAndImagesForwardedWhenAllowed(t *testing.T) {
+	upstream := &multimodalUpstream{}
+	h := newMultimodalHandler(upstream, proxy.ImagePolicies{router.RouteSanitizedForward: proxy.ImagePolicyAllow})
+
+	rec := postMultimodal(h, "/v1/chat/completions", `[{"type":"text","text":"Receipt for alice@example.com"},`+imagePart+`,{"type":"text","text":"Reply to alice@example.com"}]`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	parts := upstream.last.Messages[0].Parts
+	if len(parts) != 3 || parts[1].Type != "image_url" || parts[1].ImageURL.URL != "https://img.example/receipt.png" {
+		t.Fatalf("expected parts to keep their order with the image forwarded, got %+v", parts)
+	}
+	for _, part := range []proxy.ContentPart{parts[0], parts[2]} {
+		if strings.Contains(part.Text, "alice@example.com") {
+			t.Fatalf("raw email leaked in text part %q", part.Text)
+		}
+	}
+	surrogate := strings.TrimPrefix(parts[0].Text, "Receipt for ")
+	if !strings.HasSuffix(parts[2].Text, surrogate) {
+		t.Fatalf("expected one surrogate across text parts, got %q and %q", parts[0].Text, parts[2].Text)
+	}
+}
+
+func TestPRD66ImagesAreBlockedByDefault(t *testing.T) {
+	upstream := &multimodalUpstream{}
+	h := newMultimodalHandler(upstream, nil)
+
+	rec := postMultimodal(h, "/v1/chat/completions", `[{"type":"text","text":"What is this?"},`+imagePart+`]`)
+	var payload prd66ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to parse error response: %v", err)
+	}
+	if rec.Code != http.StatusForbidden || payload.Error.Code != "ERR_POLICY_BLOCK" {
+		t.Fatalf("expected 403 ERR_POLICY_BLOCK, got %d %+v", rec.Code, payload.Error)
+	}
+	if upstream.calls != 0 {
+		t.Fatal("expected blocked image request not to reach the provider")
+	}
+}
+
+func TestPRD66HighRiskRequestNeverForwardsImages(t *testing.T) {
+	upstream := &multimodalUpstream{}
+	// allow on high_abstraction cannot be configured; the handler still
+	// downgrades it to strip if it is set directly.
+	h := newMultimodalHandler(upstream, proxy.ImagePolicies{
+		router.RouteSanitizedForward: proxy.ImagePolicyAllow,
+		router.RouteHighAbstraction:  proxy.ImagePolicyAllow,
+	})
+
+	content := `[{"type":"text","text":"SSN 123-45-6789 and card 4111 1111 1111 1111"},` + imagePart + `]`
+	rec := postMultimodal(h, "/v1/chat/completions", content)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if upstream.last.Route != router.RouteHighAbstraction {
+		t.Fatalf("expected high_abstraction, got %s", upstream.last.Route)
+	}
+	for _, m := range upstream.last.Messages {
+		for _, part := range m.Parts {
+			if part.Type == "image_url" {
+				t.Fatalf("image forwarded on high route: %+v", upstream.last.Messages)
+			}
+		}
+	}
+
+	rec = postMultimodal(h, "/v1/debug/explain", content)
+	var explain proxy.ExplainResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &explain); err != nil {
+		t.Fatalf("failed to parse explain response: %v", err)
+	}
+	want := []proxy.ExplainContentPart{
+		{Message: 0, Index: 0, Type: "text", Disposition: "abstracted"},
+		{Message: 0, Index: 1, Type: "image_url", Disposition: "stripped"},
+	}
+	if len(explain.ContentParts) != len(want) || explain.ContentParts[0] != want[0] || explain.ContentParts[1] != want[1] {
+		t.Fatalf("expected dispositions %+v, got %+v", want, explain.ContentParts)
+	}
+}
+
+func TestPRD66ExplainReportsBlockedParts(t *testing.T) {
+	h := newMultimodalHandler(&multimodalUpstream{}, nil)
+
+	rec := postMultimodal(h, "/v1/debug/explain", `[{"type":"text","text":"hello"},`+imagePart+`]`)
+	var explain proxy.ExplainResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &explain); err != nil {
+		t.Fatalf("failed to parse explain response: %v", err)
+	}
+	if len(explain.ContentParts) != 2 || explain.ContentParts[0].Disposition != "blocked" || explain.ContentParts[1].Disposition != "blocked" {
+		t.Fatalf("expected every part blocked by the default image policy, got %+v", explain.ContentParts)
+	}
+}
@@ -0,0 +1,160 @@
+package integration_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/soloengine/lpg/internal/audit"
+	"github.com/soloengine/lpg/internal/proxy"
+	"github.com/soloengine/lpg/internal/risk"
+	"github.com/soloengine/lpg/internal/router"
+	"github.com/soloengine/lpg/internal/sanitizer"
+)
+
+// responsesUpstream records which API each request used. native toggles
+// whether it advertises Responses support.
+type responsesUpstream struct {
+	native bool
+	api    string
+	last   proxy.ForwardRequest
+}
+
+func (u *responsesUpstream) ChatCompletions(ctx context.Context, req proxy.ForwardRequest) (proxy.ForwardResponse, error) {
+	u.api, u.last = "chat", req
+	return proxy.ForwardResponse{Content: "chat reply about " + lastContent(req)}, nil
+}
+
+func (u *responsesUpstream) SupportsResponses() bool {
+	return u.native
+}
+
+func (u *responsesUpstream) Responses(ctx context.Context, req proxy.ForwardRequest) (proxy.ForwardResponse, error) {
+	u.api, u.last = "responses", req
+	return proxy.ForwardResponse{Content: "native reply about " + lastContent(req)}, nil
+}
+
+func lastContent(req proxy.ForwardRequest) string {
+	return req.Messages[len(req.Messages)-1].Content
+}
+
+type responsesAuditRecorder struct {
+	events []audit.Event
+}
+
+func (a *responsesAuditRecorder) Append(event audit.Event) (audit.Record, error) {
+	a.events = append(a.events, event)
+	return audit.Record{RequestID: event.RequestID, ActionSummary: event.ActionSummary}, nil
+}
+
+func postResponses(h *proxy.Handler, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.HandleResponses(rec, httptest.NewRequest(http.MethodPost, "/v1/responses", bytes.NewBufferString(body)))
+	return rec
+}
+
+func TestPRD66ResponsesRunsPrivacyPipelineAndTranslatesToChat(t *testing.T) {
+	upstream := &responsesUpstream{}
+	auditLog := &responsesAuditRecorder{}
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer: sanitizer.NewDefault(),
+		Scorer:    risk.NewScorer(0.70),
+		Router:    router.NewEngine(false),
+		Upstream:  upstream,
+		Audit:     auditLog,
+	})
+
+	rec := postResponses(h, `{"model":"gpt-test","instructions":"Be brief","input":"Write to alice@example.com"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if upstream.api != "chat" {
+		t.Fatalf("expected translation to chat completions, got %q", upstream.api)
+	}
+	if len(upstream.last.Messages) != 2 || upstream.last.Messages[0].Role != "system" || upstream.last.Messages[0].Content != "Be brief" {
+		t.Fatalf("expected instructions as a leading system message, got %+v", upstream.last.Messages)
+	}
+	if strings.Contains(upstream.last.Messages[1].Content, "alice@example.com") {
+		t.Fatalf("raw email leaked to provider: %q", upstream.last.Messages[1].Content)
+	}
+
+	var resp proxy.ResponsesResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	requestID := rec.Header().Get("x-lpg-request-id")
+	if resp.Object != "response" || resp.Status != "completed" || resp.ID != "resp_"+requestID || resp.Model != "gpt-test" {
+		t.Fatalf("unexpected response envelope %+v", resp)
+	}
+	if len(resp.Output) != 1 || resp.Output[0].Type != "message" || len(resp.Output[0].Content) != 1 {
+		t.Fatalf("unexpected output %+v", resp.Output)
+	}
+	if got := resp.Output[0].Content[0]; got.Type != "output_text" || got.Text != "chat reply about Write to alice@example.com" {
+		t.Fatalf("expected rehydrated output_text, got %+v", got)
+	}
+
+	if len(auditLog.events) != 1 || !strings.Contains(auditLog.events[0].ActionSummary, "api=responses") {
+		t.Fatalf("expected one audit event marked api=responses, got %+v", auditLog.events)
+	}
+}
+
+func TestPRD66ResponsesUsesNativeProviderSupport(t *testing.T) {
+	upstream := &responsesUpstream{native: true}
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer: sanitizer.NewDefault(),
+		Scorer:    risk.NewScorer(0.70),
+		Router:    router.NewEngine(false),
+		Upstream:  upstream,
+	})
+
+	rec := postResponses(h, `{"model":"gpt-test","input":[{"role":"user","content":[{"type":"input_text","text":"Call 415-555-0100"}]}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if upstream.api != "responses" || !upstream.last.Responses {
+		t.Fatalf("expected native Responses call, got %q", upstream.api)
+	}
+	if strings.Contains(lastContent(upstream.last), "415-555-0100") {
+		t.Fatalf("raw phone leaked to provider: %q", lastContent(upstream.last))
+	}
+}
+
+func TestPRD66ResponsesCriticalRequestIsBlockedWithoutEgress(t *testing.T) {
+	upstream := &responsesUpstream{native: true}
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer: sanitizer.NewDefault(),
+		Scorer:    risk.NewScorer(0.70),
+		Router:    router.NewEngine(false),
+		Upstream:  upstream,
+	})
+
+	rec := postResponses(h, `{"model":"gpt-test","input":"SSN 123-45-6789, card 4111 1111 1111 1111, mail bob@example.com, phone 415-555-0100"}`)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusForbidden, rec.Code, rec.Body.String())
+	}
+	if upstream.api != "" {
+		t.Fatalf("expected no provider call, got %q", upstream.api)
+	}
+}
+
+func TestPRD66ResponsesRejectsUnsupportedFields(t *testing.T) {
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer: sanitizer.NewDefault(),
+		Scorer:    risk.NewScorer(0.70),
+		Router:    router.NewEngine(false),
+		Upstream:  &responsesUpstream{},
+	})
+
+	rec := postResponses(h, `{"model":"gpt-test","input":"hello","stream":true}`)
+	var payload prd66ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to parse error response: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest || payload.Error.Code != "ERR_UNSUPPORTED_FIELD" || payload.Error.Message != `unsupported field "stream"` {
+		t.Fatalf("expected 400 ERR_UNSUPPORTED_FIELD, got %d %+v", rec.Code, payload.Error)
+	}
+}
@@ -0,0 +1,312 @@
+package integration_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/soloengine/lpg/internal/proxy"
+	"github.com/soloengine/lpg/internal/risk"
+	"github.com/soloengine/lpg/internal/router"
+	"github.com/soloengine/lpg/internal/sanitizer"
+)
+
+// toolCallingUpstream answers with two tool calls whose arguments echo the
+// surrogate it received in the historical send_email call.
+type toolCallingUpstream struct {
+	last proxy.ForwardRequest
+}
+
+func (u *toolCallingUpstream) ChatCompletions(ctx context.Context, req proxy.ForwardRequest) (proxy.ForwardResponse, error) {
+	u.last = req
+	var args struct {
+		To string `json:"to"`
+	}
+	for _, m := range req.Messages {
+		for _, call := range m.ToolCalls {
+			_ = json.Unmarshal([]byte(call.Function.Arguments), &args)
+		}
+	}
+	return proxy.ForwardResponse{Choices: []proxy.ForwardChoice{{
+		ToolCalls: []proxy.ToolCall{
+			{ID: "call_2", Type: "function", Function: proxy.ToolCallFunction{Name: "send_email", Arguments: `{"to":"` + args.To + `"}`}},
+			{ID: "call_3", Type: "function", Function: proxy.ToolCallFunction{Name: "log_event", Arguments: `{"note":"mailed ` + args.To + `"}`}},
+		},
+	}}}, nil
+}
+
+func newToolCallingHandler(upstream proxy.UpstreamAdapter) *proxy.Handler {
+	// Counting each distinct value once keeps three mentions of the same
+	// address in the medium band, so the request is sanitized and forwarded.
+	return proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer:      sanitizer.NewDefault(),
+		Scorer:         risk.NewScorerWithModel(0.70, risk.Model{DefaultWeight: risk.DefaultEntityWeight}),
+		Router:         router.NewEngine(false),
+		Upstream:       upstream,
+		RehydrateTools: []string{"send_email"},
+	})
+}
+
+func TestPRD66ToolResultsAndArgumentsAreSanitizedWithSharedMappings(t *testing.T) {
+	upstream := &toolCallingUpstream{}
+	h := newToolCallingHandler(upstream)
+
+	body := `{
+		"model":"gpt-test",
+		"messages":[
+			{"role":"user","content":"Mail the invoice to alice@example.com"},
+			{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"send_email","arguments":"{\"to\":\"alice@example.com\"}"}}]},
+			{"role":"tool","tool_call_id":"call_1","content":"bounced: alice@example.com mailbox full"}
+		],
+		"tools":[
+			{"type":"function","function":{"name":"send_email","parameters":{"type":"object"}}},
+			{"type":"function","function":{"name":"log_event"}}
+		],
+		"tool_choice":"auto"
+	}`
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if upstream.last.Route != router.RouteSanitizedForward {
+		t.Fatalf("expected sanitized_forward, got %s", upstream.last.Route)
+	}
+	outbound, err := json.Marshal(upstream.last.Messages)
+	if err != nil {
+		t.Fatalf("failed to marshal outbound messages: %v", err)
+	}
+	if strings.Contains(string(outbound), "alice@example.com") {
+		t.Fatalf("raw email leaked to provider: %s", outbound)
+	}
+	if len(upstream.last.Params.Tools) != 2 || string(upstream.last.Params.ToolChoice) != `"auto"` {
+		t.Fatalf("expected tools and tool_choice to be forwarded, got %+v", upstream.last.Params)
+	}
+
+	var args struct {
+		To string `json:"to"`
+	}
+	messages := upstream.last.Messages
+	if err := json.Unmarshal([]byte(messages[1].ToolCalls[0].Function.Arguments), &args); err != nil {
+		t.Fatalf("expected outbound arguments to stay valid JSON: %v", err)
+	}
+	if args.To == "" || !strings.Contains(messages[0].Content, args.To) || !strings.Contains(messages[2].Content, args.To) {
+		t.Fatalf("expected one surrogate across content, arguments and tool result, got %q / %q / %q", messages[0].Content, args.To, messages[2].Content)
+	}
+	if messages[2].Role != "tool" || messages[2].ToolCallID != "call_1" {
+		t.Fatalf("expected tool message metadata to be preserved, got %+v", messages[2])
+	}
+
+	var resp proxy.ChatCompletionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	choice := resp.Choices[0]
+	if choice.FinishReason != "tool_calls" || len(choice.Message.ToolCalls) != 2 {
+		t.Fatalf("expected tool_calls finish reason with two calls, got %+v", choice)
+	}
+	if got := choice.Message.ToolCalls[0].Function.Arguments; got != `{"to":"alice@example.com"}` {
+		t.Fatalf("expected allowlisted tool arguments to be rehydrated, got %s", got)
+	}
+	if got := choice.Message.ToolCalls[1].Function.Arguments; strings.Contains(got, "alice@example.com") || !strings.Contains(got, args.To) {
+		t.Fatalf("expected non-allowlisted tool arguments to keep the surrogate, got %s", got)
+	}
+}
+
+func TestPRD66ToolChoiceWithoutToolsIsRejected(t *testing.T) {
+	upstream := &toolCallingUpstream{}
+	h := newToolCallingHandler(upstream)
+
+	body := `{"model":"gpt-test","messages":[{"role":"user","content":"hello"}],"tool_choice":"auto"}`
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body)))
+
+	var payload prd66ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to parse error response: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest || payload.Error.Code != "ERR_VALIDATION" || payload.Error.Message != "tool_choice requires tools" {
+		t.Fatalf("expected 400 ERR_VALIDATION, got %d %+v", rec.Code, payload.Error)
+	}
+	if upstream.last.RequestID != "" {
+		t.Fatal("expected invalid request not to reach the provider")
+	}
+}
+
+func TestPRD66ToolAndResponseSchemasAreSanitized(t *testing.T) {
+	upstream := &toolCallingUpstream{}
+	h := newToolCallingHandler(upstream)
+
+	body := `{
+		"model":"gpt-test",
+		"messages":[{"role":"user","content":"Mail the invoice to alice@example.com"}],
+		"tools":[{"type":"function","function":{"name":"send_email","description":"Sends as alice@example.com","parameters":{"type":"object","properties":{"to":{"type":"string","default":"alice@example.com"}}}}}],
+		"response_format":{"type":"json_schema","json_schema":{"name":"receipt","schema":{"description":"Receipt for alice@example.com"}}}
+	}`
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	params, err := json.Marshal(upstream.last.Params)
+	if err != nil {
+		t.Fatalf("failed to marshal outbound params: %v", err)
+	}
+	if strings.Contains(string(params), "alice@example.com") {
+		t.Fatalf("raw email leaked to provider in tool or response schemas: %s", params)
+	}
+
+	surrogate := strings.TrimPrefix(upstream.last.Messages[0].Content, "Mail the invoice to ")
+	tool := upstream.last.Params.Tools[0].Function
+	if tool.Description != "Sends as "+surrogate {
+		t.Fatalf("expected the message's surrogate in the tool description, got %q", tool.Description)
+	}
+	var parameters struct {
+		Type       string `json:"type"`
+		Properties struct {
+			To struct {
+				Default string `json:"default"`
+			} `json:"to"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(tool.Parameters, &parameters); err != nil {
+		t.Fatalf("expected tool parameters to stay valid JSON: %v", err)
+	}
+	if parameters.Type != "object" || parameters.Properties.To.Default != surrogate {
+		t.Fatalf("expected schema keys kept and values sanitized, got %s", tool.Parameters)
+	}
+	if got := string(upstream.last.Params.ResponseFormat.JSONSchema); !strings.Contains(got, `"description":"Receipt for `+surrogate+`"`) {
+		t.Fatalf("expected response_format.json_schema to be sanitized, got %s", got)
+	}
+}
+
+func TestPRD66StreamWithToolsReturnsToolCalls(t *testing.T) {
+	upstream := &toolCallingUpstream{}
+	h := newToolCallingHandler(upstream)
+
+	body := `{
+		"model":"gpt-test",
+		"stream":true,
+		"messages":[
+			{"role":"user","content":"Mail the invoice to alice@example.com"},
+			{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"send_email","arguments":"{\"to\":\"alice@example.com\"}"}}]}
+		],
+		"tools":[{"type":"function","function":{"name":"send_email"}},{"type":"function","function":{"name":"log_event"}}]
+	}`
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "data: [DONE]") {
+		t.Fatalf("expected a completed stream, got %s", rec.Body.String())
+	}
+
+	var calls []proxy.ToolCallDelta
+	var finishReason string
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var chunk proxy.ChatCompletionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("failed to parse chunk %q: %v", data, err)
+		}
+		calls = append(calls, chunk.Choices[0].Delta.ToolCalls...)
+		if chunk.Choices[0].FinishReason != nil {
+			finishReason = *chunk.Choices[0].FinishReason
+		}
+	}
+	if len(calls) != 2 || finishReason != "tool_calls" {
+		t.Fatalf("expected two tool calls and finish_reason tool_calls, got %+v %q", calls, finishReason)
+	}
+	if calls[0].Function.Arguments != `{"to":"alice@example.com"}` {
+		t.Fatalf("expected allowlisted streamed arguments to be rehydrated, got %s", calls[0].Function.Arguments)
+	}
+	if strings.Contains(calls[1].Function.Arguments, "alice@example.com") {
+		t.Fatalf("expected non-allowlisted streamed arguments to keep the surrogate, got %s", calls[1].Function.Arguments)
+	}
+}
+
+func TestPRD66ToolArgumentsFollowTheRouteRehydrationSwitch(t *testing.T) {
+	upstream := &toolCallingUpstream{}
+	// Flat scoring counts each mention, so two mentions of one address land
+	// in the high band, where responses are not rehydrated.
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer:      sanitizer.NewDefault(),
+		Scorer:         risk.NewScorer(0.70),
+		Router:         router.NewEngine(false),
+		Upstream:       upstream,
+		Abstractor:     proxy.PassthroughAbstractor{},
+		RehydrateTools: []string{"send_email"},
+	})
+
+	body := `{
+		"model":"gpt-test",
+		"messages":[
+			{"role":"user","content":"Mail the invoice to alice@example.com"},
+			{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"send_email","arguments":"{\"to\":\"alice@example.com\"}"}}]}
+		],
+		"tools":[{"type":"function","function":{"name":"send_email"}},{"type":"function","function":{"name":"log_event"}}]
+	}`
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if upstream.last.Route != router.RouteHighAbstraction {
+		t.Fatalf("expected high_abstraction, got %s", upstream.last.Route)
+	}
+	var resp proxy.ChatCompletionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if got := resp.Choices[0].Message.ToolCalls[0].Function.Arguments; strings.Contains(got, "alice@example.com") {
+		t.Fatalf("expected allowlisted arguments to keep surrogates on a route without rehydration, got %s", got)
+	}
+}
+
+// promptCapturingAbstractor answers with the prompt it was given.
+type promptCapturingAbstractor struct {
+	prompt string
+}
+
+func (a *promptCapturingAbstractor) Abstract(ctx context.Context, req proxy.AbstractRequest) (string, error) {
+	a.prompt = req.SanitizedPrompt
+	return req.SanitizedPrompt, nil
+}
+
+func TestPRD66CriticalLocalOnlyPromptLeavesOutToolSchemas(t *testing.T) {
+	abstractor := &promptCapturingAbstractor{}
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer:  sanitizer.NewDefault(),
+		Scorer:     risk.NewScorer(0.70),
+		Router:     router.NewEngineWithCriticalLocalOnly(false, true),
+		Upstream:   &toolCallingUpstream{},
+		Abstractor: abstractor,
+	})
+
+	body := `{
+		"model":"gpt-test",
+		"messages":[{"role":"user","content":"a@example.com b@example.com 555-123-4567 123-45-6789"}],
+		"tools":[{"type":"function","function":{"name":"send_email","description":"Sends mail from the billing desk"}}]
+	}`
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if abstractor.prompt == "" || strings.Contains(abstractor.prompt, "billing desk") || strings.Contains(abstractor.prompt, "\n") {
+		t.Fatalf("expected only the sanitized message in the local prompt, got %q", abstractor.prompt)
+	}
+}
@@ -0,0 +1,113 @@
+package integration_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/soloengine/lpg/internal/proxy"
+	"github.com/soloengine/lpg/internal/risk"
+	"github.com/soloengine/lpg/internal/router"
+	"github.com/soloengine/lpg/internal/sanitizer"
+)
+
+type usageUpstream struct{}
+
+func (usageUpstream) ChatCompletions(ctx context.Context, req proxy.ForwardRequest) (proxy.ForwardResponse, error) {
+	return proxy.ForwardResponse{
+		Content: "done",
+		Usage:   proxy.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	}, nil
+}
+
+// summaryAbstractor replaces every prompt with a short summary, so the
+// abstraction always saves tokens.
+type summaryAbstractor struct{}
+
+func (summaryAbstractor) Abstract(ctx context.Context, req proxy.AbstractRequest) (string, error) {
+	return "summary", nil
+}
+
+func newUsageHandler() *proxy.Handler {
+	return proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer:  sanitizer.NewDefault(),
+		Scorer:     risk.NewScorer(0.70),
+		Router:     router.NewEngine(false),
+		Upstream:   usageUpstream{},
+		Abstractor: summaryAbstractor{},
+	})
+}
+
+func postUsageChat(h *proxy.Handler, content string) *httptest.ResponseRecorder {
+	body := `{"model":"gpt-test","messages":[{"role":"user","content":"` + content + `"}]}`
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body)))
+	return rec
+}
+
+func TestPRD66ChatReturnsUsageCreatedAndRoutingHeaders(t *testing.T) {
+	rec := postUsageChat(newUsageHandler(), "Write to alice@example.com")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	var resp proxy.ChatCompletionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if resp.Created <= 0 {
+		t.Fatalf("expected created timestamp, got %d", resp.Created)
+	}
+	if want := (proxy.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}); resp.Usage != want {
+		t.Fatalf("expected provider usage %+v, got %+v", want, resp.Usage)
+	}
+
+	for header, want := range map[string]string{
+		"x-lpg-route":         string(router.RouteSanitizedForward),
+		"x-lpg-risk-category": string(risk.CategoryMedium),
+		"x-lpg-detections":    "1",
+		"x-lpg-abstraction":   "false",
+	} {
+		if got := rec.Header().Get(header); got != want {
+			t.Fatalf("expected %s=%q, got %q", header, want, got)
+		}
+	}
+	if got := rec.Header().Get("x-lpg-tokens-saved"); got != "" {
+		t.Fatalf("expected no tokens-saved header without abstraction, got %q", got)
+	}
+}
+
+func TestPRD66AbstractionReportsTokensSaved(t *testing.T) {
+	rec := postUsageChat(newUsageHandler(), "Please email alice@example.com and call 415-555-0100 about the overdue invoice from last month")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("x-lpg-route"); got != string(router.RouteHighAbstraction) {
+		t.Fatalf("expected high_abstraction route header, got %q", got)
+	}
+	if got := rec.Header().Get("x-lpg-abstraction"); got != "true" {
+		t.Fatalf("expected abstraction=true, got %q", got)
+	}
+	if got := rec.Header().Get("x-lpg-tokens-saved"); got == "" || got == "0" {
+		t.Fatalf("expected positive tokens-saved header, got %q", got)
+	}
+}
+
+func TestPRD66BlockedRequestStillCarriesRoutingHeaders(t *testing.T) {
+	rec := postUsageChat(newUsageHandler(), "SSN 123-45-6789, card 4111 1111 1111 1111, mail bob@example.com, phone 415-555-0100")
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusForbidden, rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("x-lpg-risk-category"); got != string(risk.CategoryCritical) {
+		t.Fatalf("expected critical risk header, got %q", got)
+	}
+	if got := rec.Header().Get("x-lpg-detections"); got != "4" {
+		t.Fatalf("expected 4 detections, got %q", got)
+	}
+	if got := rec.Header().Get("x-lpg-abstraction"); got != "" {
+		t.Fatalf("expected no abstraction header on a blocked request, got %q", got)
+	}
+}
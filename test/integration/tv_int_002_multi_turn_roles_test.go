@@ -0,0 +1,67 @@
+package integration_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/soloengine/lpg/internal/proxy"
+	"github.com/soloengine/lpg/internal/risk"
+	"github.com/soloengine/lpg/internal/router"
+	"github.com/soloengine/lpg/internal/sanitizer"
+)
+
+type multiTurnCapturingUpstream struct {
+	last proxy.ForwardRequest
+}
+
+func (u *multiTurnCapturingUpstream) ChatCompletions(ctx context.Context, req proxy.ForwardRequest) (proxy.ForwardResponse, error) {
+	u.last = req
+	return proxy.ForwardResponse{Content: "ok"}, nil
+}
+
+func TestTVINT002MultiTurnRolesAndOrderPreservedWithSharedSurrogates(t *testing.T) {
+	upstream := &multiTurnCapturingUpstream{}
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer:  sanitizer.NewDefault(),
+		Scorer:     risk.NewScorer(0.70),
+		Router:     router.NewEngine(false),
+		Upstream:   upstream,
+		Abstractor: proxy.PassthroughAbstractor{},
+	})
+
+	body := []byte(`{"model":"gpt-test","messages":[` +
+		`{"role":"system","content":"You help alice@example.com"},` +
+		`{"role":"assistant","content":"How can I help?"},` +
+		`{"role":"user","content":"Send the summary to alice@example.com"}]}`)
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	h.HandleChatCompletions(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	expected := []proxy.ChatMessage{
+		{Role: "system", Content: "You help person1@example.net"},
+		{Role: "assistant", Content: "How can I help?"},
+		{Role: "user", Content: "Send the summary to person1@example.net"},
+	}
+	if len(upstream.last.Messages) != len(expected) {
+		t.Fatalf("expected %d outbound messages, got %d", len(expected), len(upstream.last.Messages))
+	}
+	for i, want := range expected {
+		got := upstream.last.Messages[i]
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("outbound message %d\nwant: %+v\n got: %+v", i, want, got)
+		}
+		if strings.Contains(got.Content, "alice@example.com") {
+			t.Fatalf("raw email leaked in outbound message %d: %q", i, got.Content)
+		}
+	}
+}
@@ -51,11 +51,52 @@ func TestTVROUTE002ConfidenceEscalationUsesHighRoute(t *testing.T) {
 	}
 }
 
+func TestTVROUTE002ChecksumFailureIsNotMaskedOrEscalated(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		category risk.Category
+		route    router.Route
+		masked   bool
+	}{
+		{name: "valid card number", content: "charge 4111 1111 1111 1111", category: risk.CategoryMedium, route: router.RouteSanitizedForward, masked: true},
+		{name: "order number failing luhn", content: "order 4111111111111112", category: risk.CategoryLow, route: router.RouteSanitizedForward},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := &routeCapturingUpstream{}
+			h := proxy.NewHandler(proxy.HandlerConfig{
+				Sanitizer:  sanitizer.NewDefault(),
+				Scorer:     risk.NewScorer(0.70),
+				Router:     router.NewEngine(false),
+				Upstream:   upstream,
+				Abstractor: proxy.PassthroughAbstractor{},
+			})
+
+			body := []byte(`{"model":"gpt-test","messages":[{"role":"user","content":"` + tt.content + `"}]}`)
+			rec := httptest.NewRecorder()
+			h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body)))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+			if upstream.last.RiskCategory != tt.category || upstream.last.Route != tt.route {
+				t.Fatalf("expected %s/%s, got %s/%s", tt.category, tt.route, upstream.last.RiskCategory, upstream.last.Route)
+			}
+			if masked := upstream.last.Messages[0].Content != tt.content; masked != tt.masked {
+				t.Fatalf("expected masked=%v, got %q", tt.masked, upstream.last.Messages[0].Content)
+			}
+		})
+	}
+}
+
 type lowConfidenceSanitizer struct{}
 
-func (lowConfidenceSanitizer) Sanitize(input string) (sanitizer.Result, error) {
+func (lowConfidenceSanitizer) SanitizeConversation(messages []string) (sanitizer.Result, error) {
 	return sanitizer.Result{
 		Sanitized: "person1@example.net",
+		Messages:  []string{"person1@example.net"},
 		Mappings: []sanitizer.Mapping{
 			{
 				Placeholder:     "person1@example.net",
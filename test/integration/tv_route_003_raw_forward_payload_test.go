@@ -24,8 +24,8 @@ func (u *rawForwardCapturingUpstream) ChatCompletions(ctx context.Context, req p
 
 type rawForwardTestSanitizer struct{}
 
-func (rawForwardTestSanitizer) Sanitize(input string) (sanitizer.Result, error) {
-	return sanitizer.Result{Sanitized: "sanitized-value", Mappings: nil}, nil
+func (rawForwardTestSanitizer) SanitizeConversation(messages []string) (sanitizer.Result, error) {
+	return sanitizer.Result{Sanitized: "sanitized-value", Messages: []string{"sanitized-value"}, Mappings: nil}, nil
 }
 
 func TestTVROUTE003RawForwardUsesRawPromptPayload(t *testing.T) {
@@ -50,7 +50,7 @@ func TestTVROUTE003RawForwardUsesRawPromptPayload(t *testing.T) {
 	if upstream.last.Route != router.RouteRawForward {
 		t.Fatalf("expected route %s, got %s", router.RouteRawForward, upstream.last.Route)
 	}
-	if upstream.last.SanitizedPrompt != rawInput {
-		t.Fatalf("expected raw prompt to be forwarded on raw route, got %q", upstream.last.SanitizedPrompt)
+	if len(upstream.last.Messages) != 1 || upstream.last.Messages[0].Content != rawInput {
+		t.Fatalf("expected raw prompt to be forwarded on raw route, got %+v", upstream.last.Messages)
 	}
 }
@@ -42,10 +42,14 @@ func TestTVLEAK001NoForbiddenRawEntitiesCrossOutboundBoundary(t *testing.T) {
 		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
 	}
 
-	if strings.Contains(upstream.last.SanitizedPrompt, "alice@example.com") {
-		t.Fatalf("raw email leaked to outbound payload: %q", upstream.last.SanitizedPrompt)
+	if len(upstream.last.Messages) != 1 {
+		t.Fatalf("expected 1 outbound message, got %d", len(upstream.last.Messages))
 	}
-	if !strings.Contains(upstream.last.SanitizedPrompt, "person1@example.net") {
-		t.Fatalf("expected surrogate email in outbound payload: %q", upstream.last.SanitizedPrompt)
+	outbound := upstream.last.Messages[0].Content
+	if strings.Contains(outbound, "alice@example.com") {
+		t.Fatalf("raw email leaked to outbound payload: %q", outbound)
+	}
+	if !strings.Contains(outbound, "person1@example.net") {
+		t.Fatalf("expected surrogate email in outbound payload: %q", outbound)
 	}
 }
@@ -109,14 +109,16 @@ func TestTVLEAK003AuditRecordsDoNotContainRawSensitiveRequestContentByDefault(t
 		t.Fatalf("NewChainWriter failed: %v", err)
 	}
 
+	entityKey := []byte("0123456789abcdef0123456789abcdef")
 	h := proxy.NewHandler(proxy.HandlerConfig{
-		Sanitizer:     sanitizer.NewDefault(),
-		Scorer:        risk.NewScorer(0.70),
-		Router:        router.NewEngine(false),
-		Upstream:      providerFailureUpstream{},
-		Audit:         chainWriter,
-		StrictAudit:   true,
-		PolicyVersion: "v2.1",
+		Sanitizer:      sanitizer.NewDefault(),
+		Scorer:         risk.NewScorer(0.70),
+		Router:         router.NewEngine(false),
+		Upstream:       providerFailureUpstream{},
+		Audit:          chainWriter,
+		AuditEntityKey: entityKey,
+		StrictAudit:    true,
+		PolicyVersion:  "v2.1",
 	})
 
 	body := []byte(`{"model":"gpt-test","messages":[{"role":"user","content":"contact ` + rawEmail + `"}]}`)
@@ -154,6 +156,16 @@ func TestTVLEAK003AuditRecordsDoNotContainRawSensitiveRequestContentByDefault(t
 		t.Fatalf("expected provider failure action summary, got %q", record.ActionSummary)
 	}
 	assertNoRawSensitive(t, record.ActionSummary, rawEmail)
+
+	if record.Outcome != audit.OutcomeError || record.HTTPStatus != http.StatusBadGateway || record.ErrorCode != "ERR_PROVIDER_FAILURE" {
+		t.Fatalf("unexpected structured outcome %q %d %q", record.Outcome, record.HTTPStatus, record.ErrorCode)
+	}
+	if record.Detections["EMAIL"] != 1 || len(record.EntityHashes) != 1 {
+		t.Fatalf("expected one EMAIL detection and hash, got %v %v", record.Detections, record.EntityHashes)
+	}
+	if record.EntityHashes[0].HMAC != audit.HashEntity(entityKey, "EMAIL", rawEmail) {
+		t.Fatal("expected the entity hash to be the HMAC of the mapped value")
+	}
 }
 
 func assertNoRawSensitive(t *testing.T, haystack string, sensitiveValues ...string) {
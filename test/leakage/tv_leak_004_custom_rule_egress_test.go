@@ -0,0 +1,67 @@
+package leakage_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/soloengine/lpg/internal/proxy"
+	"github.com/soloengine/lpg/internal/rehydrate"
+	"github.com/soloengine/lpg/internal/risk"
+	"github.com/soloengine/lpg/internal/router"
+	"github.com/soloengine/lpg/internal/sanitizer"
+)
+
+func TestTVLEAK004CustomRuleValuesDoNotCrossOutboundBoundary(t *testing.T) {
+	s, err := sanitizer.New(sanitizer.Rule{
+		EntityType:        "CUSTOMER_ACCOUNT",
+		Regex:             regexp.MustCompile(`\bACCT-\d{8}\b`),
+		Confidence:        0.95,
+		SurrogateTemplate: "account-{n}",
+		HardBlock:         true,
+	})
+	if err != nil {
+		t.Fatalf("sanitizer.New failed: %v", err)
+	}
+
+	upstream := &capturingUpstream{}
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer:  s,
+		Scorer:     risk.NewScorer(0.70),
+		Router:     router.NewEngine(true),
+		Upstream:   upstream,
+		Rehydrator: rehydrate.NewGuardWithPatterns(s, router.RouteSanitizedForward),
+	})
+
+	body := []byte(`{"model":"gpt-test","messages":[{"role":"user","content":"close ACCT-12345678"}]}`)
+
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if len(upstream.last.Messages) != 1 {
+		t.Fatalf("expected 1 outbound message, got %d", len(upstream.last.Messages))
+	}
+	outbound := upstream.last.Messages[0].Content
+	if strings.Contains(outbound, "ACCT-12345678") {
+		t.Fatalf("raw custom entity leaked to outbound payload: %q", outbound)
+	}
+	if outbound != "close account-1" {
+		t.Fatalf("expected custom surrogate in outbound payload, got %q", outbound)
+	}
+
+	rec = httptest.NewRecorder()
+	h.HandleDebugExplain(rec, httptest.NewRequest(http.MethodPost, "/v1/debug/explain", bytes.NewReader(body)))
+	var explain proxy.ExplainResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &explain); err != nil {
+		t.Fatalf("failed to unmarshal explain response: %v", err)
+	}
+	if !explain.HardBlock {
+		t.Fatal("expected custom hard-block rule to be reported in explain")
+	}
+}
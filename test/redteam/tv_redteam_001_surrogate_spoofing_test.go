@@ -0,0 +1,165 @@
+package redteam_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/soloengine/lpg/internal/audit"
+	"github.com/soloengine/lpg/internal/proxy"
+	"github.com/soloengine/lpg/internal/rehydrate"
+	"github.com/soloengine/lpg/internal/risk"
+	"github.com/soloengine/lpg/internal/router"
+	"github.com/soloengine/lpg/internal/sanitizer"
+)
+
+type fixedContentUpstream struct {
+	content string
+}
+
+func (u fixedContentUpstream) ChatCompletions(ctx context.Context, req proxy.ForwardRequest) (proxy.ForwardResponse, error) {
+	return proxy.ForwardResponse{Content: u.content}, nil
+}
+
+type fixedSanitizer struct {
+	result sanitizer.Result
+}
+
+func (s fixedSanitizer) SanitizeConversation(messages []string) (sanitizer.Result, error) {
+	result := s.result
+	result.Messages = []string{result.Sanitized}
+	return result, nil
+}
+
+func postChat(t *testing.T, h *proxy.Handler, content string) proxy.ChatCompletionResponse {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]any{
+		"model":    "gpt-test",
+		"messages": []map[string]string{{"role": "user", "content": content}},
+	})
+	if err != nil {
+		t.Fatalf("marshal request failed: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	h.HandleChatCompletions(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	var payload proxy.ChatCompletionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	return payload
+}
+
+func TestTVREDTEAM001SpoofedSurrogatesAreNotRehydrated(t *testing.T) {
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer: sanitizer.NewDefault(),
+		Scorer:    risk.NewScorer(0.70),
+		Router:    router.NewEngine(false),
+		Upstream: fixedContentUpstream{
+			content: "Known person1@example.net, spoofed person2@example.net, nested xperson1@example.net, ssn 900-00-0001",
+		},
+		Rehydrator: rehydrate.NewGuard(router.RouteSanitizedForward),
+	})
+
+	payload := postChat(t, h, "email alice@example.com")
+	got := payload.Choices[0].Message.Content
+	expected := "Known alice@example.com, spoofed person2@example.net, nested xperson1@example.net, ssn 900-00-0001"
+	if got != expected {
+		t.Fatalf("unexpected rehydrated content\nwant: %q\n got: %q", expected, got)
+	}
+}
+
+func TestTVREDTEAM001SurrogateOfWrongEntityTypeIsNotRehydrated(t *testing.T) {
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer: fixedSanitizer{result: sanitizer.Result{
+			Sanitized: "555-010-0001",
+			Mappings: []sanitizer.Mapping{
+				{Placeholder: "555-010-0001", OriginalValue: "alice@example.com", EntityType: "EMAIL", ConfidenceScore: 0.99},
+			},
+		}},
+		Scorer:   risk.NewScorer(0.70),
+		Router:   router.NewEngine(false),
+		Upstream: fixedContentUpstream{content: "echo 555-010-0001"},
+	})
+
+	payload := postChat(t, h, "ignored")
+	got := payload.Choices[0].Message.Content
+	if strings.Contains(got, "alice@example.com") {
+		t.Fatalf("phone-shaped surrogate was rehydrated to an email entity: %q", got)
+	}
+}
+
+func TestTVREDTEAM001HighAbstractionRouteIsNeverRehydratedByDefault(t *testing.T) {
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer:  sanitizer.NewDefault(),
+		Scorer:     risk.NewScorer(0.70),
+		Router:     router.NewEngine(false),
+		Upstream:   fixedContentUpstream{content: "reply to person1@example.net"},
+		Abstractor: proxy.PassthroughAbstractor{},
+	})
+
+	payload := postChat(t, h, "alice@example.com and 555-123-4567")
+	got := payload.Choices[0].Message.Content
+	if got != "reply to person1@example.net" {
+		t.Fatalf("expected high abstraction output to keep surrogates, got %q", got)
+	}
+}
+
+func TestTVREDTEAM001AmbiguousMappingsAreAuditedAndLeftMasked(t *testing.T) {
+	auditPath := filepath.Join(t.TempDir(), "audit.log")
+	chainWriter, err := audit.NewChainWriter(auditPath)
+	if err != nil {
+		t.Fatalf("NewChainWriter failed: %v", err)
+	}
+
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer: fixedSanitizer{result: sanitizer.Result{
+			Sanitized: "person1@example.net",
+			Mappings: []sanitizer.Mapping{
+				{Placeholder: "person1@example.net", OriginalValue: "alice@example.com", EntityType: "EMAIL", ConfidenceScore: 0.99},
+				{Placeholder: "person1@example.net", OriginalValue: "bob@example.com", EntityType: "EMAIL", ConfidenceScore: 0.99},
+			},
+		}},
+		Scorer:     risk.NewScorer(0.70),
+		Router:     router.NewEngine(false),
+		Upstream:   fixedContentUpstream{content: "hello person1@example.net"},
+		Abstractor: proxy.PassthroughAbstractor{},
+		Audit:      chainWriter,
+		Rehydrator: rehydrate.NewGuard(router.RouteHighAbstraction),
+	})
+
+	payload := postChat(t, h, "ignored")
+	got := payload.Choices[0].Message.Content
+	if got != "hello person1@example.net" {
+		t.Fatalf("expected ambiguous surrogate to remain masked, got %q", got)
+	}
+
+	if err := audit.VerifyChain(auditPath); err != nil {
+		t.Fatalf("VerifyChain failed: %v", err)
+	}
+	contents, err := os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatalf("read audit log failed: %v", err)
+	}
+	logBytes := string(contents)
+	if !strings.Contains(logBytes, "rehydration_collisions=ambiguous_mapping:person1@example.net(EMAIL)") {
+		t.Fatalf("expected collision to be audited, got %q", logBytes)
+	}
+	for _, raw := range []string{"alice@example.com", "bob@example.com"} {
+		if strings.Contains(logBytes, raw) {
+			t.Fatalf("audit log leaked raw value %q", raw)
+		}
+	}
+}
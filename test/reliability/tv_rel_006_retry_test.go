@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/soloengine/lpg/internal/proxy"
 	"github.com/soloengine/lpg/internal/risk"
@@ -24,7 +25,7 @@ func (u *failOnceThenOKUpstream) ChatCompletions(ctx context.Context, req proxy.
 	u.calls++
 	u.last = req
 	if u.calls == 1 {
-		return proxy.ForwardResponse{}, errors.New("transient upstream failure")
+		return proxy.ForwardResponse{}, &proxy.ProviderHTTPStatusError{StatusCode: http.StatusServiceUnavailable}
 	}
 	return proxy.ForwardResponse{Content: "ok"}, nil
 }
@@ -66,6 +67,7 @@ func TestTVREL006IdempotentLowMediumRetryExactlyOnceThenSucceeds(t *testing.T) {
 				Scorer:    risk.NewScorer(0.70),
 				Router:    tc.router,
 				Upstream:  upstream,
+				Retry:     proxy.RetryPolicy{BaseDelay: time.Millisecond},
 			})
 
 			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(tc.body))
@@ -166,3 +168,130 @@ func TestTVREL006HighRouteDoesNotRetryEvenWithIdempotencyKey(t *testing.T) {
 		t.Fatalf("expected high abstraction route, got %s", upstream.last.Route)
 	}
 }
+
+func TestTVREL006NonRetryableFailureIsNotRetried(t *testing.T) {
+	upstream := &failAlwaysUpstream{}
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer: sanitizer.NewDefault(),
+		Scorer:    risk.NewScorer(0.70),
+		Router:    router.NewEngine(false),
+		Upstream:  upstream,
+		Retry:     proxy.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(`{"model":"gpt-test","messages":[{"role":"user","content":"email alice@example.com"}]}`))
+	req.Header.Set("Idempotency-Key", "idem-fatal")
+	rec := httptest.NewRecorder()
+
+	h.HandleChatCompletions(rec, req)
+
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected status %d, got %d", http.StatusBadGateway, rec.Code)
+	}
+	if upstream.calls != 1 {
+		t.Fatalf("expected no retry for a non-retryable failure, got %d upstream calls", upstream.calls)
+	}
+}
+
+// retryAfterUpstream answers 429 with Retry-After until it has been called
+// failures times.
+type retryAfterUpstream struct {
+	failures   int
+	retryAfter string
+	calls      []time.Time
+}
+
+func (u *retryAfterUpstream) ChatCompletions(ctx context.Context, req proxy.ForwardRequest) (proxy.ForwardResponse, error) {
+	u.calls = append(u.calls, time.Now())
+	if len(u.calls) <= u.failures {
+		return proxy.ForwardResponse{}, &proxy.ProviderHTTPStatusError{
+			StatusCode:      http.StatusTooManyRequests,
+			ResponseHeaders: map[string]string{"retry-after": u.retryAfter},
+		}
+	}
+	return proxy.ForwardResponse{Content: "ok"}, nil
+}
+
+func TestTVREL006HonorsRetryAfterWithinMaxDelay(t *testing.T) {
+	cases := []struct {
+		name       string
+		retryAfter string
+		wantStatus int
+		wantCalls  int
+	}{
+		{name: "short retry-after is waited out", retryAfter: "1", wantStatus: http.StatusOK, wantCalls: 2},
+		{name: "retry-after beyond max delay is not retried", retryAfter: "30", wantStatus: http.StatusBadGateway, wantCalls: 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			upstream := &retryAfterUpstream{failures: 1, retryAfter: tc.retryAfter}
+			h := proxy.NewHandler(proxy.HandlerConfig{
+				Sanitizer: sanitizer.NewDefault(),
+				Scorer:    risk.NewScorer(0.70),
+				Router:    router.NewEngine(false),
+				Upstream:  upstream,
+				Retry:     proxy.RetryPolicy{BaseDelay: time.Millisecond, MaxDelay: 2 * time.Second},
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(`{"model":"gpt-test","messages":[{"role":"user","content":"email alice@example.com"}]}`))
+			req.Header.Set("Idempotency-Key", "idem-429")
+			rec := httptest.NewRecorder()
+
+			h.HandleChatCompletions(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d", tc.wantStatus, rec.Code)
+			}
+			if len(upstream.calls) != tc.wantCalls {
+				t.Fatalf("expected %d upstream calls, got %d", tc.wantCalls, len(upstream.calls))
+			}
+			if tc.wantCalls == 2 {
+				if waited := upstream.calls[1].Sub(upstream.calls[0]); waited < time.Second {
+					t.Fatalf("expected the retry to wait for Retry-After, waited %s", waited)
+				}
+			}
+		})
+	}
+}
+
+// slowUpstream blocks until its per-attempt deadline and records how long
+// each attempt was given.
+type slowUpstream struct {
+	budgets []time.Duration
+}
+
+func (u *slowUpstream) ChatCompletions(ctx context.Context, req proxy.ForwardRequest) (proxy.ForwardResponse, error) {
+	deadline, _ := ctx.Deadline()
+	u.budgets = append(u.budgets, time.Until(deadline))
+	<-ctx.Done()
+	return proxy.ForwardResponse{}, ctx.Err()
+}
+
+func TestTVREL006EachAttemptGetsItsOwnDeadlineWithinOverallBudget(t *testing.T) {
+	upstream := &slowUpstream{}
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer:       sanitizer.NewDefault(),
+		Scorer:          risk.NewScorer(0.70),
+		Router:          router.NewEngine(false),
+		Upstream:        upstream,
+		ProviderTimeout: 40 * time.Millisecond,
+		Retry:           proxy.RetryPolicy{MaxAttempts: 10, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Budget: 100 * time.Millisecond},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(`{"model":"gpt-test","messages":[{"role":"user","content":"email alice@example.com"}]}`))
+	req.Header.Set("Idempotency-Key", "idem-slow")
+	rec := httptest.NewRecorder()
+
+	h.HandleChatCompletions(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+	if len(upstream.budgets) < 2 || len(upstream.budgets) > 3 {
+		t.Fatalf("expected the overall budget to allow 2-3 attempts, got %d", len(upstream.budgets))
+	}
+	if upstream.budgets[1] < 30*time.Millisecond {
+		t.Fatalf("expected the retry to get a fresh per-attempt deadline, got %s", upstream.budgets[1])
+	}
+}
@@ -0,0 +1,232 @@
+package reliability_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/soloengine/lpg/internal/audit"
+	"github.com/soloengine/lpg/internal/proxy"
+	"github.com/soloengine/lpg/internal/risk"
+	"github.com/soloengine/lpg/internal/router"
+	"github.com/soloengine/lpg/internal/sanitizer"
+)
+
+type erroringUpstream struct {
+	calls int
+	err   error
+}
+
+func (u *erroringUpstream) ChatCompletions(ctx context.Context, req proxy.ForwardRequest) (proxy.ForwardResponse, error) {
+	u.calls++
+	return proxy.ForwardResponse{}, u.err
+}
+
+type countingTimeoutUpstream struct {
+	calls int
+}
+
+func (u *countingTimeoutUpstream) ChatCompletions(ctx context.Context, req proxy.ForwardRequest) (proxy.ForwardResponse, error) {
+	u.calls++
+	<-ctx.Done()
+	return proxy.ForwardResponse{}, ctx.Err()
+}
+
+type recordingAuditWriter struct {
+	events []audit.Event
+}
+
+func (w *recordingAuditWriter) Append(event audit.Event) (audit.Record, error) {
+	w.events = append(w.events, event)
+	return audit.Record{}, nil
+}
+
+func connectionRefused() error {
+	return &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+}
+
+func TestTVREL007FailoverOnTimeout5xxAndConnectionErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		primary proxy.UpstreamAdapter
+		outcome string
+	}{
+		{name: "timeout", primary: &countingTimeoutUpstream{}, outcome: "remote:timeout"},
+		{name: "5xx", primary: &erroringUpstream{err: &proxy.ProviderHTTPStatusError{StatusCode: http.StatusBadGateway}}, outcome: "remote:status_502"},
+		{name: "connection error", primary: &erroringUpstream{err: connectionRefused()}, outcome: "remote:connection_error"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fallback := &capturingUpstream{}
+			auditWriter := &recordingAuditWriter{}
+			h := proxy.NewHandler(proxy.HandlerConfig{
+				Sanitizer: sanitizer.NewDefault(),
+				Scorer:    risk.NewScorer(0.70),
+				Router:    router.NewEngine(false),
+				Providers: []proxy.Provider{
+					{Name: "remote", Adapter: tc.primary},
+					{Name: "local", Adapter: fallback, Local: true},
+				},
+				Audit:           auditWriter,
+				ProviderTimeout: 20 * time.Millisecond,
+			})
+
+			body := []byte(`{"model":"gpt-test","messages":[{"role":"user","content":"contact alice@example.com"}]}`)
+			rec := httptest.NewRecorder()
+			h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body)))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d after failover, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+			}
+			if fallback.last.RequestID == "" {
+				t.Fatal("expected fallback provider to serve the request")
+			}
+			if len(auditWriter.events) != 1 {
+				t.Fatalf("expected one audit event, got %d", len(auditWriter.events))
+			}
+			want := "providers=" + tc.outcome + ",local:ok"
+			if !strings.Contains(auditWriter.events[0].ActionSummary, want) {
+				t.Fatalf("expected audit summary to contain %q, got %q", want, auditWriter.events[0].ActionSummary)
+			}
+			if event := auditWriter.events[0]; event.Outcome != audit.OutcomeSuccess || event.Provider != "local" || event.HTTPStatus != http.StatusOK {
+				t.Fatalf("expected a success served by local, got %+v", event)
+			}
+		})
+	}
+}
+
+func TestTVREL007ClientErrorsDoNotFailOver(t *testing.T) {
+	primary := &erroringUpstream{err: &proxy.ProviderHTTPStatusError{StatusCode: http.StatusBadRequest}}
+	fallback := &countingTimeoutUpstream{}
+	auditWriter := &recordingAuditWriter{}
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer: sanitizer.NewDefault(),
+		Scorer:    risk.NewScorer(0.70),
+		Router:    router.NewEngine(false),
+		Providers: []proxy.Provider{
+			{Name: "remote", Adapter: primary},
+			{Name: "local", Adapter: fallback, Local: true},
+		},
+		Audit: auditWriter,
+	})
+
+	body := []byte(`{"model":"gpt-test","messages":[{"role":"user","content":"contact alice@example.com"}]}`)
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body)))
+
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected status %d, got %d", http.StatusBadGateway, rec.Code)
+	}
+	if fallback.calls != 0 {
+		t.Fatalf("expected no failover on a 4xx, got %d fallback calls", fallback.calls)
+	}
+	if !strings.Contains(auditWriter.events[0].ActionSummary, "providers=remote:status_400 provider-failure") {
+		t.Fatalf("unexpected audit summary %q", auditWriter.events[0].ActionSummary)
+	}
+	if event := auditWriter.events[0]; event.Outcome != audit.OutcomeError || event.ErrorCode != "ERR_PROVIDER_FAILURE" || event.Provider != "remote" {
+		t.Fatalf("unexpected structured audit fields %+v", event)
+	}
+}
+
+func TestTVREL007CategoryConstraintsSkipIneligibleProviders(t *testing.T) {
+	remote := &capturingUpstream{}
+	local := &capturingUpstream{}
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer: sanitizer.NewDefault(),
+		Scorer:    risk.NewScorer(0.70),
+		Router:    router.NewEngine(false),
+		Providers: []proxy.Provider{
+			{Name: "remote", Adapter: remote, Categories: []risk.Category{risk.CategoryLow, risk.CategoryMedium}},
+			{Name: "local", Adapter: local, Local: true},
+		},
+		Abstractor: proxy.PassthroughAbstractor{},
+	})
+
+	// email + phone scores High and takes the high_abstraction route.
+	body := []byte(`{"model":"gpt-test","messages":[{"role":"user","content":"email alice@example.com or call 555-123-4567"}]}`)
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if remote.last.RequestID != "" {
+		t.Fatal("expected Low/Medium-only provider to be skipped for High traffic")
+	}
+	if local.last.Route != router.RouteHighAbstraction {
+		t.Fatalf("expected local provider to serve high_abstraction, got %q", local.last.Route)
+	}
+}
+
+func TestTVREL007NoEligibleProviderFailsClosed(t *testing.T) {
+	remote := &capturingUpstream{}
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer: sanitizer.NewDefault(),
+		Scorer:    risk.NewScorer(0.70),
+		Router:    router.NewEngine(false),
+		Providers: []proxy.Provider{
+			{Name: "remote", Adapter: remote, Routes: []router.Route{router.RouteRawForward}},
+		},
+	})
+
+	body := []byte(`{"model":"gpt-test","messages":[{"role":"user","content":"contact alice@example.com"}]}`)
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body)))
+
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected status %d, got %d", http.StatusBadGateway, rec.Code)
+	}
+	var payload struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if payload.Error.Code != "ERR_PROVIDER_FAILURE" || payload.Error.Message != "no eligible provider for route" {
+		t.Fatalf("unexpected error payload %+v", payload.Error)
+	}
+	if remote.last.RequestID != "" {
+		t.Fatal("expected ineligible provider not to be called")
+	}
+}
+
+func TestTVREL007CriticalLocalOnlyNeverFailsOverToRemote(t *testing.T) {
+	remote := &erroringUpstream{err: &proxy.ProviderHTTPStatusError{StatusCode: http.StatusServiceUnavailable}}
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer: sanitizer.NewDefault(),
+		Scorer:    risk.NewScorer(0.70),
+		Router:    router.NewEngineWithCriticalLocalOnly(false, true),
+		Providers: []proxy.Provider{
+			{Name: "remote", Adapter: remote},
+		},
+		Abstractor: failingAbstractor{},
+	})
+
+	body := []byte(`{"model":"gpt-test","messages":[{"role":"user","content":"ssn 123-45-6789 email alice@example.com phone 555-123-4567 backup bob@example.com"}]}`)
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body)))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected local-only failure to fail closed with %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+	if remote.calls != 0 {
+		t.Fatalf("expected zero remote calls for critical_local_only, got %d", remote.calls)
+	}
+}
+
+type failingAbstractor struct{}
+
+func (failingAbstractor) Abstract(ctx context.Context, req proxy.AbstractRequest) (string, error) {
+	return "", context.DeadlineExceeded
+}
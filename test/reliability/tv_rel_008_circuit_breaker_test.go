@@ -0,0 +1,99 @@
+package reliability_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/soloengine/lpg/internal/proxy"
+	"github.com/soloengine/lpg/internal/risk"
+	"github.com/soloengine/lpg/internal/router"
+	"github.com/soloengine/lpg/internal/sanitizer"
+)
+
+func postBreakerRequest(h *proxy.Handler) *httptest.ResponseRecorder {
+	body := []byte(`{"model":"gpt-test","messages":[{"role":"user","content":"contact alice@example.com"}]}`)
+	rec := httptest.NewRecorder()
+	h.HandleChatCompletions(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body)))
+	return rec
+}
+
+func TestTVREL008OpenCircuitFailsFast(t *testing.T) {
+	remote := &erroringUpstream{err: &proxy.ProviderHTTPStatusError{StatusCode: http.StatusServiceUnavailable}}
+	auditWriter := &recordingAuditWriter{}
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer: sanitizer.NewDefault(),
+		Scorer:    risk.NewScorer(0.70),
+		Router:    router.NewEngine(false),
+		Providers: []proxy.Provider{{
+			Name:    "remote",
+			Adapter: remote,
+			Breaker: proxy.NewCircuitBreaker(proxy.BreakerConfig{Window: 2, MinRequests: 2, FailureRate: 1, OpenDuration: time.Minute}, remote),
+		}},
+		Audit: auditWriter,
+	})
+
+	for i := 0; i < 2; i++ {
+		if rec := postBreakerRequest(h); rec.Code != http.StatusBadGateway {
+			t.Fatalf("request %d: expected status %d, got %d", i, http.StatusBadGateway, rec.Code)
+		}
+	}
+
+	rec := postBreakerRequest(h)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d once the circuit opened, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+	var payload struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if payload.Error.Code != "ERR_PROVIDER_UNAVAILABLE" {
+		t.Fatalf("expected ERR_PROVIDER_UNAVAILABLE, got %q", payload.Error.Code)
+	}
+	if remote.calls != 2 {
+		t.Fatalf("expected the open circuit to stop provider calls, got %d", remote.calls)
+	}
+	if summary := auditWriter.events[2].ActionSummary; !strings.Contains(summary, "providers=remote:circuit_open provider-unavailable") {
+		t.Fatalf("unexpected audit summary %q", summary)
+	}
+}
+
+func TestTVREL008OpenCircuitGoesStraightToFallback(t *testing.T) {
+	remote := &erroringUpstream{err: connectionRefused()}
+	fallback := &capturingUpstream{}
+	auditWriter := &recordingAuditWriter{}
+	h := proxy.NewHandler(proxy.HandlerConfig{
+		Sanitizer: sanitizer.NewDefault(),
+		Scorer:    risk.NewScorer(0.70),
+		Router:    router.NewEngine(false),
+		Providers: []proxy.Provider{
+			{
+				Name:    "remote",
+				Adapter: remote,
+				Breaker: proxy.NewCircuitBreaker(proxy.BreakerConfig{Window: 1, MinRequests: 1, OpenDuration: time.Minute}, remote),
+			},
+			{Name: "local", Adapter: fallback, Local: true},
+		},
+		Audit: auditWriter,
+	})
+
+	for i := 0; i < 3; i++ {
+		if rec := postBreakerRequest(h); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected status %d, got %d: %s", i, http.StatusOK, rec.Code, rec.Body.String())
+		}
+	}
+	if remote.calls != 1 {
+		t.Fatalf("expected one call before the circuit opened, got %d", remote.calls)
+	}
+	if summary := auditWriter.events[2].ActionSummary; !strings.Contains(summary, "providers=remote:circuit_open,local:ok") {
+		t.Fatalf("unexpected audit summary %q", summary)
+	}
+}